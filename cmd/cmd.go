@@ -1,6 +1,15 @@
 package cmd
 
-import "github.com/urfave/cli"
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
 
 var FlagLog = cli.StringFlag{
 	Name:     "log-level",
@@ -8,3 +17,24 @@ var FlagLog = cli.StringFlag{
 	Required: false,
 	Value:    "info",
 }
+
+// Context cancelled on SIGINT or SIGTERM, aborting in-flight fetches and queries
+func signalContext() (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigChan:
+			log.Debug(fmt.Sprintf("Received %s signal", sig.String()))
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigChan)
+	}()
+
+	return ctx, cancel
+}
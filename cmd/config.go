@@ -1,14 +1,36 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"github.com/dademo/rssreader/modules/alert"
+	"github.com/dademo/rssreader/modules/auth"
 	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbalert"
+	"github.com/dademo/rssreader/modules/database/dbauth"
+	"github.com/dademo/rssreader/modules/database/dbdigest"
+	"github.com/dademo/rssreader/modules/database/dbevent"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbwebhook"
+	"github.com/dademo/rssreader/modules/digest"
+	"github.com/dademo/rssreader/modules/events"
+	"github.com/dademo/rssreader/modules/imageproxy"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/mail"
+	"github.com/dademo/rssreader/modules/rules"
+	"github.com/dademo/rssreader/modules/sanitizer"
+	"github.com/dademo/rssreader/modules/webhook"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
+// Database driver keeping feeds in memory only
+const memoryDriver = "memory"
+
 var FlagConfig = cli.StringFlag{
 	Name:      "config, c",
 	Usage:     "config file to use",
@@ -26,17 +48,180 @@ func check(cliContext *cli.Context) error {
 
 	SetLogByContext(cliContext)
 
-	_, err := getConfigFromContext(cliContext)
-
+	appConfig, err := getConfigFromContext(cliContext)
 	if err != nil {
 		log.WithError(err).Error("Unable to parse configuration")
 		return err
-	} else {
-		fmt.Println("Your configuration is correct")
-		return nil
 	}
+
+	err = validateModules(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Invalid configuration")
+		return err
+	}
+
+	fmt.Println("Your configuration is correct")
+	return nil
+}
+
+// Checks the settings of every module, without connecting to the database nor the SMTP server
+func validateModules(appConfig *config.Config) error {
+
+	for _, rule := range appConfig.Rules {
+		err := rules.Validate(rule)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, feedConfig := range appConfig.Feeds {
+		for _, rule := range feedConfig.Rules {
+			err := rules.Validate(rule)
+			if err != nil {
+				return fmt.Errorf("Feed [%s] : %s", feedConfig.Name, err)
+			}
+		}
+	}
+
+	err := webhook.Validate(appConfig.WebhooksConfig)
+	if err != nil {
+		return err
+	}
+
+	err = digest.Validate(appConfig.EmailConfig)
+	if err != nil {
+		return err
+	}
+
+	return alert.Validate(appConfig.AlertsConfig, appConfig.WebhooksConfig.Hooks)
 }
 
 func getConfigFromContext(context *cli.Context) (*config.Config, error) {
 	return config.ReadConfig(context.GlobalString("config"))
 }
+
+func configureModules(appConfig *config.Config) error {
+
+	sanitizer.Configure(appConfig.SanitizerConfig)
+	dbfeed.ConfigureDuplicates(appConfig.DuplicatesConfig)
+
+	err := imageproxy.Configure(appConfig.ImageProxyConfig)
+	if err != nil {
+		appLog.DebugError(err, "Unable to configure the image proxy")
+		return err
+	}
+
+	err = rules.Configure(appConfig.Rules, appConfig.Feeds)
+	if err != nil {
+		appLog.DebugError(err, "Unable to configure rules")
+		return err
+	}
+
+	return nil
+}
+
+func openFeedStore(ctx context.Context, appConfig *config.Config) (dbfeed.FeedStore, error) {
+
+	if appConfig.DbConfig.Driver == memoryDriver {
+		log.Debug("Using an in-memory feed store, nothing will be persisted")
+		return dbfeed.NewMemoryFeedStore(), nil
+	}
+
+	err := openDatabase(ctx, appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbfeed.NewSQLFeedStore(database.GetDatabase()), nil
+}
+
+// Must be called once the feed store is opened
+func configureAuth(ctx context.Context, appConfig *config.Config) error {
+
+	if !appConfig.AuthConfig.Enabled {
+		log.Warn("Authentication is disabled, every route is public")
+		auth.Configure(appConfig.AuthConfig, nil)
+		return nil
+	}
+
+	if database.GetDatabase() == nil {
+		return errors.New("Authentication needs a database, disable it to use the in-memory store")
+	}
+
+	store := dbauth.NewStore(database.GetDatabase())
+	auth.Configure(appConfig.AuthConfig, store)
+
+	count, err := store.CountUsers(ctx)
+	if err != nil {
+		appLog.DebugError(err, "Unable to count users")
+		return err
+	}
+	if count == 0 {
+		return errors.New("No user defined, create one with the users add command or disable authentication")
+	}
+	return nil
+}
+
+// Must be called once the feed store is opened, events being only recorded in a database
+func configureEvents(appConfig *config.Config) {
+
+	if database.GetDatabase() == nil {
+		events.Configure(appConfig.EventsConfig, nil)
+		return
+	}
+	events.Configure(appConfig.EventsConfig, dbevent.NewStore(database.GetDatabase()))
+}
+
+// Must be called once the feed store is opened, deliveries being queued in the database
+func configureWebhooks(appConfig *config.Config) error {
+
+	if database.GetDatabase() == nil {
+		return webhook.Configure(appConfig.WebhooksConfig, nil)
+	}
+	return webhook.Configure(appConfig.WebhooksConfig, dbwebhook.NewStore(database.GetDatabase()))
+}
+
+// Must be called once the feed store is opened, pending items being recorded in the database
+func configureDigests(appConfig *config.Config) error {
+
+	err := mail.Configure(appConfig.EmailConfig.Smtp)
+	if err != nil {
+		return err
+	}
+
+	if database.GetDatabase() == nil {
+		return digest.Configure(appConfig.EmailConfig, nil)
+	}
+	return digest.Configure(appConfig.EmailConfig, dbdigest.NewStore(database.GetDatabase()))
+}
+
+// Must be called once webhooks and digests are configured, alerts being notified through them
+func configureAlerts(appConfig *config.Config) error {
+
+	if database.GetDatabase() == nil {
+		return alert.Configure(appConfig.AlertsConfig, nil)
+	}
+	return alert.Configure(appConfig.AlertsConfig, dbalert.NewStore(database.GetDatabase()))
+}
+
+func openDatabase(ctx context.Context, appConfig *config.Config) error {
+
+	if appConfig.DbConfig.Driver == memoryDriver {
+		return errors.New("This command needs a database, the in-memory store is not supported")
+	}
+
+	err := database.ConnectDB(ctx, appConfig.DbConfig)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while connecting to the database")
+		return err
+	}
+
+	err = database.PrepareDatabase(ctx)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while prepairing the database")
+		return err
+	}
+	log.Debug("Database initialized")
+
+	return nil
+}
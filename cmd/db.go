@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var FlagDbExportOutput = cli.StringFlag{
+	Name:      "output, o",
+	Usage:     "file to write the export to, gzip compressed when ending with .gz, standard output when omitted",
+	TakesFile: true,
+}
+
+var FlagDbImportInput = cli.StringFlag{
+	Name:      "input, i",
+	Usage:     "export file to load, gzip compressed when ending with .gz, standard input when omitted",
+	TakesFile: true,
+}
+
+var FlagDbBackupOutput = cli.StringFlag{
+	Name:      "output, o",
+	Usage:     "directory to write the snapshot to, the configured backup directory when omitted",
+	TakesFile: true,
+}
+
+var FlagDbRestoreInput = cli.StringFlag{
+	Name:      "input, i",
+	Usage:     "snapshot to restore",
+	TakesFile: true,
+	Required:  true,
+}
+
+var CmdDatabase = cli.Command{
+	Name:  "db",
+	Usage: "Database maintenance",
+	Subcommands: []cli.Command{
+		{
+			Name:   "export",
+			Usage:  "Export the database to a portable NDJSON file",
+			Flags:  []cli.Flag{FlagDbExportOutput},
+			Action: exportDatabase,
+		},
+		{
+			Name:   "import",
+			Usage:  "Load an export into the configured, empty, database",
+			Flags:  []cli.Flag{FlagDbImportInput},
+			Action: importDatabase,
+		},
+		{
+			Name:   "backup",
+			Usage:  "Write a consistent snapshot of the sqlite database",
+			Flags:  []cli.Flag{FlagDbBackupOutput},
+			Action: backupDatabase,
+		},
+		{
+			Name:   "restore",
+			Usage:  "Replace the sqlite database by a snapshot, the current data being backed up first",
+			Flags:  []cli.Flag{FlagDbRestoreInput},
+			Action: restoreDatabase,
+		},
+	},
+}
+
+func exportDatabase(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = openDatabase(ctx, appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to open the database")
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	flush := func() error { return nil }
+	outputPath := cliContext.String("output")
+
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			log.WithError(err).Error("Unable to create the export file")
+			return err
+		}
+		defer file.Close()
+		writer = file
+
+		if strings.HasSuffix(outputPath, ".gz") {
+			gzipWriter := gzip.NewWriter(file)
+			writer = gzipWriter
+			flush = gzipWriter.Close
+		}
+	}
+
+	err = database.Export(ctx, writer)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		log.WithError(err).Error("Unable to export the database")
+		return err
+	}
+
+	log.Info("Database exported")
+	return nil
+}
+
+func importDatabase(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = openDatabase(ctx, appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to open the database")
+		return err
+	}
+
+	var reader io.Reader = os.Stdin
+	inputPath := cliContext.String("input")
+
+	if inputPath != "" {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			log.WithError(err).Error("Unable to open the export file")
+			return err
+		}
+		defer file.Close()
+		reader = file
+
+		if strings.HasSuffix(inputPath, ".gz") {
+			gzipReader, err := gzip.NewReader(file)
+			if err != nil {
+				appLog.DebugError(err, "Unable to read the compressed export")
+				return err
+			}
+			defer gzipReader.Close()
+			reader = gzipReader
+		}
+	}
+
+	report, err := database.Import(ctx, reader)
+	if err != nil {
+		log.WithError(err).Error("Unable to import the database")
+		return err
+	}
+
+	tables := make([]string, 0, len(report.Rows))
+	for table := range report.Rows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		fmt.Printf("%d rows imported into [%s]\n", report.Rows[table], table)
+	}
+
+	log.Info("Database imported")
+	return nil
+}
+
+func backupDatabase(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = openDatabase(ctx, appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to open the database")
+		return err
+	}
+
+	directory := appConfig.BackupConfig.Directory
+	if cliContext.String("output") != "" {
+		directory = cliContext.String("output")
+	}
+
+	backupPath, err := database.Backup(ctx, directory, appConfig.BackupConfig.Keep)
+	if err != nil {
+		log.WithError(err).Error("Unable to back up the database")
+		return err
+	}
+
+	fmt.Println(backupPath)
+	log.Info("Database backed up")
+	return nil
+}
+
+func restoreDatabase(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = openDatabase(ctx, appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to open the database")
+		return err
+	}
+
+	inputPath := cliContext.String("input")
+
+	err = database.ValidateBackup(ctx, inputPath)
+	if err != nil {
+		log.WithError(err).Error("Invalid backup")
+		return err
+	}
+
+	// Keeps the replaced data, the restored snapshot may not be the expected one
+	backupPath, err := database.Backup(ctx, appConfig.BackupConfig.Directory, 0)
+	if err != nil {
+		log.WithError(err).Error("Unable to back up the database before restoring")
+		return err
+	}
+	log.Info(fmt.Sprintf("Current database backed up to [%s]", backupPath))
+
+	err = database.Restore(ctx, inputPath)
+	if err != nil {
+		log.WithError(err).Error("Unable to restore the database")
+		return err
+	}
+
+	// Snapshots of older versions are migrated
+	err = database.PrepareDatabase(ctx)
+	if err != nil {
+		log.WithError(err).Error("Unable to prepare the restored database")
+		return err
+	}
+
+	log.Info("Database restored")
+	return nil
+}
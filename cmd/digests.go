@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/digest"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var FlagDigestName = cli.StringFlag{
+	Name:     "name, n",
+	Usage:    "name of the digest, as configured",
+	Required: true,
+}
+
+var FlagDigestDryRun = cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "print the text digest instead of sending it, items staying pending",
+}
+
+var CmdDigests = cli.Command{
+	Name:  "digests",
+	Usage: "Manage the email digests",
+	Subcommands: []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List the configured digests with their pending items",
+			Action: listDigests,
+		},
+		{
+			Name:   "send",
+			Usage:  "Send a digest of the pending items now",
+			Flags:  []cli.Flag{FlagDigestName, FlagDigestDryRun},
+			Action: sendDigest,
+		},
+	},
+}
+
+func listDigests(cliContext *cli.Context) error {
+
+	ctx, cancel, _, err := openDigests(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSCHEDULE\tNEXT\tTO\tPENDING\tLAST SENT")
+	for _, digestConfig := range digest.Digests() {
+
+		pending, err := digest.GetStore().PendingItemIds(ctx, digestConfig.Name)
+		if err != nil {
+			log.WithError(err).Error("Unable to get the pending items")
+			return err
+		}
+
+		lastSent, err := digest.GetStore().LastSent(ctx, digestConfig.Name)
+		if err != nil {
+			log.WithError(err).Error("Unable to get the last run")
+			return err
+		}
+
+		schedule := digest.Schedule(digestConfig.Name)
+		next := schedule.Next(time.Now())
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			digestConfig.Name,
+			schedule,
+			formatOptionalTime(&next),
+			strings.Join(digestConfig.To, ","),
+			len(pending),
+			formatOptionalTime(lastSent),
+		)
+	}
+	return writer.Flush()
+}
+
+func sendDigest(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openDigests(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	name := cliContext.String("name")
+
+	if cliContext.Bool("dry-run") {
+		rendered, err := digest.Render(ctx, store, name)
+		if err != nil {
+			log.WithError(err).Error("Unable to render the digest")
+			return err
+		}
+		if rendered == nil {
+			log.Info("No new item to send")
+			return nil
+		}
+		fmt.Printf("To: %s\nSubject: %s\n\n%s", strings.Join(rendered.Message.To, ", "), rendered.Message.Subject, rendered.Message.Text)
+		return nil
+	}
+
+	run, err := digest.Send(ctx, store, name)
+	if err != nil {
+		log.WithError(err).Error("Unable to send the digest")
+		return err
+	}
+	if run == nil {
+		log.Info("No new item to send")
+	}
+	return nil
+}
+
+func openDigests(cliContext *cli.Context) (context.Context, context.CancelFunc, dbfeed.FeedStore, error) {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return nil, nil, nil, err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return nil, nil, nil, err
+	}
+
+	ctx, cancel := signalContext()
+
+	store, err := openFeedStore(ctx, appConfig)
+	if err != nil {
+		cancel()
+		log.WithError(err).Error("Unable to open the feed store")
+		return nil, nil, nil, err
+	}
+
+	err = configureDigests(appConfig)
+	if err == nil && !digest.Enabled() {
+		err = errors.New("Digests need a database, an SMTP server and at least one digest")
+	}
+	if err != nil {
+		cancel()
+		log.WithError(err).Error("Unable to configure digests")
+		return nil, nil, nil, err
+	}
+
+	return ctx, cancel, store, nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dademo/rssreader/modules/purge"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var FlagPurgeDryRun = cli.BoolFlag{
+	Name:  "dry-run, n",
+	Usage: "only report what would be removed",
+}
+
+var CmdPurge = cli.Command{
+	Name:   "purge",
+	Usage:  "Remove old feed items according to the retention policies",
+	Flags:  []cli.Flag{FlagPurgeDryRun},
+	Action: purgeItems,
+}
+
+func purgeItems(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	store, err := openFeedStore(ctx, appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to open the feed store")
+		return err
+	}
+
+	dryRun := cliContext.Bool("dry-run")
+
+	report, err := purge.Run(ctx, appConfig, store, dryRun)
+	if err != nil {
+		log.WithError(err).Error("Unable to purge feed items")
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "FEED\tID\tDATE\tREASON\tTITLE")
+	for _, feedReport := range report.Feeds {
+		for _, candidate := range feedReport.Candidates {
+			date := "-"
+			if candidate.Date != nil {
+				date = candidate.Date.Format("2006-01-02")
+			}
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\n",
+				feedReport.FeedTitle,
+				candidate.Id,
+				date,
+				candidate.Reason,
+				candidate.Title,
+			)
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	action := "removed"
+	if dryRun {
+		action = "would be removed"
+	}
+
+	fmt.Printf("\n%d feed items %s\n", report.ItemCount(), action)
+	for _, orphans := range report.Orphans {
+		fmt.Printf("%d orphan rows of [%s] %s\n", orphans.Count, orphans.Table, action)
+	}
+
+	return nil
+}
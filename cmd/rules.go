@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/rules"
+
+	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var FlagRulesFeed = cli.StringFlag{
+	Name:     "feed, f",
+	Usage:    "name of the configured feed whose rules are tested, global rules only if not set",
+	Required: false,
+}
+
+var FlagRulesFixture = cli.StringFlag{
+	Name:      "fixture, x",
+	Usage:     "feed document (RSS, Atom or JSON Feed) the rules are tested against",
+	TakesFile: true,
+	Required:  true,
+}
+
+var CmdRules = cli.Command{
+	Name:  "rules",
+	Usage: "Manage item rules",
+	Subcommands: []cli.Command{
+		{
+			Name:   "test",
+			Usage:  "Evaluate rules against a feed fixture",
+			Flags:  []cli.Flag{FlagRulesFeed, FlagRulesFixture},
+			Action: testRules,
+		},
+	},
+}
+
+func testRules(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	err = rules.Configure(appConfig.Rules, appConfig.Feeds)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure rules")
+		return err
+	}
+
+	feedConfig, err := configuredFeedByName(appConfig, cliContext.String("feed"))
+	if err != nil {
+		log.WithError(err).Error("Unable to find the feed")
+		return err
+	}
+
+	fixture, err := os.Open(cliContext.String("fixture"))
+	if err != nil {
+		log.WithError(err).Error("Unable to open the fixture")
+		return err
+	}
+	defer fixture.Close()
+
+	parsedFeed, err := gofeed.NewParser().Parse(fixture)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse the fixture")
+		return err
+	}
+
+	results, err := rules.Apply(feedConfig, dbfeed.FromFeed(parsedFeed))
+	if err != nil {
+		log.WithError(err).Error("Unable to apply rules")
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TITLE\tMATCHED RULES\tDROPPED\tREAD\tSTARRED\tTAGS\tLINK")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%s\t%t\t%t\t%t\t%s\t%s\n",
+			result.Item.Title,
+			strings.Join(result.MatchedRules, ","),
+			result.Dropped,
+			result.Item.Read,
+			result.Item.Starred,
+			strings.Join(result.Item.Tags, ","),
+			result.Item.Link,
+		)
+	}
+
+	return writer.Flush()
+}
+
+func configuredFeedByName(appConfig *config.Config, name string) (*config.Feed, error) {
+
+	if name == "" {
+		return nil, nil
+	}
+
+	for _, feed := range appConfig.Feeds {
+		if feed.Name == name {
+			return feed, nil
+		}
+	}
+
+	return nil, errors.New(fmt.Sprintf("No feed named [%s] in the configuration", name))
+}
@@ -1,18 +1,36 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 
-	"github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/alert"
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
 	"github.com/dademo/rssreader/modules/feed"
+	"github.com/dademo/rssreader/modules/webhook"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
+var FlagRunStdin = cli.BoolFlag{
+	Name:  "stdin",
+	Usage: "parse a feed document (RSS, Atom or JSON Feed) from the standard input instead of fetching the configured feeds",
+}
+
+var FlagRunFeed = cli.StringFlag{
+	Name:     "feed, f",
+	Usage:    "name of the configured feed whose rules are applied to the standard input, global rules only if not set",
+	Required: false,
+}
+
 var CmdRun = cli.Command{
 	Name:      "run",
 	ShortName: "r",
+	Flags:     []cli.Flag{FlagRunStdin, FlagRunFeed},
 	Action:    run,
 }
 
@@ -31,28 +49,51 @@ func run(cliContext *cli.Context) error {
 		return err
 	}
 
-	err = database.ConnectDB(appConfig.DbConfig)
+	err = configureModules(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure modules")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
 
+	store, err := openFeedStore(ctx, appConfig)
 	if err != nil {
-		log.WithError(err).Error("An error occured while connecting to the database")
+		log.WithError(err).Error("Unable to open the feed store")
 		return err
 	}
 
-	err = database.PrepareDatabase()
+	configureEvents(appConfig)
+
+	err = configureWebhooks(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure webhooks")
+		return err
+	}
+
+	err = configureDigests(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure digests")
+		return err
+	}
 
+	err = configureAlerts(appConfig)
 	if err != nil {
-		log.WithError(err).Error("An error occured while prepairing the database")
+		log.WithError(err).Error("Unable to configure alerts")
 		return err
 	}
+	// Queued alert emails are sent before exiting
+	defer alert.Close()
 
-	fetchedFeeds, err := feed.FetchAll(appConfig)
+	fetchedFeeds, err := fetchRunFeeds(ctx, cliContext, appConfig)
 	if err != nil {
 		log.WithError(err).Error("Unable to fetch feeds")
 		return err
 	}
 
 	for _, fetchedFeed := range fetchedFeeds {
-		err = fetchedFeed.Save()
+		err = store.SaveFeed(ctx, fetchedFeed)
 		if err != nil {
 			log.Debug(fmt.Sprintf("An error occured while saving feed [%s]", fetchedFeed.Title))
 			return err
@@ -61,5 +102,32 @@ func run(cliContext *cli.Context) error {
 
 	log.Info("Feeds saved")
 
+	err = webhook.DeliverDue(ctx)
+	if err != nil {
+		log.WithError(err).Error("Unable to deliver webhooks")
+		return err
+	}
+
 	return nil
 }
+
+func fetchRunFeeds(ctx context.Context, cliContext *cli.Context, appConfig *config.Config) ([]*dbfeed.Feed, error) {
+
+	if !cliContext.Bool("stdin") {
+		if cliContext.String("feed") != "" {
+			return nil, errors.New("A feed name is only used along with the standard input")
+		}
+		return feed.FetchAll(ctx, appConfig)
+	}
+
+	feedConfig, err := configuredFeedByName(appConfig, cliContext.String("feed"))
+	if err != nil {
+		return nil, err
+	}
+
+	readFeed, err := feed.Read(feedConfig, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return []*dbfeed.Feed{readFeed}, nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dademo/rssreader/modules/feed"
+	"github.com/dademo/rssreader/modules/textdiff"
+
+	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// Summaries are cut in the preview
+const scrapePreviewSummaryRunes = 60
+
+var FlagScrapeFeed = cli.StringFlag{
+	Name:     "feed, f",
+	Usage:    "name of the configured feed whose selectors are tested",
+	Required: true,
+}
+
+var FlagScrapeFixture = cli.StringFlag{
+	Name:      "fixture, x",
+	Usage:     "HTML page the selectors are tested against, the feed URL being fetched if not set",
+	TakesFile: true,
+	Required:  false,
+}
+
+var CmdScrape = cli.Command{
+	Name:  "scrape",
+	Usage: "Manage the feeds read from HTML pages",
+	Subcommands: []cli.Command{
+		{
+			Name:   "test",
+			Usage:  "Preview the items extracted by the selectors of a feed",
+			Flags:  []cli.Flag{FlagScrapeFeed, FlagScrapeFixture},
+			Action: testScrape,
+		},
+	},
+}
+
+func testScrape(cliContext *cli.Context) error {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return err
+	}
+
+	feedConfig, err := configuredFeedByName(appConfig, cliContext.String("feed"))
+	if err != nil {
+		log.WithError(err).Error("Unable to find the feed")
+		return err
+	}
+
+	var page io.Reader
+	if fixturePath := cliContext.String("fixture"); fixturePath != "" {
+		fixture, err := os.Open(fixturePath)
+		if err != nil {
+			log.WithError(err).Error("Unable to open the fixture")
+			return err
+		}
+		defer fixture.Close()
+		page = fixture
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	scrapedFeed, err := feed.Scrape(ctx, feedConfig, page)
+	if err != nil {
+		log.WithError(err).Error("Unable to scrape the page")
+		return err
+	}
+
+	fmt.Printf("%s (%d items)\n\n", scrapedFeed.Title, len(scrapedFeed.Items))
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "PUBLISHED\tTITLE\tLINK\tIMAGE\tSUMMARY")
+	for _, item := range scrapedFeed.Items {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+			formatOptionalTime(item.PublishedParsed),
+			item.Title,
+			item.Link,
+			scrapedImage(item),
+			truncateRunes(strings.Join(strings.Fields(textdiff.HTMLToText(item.Description)), " "), scrapePreviewSummaryRunes),
+		)
+	}
+
+	return writer.Flush()
+}
+
+func scrapedImage(item *gofeed.Item) string {
+	if item.Image == nil {
+		return "-"
+	}
+	return item.Image.URL
+}
+
+func truncateRunes(value string, maxRunes int) string {
+	runes := []rune(value)
+	if len(runes) <= maxRunes {
+		return value
+	}
+	return string(runes[:maxRunes]) + "…"
+}
@@ -4,17 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/dademo/rssreader/modules/alert"
+	"github.com/dademo/rssreader/modules/auth"
 	"github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	"github.com/dademo/rssreader/modules/events"
 	appLog "github.com/dademo/rssreader/modules/log"
 	"github.com/dademo/rssreader/modules/scheduler"
 	"github.com/dademo/rssreader/modules/server"
+	"github.com/dademo/rssreader/modules/subscription"
 	"github.com/dademo/rssreader/modules/web"
+	webFeed "github.com/dademo/rssreader/modules/web/feed"
+	webHealth "github.com/dademo/rssreader/modules/web/health"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -23,6 +27,7 @@ import (
 var CmdServe = cli.Command{
 	Name:      "serve",
 	ShortName: "s",
+	Usage:     "Serve the HTTP API, fetching every feed at start then at its fetchIntervalMinutes (60 by default)",
 	Action:    serve,
 }
 
@@ -43,24 +48,56 @@ func serve(cliContext *cli.Context) error {
 		return err
 	}
 
-	err = database.ConnectDB(appConfig.DbConfig)
+	err = configureModules(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure modules")
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	store, err := openFeedStore(ctx, appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to open the feed store")
+		return err
+	}
+
+	configureEvents(appConfig)
+
+	err = configureWebhooks(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure webhooks")
+		return err
+	}
 
+	err = configureDigests(appConfig)
 	if err != nil {
-		log.WithError(err).Error("An error occured when connecting to the database")
+		log.WithError(err).Error("Unable to configure digests")
 		return err
 	}
 
-	err = database.PrepareDatabase()
+	err = configureAlerts(appConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to configure alerts")
+		return err
+	}
 
+	err = configureAuth(ctx, appConfig)
 	if err != nil {
-		log.WithError(err).Error("An error occured when prepairing the database")
+		log.WithError(err).Error("Unable to configure authentication")
 		return err
 	}
-	log.Debug("Database initialized")
+
+	if auth.Enabled() {
+		subscription.Configure(store, dbuser.NewStore(database.GetDatabase()))
+	}
 
 	log.Debug("Prepairing http server")
 	jobScheduler := scheduler.New()
-	server.ScheduleFromConfig(jobScheduler, appConfig)
+	server.ScheduleFromConfig(jobScheduler, appConfig, store)
+	webFeed.Configure(store)
+	webHealth.Configure(jobScheduler)
 
 	httpServeMux := http.NewServeMux()
 	err = web.RegisterServerHandlers(httpServeMux, appConfig.HttpConfig)
@@ -77,39 +114,37 @@ func serve(cliContext *cli.Context) error {
 		Addr:    appConfig.HttpConfig.ListenAddress,
 		Handler: httpServeMux,
 	}
+	// Event streams never end by themselves
+	srv.RegisterOnShutdown(events.Close)
 
 	log.Debug("Registering signal handlers")
 	var wait sync.WaitGroup
-	sigChan := make(chan os.Signal)
 	quit := make(chan int)
 
 	wait.Add(1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		for {
-			select {
-			case sig := <-sigChan:
-				log.Debug(fmt.Sprintf("Received %s signal", sig.String()))
-
-				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(shutdownTimeoutMilliseconds*time.Millisecond))
-				defer cancel()
-
-				err = srv.Shutdown(ctx)
-				if err != nil {
-					appLog.DebugError(err, "An error occured on http server shutown, ", err)
-				}
-
-				wait.Done()
-				return
-			case <-quit:
-				wait.Done()
-				return
+		select {
+		case <-ctx.Done():
+			// Aborting in-flight fetches first, requests are given some time to end
+			jobScheduler.Stop()
+
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Duration(shutdownTimeoutMilliseconds*time.Millisecond))
+			defer cancelShutdown()
+
+			err := srv.Shutdown(shutdownCtx)
+			if err != nil {
+				appLog.DebugError(err, "An error occured on http server shutown, ", err)
 			}
+		case <-quit:
+			jobScheduler.Stop()
 		}
-
+		wait.Done()
 	}()
 
+	log.Debug("Starting scheduled jobs")
+	jobScheduler.Run(ctx)
+
 	log.Debug(fmt.Sprintf("Serving on %s", appConfig.HttpConfig.ListenAddress))
 	if err = srv.ListenAndServe(); err != http.ErrServerClosed {
 		quit <- 0
@@ -118,5 +153,7 @@ func serve(cliContext *cli.Context) error {
 	log.Debug("Server closed")
 
 	wait.Wait()
+	jobScheduler.Wait()
+	alert.Close()
 	return nil
 }
@@ -0,0 +1,507 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbauth"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var FlagUsername = cli.StringFlag{
+	Name:     "username, u",
+	Usage:    "name of the user",
+	Required: true,
+}
+
+var FlagPermission = cli.StringSliceFlag{
+	Name:  "permission, p",
+	Usage: fmt.Sprintf("permission granted to the user, one of %s", permissionNames()),
+}
+
+var FlagTokenName = cli.StringFlag{
+	Name:  "name, n",
+	Usage: "name describing the token usage",
+}
+
+var FlagTokenId = cli.Uint64Flag{
+	Name:     "id",
+	Usage:    "id of the token, as listed by the token list command",
+	Required: true,
+}
+
+var FlagSubscribeAll = cli.BoolFlag{
+	Name:  "subscribe-all",
+	Usage: "subscribe the user to every feed already fetched",
+}
+
+var FlagFever = cli.BoolFlag{
+	Name:  "fever",
+	Usage: "allow the Fever API, which requires storing a weaker hash of the password",
+}
+
+var CmdUsers = cli.Command{
+	Name:  "users",
+	Usage: "Manage the users of the HTTP API, the password being prompted or read from the standard input",
+	Subcommands: []cli.Command{
+		{
+			Name:   "add",
+			Usage:  "Create a user",
+			Flags:  []cli.Flag{FlagUsername, FlagPermission, FlagSubscribeAll, FlagFever},
+			Action: addUser,
+		},
+		{
+			Name:   "list",
+			Usage:  "List users",
+			Action: listUsers,
+		},
+		{
+			Name:   "remove",
+			Usage:  "Remove a user with its tokens and sessions",
+			Flags:  []cli.Flag{FlagUsername},
+			Action: removeUser,
+		},
+		{
+			Name:   "password",
+			Usage:  "Change the password of a user, the Fever API being disabled unless asked again",
+			Flags:  []cli.Flag{FlagUsername, FlagFever},
+			Action: changeUserPassword,
+		},
+		{
+			Name:   "permissions",
+			Usage:  "Replace the permissions of a user",
+			Flags:  []cli.Flag{FlagUsername, FlagPermission},
+			Action: changeUserPermissions,
+		},
+		{
+			Name:  "token",
+			Usage: "Manage API tokens",
+			Subcommands: []cli.Command{
+				{
+					Name:   "create",
+					Usage:  "Create an API token, printed once",
+					Flags:  []cli.Flag{FlagUsername, FlagTokenName},
+					Action: createUserToken,
+				},
+				{
+					Name:   "list",
+					Usage:  "List the API tokens of a user",
+					Flags:  []cli.Flag{FlagUsername},
+					Action: listUserTokens,
+				},
+				{
+					Name:   "revoke",
+					Usage:  "Revoke an API token",
+					Flags:  []cli.Flag{FlagUsername, FlagTokenId},
+					Action: revokeUserToken,
+				},
+			},
+		},
+	},
+}
+
+func addUser(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	permissions, err := permissionsFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Invalid permission")
+		return err
+	}
+
+	username := cliContext.String("username")
+	existing, err := store.UserByName(ctx, username)
+	if err != nil {
+		log.WithError(err).Error("Unable to check for user existance")
+		return err
+	}
+	if existing != nil {
+		err = fmt.Errorf("User [%s] already exists", username)
+		log.WithError(err).Error("Unable to create the user")
+		return err
+	}
+
+	password, passwordHash, err := readPassword()
+	if err != nil {
+		log.WithError(err).Error("Unable to read the password")
+		return err
+	}
+
+	user := &dbauth.User{
+		Username:     username,
+		PasswordHash: passwordHash,
+		Permissions:  permissions,
+	}
+	err = store.CreateUser(ctx, user)
+	if err != nil {
+		log.WithError(err).Error("Unable to create the user")
+		return err
+	}
+
+	log.Info(fmt.Sprintf("User [%s] created", username))
+
+	if cliContext.Bool("fever") {
+		err = auth.SetFeverKey(ctx, user, password)
+		if err != nil {
+			log.WithError(err).Error("Unable to enable the Fever API")
+			return err
+		}
+	}
+
+	if cliContext.Bool("subscribe-all") {
+		return subscribeToAllFeeds(ctx, username)
+	}
+	return nil
+}
+
+func subscribeToAllFeeds(ctx context.Context, username string) error {
+
+	user, err := auth.GetStore().UserByName(ctx, username)
+	if err != nil {
+		log.WithError(err).Error("Unable to get the user")
+		return err
+	}
+
+	feeds, err := dbfeed.NewSQLFeedStore(database.GetDatabase()).GetAllFeeds(ctx, false)
+	if err != nil {
+		log.WithError(err).Error("Unable to list feeds")
+		return err
+	}
+
+	userStore := dbuser.NewStore(database.GetDatabase())
+	for _, feed := range feeds {
+		err = userStore.CreateSubscription(ctx, user.Id, &dbuser.Subscription{FeedId: feed.Id})
+		if err != nil {
+			log.WithError(err).Error(fmt.Sprintf("Unable to subscribe to feed [%s]", feed.Title))
+			return err
+		}
+	}
+
+	log.Info(fmt.Sprintf("User [%s] subscribed to %d feeds", username, len(feeds)))
+	return nil
+}
+
+func listUsers(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	users, err := store.GetUsers(ctx)
+	if err != nil {
+		log.WithError(err).Error("Unable to list users")
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tUSERNAME\tPERMISSIONS\tCREATED")
+	for _, user := range users {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", user.Id, user.Username, strings.Join(user.Permissions, ","), formatOptionalTime(user.Created))
+	}
+	return writer.Flush()
+}
+
+func removeUser(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	user, err := userFromContext(ctx, cliContext, store)
+	if err != nil {
+		return err
+	}
+
+	err = store.DeleteUser(ctx, user.Id)
+	if err != nil {
+		log.WithError(err).Error("Unable to remove the user")
+		return err
+	}
+
+	log.Info(fmt.Sprintf("User [%s] removed", user.Username))
+	return nil
+}
+
+func changeUserPassword(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	user, err := userFromContext(ctx, cliContext, store)
+	if err != nil {
+		return err
+	}
+
+	password, passwordHash, err := readPassword()
+	if err != nil {
+		log.WithError(err).Error("Unable to read the password")
+		return err
+	}
+
+	user.PasswordHash = passwordHash
+	err = store.UpdateUser(ctx, user)
+	if err != nil {
+		log.WithError(err).Error("Unable to update the user")
+		return err
+	}
+
+	// The Fever key derives from the password, the previous one is no longer valid
+	if cliContext.Bool("fever") {
+		err = auth.SetFeverKey(ctx, user, password)
+	} else {
+		err = auth.ClearFeverKey(ctx, user)
+	}
+	if err != nil {
+		log.WithError(err).Error("Unable to update the Fever API access")
+		return err
+	}
+
+	log.Info(fmt.Sprintf("Password of user [%s] changed", user.Username))
+	return nil
+}
+
+func changeUserPermissions(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	user, err := userFromContext(ctx, cliContext, store)
+	if err != nil {
+		return err
+	}
+
+	user.Permissions, err = permissionsFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Invalid permission")
+		return err
+	}
+
+	err = store.UpdateUser(ctx, user)
+	if err != nil {
+		log.WithError(err).Error("Unable to update the user")
+		return err
+	}
+
+	log.Info(fmt.Sprintf("Permissions of user [%s] changed", user.Username))
+	return nil
+}
+
+func createUserToken(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	user, err := userFromContext(ctx, cliContext, store)
+	if err != nil {
+		return err
+	}
+
+	_, secret, err := auth.CreateToken(ctx, user.Id, cliContext.String("name"))
+	if err != nil {
+		log.WithError(err).Error("Unable to create the token")
+		return err
+	}
+
+	fmt.Println(secret)
+	return nil
+}
+
+func listUserTokens(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	user, err := userFromContext(ctx, cliContext, store)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := store.GetUserTokens(ctx, user.Id)
+	if err != nil {
+		log.WithError(err).Error("Unable to list tokens")
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tCREATED\tLAST USED")
+	for _, token := range tokens {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", token.Id, token.Name, formatOptionalTime(token.Created), formatOptionalTime(token.LastUsed))
+	}
+	return writer.Flush()
+}
+
+func revokeUserToken(cliContext *cli.Context) error {
+
+	ctx, cancel, store, err := openAuthStore(cliContext)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	user, err := userFromContext(ctx, cliContext, store)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := store.DeleteToken(ctx, user.Id, cliContext.Uint64("id"))
+	if err != nil {
+		log.WithError(err).Error("Unable to revoke the token")
+		return err
+	}
+	if !deleted {
+		err = fmt.Errorf("User [%s] has no token [%d]", user.Username, cliContext.Uint64("id"))
+		log.WithError(err).Error("Unable to revoke the token")
+		return err
+	}
+
+	log.Info("Token revoked")
+	return nil
+}
+
+func openAuthStore(cliContext *cli.Context) (context.Context, context.CancelFunc, *dbauth.Store, error) {
+
+	appConfig, err := getConfigFromContext(cliContext)
+	if err != nil {
+		log.WithError(err).Error("Unable to parse configuration")
+		return nil, nil, nil, err
+	}
+
+	err = SetLogByContextAndConfig(cliContext, appConfig.LogConfig)
+	if err != nil {
+		log.WithError(err).Error("Unable to set log configuration")
+		return nil, nil, nil, err
+	}
+
+	ctx, cancel := signalContext()
+
+	err = openDatabase(ctx, appConfig)
+	if err != nil {
+		cancel()
+		log.WithError(err).Error("Unable to open the database")
+		return nil, nil, nil, err
+	}
+
+	store := dbauth.NewStore(database.GetDatabase())
+	auth.Configure(appConfig.AuthConfig, store)
+
+	return ctx, cancel, store, nil
+}
+
+func userFromContext(ctx context.Context, cliContext *cli.Context, store *dbauth.Store) (*dbauth.User, error) {
+
+	username := cliContext.String("username")
+
+	user, err := store.UserByName(ctx, username)
+	if err != nil {
+		log.WithError(err).Error("Unable to get the user")
+		return nil, err
+	}
+	if user == nil {
+		err = fmt.Errorf("User [%s] does not exist", username)
+		log.WithError(err).Error("Unable to get the user")
+		return nil, err
+	}
+	return user, nil
+}
+
+func permissionsFromContext(cliContext *cli.Context) ([]string, error) {
+
+	permissions := make([]string, 0)
+	for _, permission := range cliContext.StringSlice("permission") {
+		if !auth.IsGrantable(auth.Permission(permission)) {
+			return nil, fmt.Errorf("Unknown permission [%s], expected one of %s", permission, permissionNames())
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
+
+func permissionNames() string {
+
+	names := make([]string, 0, len(auth.GrantablePermissions))
+	for _, permission := range auth.GrantablePermissions {
+		names = append(names, string(permission))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Prompted twice on a terminal, read from the first line of the standard input otherwise
+func readPassword() (string, string, error) {
+
+	var password string
+	stdin := int(os.Stdin.Fd())
+
+	if terminal.IsTerminal(stdin) {
+
+		fmt.Fprint(os.Stderr, "Password: ")
+		typed, err := terminal.ReadPassword(stdin)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", "", err
+		}
+
+		fmt.Fprint(os.Stderr, "Confirm password: ")
+		confirmation, err := terminal.ReadPassword(stdin)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", "", err
+		}
+
+		if string(typed) != string(confirmation) {
+			return "", "", errors.New("Passwords do not match")
+		}
+		password = string(typed)
+
+	} else {
+
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	return password, passwordHash, err
+}
+
+func formatOptionalTime(value *time.Time) string {
+	if value == nil {
+		return "-"
+	}
+	return value.Local().Format(time.RFC3339)
+}
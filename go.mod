@@ -4,6 +4,7 @@ go 1.15
 
 require (
 	github.com/Abramovic/logrus_influxdb v0.0.0-20191225071031-ec7855d61bb9
+	github.com/PuerkitoBio/goquery v1.5.1
 	github.com/elastic/go-elasticsearch/v7 v7.10.0
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/google/uuid v1.2.0
@@ -16,6 +17,8 @@ require (
 	github.com/tidwall/buntdb v1.1.8
 	github.com/urfave/cli v1.22.5
 	go.mongodb.org/mongo-driver v1.4.6
+	golang.org/x/crypto v0.0.0-20190530122614-20be4c3c3ed5
+	golang.org/x/net v0.0.0-20200301022130-244492dfa37a
 	gopkg.in/go-extras/elogrus.v7 v7.2.0
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -19,8 +19,16 @@ import (
 	_ "go.mongodb.org/mongo-driver/mongo"
 
 	// HTTP endpoints
+	_ "github.com/dademo/rssreader/modules/web/alert"
+	_ "github.com/dademo/rssreader/modules/web/auth"
 	_ "github.com/dademo/rssreader/modules/web/feed"
+	_ "github.com/dademo/rssreader/modules/web/fever"
+	_ "github.com/dademo/rssreader/modules/web/greader"
+	_ "github.com/dademo/rssreader/modules/web/health"
+	_ "github.com/dademo/rssreader/modules/web/imageproxy"
 	_ "github.com/dademo/rssreader/modules/web/log"
+	_ "github.com/dademo/rssreader/modules/web/stream"
+	_ "github.com/dademo/rssreader/modules/web/webhook"
 )
 
 var (
@@ -48,6 +56,12 @@ func main() {
 		cmd.CmdRun,
 		cmd.CmdConfig,
 		cmd.CmdServe,
+		cmd.CmdRules,
+		cmd.CmdPurge,
+		cmd.CmdDatabase,
+		cmd.CmdUsers,
+		cmd.CmdDigests,
+		cmd.CmdScrape,
 	}
 
 	sort.Sort(cli.FlagsByName(app.Flags))
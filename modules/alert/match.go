@@ -0,0 +1,158 @@
+package alert
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/textdiff"
+)
+
+const (
+	// Text kept around the matches, in bytes
+	snippetContext = 80
+	maxSnippets    = 5
+	// Per term and field, a few being enough to show the context
+	maxMatches = 3
+)
+
+type span struct {
+	start int
+	end   int
+}
+
+// Matched terms, in watchlist order, with snippets of the title, description and content
+func (configured *watchlist) match(item *dbfeed.FeedItem) ([]string, []string) {
+
+	fields := []string{item.Title}
+	description := textdiff.HTMLToText(item.Description)
+	content := textdiff.HTMLToText(item.Content)
+	fields = append(fields, description)
+	if content != description {
+		fields = append(fields, content)
+	}
+
+	terms := make([]string, 0)
+	snippets := make([]string, 0)
+	for _, matchedTerm := range configured.terms {
+
+		found := false
+		for _, field := range fields {
+			if matchedTerm.pattern.MatchString(field) {
+				found = true
+				break
+			}
+		}
+		if found {
+			terms = append(terms, matchedTerm.label)
+		}
+	}
+
+	if len(terms) == 0 {
+		return terms, snippets
+	}
+
+	for _, field := range fields {
+		if len(snippets) >= maxSnippets {
+			break
+		}
+		text := strings.Join(strings.Fields(field), " ")
+		snippets = append(snippets, configured.snippets(text, maxSnippets-len(snippets))...)
+	}
+	return terms, snippets
+}
+
+// Extracts around the matches, nearby matches sharing the same snippet
+func (configured *watchlist) snippets(text string, limit int) []string {
+
+	matches := make([]span, 0)
+	for _, matchedTerm := range configured.terms {
+		for _, indexes := range matchedTerm.pattern.FindAllStringIndex(text, maxMatches) {
+			if indexes[1] > indexes[0] {
+				matches = append(matches, span{start: indexes[0], end: indexes[1]})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	snippets := make([]string, 0)
+	for i := 0; i < len(matches) && len(snippets) < limit; {
+
+		window := contextOf(text, matches[i])
+		grouped := make([]span, 0)
+		for ; i < len(matches) && matches[i].start < window.end; i++ {
+			// Overlapping matches being merged
+			if len(grouped) > 0 && matches[i].start < grouped[len(grouped)-1].end {
+				if matches[i].end > grouped[len(grouped)-1].end {
+					grouped[len(grouped)-1].end = matches[i].end
+				}
+			} else {
+				grouped = append(grouped, matches[i])
+			}
+			if extended := contextOf(text, matches[i]); extended.end > window.end {
+				window.end = extended.end
+			}
+		}
+
+		snippets = append(snippets, highlight(text, window, grouped))
+	}
+	return snippets
+}
+
+// Cut on spaces when possible, so that words are kept whole
+func contextOf(text string, match span) span {
+
+	window := span{start: match.start - snippetContext, end: match.end + snippetContext}
+
+	if window.start <= 0 {
+		window.start = 0
+	} else if space := strings.IndexByte(text[window.start:match.start], ' '); space >= 0 {
+		window.start += space + 1
+	} else {
+		for window.start < match.start && !utf8.RuneStart(text[window.start]) {
+			window.start++
+		}
+	}
+
+	if window.end >= len(text) {
+		window.end = len(text)
+	} else if space := strings.LastIndexByte(text[match.end:window.end], ' '); space >= 0 {
+		window.end = match.end + space
+	} else {
+		for window.end > match.end && !utf8.RuneStart(text[window.end]) {
+			window.end--
+		}
+	}
+	return window
+}
+
+func highlight(text string, window span, matches []span) string {
+
+	var builder strings.Builder
+	if window.start > 0 {
+		builder.WriteString("…")
+	}
+
+	position := window.start
+	for _, match := range matches {
+		builder.WriteString(html.EscapeString(text[position:match.start]))
+		builder.WriteString("<mark>")
+		builder.WriteString(html.EscapeString(text[match.start:match.end]))
+		builder.WriteString("</mark>")
+		position = match.end
+	}
+	builder.WriteString(html.EscapeString(text[position:window.end]))
+
+	if window.end < len(text) {
+		builder.WriteString("…")
+	}
+	return builder.String()
+}
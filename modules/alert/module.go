@@ -0,0 +1,268 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbalert"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/mail"
+	"github.com/dademo/rssreader/modules/metrics"
+	"github.com/dademo/rssreader/modules/webhook"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Event sent to the webhooks of a watchlist
+const EventAlertMatched = "alert.matched"
+
+// Configured watchlist, with its terms compiled
+type watchlist struct {
+	config *config.Watchlist
+	terms  []*term
+	feeds  map[string]bool
+}
+
+type term struct {
+	label   string
+	pattern *regexp.Regexp
+}
+
+var (
+	watchlists []*watchlist
+	alertStore *dbalert.Store
+)
+
+var alertsCounter = metrics.NewCounterVec(
+	"rssreader_alerts_total",
+	"Items matched by watchlist.",
+	"watchlist",
+)
+
+func init() {
+	dbfeed.RegisterChangeListener(onChanges)
+}
+
+// Matches being stored in the database, watchlists are disabled without one
+// The webhook and mail modules must be configured first
+func Configure(config *config.AlertsConfig, store *dbalert.Store) error {
+
+	Close()
+
+	configured, err := newWatchlists(config, webhook.Hooks())
+	if err != nil {
+		return err
+	}
+
+	watchlists = configured
+	alertStore = store
+	sendsEmails := false
+
+	for _, configured := range watchlists {
+		if len(configured.config.Email) > 0 && !mail.Enabled() {
+			log.Warn(fmt.Sprintf("No SMTP server configured, watchlist [%s] will not send emails", configured.config.Name))
+		}
+		sendsEmails = sendsEmails || len(configured.config.Email) > 0
+	}
+
+	if store != nil && sendsEmails && mail.Enabled() {
+		startMailWorker()
+	}
+
+	if store == nil && len(watchlists) > 0 {
+		log.Warn("Watchlists need a database, they are disabled")
+	}
+	return nil
+}
+
+// Checks the watchlists against the given webhooks, nothing being configured
+func Validate(config *config.AlertsConfig, hooks []*config.Webhook) error {
+	_, err := newWatchlists(config, hooks)
+	return err
+}
+
+func Enabled() bool {
+	return alertStore != nil && len(watchlists) > 0
+}
+
+func GetStore() *dbalert.Store {
+	return alertStore
+}
+
+// Configured watchlists, in their configuration order
+func Watchlists() []*config.Watchlist {
+
+	configs := make([]*config.Watchlist, 0, len(watchlists))
+	for _, configured := range watchlists {
+		configs = append(configs, configured.config)
+	}
+	return configs
+}
+
+func newWatchlists(config *config.AlertsConfig, hooks []*config.Webhook) ([]*watchlist, error) {
+
+	// Webhooks being targeted by name, whatever their own filters
+	hookNames := map[string]bool{}
+	for _, hook := range hooks {
+		hookNames[hook.Name] = true
+	}
+
+	configured := make([]*watchlist, 0, len(config.Watchlists))
+
+	names := map[string]bool{}
+	for _, watchlistConfig := range config.Watchlists {
+
+		if names[watchlistConfig.Name] {
+			return nil, fmt.Errorf("Watchlist [%s] is defined twice", watchlistConfig.Name)
+		}
+		names[watchlistConfig.Name] = true
+
+		newWatchlist, err := newWatchlist(watchlistConfig, hookNames)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Invalid watchlist [%s]", watchlistConfig.Name))
+			return nil, err
+		}
+		configured = append(configured, newWatchlist)
+	}
+	return configured, nil
+}
+
+func newWatchlist(watchlistConfig *config.Watchlist, hookNames map[string]bool) (*watchlist, error) {
+
+	if watchlistConfig.Name == "" {
+		return nil, errors.New("Watchlists must be named")
+	}
+
+	configured := &watchlist{
+		config: watchlistConfig,
+		terms:  make([]*term, 0, len(watchlistConfig.Keywords)+len(watchlistConfig.Patterns)),
+		feeds:  map[string]bool{},
+	}
+
+	for _, keyword := range watchlistConfig.Keywords {
+		if keyword == "" {
+			continue
+		}
+		configured.terms = append(configured.terms, &term{label: keyword, pattern: keywordPattern(keyword)})
+	}
+
+	for _, pattern := range watchlistConfig.Patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid watchlist pattern [%s]: %s", pattern, err)
+		}
+		configured.terms = append(configured.terms, &term{label: pattern, pattern: compiled})
+	}
+
+	if len(configured.terms) == 0 {
+		return nil, errors.New("Watchlists need at least a keyword or a pattern")
+	}
+
+	for _, feed := range watchlistConfig.Feeds {
+		configured.feeds[feed] = true
+	}
+
+	for _, name := range watchlistConfig.Webhooks {
+		if !hookNames[name] {
+			return nil, fmt.Errorf("Unknown webhook [%s]", name)
+		}
+	}
+
+	_, err := mail.ParseAddresses(watchlistConfig.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return configured, nil
+}
+
+// Keywords edges only need to be word boundaries when they are word characters
+func keywordPattern(keyword string) *regexp.Regexp {
+
+	pattern := regexp.QuoteMeta(keyword)
+	if isWordByte(keyword[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordByte(keyword[len(keyword)-1]) {
+		pattern = pattern + `\b`
+	}
+	return regexp.MustCompile("(?i)" + pattern)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// Feeds are designated by their configuration name or their title
+func (configured *watchlist) watches(feed *dbfeed.Feed) bool {
+	return len(configured.feeds) == 0 || configured.feeds[feed.ConfigName] || configured.feeds[feed.Title]
+}
+
+func onChanges(ctx context.Context, changes []*dbfeed.Change) {
+
+	if !Enabled() {
+		return
+	}
+
+	// The matches of a fetch are emailed together
+	matched := make(map[*watchlist][]*dbalert.Alert)
+
+	for _, change := range changes {
+		if change.Type != dbfeed.ChangeItemInserted {
+			continue
+		}
+		for _, configured := range watchlists {
+			if !configured.watches(change.Feed) {
+				continue
+			}
+			alert, err := configured.evaluate(ctx, change.Feed, change.Item)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to evaluate watchlist [%s]", configured.config.Name))
+				continue
+			}
+			if alert != nil {
+				matched[configured] = append(matched[configured], alert)
+			}
+		}
+	}
+
+	for _, configured := range watchlists {
+		if alerts := matched[configured]; len(alerts) > 0 {
+			configured.email(alerts)
+		}
+	}
+}
+
+// Records an alert when the item matches, notifying its webhooks
+func (configured *watchlist) evaluate(ctx context.Context, feed *dbfeed.Feed, item *dbfeed.FeedItem) (*dbalert.Alert, error) {
+
+	terms, snippets := configured.match(item)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	alert := &dbalert.Alert{
+		Watchlist: configured.config.Name,
+		FeedId:    feed.Id,
+		ItemId:    item.Id,
+		FeedTitle: feed.Title,
+		ItemTitle: item.Title,
+		ItemLink:  item.Link,
+		Terms:     terms,
+		Snippets:  snippets,
+	}
+
+	err := alertStore.CreateAlert(ctx, alert)
+	if err != nil {
+		return nil, err
+	}
+	alertsCounter.Inc(configured.config.Name)
+	log.Info(fmt.Sprintf("Watchlist [%s] matched item [%s]", configured.config.Name, item.Title))
+
+	configured.notify(ctx, alert, feed, item)
+	return alert, nil
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+
+	"github.com/dademo/rssreader/modules/database/dbalert"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/mail"
+	"github.com/dademo/rssreader/modules/webhook"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Emails waiting for the worker, later ones being dropped when full
+const mailQueueSize = 64
+
+var (
+	mailQueue  chan *mail.Message
+	mailWorker sync.WaitGroup
+)
+
+// Emails are sent in the background, fetches not waiting for the SMTP server
+func startMailWorker() {
+
+	queue := make(chan *mail.Message, mailQueueSize)
+	mailQueue = queue
+
+	mailWorker.Add(1)
+	go func() {
+		defer mailWorker.Done()
+		for message := range queue {
+			err := mail.Send(context.Background(), message)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to email alert [%s]", message.Subject))
+			}
+		}
+	}()
+}
+
+// Sends the queued emails then stops the worker
+func Close() {
+
+	if mailQueue == nil {
+		return
+	}
+	close(mailQueue)
+	mailQueue = nil
+	mailWorker.Wait()
+}
+
+// Failures are logged, the alert being recorded anyway
+func (configured *watchlist) notify(ctx context.Context, alert *dbalert.Alert, feed *dbfeed.Feed, item *dbfeed.FeedItem) {
+
+	for _, name := range configured.config.Webhooks {
+		err := webhook.Notify(ctx, name, EventAlertMatched, feed, item, alert)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to notify webhook [%s] of an alert", name))
+		}
+	}
+}
+
+// Queues a single email for the alerts, failures being logged by the worker
+func (configured *watchlist) email(alerts []*dbalert.Alert) {
+
+	if len(configured.config.Email) == 0 || mailQueue == nil {
+		return
+	}
+
+	select {
+	case mailQueue <- alertMessage(configured.config.Email, alerts):
+	default:
+		log.Warn(fmt.Sprintf("Too many alerts waiting to be emailed, dropping %d of watchlist [%s]", len(alerts), configured.config.Name))
+	}
+}
+
+// One message for the matches of a watchlist in a fetch
+func alertMessage(to []string, alerts []*dbalert.Alert) *mail.Message {
+
+	var text, body strings.Builder
+
+	for it, alert := range alerts {
+		if it > 0 {
+			text.WriteString("\n")
+			body.WriteString("<hr>\n")
+		}
+
+		fmt.Fprintf(&text, "Watchlist %s matched %s in %s\n%s\n%s\n\n", alert.Watchlist, strings.Join(alert.Terms, ", "), alert.FeedTitle, alert.ItemTitle, alert.ItemLink)
+		fmt.Fprintf(&body, "<p>Watchlist <strong>%s</strong> matched %s in %s</p>\n<p><a href=\"%s\">%s</a></p>\n",
+			html.EscapeString(alert.Watchlist),
+			html.EscapeString(strings.Join(alert.Terms, ", ")),
+			html.EscapeString(alert.FeedTitle),
+			html.EscapeString(alert.ItemLink),
+			html.EscapeString(alert.ItemTitle),
+		)
+
+		for _, snippet := range alert.Snippets {
+			fmt.Fprintf(&text, "> %s\n", snippetText(snippet))
+			fmt.Fprintf(&body, "<blockquote>%s</blockquote>\n", snippet)
+		}
+	}
+
+	subject := fmt.Sprintf("[%s] %s", alerts[0].Watchlist, alerts[0].ItemTitle)
+	if len(alerts) > 1 {
+		subject = fmt.Sprintf("[%s] %d new matches", alerts[0].Watchlist, len(alerts))
+	}
+
+	return &mail.Message{
+		To:      to,
+		Subject: subject,
+		Text:    text.String(),
+		Html:    body.String(),
+	}
+}
+
+// Snippets are escaped HTML, matches being shown between asterisks in text
+func snippetText(snippet string) string {
+	replacer := strings.NewReplacer("<mark>", "*", "</mark>", "*")
+	return html.UnescapeString(replacer.Replace(snippet))
+}
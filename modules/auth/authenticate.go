@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbauth"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SessionCookieName = "rssreader_session"
+
+	tokenPrefix         = "rsr_"
+	secretBytes         = 32
+	tokenTouchPrecision = time.Minute
+)
+
+// Compared when the user does not exist, so that unknown usernames take as long to reject
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+func HashPassword(password string) (string, error) {
+
+	if password == "" {
+		return "", errors.New("The password can not be empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Opens a session, returning the secret to send as the session cookie
+func Login(ctx context.Context, username string, password string) (*dbauth.User, *dbauth.Session, string, error) {
+
+	if authStore == nil {
+		return nil, nil, "", errors.New("Authentication is not configured")
+	}
+
+	user, err := authStore.UserByName(ctx, username)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if user == nil {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("rssreader"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, nil, "", ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, nil, "", ErrInvalidCredentials
+	}
+
+	err = authStore.DeleteExpiredSessions(ctx, time.Now())
+	if err != nil {
+		appLog.DebugError(err, "Unable to remove expired sessions")
+	}
+
+	secret, err := newSecret("")
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	created := time.Now()
+	expires := created.Add(time.Duration(authConfig.SessionDurationHours) * time.Hour)
+	session := &dbauth.Session{
+		UserId:  user.Id,
+		Created: &created,
+		Expires: &expires,
+	}
+
+	err = authStore.CreateSession(ctx, session, hashSecret(secret))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return user, session, secret, nil
+}
+
+func Logout(ctx context.Context, principal *Principal) error {
+
+	if principal == nil || principal.Session == nil {
+		return nil
+	}
+	return authStore.DeleteSession(ctx, principal.Session.Id)
+}
+
+// Creates an API token, the returned secret can not be retrieved afterwards
+func CreateToken(ctx context.Context, userId appDatabase.PrimaryKey, name string) (*dbauth.Token, string, error) {
+
+	secret, err := newSecret(tokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &dbauth.Token{
+		UserId: userId,
+		Name:   name,
+	}
+
+	err = authStore.CreateToken(ctx, token, hashSecret(secret))
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// Fever clients send the MD5 of "username:password" as their key
+func SetFeverKey(ctx context.Context, user *dbauth.User, password string) error {
+
+	key := md5.Sum([]byte(user.Username + ":" + password))
+	keyHash := hashSecret(hex.EncodeToString(key[:]))
+	return authStore.SetFeverKeyHash(ctx, user.Id, &keyHash)
+}
+
+func ClearFeverKey(ctx context.Context, user *dbauth.User) error {
+	return authStore.SetFeverKeyHash(ctx, user.Id, nil)
+}
+
+// Returns nil when no user enabled the Fever API with this key
+func FeverPrincipal(ctx context.Context, apiKey string) (*Principal, error) {
+
+	if authStore == nil || apiKey == "" {
+		return nil, nil
+	}
+
+	user, err := authStore.UserByFeverKeyHash(ctx, hashSecret(strings.ToLower(apiKey)))
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return &Principal{User: user}, nil
+}
+
+// Reads the bearer token, the Google Reader login or the session cookie, returning nil when none is valid
+func Authenticate(request *http.Request) (*Principal, error) {
+
+	ctx := request.Context()
+
+	if authorization := request.Header.Get("Authorization"); authorization != "" {
+
+		const (
+			bearerPrefix = "Bearer "
+			// Google Reader clients send the secret returned by their login
+			googleLoginPrefix = "GoogleLogin auth="
+		)
+
+		switch {
+		case strings.HasPrefix(authorization, bearerPrefix):
+			return tokenPrincipal(ctx, strings.TrimPrefix(authorization, bearerPrefix))
+		case strings.HasPrefix(authorization, googleLoginPrefix):
+			return sessionPrincipal(ctx, strings.TrimPrefix(authorization, googleLoginPrefix))
+		default:
+			return nil, nil
+		}
+	}
+
+	cookie, err := request.Cookie(SessionCookieName)
+	if err != nil {
+		// No session cookie
+		return nil, nil
+	}
+	return sessionPrincipal(ctx, cookie.Value)
+}
+
+func tokenPrincipal(ctx context.Context, secret string) (*Principal, error) {
+
+	user, token, err := authStore.UserByTokenHash(ctx, hashSecret(secret))
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.LastUsed == nil || now.Sub(*token.LastUsed) > tokenTouchPrecision {
+		err = authStore.TouchToken(ctx, token.Id, now)
+		if err != nil {
+			appLog.DebugError(err, "Unable to update the token last use")
+		}
+	}
+
+	return &Principal{User: user, Token: token}, nil
+}
+
+func sessionPrincipal(ctx context.Context, secret string) (*Principal, error) {
+
+	user, session, err := authStore.UserBySessionHash(ctx, hashSecret(secret))
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	if session.Expires == nil || session.Expires.Before(time.Now()) {
+		return nil, nil
+	}
+
+	return &Principal{User: user, Session: session}, nil
+}
+
+func SessionCookie(secret string, session *dbauth.Session) *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    secret,
+		Path:     "/",
+		Expires:  *session.Expires,
+		HttpOnly: true,
+		Secure:   authConfig.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func ExpiredSessionCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   authConfig.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func newSecret(prefix string) (string, error) {
+
+	buffer := make([]byte, secretBytes)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		appLog.DebugError(err, "Unable to generate a secret")
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// Secrets are random enough for a plain hash
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
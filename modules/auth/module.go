@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbauth"
+)
+
+type Permission string
+
+const (
+	// Zero value, any authenticated user is allowed
+	PermissionAuthenticated Permission = ""
+	// No authentication needed, can not be granted
+	PermissionPublic Permission = "public"
+	// Grants every permission
+	PermissionAdmin       Permission = "admin"
+	PermissionFeedsRead   Permission = "feeds:read"
+	PermissionLogsRead    Permission = "logs:read"
+	PermissionMetricsRead Permission = "metrics:read"
+)
+
+// Permissions which can be granted to users
+var GrantablePermissions = []Permission{
+	PermissionAdmin,
+	PermissionFeedsRead,
+	PermissionLogsRead,
+	PermissionMetricsRead,
+}
+
+var ErrInvalidCredentials = errors.New("Invalid username or password")
+
+var (
+	authConfig *config.AuthConfig
+	authStore  *dbauth.Store
+)
+
+// Authenticated user, with the session or the token used
+type Principal struct {
+	User    *dbauth.User
+	Session *dbauth.Session
+	Token   *dbauth.Token
+}
+
+type principalContextKey struct{}
+
+func Configure(config *config.AuthConfig, store *dbauth.Store) {
+	authConfig = config
+	authStore = store
+}
+
+func Enabled() bool {
+	return authConfig != nil && authConfig.Enabled
+}
+
+func GetStore() *dbauth.Store {
+	return authStore
+}
+
+func IsGrantable(permission Permission) bool {
+	for _, grantable := range GrantablePermissions {
+		if permission == grantable {
+			return true
+		}
+	}
+	return false
+}
+
+func HasPermission(user *dbauth.User, permission Permission) bool {
+
+	if permission == PermissionPublic || permission == PermissionAuthenticated {
+		return true
+	}
+
+	for _, granted := range user.Permissions {
+		if Permission(granted) == permission || Permission(granted) == PermissionAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// Returns nil for public routes and when authentication is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
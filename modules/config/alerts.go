@@ -0,0 +1,24 @@
+package config
+
+type Watchlist struct {
+	Name string `yaml:"name"`
+	// Matched case-insensitively as whole words, like CVE-2021-44228 or product names
+	Keywords []string `yaml:"keywords"`
+	// Regular expressions, case sensitive unless starting with (?i)
+	Patterns []string `yaml:"patterns"`
+	// Feeds watched, every feed when empty
+	Feeds []string `yaml:"feeds"`
+	// Notifications, by webhook name and email address
+	Webhooks []string `yaml:"webhooks"`
+	Email    []string `yaml:"email"`
+}
+
+type AlertsConfig struct {
+	Watchlists []*Watchlist `yaml:"watchlists"`
+}
+
+func defaultAlertsConfig() *AlertsConfig {
+	return &AlertsConfig{
+		Watchlists: []*Watchlist{},
+	}
+}
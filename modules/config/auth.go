@@ -0,0 +1,17 @@
+package config
+
+type AuthConfig struct {
+	// When disabled, every route is public. The server refuses to start while no user exists
+	Enabled              bool `yaml:"enabled"`
+	SessionDurationHours uint `yaml:"sessionDurationHours"`
+	// Session cookies are only sent over HTTPS
+	SecureCookies bool `yaml:"secureCookies"`
+}
+
+func defaultAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		Enabled:              true,
+		SessionDurationHours: 14 * 24,
+		SecureCookies:        false,
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+type BackupConfig struct {
+	Directory string `yaml:"directory"`
+	// Zero disables scheduled backups
+	IntervalMinutes uint `yaml:"intervalMinutes"`
+	// Count of snapshots kept, zero keeps them all
+	Keep uint `yaml:"keep"`
+}
+
+func defaultBackupConfig() *BackupConfig {
+	return &BackupConfig{
+		Directory:       "backups",
+		IntervalMinutes: 0,
+		Keep:            7,
+	}
+}
@@ -0,0 +1,16 @@
+package config
+
+type DuplicatesConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	MaxDistance int  `yaml:"maxDistance"`
+	// Latest items compared to new ones when MaxDistance is over 3, closer items being looked up by their SimHash
+	CandidateWindow int `yaml:"candidateWindow"`
+}
+
+func defaultDuplicatesConfig() *DuplicatesConfig {
+	return &DuplicatesConfig{
+		Enabled:         true,
+		MaxDistance:     3,
+		CandidateWindow: 2000,
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+type SmtpConfig struct {
+	Host     string `yaml:"host"`
+	Port     uint   `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	// One of starttls, tls for an implicit TLS connection, or none
+	Security           string `yaml:"security"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	TimeoutSeconds     uint   `yaml:"timeoutSeconds"`
+}
+
+type Digest struct {
+	Name string   `yaml:"name"`
+	To   []string `yaml:"to"`
+	// Cron expression or shortcut, like @daily or @weekly
+	Schedule string `yaml:"schedule"`
+	// Go template, given the same values as the body templates
+	Subject string `yaml:"subject"`
+	// Selection, every feed being included when empty
+	Feeds      []string `yaml:"feeds"`
+	Categories []string `yaml:"categories"`
+	// Either feed or category
+	GroupBy  string `yaml:"groupBy"`
+	MaxItems uint   `yaml:"maxItems"`
+	// Go template files replacing the default bodies
+	HtmlTemplate string `yaml:"htmlTemplate"`
+	TextTemplate string `yaml:"textTemplate"`
+	// Sends a digest even when no item arrived
+	SendEmpty bool `yaml:"sendEmpty"`
+}
+
+type EmailConfig struct {
+	Smtp    *SmtpConfig `yaml:"smtp"`
+	Digests []*Digest   `yaml:"digests"`
+}
+
+func defaultEmailConfig() *EmailConfig {
+	return &EmailConfig{
+		Smtp: &SmtpConfig{
+			Port:           587,
+			Security:       "starttls",
+			TimeoutSeconds: 30,
+		},
+		Digests: []*Digest{},
+	}
+}
@@ -0,0 +1,12 @@
+package config
+
+type EventsConfig struct {
+	// Older events are deleted, stream clients can not resume from them
+	RetentionHours uint `yaml:"retentionHours"`
+}
+
+func defaultEventsConfig() *EventsConfig {
+	return &EventsConfig{
+		RetentionHours: 168,
+	}
+}
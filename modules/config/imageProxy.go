@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+type ImageProxyConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	Secret         string `yaml:"secret"`
+	CacheDir       string `yaml:"cacheDir"`
+	MaxCacheSizeMB uint   `yaml:"maxCacheSizeMB"`
+	CacheTTLHours  uint   `yaml:"cacheTTLHours"`
+	MaxImageSizeMB uint   `yaml:"maxImageSizeMB"`
+	TimeoutSeconds uint   `yaml:"timeoutSeconds"`
+	RewriteContent bool   `yaml:"rewriteContent"`
+	// Images of loopback, private and link-local addresses are refused unless set
+	AllowPrivateNetworks bool `yaml:"allowPrivateNetworks"`
+}
+
+func defaultImageProxyConfig() *ImageProxyConfig {
+	return &ImageProxyConfig{
+		Enabled:              false,
+		Secret:               "",
+		CacheDir:             filepath.Join(os.TempDir(), "rssreader-image-cache"),
+		MaxCacheSizeMB:       256,
+		CacheTTLHours:        7 * 24,
+		MaxImageSizeMB:       10,
+		TimeoutSeconds:       10,
+		RewriteContent:       true,
+		AllowPrivateNetworks: false,
+	}
+}
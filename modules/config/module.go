@@ -11,9 +11,64 @@ import (
 )
 
 type Feed struct {
-	Name                 string `yaml:"name"`
-	Url                  string `yaml:"url"`
-	FetchIntervalMinutes uint   `yaml:"fetchIntervalMinutes"`
+	Name string `yaml:"name"`
+	// file:// URLs read local files, their path being possibly a glob pattern or a directory
+	Url string `yaml:"url"`
+	// Used instead of the URL, for sources without feed
+	Command *FeedCommand `yaml:"command"`
+	// Reads the items of the HTML page at the URL
+	Scrape *FeedScrape `yaml:"scrape"`
+	// Used instead of the URL, for newsletters received by email
+	Mailbox *FeedMailbox `yaml:"mailbox"`
+	// serve fetches the feed when starting, then every interval, 60 minutes when not set
+	FetchIntervalMinutes uint `yaml:"fetchIntervalMinutes"`
+	// Timeout of the HTTP requests of URL and scraped feeds, 30 seconds when not set
+	FetchTimeoutSeconds uint             `yaml:"fetchTimeoutSeconds"`
+	Rules               []*Rule          `yaml:"rules"`
+	Retention           *RetentionConfig `yaml:"retention"`
+}
+
+// Executable writing an RSS, Atom or JSON feed on its standard output
+type FeedCommand struct {
+	Path string   `yaml:"path"`
+	Args []string `yaml:"args"`
+	// Added to the environment of the reader, which is left out when ClearEnv is set
+	Env            map[string]string `yaml:"env"`
+	ClearEnv       bool              `yaml:"clearEnv"`
+	Dir            string            `yaml:"dir"`
+	TimeoutSeconds uint              `yaml:"timeoutSeconds"`
+}
+
+// CSS selectors, relative to the item container, the container itself being used when empty
+type FeedScrape struct {
+	Items string `yaml:"items"`
+	Title string `yaml:"title"`
+	Link  string `yaml:"link"`
+	// Attributes are read instead of the text when set, links and images defaulting to href and src
+	LinkAttribute string `yaml:"linkAttribute"`
+	Date          string `yaml:"date"`
+	DateAttribute string `yaml:"dateAttribute"`
+	// Go time layout, common formats being tried otherwise
+	DateFormat       string `yaml:"dateFormat"`
+	Summary          string `yaml:"summary"`
+	SummaryAttribute string `yaml:"summaryAttribute"`
+	Image            string `yaml:"image"`
+	ImageAttribute   string `yaml:"imageAttribute"`
+}
+
+// Mail folder whose messages are read as items, a feed being made for each sender or list
+type FeedMailbox struct {
+	// Maildir directory or mbox file
+	Path string `yaml:"path"`
+	// maildir or mbox, guessed from the path when empty
+	Format string `yaml:"format"`
+	// When set, only the messages sent by these addresses or @domains, or through these lists, are read
+	From    []string `yaml:"from"`
+	ListIds []string `yaml:"listIds"`
+	// sender or list, messages sent outside of lists being grouped by sender
+	GroupBy string `yaml:"groupBy"`
+	// Only the most recent messages are read, 1000 when not set
+	MaxMessages uint `yaml:"maxMessages"`
 }
 
 type DatabaseConfig struct {
@@ -40,10 +95,21 @@ type HttpConfig struct {
 }
 
 type Config struct {
-	Feeds      []*Feed         `yaml:"feeds"`
-	DbConfig   *DatabaseConfig `yaml:"database"`
-	LogConfig  *LogConfig      `yaml:"log"`
-	HttpConfig *HttpConfig     `yaml:"http"`
+	Feeds            []*Feed           `yaml:"feeds"`
+	DbConfig         *DatabaseConfig   `yaml:"database"`
+	LogConfig        *LogConfig        `yaml:"log"`
+	HttpConfig       *HttpConfig       `yaml:"http"`
+	SanitizerConfig  *SanitizerConfig  `yaml:"sanitizer"`
+	ImageProxyConfig *ImageProxyConfig `yaml:"imageProxy"`
+	Rules            []*Rule           `yaml:"rules"`
+	DuplicatesConfig *DuplicatesConfig `yaml:"duplicates"`
+	RetentionConfig  *RetentionConfig  `yaml:"retention"`
+	BackupConfig     *BackupConfig     `yaml:"backup"`
+	AuthConfig       *AuthConfig       `yaml:"auth"`
+	EventsConfig     *EventsConfig     `yaml:"events"`
+	WebhooksConfig   *WebhooksConfig   `yaml:"webhooks"`
+	EmailConfig      *EmailConfig      `yaml:"email"`
+	AlertsConfig     *AlertsConfig     `yaml:"alerts"`
 }
 
 func ReadConfig(configFilePath string) (*Config, error) {
@@ -101,10 +167,21 @@ func DefaultLogConfig() *LogConfig {
 
 func defaultConfig() *Config {
 	return &Config{
-		Feeds:      []*Feed{},
-		DbConfig:   defaultDatabaseConfig(),
-		LogConfig:  DefaultLogConfig(),
-		HttpConfig: defaultHttpĈonfig(),
+		Feeds:            []*Feed{},
+		DbConfig:         defaultDatabaseConfig(),
+		LogConfig:        DefaultLogConfig(),
+		HttpConfig:       defaultHttpĈonfig(),
+		SanitizerConfig:  defaultSanitizerConfig(),
+		ImageProxyConfig: defaultImageProxyConfig(),
+		Rules:            []*Rule{},
+		DuplicatesConfig: defaultDuplicatesConfig(),
+		RetentionConfig:  defaultRetentionConfig(),
+		BackupConfig:     defaultBackupConfig(),
+		AuthConfig:       defaultAuthConfig(),
+		EventsConfig:     defaultEventsConfig(),
+		WebhooksConfig:   defaultWebhooksConfig(),
+		EmailConfig:      defaultEmailConfig(),
+		AlertsConfig:     defaultAlertsConfig(),
 	}
 }
 
@@ -0,0 +1,41 @@
+package config
+
+type RetentionConfig struct {
+	// Zero values disable the limit
+	MaxItems    *uint `yaml:"maxItems"`
+	MaxAgeDays  *uint `yaml:"maxAgeDays"`
+	KeepStarred *bool `yaml:"keepStarred"`
+	// Only used in the global configuration
+	PurgeIntervalMinutes uint `yaml:"purgeIntervalMinutes"`
+}
+
+func (retentionConfig *RetentionConfig) Merge(override *RetentionConfig) *RetentionConfig {
+
+	merged := *retentionConfig
+
+	if override == nil {
+		return &merged
+	}
+
+	if override.MaxItems != nil {
+		merged.MaxItems = override.MaxItems
+	}
+	if override.MaxAgeDays != nil {
+		merged.MaxAgeDays = override.MaxAgeDays
+	}
+	if override.KeepStarred != nil {
+		merged.KeepStarred = override.KeepStarred
+	}
+
+	return &merged
+}
+
+func defaultRetentionConfig() *RetentionConfig {
+	keepStarred := true
+	return &RetentionConfig{
+		MaxItems:             nil,
+		MaxAgeDays:           nil,
+		KeepStarred:          &keepStarred,
+		PurgeIntervalMinutes: 60,
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+type Rule struct {
+	Name     string           `yaml:"name" json:"name"`
+	Include  []*RuleCondition `yaml:"include" json:"include"`
+	Exclude  []*RuleCondition `yaml:"exclude" json:"exclude"`
+	Rewrites []*RuleRewrite   `yaml:"rewrites" json:"rewrites"`
+	Actions  *RuleActions     `yaml:"actions" json:"actions"`
+}
+
+type RuleCondition struct {
+	Field   string `yaml:"field" json:"field"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+type RuleRewrite struct {
+	Type        string `yaml:"type" json:"type"`
+	Field       string `yaml:"field" json:"field"`
+	Pattern     string `yaml:"pattern" json:"pattern"`
+	Replacement string `yaml:"replacement" json:"replacement"`
+}
+
+type RuleActions struct {
+	MarkRead bool     `yaml:"markRead" json:"markRead"`
+	Star     bool     `yaml:"star" json:"star"`
+	Tags     []string `yaml:"tags" json:"tags"`
+	Drop     bool     `yaml:"drop" json:"drop"`
+}
@@ -0,0 +1,22 @@
+package config
+
+type SanitizerConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	KeepRaw       bool     `yaml:"keepRaw"`
+	StripTrackers bool     `yaml:"stripTrackers"`
+	TrackerHosts  []string `yaml:"trackerHosts"`
+}
+
+func defaultSanitizerConfig() *SanitizerConfig {
+	return &SanitizerConfig{
+		Enabled:       true,
+		KeepRaw:       false,
+		StripTrackers: true,
+		TrackerHosts: []string{
+			"feeds.feedburner.com",
+			"pixel.wp.com",
+			"stats.wordpress.com",
+			"www.google-analytics.com",
+		},
+	}
+}
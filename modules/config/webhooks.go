@@ -0,0 +1,37 @@
+package config
+
+type Webhook struct {
+	Name string `yaml:"name"`
+	Url  string `yaml:"url"`
+	// Signs the body with HMAC-SHA256 when set
+	Secret string `yaml:"secret"`
+	// Item changes sent, item.inserted and item.updated by default
+	Events []string `yaml:"events"`
+	// Filters, all of the given ones must match
+	Feeds      []string `yaml:"feeds"`
+	Categories []string `yaml:"categories"`
+	// Searched in the item title, description and content
+	Match string `yaml:"match"`
+	// Go template rendering the body, a JSON document being sent otherwise
+	Template       string            `yaml:"template"`
+	ContentType    string            `yaml:"contentType"`
+	Headers        map[string]string `yaml:"headers"`
+	MaxAttempts    uint              `yaml:"maxAttempts"`
+	TimeoutSeconds uint              `yaml:"timeoutSeconds"`
+}
+
+type WebhooksConfig struct {
+	Hooks []*Webhook `yaml:"hooks"`
+	// Delay between two runs of the delivery queue
+	DeliveryIntervalSeconds uint `yaml:"deliveryIntervalSeconds"`
+	// Ended deliveries older than this are removed from the log, zero keeps them
+	LogRetentionDays uint `yaml:"logRetentionDays"`
+}
+
+func defaultWebhooksConfig() *WebhooksConfig {
+	return &WebhooksConfig{
+		Hooks:                   []*Webhook{},
+		DeliveryIntervalSeconds: 10,
+		LogRetentionDays:        30,
+	}
+}
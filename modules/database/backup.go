@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	"github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	backupFilePrefix = "rssreader-"
+	backupFileSuffix = ".sqlite"
+	backupTimeFormat = "20060102T150405.000Z"
+
+	// Pages copied at once, writers are given some time between steps
+	backupPagesPerStep = 1024
+	backupStepPause    = 10 * time.Millisecond
+)
+
+func IsSQLite() bool {
+	switch dbDriver {
+	case "sqlite", "sqlite3":
+		return true
+	default:
+		return false
+	}
+}
+
+// Writes a consistent snapshot of the sqlite database to directory, keeping the latest keep snapshots
+func Backup(ctx context.Context, directory string, keep uint) (string, error) {
+
+	if database == nil || !IsSQLite() {
+		return "", errors.New("Backups are only available for sqlite databases")
+	}
+
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		appLog.DebugError(err, "Unable to create the backup directory")
+		return "", err
+	}
+
+	backupPath := filepath.Join(directory, backupFilePrefix+time.Now().UTC().Format(backupTimeFormat)+backupFileSuffix)
+	temporaryPath := backupPath + ".tmp"
+
+	log.Debug(fmt.Sprintf("Backing up the database to [%s]", backupPath))
+
+	destination, err := sql.Open("sqlite3", temporaryPath)
+	if err != nil {
+		appLog.DebugError(err, "Unable to create the backup file")
+		return "", err
+	}
+
+	err = sqliteCopy(ctx, destination, database)
+	closeErr := destination.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		appLog.DebugError(err, "Unable to back up the database")
+		os.Remove(temporaryPath)
+		return "", err
+	}
+
+	err = os.Rename(temporaryPath, backupPath)
+	if err != nil {
+		appLog.DebugError(err, "Unable to move the backup file")
+		return "", err
+	}
+
+	err = rotateBackups(directory, keep)
+	if err != nil {
+		// The backup is still usable
+		appLog.DebugError(err, "Unable to remove old backups")
+	}
+
+	return backupPath, nil
+}
+
+// Checks that a snapshot is readable and holds modules this version of the application can migrate
+func ValidateBackup(ctx context.Context, backupPath string) error {
+
+	source, err := openBackup(backupPath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return checkBackup(ctx, source)
+}
+
+// Replaces the content of the sqlite database by the one of a backup, PrepareDatabase should be called afterwards
+func Restore(ctx context.Context, backupPath string) error {
+
+	if database == nil || !IsSQLite() {
+		return errors.New("Restores are only available for sqlite databases")
+	}
+
+	source, err := openBackup(backupPath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	err = checkBackup(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	log.Debug(fmt.Sprintf("Restoring the database from [%s]", backupPath))
+
+	err = sqliteCopy(ctx, database, source)
+	if err != nil {
+		appLog.DebugError(err, "Unable to restore the database")
+		return err
+	}
+	return nil
+}
+
+func openBackup(backupPath string) (*sql.DB, error) {
+
+	if _, err := os.Stat(backupPath); err != nil {
+		return nil, err
+	}
+
+	source, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", backupPath))
+	if err != nil {
+		appLog.DebugError(err, "Unable to open the backup file")
+		return nil, err
+	}
+	return source, nil
+}
+
+func checkBackup(ctx context.Context, source *sql.DB) error {
+
+	var integrity string
+	err := source.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&integrity)
+	if err != nil {
+		appLog.DebugError(err, "Unable to check the backup integrity")
+		return err
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("The backup is corrupted, %s", integrity)
+	}
+
+	rows, err := source.QueryContext(ctx, "SELECT module, version FROM system_information_table")
+	if err != nil {
+		appLog.DebugError(err, "Unable to read the backup module versions")
+		return errors.New("The file is not a backup of this application")
+	}
+	defer DeferRowsCloseFct(rows)()
+
+	registeredVersions := map[string]string{}
+	for _, databaseTableCreatorDef := range registeredTableCreatorDefs {
+		registeredVersions[databaseTableCreatorDef.ModuleName] = databaseTableCreatorDef.Version
+	}
+
+	for rows.Next() {
+		var module DatabaseModuleDescption
+		err = rows.Scan(&module.ModuleName, &module.Version)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return err
+		}
+
+		registeredVersion, ok := registeredVersions[module.ModuleName]
+		if !ok {
+			return fmt.Errorf("Module [%s] of the backup is unknown to this version of the application", module.ModuleName)
+		}
+
+		// Older modules are migrated once restored
+		newer, err := isNewerVersion(module.Version, registeredVersion)
+		if err != nil {
+			return err
+		}
+		if newer {
+			return fmt.Errorf("Module [%s] of the backup is in version [%s], newer than the supported version [%s]", module.ModuleName, module.Version, registeredVersion)
+		}
+	}
+	return rows.Err()
+}
+
+func sqliteCopy(ctx context.Context, destination *sql.DB, source *sql.DB) error {
+
+	return withSQLiteConn(ctx, destination, func(destinationConn *sqlite3.SQLiteConn) error {
+		return withSQLiteConn(ctx, source, func(sourceConn *sqlite3.SQLiteConn) error {
+
+			backup, err := destinationConn.Backup("main", sourceConn, "main")
+			if err != nil {
+				return err
+			}
+
+			for {
+				if err := ctx.Err(); err != nil {
+					backup.Close()
+					return err
+				}
+
+				done, err := backup.Step(backupPagesPerStep)
+				if err != nil {
+					backup.Close()
+					return err
+				}
+				if done {
+					return backup.Finish()
+				}
+				time.Sleep(backupStepPause)
+			}
+		})
+	})
+}
+
+func withSQLiteConn(ctx context.Context, db *sql.DB, fct func(conn *sqlite3.SQLiteConn) error) error {
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get a database connection")
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return errors.New("The database is not a sqlite database")
+		}
+		return fct(sqliteConn)
+	})
+}
+
+func rotateBackups(directory string, keep uint) error {
+
+	if keep == 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+
+	backups := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), backupFilePrefix) && strings.HasSuffix(entry.Name(), backupFileSuffix) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	// Names hold the backup date, newest last
+	sort.Strings(backups)
+
+	for len(backups) > int(keep) {
+		log.Debug(fmt.Sprintf("Removing old backup [%s]", backups[0]))
+		err = os.Remove(filepath.Join(directory, backups[0]))
+		if err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func isNewerVersion(version string, reference string) (bool, error) {
+
+	parts := strings.Split(version, ".")
+	referenceParts := strings.Split(reference, ".")
+
+	for i := 0; i < len(parts) || i < len(referenceParts); i++ {
+
+		value, err := versionPart(parts, i)
+		if err != nil {
+			return false, fmt.Errorf("Unable to parse version [%s]", version)
+		}
+		referenceValue, err := versionPart(referenceParts, i)
+		if err != nil {
+			return false, fmt.Errorf("Unable to parse version [%s]", reference)
+		}
+
+		if value != referenceValue {
+			return value > referenceValue, nil
+		}
+	}
+	return false, nil
+}
+
+func versionPart(parts []string, index int) (int, error) {
+	if index >= len(parts) {
+		return 0, nil
+	}
+	return strconv.Atoi(parts[index])
+}
@@ -20,13 +20,14 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type DatabaseModuleTableCreator = func(connection *sql.Tx) error
-type DatabaseModuleTableUpdater = func(connection *sql.Tx, oldVersion string) error
+type DatabaseModuleTableCreator = func(ctx context.Context, connection *sql.Tx) error
+type DatabaseModuleTableUpdater = func(ctx context.Context, connection *sql.Tx, oldVersion string) error
 type DatabaseModuleOnDatabaseSetFct = func(db *sql.DB)
 
 type DatabaseModuleDescption struct {
 	ModuleName string
 	Version    string
+	LastUpdate *time.Time
 }
 
 type DatabaseModuleTableCreationDef struct {
@@ -34,6 +35,13 @@ type DatabaseModuleTableCreationDef struct {
 	Version                    string
 	DatabaseModuleTableCreator DatabaseModuleTableCreator
 	DatabaseModuleTableUpdater DatabaseModuleTableUpdater
+	Tables                     []TableDefinition
+}
+
+type DatabaseModuleMigration struct {
+	FromVersion string
+	ToVersion   string
+	SQL         []string
 }
 
 var (
@@ -66,7 +74,7 @@ type DatabaseEntity interface {
 
 type PrimaryKey = uint64
 
-func ConnectDB(dbConfig *config.DatabaseConfig) error {
+func ConnectDB(ctx context.Context, dbConfig *config.DatabaseConfig) error {
 
 	var err error
 
@@ -82,7 +90,7 @@ func ConnectDB(dbConfig *config.DatabaseConfig) error {
 	dbDriver = dbConfig.Driver
 	log.Debug("Connected to the database")
 
-	err = database.Ping()
+	err = database.PingContext(ctx)
 	if err != nil {
 		appLog.DebugError(err, "Unable to establish a connection to the database")
 		return err
@@ -100,12 +108,14 @@ func Cleanup() {
 	}
 }
 
-func PrepareDatabase() (err error) {
+func GetDatabase() *sql.DB {
+	return database
+}
+
+func PrepareDatabase(ctx context.Context) (err error) {
 
 	log.Debug("Prepairing database")
 
-	ctx := context.Background()
-	defer ctx.Done()
 	conn, err := database.Conn(ctx)
 	if err != nil {
 		appLog.DebugError(err, "Unable to connect to the database")
@@ -128,13 +138,13 @@ func PrepareDatabase() (err error) {
 		}
 	}
 
-	tx, err := database.Begin()
+	tx, err := database.BeginTx(ctx, nil)
 	if err != nil {
 		appLog.DebugError(err, "Unable to begin transaction")
 		return err
 	}
 
-	err = initInformationTables(tx)
+	err = initInformationTables(ctx, tx)
 	if err != nil {
 		appLog.DebugError(err, "Unable to initalize system tables")
 		return err
@@ -147,13 +157,13 @@ func PrepareDatabase() (err error) {
 
 	for _, databaseTableCreatorDef := range registeredTableCreatorDefs {
 
-		tx, err := database.Begin()
+		tx, err := database.BeginTx(ctx, nil)
 		if err != nil {
 			appLog.DebugError(err, "Unable to begin transaction")
 			return err
 		}
 
-		existingModuleDef, err := fetchModuleByName(tx, databaseTableCreatorDef.ModuleName)
+		existingModuleDef, err := fetchModuleByName(ctx, tx, databaseTableCreatorDef.ModuleName)
 
 		if err != nil {
 			appLog.DebugError(err, "Unable to check for module installation, ")
@@ -163,7 +173,7 @@ func PrepareDatabase() (err error) {
 
 		if existingModuleDef == nil {
 			log.Debug(fmt.Sprintf("Creating tables for mod [%s:%s]", databaseTableCreatorDef.ModuleName, databaseTableCreatorDef.Version))
-			err = databaseTableCreatorDef.DatabaseModuleTableCreator(tx)
+			err = databaseTableCreatorDef.DatabaseModuleTableCreator(ctx, tx)
 			if err != nil {
 				appLog.DebugError(err, "An error occured while creating some tables, ")
 				defer rollbackFunc(tx)
@@ -173,6 +183,7 @@ func PrepareDatabase() (err error) {
 
 			log.Debug("Saving the new module status")
 			err = saveModuleByName(
+				ctx,
 				tx,
 				DatabaseModuleDescption{
 					ModuleName: databaseTableCreatorDef.ModuleName,
@@ -191,7 +202,7 @@ func PrepareDatabase() (err error) {
 
 			log.Debug(fmt.Sprintf("Updating tables for mod [%s:%s] from version [%s]", databaseTableCreatorDef.ModuleName, databaseTableCreatorDef.Version, existingModuleDef.Version))
 
-			err = databaseTableCreatorDef.DatabaseModuleTableUpdater(tx, existingModuleDef.Version)
+			err = databaseTableCreatorDef.DatabaseModuleTableUpdater(ctx, tx, existingModuleDef.Version)
 			if err != nil {
 				appLog.DebugError(err, "An error occured while updating some tables, ")
 				defer rollbackFunc(tx)
@@ -203,6 +214,7 @@ func PrepareDatabase() (err error) {
 			log.Debug("Saving the new module status")
 
 			err = saveModuleByName(
+				ctx,
 				tx,
 				DatabaseModuleDescption{
 					ModuleName: databaseTableCreatorDef.ModuleName,
@@ -243,6 +255,43 @@ func RegisterOnDatabaseSet(onDatabaseSetRef DatabaseModuleOnDatabaseSetFct) {
 	registeredModulesOnDatabaseSetFcts = append(registeredModulesOnDatabaseSetFcts, onDatabaseSetRef)
 }
 
+func RunMigrations(ctx context.Context, connection *sql.Tx, fromVersion string, toVersion string, migrations []DatabaseModuleMigration) error {
+
+	currentVersion := fromVersion
+
+	for _, migration := range migrations {
+
+		if migration.FromVersion != currentVersion {
+			continue
+		}
+
+		log.Debug(fmt.Sprintf("Migrating tables from version [%s] to version [%s]", migration.FromVersion, migration.ToVersion))
+
+		for _, row := range migration.SQL {
+			sql, err := NormalizedSql(row)
+			if err != nil {
+				appLog.DebugError(err)
+				return err
+			}
+
+			log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+			_, err = connection.ExecContext(ctx, sql)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to migrate tables to version [%s]", migration.ToVersion))
+				return err
+			}
+		}
+		currentVersion = migration.ToVersion
+	}
+
+	if currentVersion != toVersion {
+		return fmt.Errorf("Unable to find a migration path from version [%s] to version [%s], stopped at version [%s]", fromVersion, toVersion, currentVersion)
+	}
+
+	return nil
+}
+
 func NormalizedSql(sql string) (string, error) {
 
 	const _PLACEHOLDER = "?"
@@ -293,6 +342,43 @@ func PrepareExecSQL(sql string) string {
 	}
 }
 
+func InsertIgnoreSql(table string, columns []string, rowCount int) string {
+
+	rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	allPlaceholders := make([]string, 0, rowCount)
+	for it := 0; it < rowCount; it++ {
+		allPlaceholders = append(allPlaceholders, rowPlaceholders)
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		table,
+		strings.Join(columns, ", "),
+		strings.Join(allPlaceholders, ", "),
+	)
+
+	switch dbDriver {
+	case "mysql":
+		return fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s = %s", sql, columns[0], columns[0])
+	default:
+		return fmt.Sprintf("%s ON CONFLICT DO NOTHING", sql)
+	}
+}
+
+// Placeholders of an IN clause of count values
+func InPlaceholders(count int) string {
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", count), ", ") + ")"
+}
+
+// Expression whose values compare as instants, sqlite storing dates as text holding their offset
+func SqlComparableDate(expression string) string {
+	switch dbDriver {
+	case "sqlite", "sqlite3":
+		return fmt.Sprintf("julianday(%s)", expression)
+	default:
+		return expression
+	}
+}
+
 func EntityId(e interface{}) interface{} {
 
 	const fieldIdName = "Id"
@@ -326,23 +412,23 @@ func EntityId(e interface{}) interface{} {
 	return nil
 }
 
-func SqlExec(statement *sql.Stmt, args ...interface{}) error {
-	_, err := sqlExecCommonDriver(statement, false, args...)
+func SqlExec(ctx context.Context, statement *sql.Stmt, args ...interface{}) error {
+	_, err := sqlExecCommonDriver(ctx, statement, false, args...)
 	return err
 }
 
-func SqlExecGetId(statement *sql.Stmt, args ...interface{}) (int64, error) {
+func SqlExecGetId(ctx context.Context, statement *sql.Stmt, args ...interface{}) (int64, error) {
 	switch dbDriver {
 	case "postgres", "postgresql":
-		return sqlExecPostgresql(statement, args...)
+		return sqlExecPostgresql(ctx, statement, args...)
 	default:
-		return sqlExecCommonDriver(statement, true, args...)
+		return sqlExecCommonDriver(ctx, statement, true, args...)
 	}
 }
 
-func sqlExecCommonDriver(statement *sql.Stmt, fetchId bool, args ...interface{}) (int64, error) {
+func sqlExecCommonDriver(ctx context.Context, statement *sql.Stmt, fetchId bool, args ...interface{}) (int64, error) {
 
-	result, err := statement.Exec(args...)
+	result, err := statement.ExecContext(ctx, args...)
 	if err != nil {
 		log.Debug("An error occured while running a statement")
 		return 0, err
@@ -368,9 +454,9 @@ func sqlExecCommonDriver(statement *sql.Stmt, fetchId bool, args ...interface{})
 	}
 }
 
-func sqlExecPostgresql(statement *sql.Stmt, args ...interface{}) (int64, error) {
+func sqlExecPostgresql(ctx context.Context, statement *sql.Stmt, args ...interface{}) (int64, error) {
 
-	rows, err := statement.Query(args...)
+	rows, err := statement.QueryContext(ctx, args...)
 	if err != nil {
 		log.Debug("An error occured while running a statement")
 		return 0, err
@@ -429,7 +515,7 @@ func SqlDateParse(value interface{}) (*time.Time, error) {
 	return nil, errors.New(fmt.Sprintf("Unable to parse sql date (unknown type for value [%s] of type [%s])", value, reflect.TypeOf(value)))
 }
 
-func initInformationTables(connection *sql.Tx) error {
+func initInformationTables(ctx context.Context, connection *sql.Tx) error {
 
 	log.Debug("Creating system tables")
 
@@ -448,7 +534,7 @@ func initInformationTables(connection *sql.Tx) error {
 
 	log.Debug(fmt.Sprintf("Running command :\n%s", sql))
 
-	_, err = connection.Exec(sql)
+	_, err = connection.ExecContext(ctx, sql)
 
 	if err != nil {
 		appLog.DebugError(err, "Unable to create system tables")
@@ -459,12 +545,13 @@ func initInformationTables(connection *sql.Tx) error {
 	return nil
 }
 
-func fetchModuleByName(tx *sql.Tx, moduleName string) (*DatabaseModuleDescption, error) {
+func fetchModuleByName(ctx context.Context, tx *sql.Tx, moduleName string) (*DatabaseModuleDescption, error) {
 
 	sql, err := NormalizedSql(`
 		SELECT
 			module,
-			version
+			version,
+			last_update
 		FROM system_information_table
 		WHERE module = ?
 	`)
@@ -474,14 +561,14 @@ func fetchModuleByName(tx *sql.Tx, moduleName string) (*DatabaseModuleDescption,
 	}
 	v := new(DatabaseModuleDescption)
 
-	stmt, err := tx.Prepare(sql)
+	stmt, err := tx.PrepareContext(ctx, sql)
 	if err != nil {
 		log.Debug("Unable to prepare statement")
 		return nil, err
 	}
-	defer DeferStmtCloseFct(stmt)
+	defer DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(moduleName)
+	rows, err := stmt.QueryContext(ctx, moduleName)
 	if err != nil {
 		log.Debug("Unable to get result row")
 		return nil, err
@@ -490,22 +577,30 @@ func fetchModuleByName(tx *sql.Tx, moduleName string) (*DatabaseModuleDescption,
 		log.Debug("Unable to get result row")
 		return nil, rows.Err()
 	}
-	defer DeferRowsCloseFct(rows)
+	defer DeferRowsCloseFct(rows)()
 
 	if rows.Next() {
-		err = rows.Scan(&v.ModuleName, &v.Version)
+		var lastUpdate interface{}
+		err = rows.Scan(&v.ModuleName, &v.Version, &lastUpdate)
 		if err != nil {
 			log.Debug("Unable to affect results")
 			return nil, err
-		} else {
-			return v, nil
 		}
+		if raw, ok := lastUpdate.([]byte); ok {
+			lastUpdate = string(raw)
+		}
+		v.LastUpdate, err = SqlDateParse(lastUpdate)
+		if err != nil {
+			log.Debug("Unable to parse the module update date")
+			return nil, err
+		}
+		return v, nil
 	} else {
 		return nil, nil
 	}
 }
 
-func saveModuleByName(tx *sql.Tx, databaseModuleDescription DatabaseModuleDescption, update bool) error {
+func saveModuleByName(ctx context.Context, tx *sql.Tx, databaseModuleDescription DatabaseModuleDescption, update bool) error {
 
 	var sql string
 	var err error
@@ -531,14 +626,14 @@ func saveModuleByName(tx *sql.Tx, databaseModuleDescription DatabaseModuleDescpt
 		}
 	}
 
-	stmt, err := tx.Prepare(PrepareExecSQL(sql))
+	stmt, err := tx.PrepareContext(ctx, PrepareExecSQL(sql))
 	if err != nil {
 		log.Debug("Unable to create the statement for database version creation or update")
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = SqlExecGetId(stmt,
+	_, err = SqlExecGetId(ctx, stmt,
 		databaseModuleDescription.Version,
 		time.Now(),
 		StrWithMaxLength(databaseModuleDescription.ModuleName, 200),
@@ -0,0 +1,128 @@
+package dbalert
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	// Registered after the feed module, whose ids the matches hold
+	_ "github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Items are not referenced, their title and link being copied for alerts to outlive purges
+func getAlertSQL() []string {
+	return []string{
+		`
+		CREATE TABLE alert_match (
+			id				{{.SqlPrimaryKey}},
+			watchlist		VARCHAR(200) NOT NULL,
+			id_feed			INTEGER,
+			id_feed_item	INTEGER,
+			feed_title		TEXT,
+			item_title		TEXT,
+			item_link		TEXT,
+			terms			TEXT,
+			snippets		TEXT,
+			created			{{.SqlTimestamp}}
+		);`,
+		`CREATE INDEX alert_match_watchlist_idx ON alert_match(watchlist);`,
+		`CREATE INDEX alert_match_feed_idx ON alert_match(id_feed);`,
+	}
+}
+
+func getAlertMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{}
+}
+
+// Matched feeds and items may have been purged since, their ids being emptied on import then
+func getAlertTables() []appDatabase.TableDefinition {
+
+	text := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnText}
+	}
+	timestamp := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnTimestamp}
+	}
+	looseReference := func(name string, table string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnInteger, References: table, Loose: true}
+	}
+
+	return []appDatabase.TableDefinition{
+		{Name: "alert_match", HasId: true, Columns: []appDatabase.TableColumn{
+			text("watchlist"),
+			looseReference("id_feed", "feed"),
+			looseReference("id_feed_item", "feed_item"),
+			text("feed_title"),
+			text("item_title"),
+			text("item_link"),
+			text("terms"),
+			text("snippets"),
+			timestamp("created"),
+		}},
+	}
+}
+
+const (
+	alertModuleInitialVersion = "0.0.1"
+	alertModuleVersion        = "0.0.1"
+)
+
+var alertModuleDef = appDatabase.DatabaseModuleTableCreationDef{
+	ModuleName:                 "Alert",
+	Version:                    alertModuleVersion,
+	DatabaseModuleTableCreator: databaseAlertModuleCreator,
+	DatabaseModuleTableUpdater: databaseAlertModuleUpdater,
+	Tables:                     getAlertTables(),
+}
+
+func init() {
+	appDatabase.RegisterDatabaseTableCreator(alertModuleDef)
+}
+
+func databaseAlertModuleCreator(ctx context.Context, connection *sql.Tx) error {
+
+	log.Debug("Creating alert tables")
+
+	for _, row := range getAlertSQL() {
+		sql, err := appDatabase.NormalizedSql(row)
+
+		if err != nil {
+			return err
+		}
+
+		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+		_, err = connection.ExecContext(ctx, sql)
+		if err != nil {
+			appLog.DebugError(err, "Unable to create alert tables")
+			return err
+		}
+	}
+
+	err := appDatabase.RunMigrations(ctx, connection, alertModuleInitialVersion, alertModuleVersion, getAlertMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate alert tables")
+		return err
+	}
+
+	log.Debug("Alert tables created")
+	return nil
+}
+
+func databaseAlertModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating alert tables")
+
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, alertModuleVersion, getAlertMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate alert tables")
+		return err
+	}
+
+	log.Debug("Alert tables updated")
+	return nil
+}
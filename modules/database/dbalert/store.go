@@ -0,0 +1,206 @@
+package dbalert
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+// Item matched by a watchlist
+type Alert struct {
+	Id        appDatabase.PrimaryKey `json:"id"`
+	Watchlist string                 `json:"watchlist"`
+	FeedId    appDatabase.PrimaryKey `json:"feedId"`
+	ItemId    appDatabase.PrimaryKey `json:"itemId"`
+	FeedTitle string                 `json:"feedTitle"`
+	ItemTitle string                 `json:"itemTitle"`
+	ItemLink  string                 `json:"itemLink"`
+	// Keywords and patterns found in the item
+	Terms []string `json:"terms"`
+	// HTML extracts of the item, matches being wrapped in <mark> elements
+	Snippets []string   `json:"snippets"`
+	Created  *time.Time `json:"created"`
+}
+
+// Empty fields are ignored, a nil FeedIds meaning every feed
+type Filter struct {
+	Watchlist string
+	FeedIds   []appDatabase.PrimaryKey
+	// Only alerts created after this one
+	SinceId appDatabase.PrimaryKey
+	Limit   int
+	Offset  int
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (store *Store) CreateAlert(ctx context.Context, alert *Alert) error {
+
+	terms, err := json.Marshal(alert.Terms)
+	if err != nil {
+		return err
+	}
+	snippets, err := json.Marshal(alert.Snippets)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	newId, err := store.execGetId(ctx, `
+		INSERT INTO alert_match (watchlist, id_feed, id_feed_item, feed_title, item_title, item_link, terms, snippets, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		appDatabase.StrWithMaxLength(alert.Watchlist, 200),
+		alert.FeedId,
+		alert.ItemId,
+		alert.FeedTitle,
+		alert.ItemTitle,
+		alert.ItemLink,
+		string(terms),
+		string(snippets),
+		now,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving an alert")
+		return err
+	}
+
+	alert.Id = appDatabase.PrimaryKey(newId)
+	alert.Created = &now
+	return nil
+}
+
+// Most recent alerts first
+func (store *Store) GetAlerts(ctx context.Context, filter *Filter) ([]*Alert, error) {
+
+	if filter.FeedIds != nil && len(filter.FeedIds) == 0 {
+		return []*Alert{}, nil
+	}
+
+	conditions := []string{"id > ?"}
+	args := []interface{}{filter.SinceId}
+	if filter.Watchlist != "" {
+		conditions = append(conditions, "watchlist = ?")
+		args = append(args, filter.Watchlist)
+	}
+	if filter.FeedIds != nil {
+		conditions = append(conditions, fmt.Sprintf("id_feed IN (%s)", strings.TrimSuffix(strings.Repeat("?, ", len(filter.FeedIds)), ", ")))
+		for _, feedId := range filter.FeedIds {
+			args = append(args, feedId)
+		}
+	}
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := store.query(ctx, fmt.Sprintf(`
+		SELECT
+			id,
+			watchlist,
+			id_feed,
+			id_feed_item,
+			feed_title,
+			item_title,
+			item_link,
+			terms,
+			snippets,
+			created
+		FROM alert_match
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	alerts := make([]*Alert, 0)
+	for rows.Next() {
+
+		alert := new(Alert)
+		var feedId, itemId sql.NullInt64
+		var feedTitle, itemTitle, itemLink, terms, snippets sql.NullString
+		var createdRawValue interface{}
+
+		err = rows.Scan(&alert.Id, &alert.Watchlist, &feedId, &itemId, &feedTitle, &itemTitle, &itemLink, &terms, &snippets, &createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		alert.FeedId = appDatabase.PrimaryKey(feedId.Int64)
+		alert.ItemId = appDatabase.PrimaryKey(itemId.Int64)
+		alert.FeedTitle = feedTitle.String
+		alert.ItemTitle = itemTitle.String
+		alert.ItemLink = itemLink.String
+		alert.Terms, err = unmarshalStrings(terms)
+		if err == nil {
+			alert.Snippets, err = unmarshalStrings(snippets)
+		}
+		if err == nil {
+			alert.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		}
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse the alert")
+			return nil, err
+		}
+
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+func unmarshalStrings(value sql.NullString) ([]string, error) {
+
+	values := []string{}
+	if !value.Valid || value.String == "" {
+		return values, nil
+	}
+	err := json.Unmarshal([]byte(value.String), &values)
+	return values, err
+}
+
+func (store *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+
+	rows, err := store.db.QueryContext(ctx, normalized, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (store *Store) execGetId(ctx context.Context, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(appDatabase.PrepareExecSQL(query))
+	if err != nil {
+		appLog.DebugError(err, err)
+		return 0, err
+	}
+
+	stmt, err := store.db.PrepareContext(ctx, normalized)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return 0, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	return appDatabase.SqlExecGetId(ctx, stmt, args...)
+}
@@ -0,0 +1,151 @@
+package dbauth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getAuthSQL() []string {
+	return []string{
+		`
+		CREATE TABLE auth_user (
+			id				{{.SqlPrimaryKey}},
+			username		VARCHAR(200) NOT NULL UNIQUE,
+			password_hash	TEXT NOT NULL,
+			permissions		TEXT,
+			created			{{.SqlTimestamp}}
+		);`,
+		`
+		CREATE TABLE auth_token (
+			id			{{.SqlPrimaryKey}},
+			id_user		INTEGER NOT NULL REFERENCES auth_user(id),
+			name		TEXT,
+			token_hash	VARCHAR(64) NOT NULL UNIQUE,
+			created		{{.SqlTimestamp}},
+			last_used	{{.SqlTimestamp}}
+		);`,
+		`
+		CREATE TABLE auth_session (
+			id				{{.SqlPrimaryKey}},
+			id_user			INTEGER NOT NULL REFERENCES auth_user(id),
+			session_hash	VARCHAR(64) NOT NULL UNIQUE,
+			created			{{.SqlTimestamp}},
+			expires			{{.SqlTimestamp}}
+		);`,
+	}
+}
+
+func getAuthMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{
+		{
+			FromVersion: "0.0.1",
+			ToVersion:   "0.0.2",
+			SQL: []string{
+				`ALTER TABLE auth_user ADD COLUMN fever_key_hash VARCHAR(64);`,
+			},
+		},
+	}
+}
+
+func getAuthTables() []appDatabase.TableDefinition {
+
+	text := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnText}
+	}
+	timestamp := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnTimestamp}
+	}
+	reference := func(name string, table string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnInteger, References: table}
+	}
+
+	return []appDatabase.TableDefinition{
+		{Name: "auth_user", HasId: true, Columns: []appDatabase.TableColumn{
+			text("username"),
+			text("password_hash"),
+			text("permissions"),
+			timestamp("created"),
+			text("fever_key_hash"),
+		}},
+		{Name: "auth_token", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_user", "auth_user"),
+			text("name"),
+			text("token_hash"),
+			timestamp("created"),
+			timestamp("last_used"),
+		}},
+		{Name: "auth_session", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_user", "auth_user"),
+			text("session_hash"),
+			timestamp("created"),
+			timestamp("expires"),
+		}},
+	}
+}
+
+const (
+	authModuleInitialVersion = "0.0.1"
+	authModuleVersion        = "0.0.2"
+)
+
+var authModuleDef = appDatabase.DatabaseModuleTableCreationDef{
+	ModuleName:                 "Auth",
+	Version:                    authModuleVersion,
+	DatabaseModuleTableCreator: databaseAuthModuleCreator,
+	DatabaseModuleTableUpdater: databaseAuthModuleUpdater,
+	Tables:                     getAuthTables(),
+}
+
+func init() {
+	appDatabase.RegisterDatabaseTableCreator(authModuleDef)
+}
+
+func databaseAuthModuleCreator(ctx context.Context, connection *sql.Tx) error {
+
+	log.Debug("Creating auth tables")
+
+	for _, row := range getAuthSQL() {
+		sql, err := appDatabase.NormalizedSql(row)
+
+		if err != nil {
+			return err
+		}
+
+		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+		_, err = connection.ExecContext(ctx, sql)
+		if err != nil {
+			appLog.DebugError(err, "Unable to create auth tables")
+			return err
+		}
+	}
+
+	err := appDatabase.RunMigrations(ctx, connection, authModuleInitialVersion, authModuleVersion, getAuthMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate auth tables")
+		return err
+	}
+
+	log.Debug("Auth tables created")
+	return nil
+}
+
+func databaseAuthModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating auth tables")
+
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, authModuleVersion, getAuthMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate auth tables")
+		return err
+	}
+
+	log.Debug("Auth tables updated")
+	return nil
+}
@@ -0,0 +1,525 @@
+package dbauth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+const permissionsSeparator = ","
+
+type User struct {
+	Id           appDatabase.PrimaryKey `json:"id"`
+	Username     string                 `json:"username"`
+	PasswordHash string                 `json:"-"`
+	Permissions  []string               `json:"permissions"`
+	Created      *time.Time             `json:"created"`
+}
+
+// API token, only its hash is stored
+type Token struct {
+	Id       appDatabase.PrimaryKey `json:"id"`
+	UserId   appDatabase.PrimaryKey `json:"userId"`
+	Name     string                 `json:"name"`
+	Created  *time.Time             `json:"created"`
+	LastUsed *time.Time             `json:"lastUsed"`
+}
+
+// Browser session, only the hash of its cookie value is stored
+type Session struct {
+	Id      appDatabase.PrimaryKey `json:"id"`
+	UserId  appDatabase.PrimaryKey `json:"userId"`
+	Created *time.Time             `json:"created"`
+	Expires *time.Time             `json:"expires"`
+}
+
+// Tables holding an id_user column, cleared when a user is removed
+var userReferences []string
+
+func RegisterUserReference(table string) {
+	userReferences = append(userReferences, table)
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const userColumns = `
+	auth_user.id,
+	auth_user.username,
+	auth_user.password_hash,
+	auth_user.permissions,
+	auth_user.created
+`
+
+func (store *Store) CreateUser(ctx context.Context, user *User) error {
+
+	now := time.Now()
+
+	newId, err := store.execGetId(ctx, `
+		INSERT INTO auth_user (username, password_hash, permissions, created)
+		VALUES (?, ?, ?, ?)
+	`,
+		user.Username,
+		user.PasswordHash,
+		strings.Join(user.Permissions, permissionsSeparator),
+		now,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a user")
+		return err
+	}
+
+	user.Id = appDatabase.PrimaryKey(newId)
+	user.Created = &now
+	return nil
+}
+
+func (store *Store) UpdateUser(ctx context.Context, user *User) error {
+
+	err := store.exec(ctx, `
+		UPDATE auth_user SET
+			password_hash = ?,
+			permissions = ?
+		WHERE id = ?
+	`,
+		user.PasswordHash,
+		strings.Join(user.Permissions, permissionsSeparator),
+		user.Id,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while updating a user")
+	}
+	return err
+}
+
+// Removes the user with its tokens, sessions and registered references
+func (store *Store) DeleteUser(ctx context.Context, userId appDatabase.PrimaryKey) error {
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin a transaction")
+		return err
+	}
+
+	queries := make([]string, 0, len(userReferences)+3)
+	for _, table := range userReferences {
+		queries = append(queries, fmt.Sprintf(`DELETE FROM %s WHERE id_user = ?`, table))
+	}
+	queries = append(queries,
+		`DELETE FROM auth_token WHERE id_user = ?`,
+		`DELETE FROM auth_session WHERE id_user = ?`,
+		`DELETE FROM auth_user WHERE id = ?`,
+	)
+
+	for _, query := range queries {
+		normalized, err := appDatabase.NormalizedSql(query)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, normalized, userId)
+		}
+		if err != nil {
+			appLog.DebugError(err, "Unable to delete the user")
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *Store) GetUsers(ctx context.Context) ([]*User, error) {
+	return store.queryUsers(ctx, `SELECT `+userColumns+` FROM auth_user ORDER BY username`)
+}
+
+func (store *Store) CountUsers(ctx context.Context) (int, error) {
+
+	var count int
+	normalized, err := appDatabase.NormalizedSql(`SELECT COUNT(*) FROM auth_user`)
+	if err != nil {
+		return 0, err
+	}
+	err = store.db.QueryRowContext(ctx, normalized).Scan(&count)
+	return count, err
+}
+
+func (store *Store) UserByName(ctx context.Context, username string) (*User, error) {
+	return store.queryUser(ctx, `SELECT `+userColumns+` FROM auth_user WHERE username = ?`, username)
+}
+
+func (store *Store) UserById(ctx context.Context, userId appDatabase.PrimaryKey) (*User, error) {
+	return store.queryUser(ctx, `SELECT `+userColumns+` FROM auth_user WHERE id = ?`, userId)
+}
+
+// The Fever API authenticates with a hash of the credentials, only stored for users enabling it
+func (store *Store) SetFeverKeyHash(ctx context.Context, userId appDatabase.PrimaryKey, feverKeyHash *string) error {
+
+	err := store.exec(ctx, `UPDATE auth_user SET fever_key_hash = ? WHERE id = ?`, feverKeyHash, userId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while updating a user")
+	}
+	return err
+}
+
+func (store *Store) UserByFeverKeyHash(ctx context.Context, feverKeyHash string) (*User, error) {
+	return store.queryUser(ctx, `SELECT `+userColumns+` FROM auth_user WHERE fever_key_hash = ?`, feverKeyHash)
+}
+
+func (store *Store) CreateToken(ctx context.Context, token *Token, tokenHash string) error {
+
+	now := time.Now()
+
+	newId, err := store.execGetId(ctx, `
+		INSERT INTO auth_token (id_user, name, token_hash, created)
+		VALUES (?, ?, ?, ?)
+	`,
+		token.UserId,
+		token.Name,
+		tokenHash,
+		now,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a token")
+		return err
+	}
+
+	token.Id = appDatabase.PrimaryKey(newId)
+	token.Created = &now
+	return nil
+}
+
+func (store *Store) GetUserTokens(ctx context.Context, userId appDatabase.PrimaryKey) ([]*Token, error) {
+
+	stmt, err := store.prepare(ctx, `
+		SELECT
+			id,
+			id_user,
+			name,
+			created,
+			last_used
+		FROM auth_token
+		WHERE id_user = ?
+		ORDER BY id
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return nil, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	rows, err := stmt.QueryContext(ctx, userId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	tokens := make([]*Token, 0)
+	for rows.Next() {
+
+		token := new(Token)
+		var createdRawValue, lastUsedRawValue interface{}
+
+		err = rows.Scan(&token.Id, &token.UserId, &token.Name, &createdRawValue, &lastUsedRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		token.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse created date")
+			return nil, err
+		}
+		token.LastUsed, err = appDatabase.SqlDateParse(lastUsedRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse last used date")
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Returns false when the user has no such token
+func (store *Store) DeleteToken(ctx context.Context, userId appDatabase.PrimaryKey, tokenId appDatabase.PrimaryKey) (bool, error) {
+
+	stmt, err := store.prepare(ctx, `DELETE FROM auth_token WHERE id = ? AND id_user = ?`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return false, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	result, err := stmt.ExecContext(ctx, tokenId, userId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the token")
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Returns the owner of a token, nil when no token matches
+func (store *Store) UserByTokenHash(ctx context.Context, tokenHash string) (*User, *Token, error) {
+
+	user, err := store.queryUser(ctx, `
+		SELECT `+userColumns+`
+		FROM auth_user
+		JOIN auth_token ON auth_token.id_user = auth_user.id
+		WHERE auth_token.token_hash = ?
+	`, tokenHash)
+	if err != nil || user == nil {
+		return nil, nil, err
+	}
+
+	var token Token
+	var lastUsedRawValue interface{}
+
+	normalized, err := appDatabase.NormalizedSql(`SELECT id, id_user, name, last_used FROM auth_token WHERE token_hash = ?`)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = store.db.QueryRowContext(ctx, normalized, tokenHash).Scan(&token.Id, &token.UserId, &token.Name, &lastUsedRawValue)
+	if err != nil {
+		appLog.DebugError(err, "Unable to affect results")
+		return nil, nil, err
+	}
+
+	token.LastUsed, err = appDatabase.SqlDateParse(lastUsedRawValue)
+	if err != nil {
+		appLog.DebugError(err, "Unable to parse last used date")
+		return nil, nil, err
+	}
+
+	return user, &token, nil
+}
+
+func (store *Store) TouchToken(ctx context.Context, tokenId appDatabase.PrimaryKey, lastUsed time.Time) error {
+	return store.exec(ctx, `UPDATE auth_token SET last_used = ? WHERE id = ?`, lastUsed, tokenId)
+}
+
+func (store *Store) CreateSession(ctx context.Context, session *Session, sessionHash string) error {
+
+	newId, err := store.execGetId(ctx, `
+		INSERT INTO auth_session (id_user, session_hash, created, expires)
+		VALUES (?, ?, ?, ?)
+	`,
+		session.UserId,
+		sessionHash,
+		session.Created,
+		session.Expires,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a session")
+		return err
+	}
+
+	session.Id = appDatabase.PrimaryKey(newId)
+	return nil
+}
+
+// Returns the owner of a session, nil when no session matches
+func (store *Store) UserBySessionHash(ctx context.Context, sessionHash string) (*User, *Session, error) {
+
+	user, err := store.queryUser(ctx, `
+		SELECT `+userColumns+`
+		FROM auth_user
+		JOIN auth_session ON auth_session.id_user = auth_user.id
+		WHERE auth_session.session_hash = ?
+	`, sessionHash)
+	if err != nil || user == nil {
+		return nil, nil, err
+	}
+
+	var session Session
+	var createdRawValue, expiresRawValue interface{}
+
+	normalized, err := appDatabase.NormalizedSql(`SELECT id, id_user, created, expires FROM auth_session WHERE session_hash = ?`)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = store.db.QueryRowContext(ctx, normalized, sessionHash).Scan(&session.Id, &session.UserId, &createdRawValue, &expiresRawValue)
+	if err != nil {
+		appLog.DebugError(err, "Unable to affect results")
+		return nil, nil, err
+	}
+
+	session.Created, err = appDatabase.SqlDateParse(createdRawValue)
+	if err != nil {
+		appLog.DebugError(err, "Unable to parse created date")
+		return nil, nil, err
+	}
+	session.Expires, err = appDatabase.SqlDateParse(expiresRawValue)
+	if err != nil {
+		appLog.DebugError(err, "Unable to parse expiration date")
+		return nil, nil, err
+	}
+
+	return user, &session, nil
+}
+
+func (store *Store) DeleteSession(ctx context.Context, sessionId appDatabase.PrimaryKey) error {
+	return store.exec(ctx, `DELETE FROM auth_session WHERE id = ?`, sessionId)
+}
+
+// Expiration dates are compared here, their SQL representation depending on the driver
+func (store *Store) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+
+	stmt, err := store.prepare(ctx, `SELECT id, expires FROM auth_session`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return err
+	}
+
+	expired := make([]appDatabase.PrimaryKey, 0)
+	for rows.Next() {
+
+		var sessionId appDatabase.PrimaryKey
+		var expiresRawValue interface{}
+
+		err = rows.Scan(&sessionId, &expiresRawValue)
+		if err != nil {
+			appDatabase.DeferRowsCloseFct(rows)()
+			appLog.DebugError(err, "Unable to affect results")
+			return err
+		}
+
+		expires, err := appDatabase.SqlDateParse(expiresRawValue)
+		if err != nil {
+			appDatabase.DeferRowsCloseFct(rows)()
+			appLog.DebugError(err, "Unable to parse expiration date")
+			return err
+		}
+
+		if expires == nil || expires.Before(now) {
+			expired = append(expired, sessionId)
+		}
+	}
+	appDatabase.DeferRowsCloseFct(rows)()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, sessionId := range expired {
+		err = store.DeleteSession(ctx, sessionId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *Store) queryUser(ctx context.Context, query string, args ...interface{}) (*User, error) {
+
+	users, err := store.queryUsers(ctx, query, args...)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+func (store *Store) queryUsers(ctx context.Context, query string, args ...interface{}) ([]*User, error) {
+
+	stmt, err := store.prepare(ctx, query)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return nil, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	users := make([]*User, 0)
+	for rows.Next() {
+
+		user := new(User)
+		var permissions sql.NullString
+		var createdRawValue interface{}
+
+		err = rows.Scan(&user.Id, &user.Username, &user.PasswordHash, &permissions, &createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		user.Permissions = splitPermissions(permissions.String)
+		user.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse created date")
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (store *Store) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+	return store.db.PrepareContext(ctx, normalized)
+}
+
+func (store *Store) exec(ctx context.Context, query string, args ...interface{}) error {
+
+	stmt, err := store.prepare(ctx, query)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	return appDatabase.SqlExec(ctx, stmt, args...)
+}
+
+func (store *Store) execGetId(ctx context.Context, query string, args ...interface{}) (int64, error) {
+
+	stmt, err := store.prepare(ctx, appDatabase.PrepareExecSQL(query))
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return 0, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	return appDatabase.SqlExecGetId(ctx, stmt, args...)
+}
+
+func splitPermissions(permissions string) []string {
+
+	split := make([]string, 0)
+	for _, permission := range strings.Split(permissions, permissionsSeparator) {
+		if permission = strings.TrimSpace(permission); permission != "" {
+			split = append(split, permission)
+		}
+	}
+	return split
+}
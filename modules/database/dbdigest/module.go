@@ -0,0 +1,101 @@
+package dbdigest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getDigestSQL() []string {
+	return []string{
+		`
+		CREATE TABLE digest_item (
+			id				{{.SqlPrimaryKey}},
+			digest			VARCHAR(200) NOT NULL,
+			id_feed_item	INTEGER NOT NULL REFERENCES feed_item(id),
+			created			{{.SqlTimestamp}},
+			UNIQUE(digest, id_feed_item)
+		);`,
+		`
+		CREATE TABLE digest_run (
+			id				{{.SqlPrimaryKey}},
+			digest			VARCHAR(200) NOT NULL,
+			sent			{{.SqlTimestamp}},
+			item_count		INTEGER NOT NULL DEFAULT 0,
+			error			TEXT
+		);`,
+		`CREATE INDEX digest_run_digest_idx ON digest_run(digest, sent);`,
+	}
+}
+
+func getDigestMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{}
+}
+
+const (
+	digestModuleInitialVersion = "0.0.1"
+	digestModuleVersion        = "0.0.1"
+)
+
+var digestModuleDef = appDatabase.DatabaseModuleTableCreationDef{
+	ModuleName:                 "Digest",
+	Version:                    digestModuleVersion,
+	DatabaseModuleTableCreator: databaseDigestModuleCreator,
+	DatabaseModuleTableUpdater: databaseDigestModuleUpdater,
+}
+
+func init() {
+	appDatabase.RegisterDatabaseTableCreator(digestModuleDef)
+	// Purged items are dropped from the pending digests
+	dbfeed.RegisterItemReference("digest_item", "")
+}
+
+func databaseDigestModuleCreator(ctx context.Context, connection *sql.Tx) error {
+
+	log.Debug("Creating digest tables")
+
+	for _, row := range getDigestSQL() {
+		sql, err := appDatabase.NormalizedSql(row)
+
+		if err != nil {
+			return err
+		}
+
+		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+		_, err = connection.ExecContext(ctx, sql)
+		if err != nil {
+			appLog.DebugError(err, "Unable to create digest tables")
+			return err
+		}
+	}
+
+	err := appDatabase.RunMigrations(ctx, connection, digestModuleInitialVersion, digestModuleVersion, getDigestMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate digest tables")
+		return err
+	}
+
+	log.Debug("Digest tables created")
+	return nil
+}
+
+func databaseDigestModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating digest tables")
+
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, digestModuleVersion, getDigestMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate digest tables")
+		return err
+	}
+
+	log.Debug("Digest tables updated")
+	return nil
+}
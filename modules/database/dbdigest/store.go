@@ -0,0 +1,244 @@
+package dbdigest
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+// Sending of a digest, successful when without error
+type Run struct {
+	Id        appDatabase.PrimaryKey `json:"id"`
+	Digest    string                 `json:"digest"`
+	Sent      *time.Time             `json:"sent"`
+	ItemCount int                    `json:"itemCount"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Adds an item to the next digest, items already pending being ignored
+func (store *Store) AddItem(ctx context.Context, digest string, itemId appDatabase.PrimaryKey) error {
+
+	_, err := store.exec(ctx,
+		appDatabase.InsertIgnoreSql("digest_item", []string{"digest", "id_feed_item", "created"}, 1),
+		appDatabase.StrWithMaxLength(digest, 200),
+		itemId,
+		time.Now(),
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while adding an item to a digest")
+	}
+	return err
+}
+
+// Items arrived since the last successful digest, in their arrival order
+func (store *Store) PendingItemIds(ctx context.Context, digest string) ([]appDatabase.PrimaryKey, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT id_feed_item
+		FROM digest_item
+		WHERE digest = ?
+		ORDER BY id
+	`, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	itemIds := make([]appDatabase.PrimaryKey, 0)
+	for rows.Next() {
+		var itemId appDatabase.PrimaryKey
+		err = rows.Scan(&itemId)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+		itemIds = append(itemIds, itemId)
+	}
+	return itemIds, rows.Err()
+}
+
+// Saves the run, the sent items leaving the pending ones when successful
+func (store *Store) RecordRun(ctx context.Context, run *Run, itemIds []appDatabase.PrimaryKey) error {
+
+	var runError interface{}
+	if run.Error != "" {
+		runError = run.Error
+	}
+
+	err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		_, err := store.txExec(ctx, tx, `
+			INSERT INTO digest_run (digest, sent, item_count, error)
+			VALUES (?, ?, ?, ?)
+		`,
+			appDatabase.StrWithMaxLength(run.Digest, 200),
+			run.Sent,
+			run.ItemCount,
+			runError,
+		)
+		if err != nil || run.Error != "" {
+			return err
+		}
+
+		for _, itemId := range itemIds {
+			_, err = store.txExec(ctx, tx, `DELETE FROM digest_item WHERE digest = ? AND id_feed_item = ?`, run.Digest, itemId)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a digest run")
+	}
+	return err
+}
+
+// Most recent runs first
+func (store *Store) GetRuns(ctx context.Context, digest string, limit int) ([]*Run, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT
+			id,
+			digest,
+			sent,
+			item_count,
+			error
+		FROM digest_run
+		WHERE digest = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, digest, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	runs := make([]*Run, 0)
+	for rows.Next() {
+
+		run := new(Run)
+		var sentRawValue interface{}
+		var runError sql.NullString
+
+		err = rows.Scan(&run.Id, &run.Digest, &sentRawValue, &run.ItemCount, &runError)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		run.Error = runError.String
+		run.Sent, err = appDatabase.SqlDateParse(sentRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse run date")
+			return nil, err
+		}
+
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Time of the last successful run, nil when the digest was never sent
+func (store *Store) LastSent(ctx context.Context, digest string) (*time.Time, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT sent
+		FROM digest_run
+		WHERE digest = ? AND error IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var sentRawValue interface{}
+	err = rows.Scan(&sentRawValue)
+	if err != nil {
+		appLog.DebugError(err, "Unable to affect results")
+		return nil, err
+	}
+	return appDatabase.SqlDateParse(sentRawValue)
+}
+
+func (store *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+
+	rows, err := store.db.QueryContext(ctx, normalized, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Returns the number of affected rows
+func (store *Store) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return 0, err
+	}
+
+	result, err := store.db.ExecContext(ctx, normalized, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Returns the number of affected rows
+func (store *Store) txExec(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, normalized, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (store *Store) inTransaction(ctx context.Context, fct func(tx *sql.Tx) error) error {
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin a transaction")
+		return err
+	}
+
+	err = fct(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+package dbevent
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Feeds and items are not referenced, events outliving purged items
+func getEventSQL() []string {
+	return []string{
+		`
+		CREATE TABLE feed_event (
+			id				{{.SqlPrimaryKey}},
+			type			VARCHAR(50) NOT NULL,
+			id_feed			INTEGER,
+			id_feed_item	INTEGER,
+			categories		TEXT,
+			data			TEXT,
+			created			{{.SqlTimestamp}}
+		);`,
+		`CREATE INDEX feed_event_created_idx ON feed_event(created);`,
+	}
+}
+
+func getEventMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{}
+}
+
+const (
+	eventModuleInitialVersion = "0.0.1"
+	eventModuleVersion        = "0.0.1"
+)
+
+var eventModuleDef = appDatabase.DatabaseModuleTableCreationDef{
+	ModuleName:                 "Event",
+	Version:                    eventModuleVersion,
+	DatabaseModuleTableCreator: databaseEventModuleCreator,
+	DatabaseModuleTableUpdater: databaseEventModuleUpdater,
+}
+
+func init() {
+	appDatabase.RegisterDatabaseTableCreator(eventModuleDef)
+}
+
+func databaseEventModuleCreator(ctx context.Context, connection *sql.Tx) error {
+
+	log.Debug("Creating event tables")
+
+	for _, row := range getEventSQL() {
+		sql, err := appDatabase.NormalizedSql(row)
+
+		if err != nil {
+			return err
+		}
+
+		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+		_, err = connection.ExecContext(ctx, sql)
+		if err != nil {
+			appLog.DebugError(err, "Unable to create event tables")
+			return err
+		}
+	}
+
+	err := appDatabase.RunMigrations(ctx, connection, eventModuleInitialVersion, eventModuleVersion, getEventMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate event tables")
+		return err
+	}
+
+	log.Debug("Event tables created")
+	return nil
+}
+
+func databaseEventModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating event tables")
+
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, eventModuleVersion, getEventMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate event tables")
+		return err
+	}
+
+	log.Debug("Event tables updated")
+	return nil
+}
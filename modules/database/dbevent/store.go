@@ -0,0 +1,186 @@
+package dbevent
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+// Category names may hold commas
+const categoriesSeparator = "\n"
+
+// Event ids are the sequence clients resume from
+type Event struct {
+	Id         appDatabase.PrimaryKey
+	Type       string
+	FeedId     *appDatabase.PrimaryKey
+	ItemId     *appDatabase.PrimaryKey
+	Categories []string
+	// JSON payload
+	Data    string
+	Created *time.Time
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Saves the events in a single transaction, setting their ids
+func (store *Store) Append(ctx context.Context, events []*Event) error {
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	normalized, err := appDatabase.NormalizedSql(appDatabase.PrepareExecSQL(`
+		INSERT INTO feed_event (type, id_feed, id_feed_item, categories, data, created)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`))
+	if err != nil {
+		appLog.DebugError(err, err)
+		return err
+	}
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin a transaction")
+		return err
+	}
+
+	err = func() error {
+
+		stmt, err := tx.PrepareContext(ctx, normalized)
+		if err != nil {
+			appLog.DebugError(err, "Unable to prepare statement")
+			return err
+		}
+		defer appDatabase.DeferStmtCloseFct(stmt)()
+
+		now := time.Now()
+		for _, event := range events {
+
+			newId, err := appDatabase.SqlExecGetId(ctx, stmt,
+				event.Type,
+				event.FeedId,
+				event.ItemId,
+				strings.Join(event.Categories, categoriesSeparator),
+				event.Data,
+				now,
+			)
+			if err != nil {
+				appLog.DebugError(err, "An error occured while saving an event")
+				return err
+			}
+
+			event.Id = appDatabase.PrimaryKey(newId)
+			event.Created = &now
+		}
+		return nil
+	}()
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Events following lastId, oldest first
+func (store *Store) Since(ctx context.Context, lastId appDatabase.PrimaryKey, limit int) ([]*Event, error) {
+
+	normalized, err := appDatabase.NormalizedSql(`
+		SELECT
+			id,
+			type,
+			id_feed,
+			id_feed_item,
+			categories,
+			data,
+			created
+		FROM feed_event
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+
+	rows, err := store.db.QueryContext(ctx, normalized, lastId, limit)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	events := make([]*Event, 0)
+	for rows.Next() {
+
+		event := new(Event)
+		var categories, data sql.NullString
+		var createdRawValue interface{}
+
+		err = rows.Scan(&event.Id, &event.Type, &event.FeedId, &event.ItemId, &categories, &data, &createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		if categories.String != "" {
+			event.Categories = strings.Split(categories.String, categoriesSeparator)
+		}
+		event.Data = data.String
+		event.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse created date")
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Zero when no event was stored
+func (store *Store) LastId(ctx context.Context) (appDatabase.PrimaryKey, error) {
+
+	normalized, err := appDatabase.NormalizedSql(`SELECT COALESCE(MAX(id), 0) FROM feed_event`)
+	if err != nil {
+		return 0, err
+	}
+
+	var lastId int64
+	err = store.db.QueryRowContext(ctx, normalized).Scan(&lastId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get the last event id")
+		return 0, err
+	}
+	return appDatabase.PrimaryKey(lastId), nil
+}
+
+// Returns the number of deleted events
+func (store *Store) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(`DELETE FROM feed_event WHERE created < ?`)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := store.db.ExecContext(ctx, normalized, before)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete events")
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,73 @@
+package dbfeed
+
+import (
+	"context"
+	"database/sql"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+const (
+	ChangeFeedCreated  = "feed.created"
+	ChangeFeedUpdated  = "feed.updated"
+	ChangeItemInserted = "item.inserted"
+	// Only reported when the item content changed, as for revisions
+	ChangeItemUpdated = "item.updated"
+)
+
+// Change made by a save, Item being nil for feed changes
+type Change struct {
+	Type string
+	Feed *Feed
+	Item *FeedItem
+}
+
+// Called once the changes are stored, in the saving goroutine
+type ChangeListener func(ctx context.Context, changes []*Change)
+
+var changeListeners []ChangeListener
+
+func RegisterChangeListener(listener ChangeListener) {
+	changeListeners = append(changeListeners, listener)
+}
+
+// Called with the changes of a save before its transaction is committed, an error rolling the save back
+type ChangeWriter func(ctx context.Context, tx *sql.Tx, changes []*Change) error
+
+var changeWriters []ChangeWriter
+
+func RegisterChangeWriter(writer ChangeWriter) {
+	changeWriters = append(changeWriters, writer)
+}
+
+func writeChanges(ctx context.Context, tx *sql.Tx, changes []*Change) error {
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, writer := range changeWriters {
+		err := writer(ctx, tx, changes)
+		if err != nil {
+			appLog.DebugError(err, "Unable to write the changes of a save")
+			return err
+		}
+	}
+	return nil
+}
+
+func notifyChanges(ctx context.Context, changes []*Change) {
+
+	if len(changes) == 0 {
+		return
+	}
+
+	for _, listener := range changeListeners {
+		listener(ctx, changes)
+	}
+}
+
+// Reported once the transaction is committed
+func (s *session) recordChange(changeType string, feed *Feed, item *FeedItem) {
+	s.changes = append(s.changes, &Change{Type: changeType, Feed: feed, Item: item})
+}
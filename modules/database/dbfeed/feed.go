@@ -1,7 +1,10 @@
 package dbfeed
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	appDatabase "github.com/dademo/rssreader/modules/database"
@@ -27,6 +30,7 @@ type Feed struct {
 	Copyright   string          `json:"copyright"`
 	Generator   string          `json:"generator"`
 	LastUpdate  *time.Time      `json:"lastUpdate"`
+	ConfigName  string          `json:"configName"`
 }
 
 func FromFeed(feed *gofeed.Feed) *Feed {
@@ -49,12 +53,12 @@ func FromFeed(feed *gofeed.Feed) *Feed {
 	}
 }
 
-func (f *Feed) Save() error {
+func (f *Feed) save(s *session) error {
 
 	log.Debug("Saving a feed")
 
 	if f.Author != nil {
-		err := f.Author.Save()
+		err := f.Author.save(s)
 		if err != nil {
 			appLog.DebugError(err, "Unable to save a feed author")
 			return err
@@ -62,7 +66,7 @@ func (f *Feed) Save() error {
 	}
 
 	if f.Image != nil {
-		err := f.Image.Save()
+		err := f.Image.save(s)
 		if err != nil {
 			appLog.DebugError(err, "Unable to save a feed image")
 			return err
@@ -71,7 +75,7 @@ func (f *Feed) Save() error {
 
 	if len(f.Categories) > 0 {
 		for _, category := range f.Categories {
-			err := category.Save()
+			err := category.save(s)
 			if err != nil {
 				appLog.DebugError(err, "Unable to save a feed cateogry")
 				return err
@@ -79,7 +83,7 @@ func (f *Feed) Save() error {
 		}
 	}
 
-	existingFeed, err := feedByTitle(f.Title)
+	existingFeed, err := storedFeed(s, f)
 	if err != nil {
 		appLog.DebugError(err, "Unable to check for feed existance")
 		return err
@@ -93,24 +97,16 @@ func (f *Feed) Save() error {
 
 		log.Debug("Adding a new feed")
 
-		sql, err := appDatabase.NormalizedSql(`
-			INSERT INTO feed (id_author, id_image, title, description, link, feed_link, updated, published, language, copyright, generator, last_update)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		stmt, err := s.prepareInsert(`
+			INSERT INTO feed (id_author, id_image, title, description, link, feed_link, updated, published, language, copyright, generator, last_update, config_name)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed creation")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		newId, err := appDatabase.SqlExecGetId(stmt,
+		newId, err := appDatabase.SqlExecGetId(s.ctx, stmt,
 			appDatabase.EntityId(f.Author),
 			appDatabase.EntityId(f.Image),
 			appDatabase.StrWithMaxLength(f.Title, 200),
@@ -123,6 +119,7 @@ func (f *Feed) Save() error {
 			f.Copyright,
 			f.Generator,
 			time.Now(),
+			appDatabase.StrWithMaxLength(f.ConfigName, 200),
 		)
 
 		if err != nil {
@@ -131,12 +128,13 @@ func (f *Feed) Save() error {
 		} else {
 			f.Id = appDatabase.PrimaryKey(newId)
 		}
+		s.recordChange(ChangeFeedCreated, f, nil)
 
 	} else {
 
 		log.Debug("Updating a feed")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			UPDATE feed SET
 				id_author = ?,
 				id_image = ?,
@@ -149,23 +147,16 @@ func (f *Feed) Save() error {
 				language = ?,
 				copyright = ?,
 				generator = ?,
-				last_update = ?
+				last_update = ?,
+				config_name = ?
 			WHERE id = ?
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed creation")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		_, err = appDatabase.SqlExecGetId(stmt,
+		_, err = appDatabase.SqlExecGetId(s.ctx, stmt,
 			appDatabase.EntityId(f.Author),
 			appDatabase.EntityId(f.Image),
 			appDatabase.StrWithMaxLength(f.Title, 200),
@@ -178,6 +169,7 @@ func (f *Feed) Save() error {
 			f.Copyright,
 			f.Generator,
 			time.Now(),
+			appDatabase.StrWithMaxLength(f.ConfigName, 200),
 			f.Id,
 		)
 
@@ -185,23 +177,29 @@ func (f *Feed) Save() error {
 			appLog.DebugError(err, fmt.Sprintf("An error occured while updating a feed (%d)", f.Id))
 			return err
 		}
+		if existingFeed == nil || f.metadataChanged(existingFeed) {
+			s.recordChange(ChangeFeedUpdated, f, nil)
+		}
 	}
 
-	log.Debug("Saving feeds")
-	if len(f.Items) > 0 {
-		for _, item := range f.Items {
-			item.Feed = f
-			err := item.Save()
-			if err != nil {
-				return err
-			}
+	log.Debug("Saving feed items")
+	for start := 0; start < len(f.Items); start += itemBatchSize {
+
+		end := start + itemBatchSize
+		if end > len(f.Items) {
+			end = len(f.Items)
+		}
+
+		err := saveItems(s, f, f.Items[start:end])
+		if err != nil {
+			return err
 		}
 	}
 
 	log.Debug("Linking feed to its categories")
 	if len(f.Categories) > 0 {
 		for _, category := range f.Categories {
-			err := linkFeedCategoryToFeed(category, f)
+			err := linkFeedCategoryToFeed(s, category, f)
 			if err != nil {
 				return err
 			}
@@ -211,9 +209,30 @@ func (f *Feed) Save() error {
 	return nil
 }
 
-func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
+func (store *SQLFeedStore) GetAllFeeds(ctx context.Context, withFeedItems bool) ([]*Feed, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
 
-	sql, err := appDatabase.NormalizedSql(`
+	return queryFeeds(s, "1 = 1", withFeedItems)
+}
+
+// Returns nil when the feed does not exist, items being left out
+func (store *SQLFeedStore) GetFeed(ctx context.Context, feedId appDatabase.PrimaryKey) (*Feed, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	feeds, err := queryFeeds(s, "id = ?", false, feedId)
+	if err != nil || len(feeds) == 0 {
+		return nil, err
+	}
+	return feeds[0], nil
+}
+
+func queryFeeds(s *session, where string, withFeedItems bool, args ...interface{}) ([]*Feed, error) {
+
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			id_author,
@@ -227,22 +246,17 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 			language,
 			copyright,
 			generator,
-			last_update
+			last_update,
+			COALESCE(config_name, '')
 		FROM feed
+		WHERE ` + where + `
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare SELECT statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query()
+	rows, err := stmt.QueryContext(s.ctx, args...)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -274,6 +288,7 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 			&v.Copyright,
 			&v.Generator,
 			&lastUpdateRawValue,
+			&v.ConfigName,
 		)
 		if err != nil {
 			appLog.DebugError(err, "Unable to affect results")
@@ -299,7 +314,7 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 		}
 
 		if authorId != nil {
-			v.Author, err = authorById(*authorId)
+			v.Author, err = authorById(s, *authorId)
 			if err != nil {
 				appLog.DebugError(err, "Unable to fetch feed author")
 				return nil, err
@@ -309,7 +324,7 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 		}
 
 		if imageId != nil {
-			v.Image, err = imageById(*imageId)
+			v.Image, err = imageById(s, *imageId)
 			if err != nil {
 				appLog.DebugError(err, "Unable to fetch feed image")
 				return nil, err
@@ -318,7 +333,7 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 			v.Image = nil
 		}
 
-		v.Categories, err = categoriesOfFeed(v)
+		v.Categories, err = categoriesOfFeed(s, v)
 		if err != nil {
 			appLog.DebugError(err, "Unable to fetch feed categories")
 			return nil, err
@@ -326,7 +341,7 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 
 		if withFeedItems {
 
-			v.Items, err = itemsOfFeed(v)
+			v.Items, err = itemsOfFeed(s, v)
 			if err != nil {
 				appLog.DebugError(err, "Unable to fetch feeds")
 				return nil, err
@@ -341,126 +356,67 @@ func GetAllFeeds(withFeedItems bool) ([]*Feed, error) {
 	return allRows, nil
 }
 
-func feedByTitle(title string) (*Feed, error) {
+// Feeds are told apart by their title, or by their mailto link for mailboxes, the title of those holding the sender name
+func storedFeed(s *session, f *Feed) (*Feed, error) {
+	if link := f.mailtoLink(); link != "" {
+		return feedWhere(s, "LOWER(link) = ?", link)
+	}
+	return feedWhere(s, "title = ?", appDatabase.StrWithMaxLength(f.Title, 200))
+}
+
+func (f *Feed) mailtoLink() string {
+	link := strings.ToLower(f.Link)
+	if strings.HasPrefix(link, "mailto:") {
+		return link
+	}
+	return ""
+}
+
+// Returns the stored feed with its id and the metadata compared when saving, nil if it does not exist
+func feedWhere(s *session, where string, arg interface{}) (*Feed, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
-			id_author,
-			id_image,
-			title,
 			description,
 			link,
-			feed_link,
-			updated,
-			published,
-			language,
-			copyright,
-			generator,
-			last_update
+			feed_link
 		FROM feed
-		WHERE title = ?
+		WHERE ` + where + `
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare SELECT statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(title)
+	rows, err := stmt.QueryContext(s.ctx, arg)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
 	}
 	if rows.Err() != nil {
 		appLog.DebugError(err, "Unable to get result row")
-		return nil, err
+		return nil, rows.Err()
 	}
 	defer appDatabase.DeferRowsCloseFct(rows)()
 
 	if rows.Next() {
-		var authorId, imageId *appDatabase.PrimaryKey
-		var updatedRawValue, publishedRawValue, lastUpdateRawValue interface{}
-		result := new(Feed)
-
-		err = rows.Scan(
-			&result.Id,
-			&authorId,
-			&imageId,
-			&result.Title,
-			&result.Description,
-			&result.Link,
-			&result.FeedLink,
-			&updatedRawValue,
-			&publishedRawValue,
-			&result.Language,
-			&result.Copyright,
-			&result.Generator,
-			&lastUpdateRawValue,
-		)
+		var feed Feed
+		var description, link, feedLink sql.NullString
+		err = rows.Scan(&feed.Id, &description, &link, &feedLink)
 		if err != nil {
 			appLog.DebugError(err, "Unable to affect results")
 			return nil, err
 		}
-
-		result.Updated, err = appDatabase.SqlDateParse(updatedRawValue)
-		if err != nil {
-			appLog.DebugError(err, "Unable to parse updated date")
-			return nil, err
-		}
-
-		result.Published, err = appDatabase.SqlDateParse(publishedRawValue)
-		if err != nil {
-			appLog.DebugError(err, "Unable to parse published date")
-			return nil, err
-		}
-
-		result.LastUpdate, err = appDatabase.SqlDateParse(lastUpdateRawValue)
-		if err != nil {
-			appLog.DebugError(err, "Unable to parse last update date")
-			return nil, err
-		}
-
-		if authorId != nil {
-			result.Author, err = authorById(*authorId)
-			if err != nil {
-				appLog.DebugError(err, "Unable to fetch feed author")
-				return nil, err
-			}
-		} else {
-			result.Author = nil
-		}
-
-		if imageId != nil {
-			result.Image, err = imageById(*imageId)
-			if err != nil {
-				appLog.DebugError(err, "Unable to fetch feed image")
-				return nil, err
-			}
-		} else {
-			result.Image = nil
-		}
-
-		result.Categories, err = categoriesOfFeed(result)
-		if err != nil {
-			appLog.DebugError(err, "Unable to fetch feed categories")
-			return nil, err
-		}
-
-		result.Items, err = itemsOfFeed(result)
-		if err != nil {
-			appLog.DebugError(err, "Unable to fetch feeds")
-			return nil, err
-		}
-
-		return result, nil
+		feed.Description = description.String
+		feed.Link = link.String
+		feed.FeedLink = feedLink.String
+		return &feed, nil
 	} else {
 		return nil, nil
 	}
 }
+
+func (f *Feed) metadataChanged(previous *Feed) bool {
+	return f.Description != previous.Description || f.Link != previous.Link || f.FeedLink != previous.FeedLink
+}
@@ -28,11 +28,16 @@ func FromPerson(author *gofeed.Person) *FeedAuthor {
 	}
 }
 
-func (f *FeedAuthor) Save() error {
+func (f *FeedAuthor) save(s *session) error {
 
 	if f.Id == 0 {
 
-		existing, err := feedAuthorByName(f.Name)
+		if id, ok := s.savedId("feed_author", f.Name); ok {
+			f.Id = id
+			return nil
+		}
+
+		existing, err := feedAuthorByName(s, f.Name)
 		if err != nil {
 			appLog.DebugError(err, "Unable to get author by name")
 			return err
@@ -40,29 +45,22 @@ func (f *FeedAuthor) Save() error {
 
 		if existing != nil {
 			f.Id = existing.Id
+			s.rememberId("feed_author", f.Name, f.Id)
 			return nil
 		} else {
 
 			log.Debug("Adding a new feed author")
 
-			sql, err := appDatabase.NormalizedSql(`
+			stmt, err := s.prepareInsert(`
 				INSERT INTO feed_author (name, email)
 				VALUES (?, ?)
 			`)
-			if err != nil {
-				appLog.DebugError(err, err)
-				return err
-			}
-
-			stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 			if err != nil {
 				appLog.DebugError(err, "Unable to create the statement for feed author update")
 				return err
 			}
-			defer appDatabase.DeferStmtCloseFct(stmt)()
 
-			newId, err := appDatabase.SqlExecGetId(stmt,
+			newId, err := appDatabase.SqlExecGetId(s.ctx, stmt,
 				f.Name,
 				f.Email,
 			)
@@ -72,6 +70,7 @@ func (f *FeedAuthor) Save() error {
 				return err
 			} else {
 				f.Id = appDatabase.PrimaryKey(newId)
+				s.rememberId("feed_author", f.Name, f.Id)
 				return nil
 			}
 		}
@@ -80,26 +79,18 @@ func (f *FeedAuthor) Save() error {
 
 		log.Debug("Updating a feed author")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			UPDATE feed_author SET
 				name = ?,
 				email = ?
 			WHERE id = ?
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed author update")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		_, err = appDatabase.SqlExecGetId(stmt,
+		_, err = appDatabase.SqlExecGetId(s.ctx, stmt,
 			f.Name,
 			f.Email,
 			f.Id,
@@ -114,9 +105,9 @@ func (f *FeedAuthor) Save() error {
 	}
 }
 
-func feedAuthorByName(name string) (*FeedAuthor, error) {
+func feedAuthorByName(s *session, name string) (*FeedAuthor, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			name,
@@ -124,19 +115,12 @@ func feedAuthorByName(name string) (*FeedAuthor, error) {
 		FROM feed_author
 		WHERE name = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(name)
+	rows, err := stmt.QueryContext(s.ctx, name)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -162,9 +146,9 @@ func feedAuthorByName(name string) (*FeedAuthor, error) {
 	}
 }
 
-func authorById(authorId appDatabase.PrimaryKey) (*FeedAuthor, error) {
+func authorById(s *session, authorId appDatabase.PrimaryKey) (*FeedAuthor, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			name,
@@ -172,19 +156,12 @@ func authorById(authorId appDatabase.PrimaryKey) (*FeedAuthor, error) {
 		FROM feed_author
 		WHERE id = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(authorId)
+	rows, err := stmt.QueryContext(s.ctx, authorId)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -32,11 +32,16 @@ func mapCategories(categories []string) []*FeedCategory {
 	return feedCategories
 }
 
-func (f *FeedCategory) Save() error {
+func (f *FeedCategory) save(s *session) error {
 
 	if f.Id == 0 {
 
-		existing, err := feedCategoryByCategory(f.Category)
+		if id, ok := s.savedId("feed_category", f.Category); ok {
+			f.Id = id
+			return nil
+		}
+
+		existing, err := feedCategoryByCategory(s, f.Category)
 		if err != nil {
 			appLog.DebugError(err, "Unable to get category by name")
 			return err
@@ -44,29 +49,22 @@ func (f *FeedCategory) Save() error {
 
 		if existing != nil {
 			f.Id = existing.Id
+			s.rememberId("feed_category", f.Category, f.Id)
 			return nil
 		} else {
 
 			log.Debug("Adding a new feed category")
 
-			sql, err := appDatabase.NormalizedSql(`
+			stmt, err := s.prepareInsert(`
 				INSERT INTO feed_category (category)
 				VALUES (?)
 			`)
-			if err != nil {
-				appLog.DebugError(err, err)
-				return err
-			}
-
-			stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 			if err != nil {
 				appLog.DebugError(err, "Unable to create the statement for feed category update")
 				return err
 			}
-			defer appDatabase.DeferStmtCloseFct(stmt)()
 
-			newId, err := appDatabase.SqlExecGetId(stmt,
+			newId, err := appDatabase.SqlExecGetId(s.ctx, stmt,
 				f.Category,
 			)
 
@@ -75,6 +73,7 @@ func (f *FeedCategory) Save() error {
 				return err
 			} else {
 				f.Id = appDatabase.PrimaryKey(newId)
+				s.rememberId("feed_category", f.Category, f.Id)
 				return nil
 			}
 		}
@@ -83,25 +82,17 @@ func (f *FeedCategory) Save() error {
 
 		log.Debug("Updating a feed category")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			UPDATE feed_category SET
 				category = ?
 			WHERE id = ?
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed category update")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		_, err = appDatabase.SqlExecGetId(stmt,
+		_, err = appDatabase.SqlExecGetId(s.ctx, stmt,
 			f.Category,
 			f.Id,
 		)
@@ -115,30 +106,23 @@ func (f *FeedCategory) Save() error {
 	}
 }
 
-func feedCategoryByCategory(category string) (*FeedCategory, error) {
+func feedCategoryByCategory(s *session, category string) (*FeedCategory, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			category
 		FROM feed_category
 		WHERE category = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
 	v := new(FeedCategory)
 
-	rows, err := stmt.Query(category)
+	rows, err := stmt.QueryContext(s.ctx, category)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -162,9 +146,9 @@ func feedCategoryByCategory(category string) (*FeedCategory, error) {
 	}
 }
 
-func categoriesOfFeedItem(feedItem *FeedItem) ([]*FeedCategory, error) {
+func categoriesOfFeedItem(s *session, feedItem *FeedItem) ([]*FeedCategory, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			category
@@ -173,19 +157,12 @@ func categoriesOfFeedItem(feedItem *FeedItem) ([]*FeedCategory, error) {
 			ON feed_category_item.id_feed_category = feed_category.id
 		WHERE id_feed_item = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(feedItem.Id)
+	rows, err := stmt.QueryContext(s.ctx, feedItem.Id)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -211,9 +188,9 @@ func categoriesOfFeedItem(feedItem *FeedItem) ([]*FeedCategory, error) {
 	return allValues, nil
 }
 
-func categoriesOfFeed(feed *Feed) ([]*FeedCategory, error) {
+func categoriesOfFeed(s *session, feed *Feed) ([]*FeedCategory, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			category
@@ -222,19 +199,12 @@ func categoriesOfFeed(feed *Feed) ([]*FeedCategory, error) {
 			ON feed_category_feed.id_feed_category = feed_category.id
 		WHERE id_feed = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(feed.Id)
+	rows, err := stmt.QueryContext(s.ctx, feed.Id)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
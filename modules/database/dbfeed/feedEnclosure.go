@@ -41,11 +41,16 @@ func mapEnclosures(enclosures []*gofeed.Enclosure) []*FeedEnclosure {
 	return feedEnclosures
 }
 
-func (f *FeedEnclosure) Save() error {
+func (f *FeedEnclosure) save(s *session) error {
 
 	if f.Id == 0 {
 
-		existing, err := feedEnclosureByUrl(f.URL)
+		if id, ok := s.savedId("feed_enclosure", f.URL); ok {
+			f.Id = id
+			return nil
+		}
+
+		existing, err := feedEnclosureByUrl(s, f.URL)
 		if err != nil {
 			appLog.DebugError(err, "Unable to get enclosure by url")
 			return err
@@ -53,29 +58,22 @@ func (f *FeedEnclosure) Save() error {
 
 		if existing != nil {
 			f.Id = existing.Id
+			s.rememberId("feed_enclosure", f.URL, f.Id)
 			return nil
 		} else {
 
 			log.Debug("Adding a new feed enclosure")
 
-			sql, err := appDatabase.NormalizedSql(`
+			stmt, err := s.prepareInsert(`
 				INSERT INTO feed_enclosure (url, length, type)
 				VALUES (?, ?, ?)
 			`)
-			if err != nil {
-				appLog.DebugError(err, err)
-				return err
-			}
-
-			stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 			if err != nil {
 				appLog.DebugError(err, "Unable to create the statement for feed enclosure update")
 				return err
 			}
-			defer appDatabase.DeferStmtCloseFct(stmt)()
 
-			newId, err := appDatabase.SqlExecGetId(stmt,
+			newId, err := appDatabase.SqlExecGetId(s.ctx, stmt,
 				f.URL,
 				f.Length,
 				f.Type,
@@ -86,6 +84,7 @@ func (f *FeedEnclosure) Save() error {
 				return err
 			} else {
 				f.Id = appDatabase.PrimaryKey(newId)
+				s.rememberId("feed_enclosure", f.URL, f.Id)
 				return nil
 			}
 		}
@@ -93,27 +92,19 @@ func (f *FeedEnclosure) Save() error {
 
 		log.Debug("Updating a feed enclosure")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			UPDATE feed_enclosure SET
 				url = ?,
 				length = ?,
 				type = ?
 			WHERE id = ?
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed enclosure update")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		_, err = appDatabase.SqlExecGetId(stmt,
+		_, err = appDatabase.SqlExecGetId(s.ctx, stmt,
 			f.URL,
 			f.Length,
 			f.Type,
@@ -129,9 +120,9 @@ func (f *FeedEnclosure) Save() error {
 	}
 }
 
-func enclosuresOfFeedItem(feedItem *FeedItem) ([]*FeedEnclosure, error) {
+func enclosuresOfFeedItem(s *session, feedItem *FeedItem) ([]*FeedEnclosure, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			url,
@@ -142,19 +133,12 @@ func enclosuresOfFeedItem(feedItem *FeedItem) ([]*FeedEnclosure, error) {
 			ON feed_enclosure_item.id_feed_enclosure = feed_enclosure.id
 		WHERE id_feed_item = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(feedItem.Id)
+	rows, err := stmt.QueryContext(s.ctx, feedItem.Id)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -180,9 +164,9 @@ func enclosuresOfFeedItem(feedItem *FeedItem) ([]*FeedEnclosure, error) {
 	return allValues, nil
 }
 
-func feedEnclosureByUrl(url string) (*FeedEnclosure, error) {
+func feedEnclosureByUrl(s *session, url string) (*FeedEnclosure, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			url,
@@ -191,21 +175,14 @@ func feedEnclosureByUrl(url string) (*FeedEnclosure, error) {
 		FROM feed_enclosure
 		WHERE url = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
 	v := new(FeedEnclosure)
 
-	rows, err := stmt.Query(url)
+	rows, err := stmt.QueryContext(s.ctx, url)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
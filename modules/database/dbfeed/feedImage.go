@@ -28,9 +28,15 @@ func FromImage(image *gofeed.Image) *FeedImage {
 	}
 }
 
-func (f *FeedImage) Save() error {
+func (f *FeedImage) save(s *session) error {
 
-	existingFeedImage, err := imageByUrl(f.URL)
+	// Saved once per session, the first title being kept
+	if id, ok := s.savedId("feed_image", f.URL); ok {
+		f.Id = id
+		return nil
+	}
+
+	existingFeedImage, err := imageByUrl(s, f.URL)
 	if err != nil {
 		appLog.DebugError(err, "Unable to check for feed image existance")
 		return err
@@ -44,24 +50,16 @@ func (f *FeedImage) Save() error {
 
 		log.Debug("Adding a new feed image")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			INSERT INTO feed_image (url, title)
 			VALUES (?, ?)
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed image update")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		newId, err := appDatabase.SqlExecGetId(stmt,
+		newId, err := appDatabase.SqlExecGetId(s.ctx, stmt,
 			f.URL,
 			f.Title,
 		)
@@ -71,6 +69,7 @@ func (f *FeedImage) Save() error {
 			return err
 		} else {
 			f.Id = appDatabase.PrimaryKey(newId)
+			s.rememberId("feed_image", f.URL, f.Id)
 			return nil
 		}
 
@@ -78,26 +77,18 @@ func (f *FeedImage) Save() error {
 
 		log.Debug("Updating a feed image")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			UPDATE feed_image SET
 				url = ?,
 				title = ?
 			WHERE id = ?
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed image update")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		_, err = appDatabase.SqlExecGetId(stmt,
+		_, err = appDatabase.SqlExecGetId(s.ctx, stmt,
 			f.URL,
 			f.Title,
 			f.Id,
@@ -107,14 +98,15 @@ func (f *FeedImage) Save() error {
 			appLog.DebugError(err, fmt.Sprintf("An error occured while updating a feed image (%d)", f.Id))
 			return err
 		} else {
+			s.rememberId("feed_image", f.URL, f.Id)
 			return nil
 		}
 	}
 }
 
-func imageById(imageId appDatabase.PrimaryKey) (*FeedImage, error) {
+func imageById(s *session, imageId appDatabase.PrimaryKey) (*FeedImage, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			url,
@@ -122,19 +114,12 @@ func imageById(imageId appDatabase.PrimaryKey) (*FeedImage, error) {
 		FROM feed_image
 		WHERE id = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(imageId)
+	rows, err := stmt.QueryContext(s.ctx, imageId)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -160,9 +145,9 @@ func imageById(imageId appDatabase.PrimaryKey) (*FeedImage, error) {
 	}
 }
 
-func imageByUrl(url string) (*FeedImage, error) {
+func imageByUrl(s *session, url string) (*FeedImage, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			url,
@@ -170,19 +155,12 @@ func imageByUrl(url string) (*FeedImage, error) {
 		FROM feed_image
 		WHERE url = ?
 	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(url)
+	rows, err := stmt.QueryContext(s.ctx, url)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
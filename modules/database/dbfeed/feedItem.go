@@ -1,6 +1,7 @@
 package dbfeed
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -14,19 +15,28 @@ import (
 )
 
 type FeedItem struct {
-	Id          uint64           `json:"id"`
-	Author      *FeedAuthor      `json:"author"`
-	Image       *FeedImage       `json:"image"`
-	Categories  []*FeedCategory  `json:"categories"`
-	Enclosures  []*FeedEnclosure `json:"enclosures"`
-	Feed        *Feed            `json:"feed"`
-	Title       string           `json:"title"`
-	Description string           `json:"description"`
-	Content     string           `json:"content"`
-	Link        string           `json:"link"`
-	Updated     *time.Time       `json:"updated"`
-	Published   *time.Time       `json:"published"`
-	GUID        string           `json:"guid"`
+	Id             uint64               `json:"id"`
+	Author         *FeedAuthor          `json:"author"`
+	Image          *FeedImage           `json:"image"`
+	Categories     []*FeedCategory      `json:"categories"`
+	Enclosures     []*FeedEnclosure     `json:"enclosures"`
+	Feed           *Feed                `json:"feed"`
+	Title          string               `json:"title"`
+	Description    string               `json:"description"`
+	Content        string               `json:"content"`
+	RawDescription string               `json:"rawDescription,omitempty"`
+	RawContent     string               `json:"rawContent,omitempty"`
+	Link           string               `json:"link"`
+	Updated        *time.Time           `json:"updated"`
+	Published      *time.Time           `json:"published"`
+	GUID           string               `json:"guid"`
+	Read           bool                 `json:"read"`
+	Starred        bool                 `json:"starred"`
+	Tags           []string             `json:"tags"`
+	CanonicalURL   string               `json:"canonicalUrl"`
+	SimHash        uint64               `json:"-"`
+	ClusterId      uint64               `json:"clusterId"`
+	AlsoIn         []*FeedItemReference `json:"alsoIn,omitempty"`
 }
 
 func FromFeedItem(item *gofeed.Item) *FeedItem {
@@ -43,6 +53,7 @@ func FromFeedItem(item *gofeed.Item) *FeedItem {
 		Updated:     item.UpdatedParsed,
 		Published:   item.PublishedParsed,
 		GUID:        item.GUID,
+		Tags:        []string{},
 	}
 }
 
@@ -57,10 +68,56 @@ func mapItems(items []*gofeed.Item) []*FeedItem {
 	return feedItems
 }
 
-func (f *FeedItem) Save() error {
+// Items of a feed saved together, their purge state and stored version being fetched once per chunk
+func saveItems(s *session, feed *Feed, items []*FeedItem) error {
+
+	guids := make([]string, 0, len(items))
+	for _, item := range items {
+		item.Feed = feed
+
+		err := item.Normalize()
+		if err != nil {
+			appLog.DebugError(err, "Unable to normalize item")
+			return err
+		}
+		guids = append(guids, item.GUID)
+	}
+
+	purged, err := purgedGUIDs(s, feed.Id, guids)
+	if err != nil {
+		appLog.DebugError(err, "Unable to check whether the feed items have been purged")
+		return err
+	}
+
+	existingFeedItems, err := feedItemsByGUID(s, guids)
+	if err != nil {
+		appLog.DebugError(err, "Unable to check for feed items existance")
+		return err
+	}
+
+	for _, item := range items {
+
+		if purged[appDatabase.StrWithMaxLength(item.GUID, 700)] {
+			log.Debug(fmt.Sprintf("Feed item [%s] has been purged, skipping", item.GUID))
+			continue
+		}
+
+		err := item.save(s, existingFeedItems[item.GUID])
+		if err != nil {
+			return err
+		}
+
+		// Later items of the feed sharing the GUID update this one
+		existingFeedItems[item.GUID] = item
+	}
+
+	return nil
+}
+
+func (f *FeedItem) save(s *session, existingFeedItem *FeedItem) error {
 
 	if f.Author != nil {
-		err := f.Author.Save()
+		err := f.Author.save(s)
 		if err != nil {
 			appLog.DebugError(err, "Unable to save a feed author")
 			return err
@@ -68,7 +125,7 @@ func (f *FeedItem) Save() error {
 	}
 
 	if f.Image != nil {
-		err := f.Image.Save()
+		err := f.Image.save(s)
 		if err != nil {
 			appLog.DebugError(err, "Unable to save a feed image")
 			return err
@@ -77,7 +134,7 @@ func (f *FeedItem) Save() error {
 
 	if len(f.Categories) > 0 {
 		for _, category := range f.Categories {
-			err := category.Save()
+			err := category.save(s)
 			if err != nil {
 				appLog.DebugError(err, "Unable to save a feed cateogry")
 				return err
@@ -87,7 +144,7 @@ func (f *FeedItem) Save() error {
 
 	if len(f.Enclosures) > 0 {
 		for _, enclosure := range f.Enclosures {
-			err := enclosure.Save()
+			err := enclosure.save(s)
 			if err != nil {
 				appLog.DebugError(err, "Unable to save a feed enclosure")
 				return err
@@ -95,54 +152,58 @@ func (f *FeedItem) Save() error {
 		}
 	}
 
-	err := f.Normalize()
-	if err != nil {
-		appLog.DebugError(err, "Unable to normalize item")
-		return err
-	}
-
-	existingFeedItem, err := feedItemByGUID(f.GUID)
-	if err != nil {
-		appLog.DebugError(err, "Unable to check for feed item existance")
-		return err
-	}
+	f.computeFingerprints(existingFeedItem)
+	bands := f.simHashBands()
 
 	if existingFeedItem != nil {
 		f.Id = existingFeedItem.Id
+		f.ClusterId = existingFeedItem.ClusterId
+
+		// Rules only ever set the flags, readers keeping theirs as user states
+		f.Read = f.Read || existingFeedItem.Read
+		f.Starred = f.Starred || existingFeedItem.Starred
 	}
 
-	if f.Id == 0 {
+	unchanged := existingFeedItem != nil && f.storedAs(existingFeedItem)
 
-		log.Debug("Adding a new feed item")
+	if unchanged {
 
-		sql, err := appDatabase.NormalizedSql(`
-			INSERT INTO feed_item (id_feed, id_author, id_image, title, description, content, link, updated, published, guid)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
+		log.Debug(fmt.Sprintf("Feed item (%d) is unchanged", f.Id))
+
+	} else if f.Id == 0 {
 
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
+		log.Debug("Adding a new feed item")
 
+		stmt, err := s.prepareInsert(`
+			INSERT INTO feed_item (id_feed, id_author, id_image, title, description, content, raw_description, raw_content, link, updated, published, guid, is_read, is_starred, canonical_url, simhash, simhash_band0, simhash_band1, simhash_band2, simhash_band3)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`)
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed item creation")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		newId, err := appDatabase.SqlExecGetId(stmt,
+		newId, err := appDatabase.SqlExecGetId(s.ctx, stmt,
 			appDatabase.EntityId(f.Feed),
 			appDatabase.EntityId(f.Author),
 			appDatabase.EntityId(f.Image),
 			f.Title,
 			f.Description,
 			f.Content,
+			f.RawDescription,
+			f.RawContent,
 			f.Link,
 			f.Updated,
 			f.Published,
 			f.GUID,
+			f.Read,
+			f.Starred,
+			appDatabase.StrWithMaxLength(f.CanonicalURL, 700),
+			int64(f.SimHash),
+			bands[0],
+			bands[1],
+			bands[2],
+			bands[3],
 		)
 
 		if err != nil {
@@ -151,12 +212,14 @@ func (f *FeedItem) Save() error {
 		} else {
 			f.Id = appDatabase.PrimaryKey(newId)
 		}
+		s.countSavedItem(f, itemOperationInserted)
+		s.recordChange(ChangeItemInserted, f.Feed, f)
 
 	} else {
 
 		log.Debug("Updating a feed item")
 
-		sql, err := appDatabase.NormalizedSql(`
+		stmt, err := s.prepareInsert(`
 			UPDATE feed_item SET
 				id_feed = ?,
 				id_author = ?,
@@ -164,36 +227,48 @@ func (f *FeedItem) Save() error {
 				title = ?,
 				description = ?,
 				content = ?,
+				raw_description = ?,
+				raw_content = ?,
 				link = ?,
 				updated = ?,
 				published = ?,
-				guid = ?
+				guid = ?,
+				is_read = ?,
+				is_starred = ?,
+				canonical_url = ?,
+				simhash = ?,
+				simhash_band0 = ?,
+				simhash_band1 = ?,
+				simhash_band2 = ?,
+				simhash_band3 = ?
 			WHERE id = ?
 		`)
-		if err != nil {
-			appLog.DebugError(err, err)
-			return err
-		}
-
-		stmt, err := database.Prepare(appDatabase.PrepareExecSQL(sql))
-
 		if err != nil {
 			appLog.DebugError(err, "Unable to create the statement for feed item creation")
 			return err
 		}
-		defer appDatabase.DeferStmtCloseFct(stmt)()
 
-		_, err = appDatabase.SqlExecGetId(stmt,
+		_, err = appDatabase.SqlExecGetId(s.ctx, stmt,
 			appDatabase.EntityId(f.Feed),
 			appDatabase.EntityId(f.Author),
 			appDatabase.EntityId(f.Image),
 			f.Title,
 			f.Description,
 			f.Content,
+			f.RawDescription,
+			f.RawContent,
 			f.Link,
 			f.Updated,
 			f.Published,
 			f.GUID,
+			f.Read,
+			f.Starred,
+			appDatabase.StrWithMaxLength(f.CanonicalURL, 700),
+			int64(f.SimHash),
+			bands[0],
+			bands[1],
+			bands[2],
+			bands[3],
 			f.Id,
 		)
 
@@ -201,12 +276,29 @@ func (f *FeedItem) Save() error {
 			appLog.DebugError(err, fmt.Sprintf("An error occured while updating a feed item (%d)", f.Id))
 			return err
 		}
+		s.countSavedItem(f, itemOperationUpdated)
+	}
+
+	if !unchanged {
+		err := f.saveRevision(s, existingFeedItem)
+		if err != nil {
+			appLog.DebugError(err, "Unable to save the feed item revision")
+			return err
+		}
+	}
+
+	if f.ClusterId == 0 {
+		err := f.assignCluster(s)
+		if err != nil {
+			appLog.DebugError(err, "Unable to assign the feed item to a cluster")
+			return err
+		}
 	}
 
 	log.Debug("Linking feed item to its categories")
-	if len(f.Enclosures) > 0 {
+	if len(f.Categories) > 0 {
 		for _, category := range f.Categories {
-			err := linkFeedCategoryToFeedItem(category, f)
+			err := linkFeedCategoryToFeedItem(s, category, f)
 			if err != nil {
 				return err
 			}
@@ -216,13 +308,21 @@ func (f *FeedItem) Save() error {
 	log.Debug("Linking feed item to its enclosure")
 	if len(f.Enclosures) > 0 {
 		for _, enclosure := range f.Enclosures {
-			err := linkFeedEnclosureToFeedItem(enclosure, f)
+			err := linkFeedEnclosureToFeedItem(s, enclosure, f)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	log.Debug("Linking feed item to its tags")
+	for _, tag := range f.Tags {
+		err := linkTagToFeedItem(s, tag, f)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -243,36 +343,86 @@ func (f *FeedItem) Normalize() error {
 	return nil
 }
 
-func feedItemByGUID(guid string) (*FeedItem, error) {
+// Whether saving the item would leave its stored row as is
+func (f *FeedItem) storedAs(existing *FeedItem) bool {
+	return appDatabase.EntityId(f.Feed) == appDatabase.EntityId(existing.Feed) &&
+		appDatabase.EntityId(f.Author) == appDatabase.EntityId(existing.Author) &&
+		appDatabase.EntityId(f.Image) == appDatabase.EntityId(existing.Image) &&
+		f.Title == existing.Title &&
+		f.Description == existing.Description &&
+		f.Content == existing.Content &&
+		f.RawDescription == existing.RawDescription &&
+		f.RawContent == existing.RawContent &&
+		f.Link == existing.Link &&
+		sameStoredTime(f.Updated, existing.Updated) &&
+		sameStoredTime(f.Published, existing.Published) &&
+		f.GUID == existing.GUID &&
+		f.Read == existing.Read &&
+		f.Starred == existing.Starred &&
+		appDatabase.StrWithMaxLength(f.CanonicalURL, 700) == existing.CanonicalURL &&
+		f.SimHash == existing.SimHash
+}
+
+// Dates are compared to the second, the precision kept by every driver
+func sameStoredTime(value *time.Time, stored *time.Time) bool {
+	if value == nil || stored == nil {
+		return value == nil && stored == nil
+	}
+	return value.Truncate(time.Second).Equal(stored.Truncate(time.Second))
+}
+
+func feedItemByGUID(s *session, guid string) (*FeedItem, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	items, err := feedItemsByGUID(s, []string{guid})
+	if err != nil {
+		return nil, err
+	}
+	return items[guid], nil
+}
+
+// Stored items of the given GUIDs, by GUID
+func feedItemsByGUID(s *session, guids []string) (map[string]*FeedItem, error) {
+
+	items := make(map[string]*FeedItem, len(guids))
+	if len(guids) == 0 {
+		return items, nil
+	}
+
+	stmt, err := s.prepare(`
 		SELECT
 			id,
+			id_feed,
 			id_author,
 			id_image,
 			title,
 			description,
 			content,
+			COALESCE(raw_description, ''),
+			COALESCE(raw_content, ''),
 			link,
 			updated,
 			published,
-			guid
+			guid,
+			is_read,
+			is_starred,
+			COALESCE(canonical_url, ''),
+			COALESCE(simhash, 0),
+			COALESCE(id_cluster, 0)
 		FROM feed_item
-		WHERE guid = ?
+		WHERE guid IN ` + appDatabase.InPlaceholders(len(guids)) + `
+		ORDER BY id
 	`)
 	if err != nil {
-		appLog.DebugError(err, err)
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
 		return nil, err
 	}
 
-	stmt, err := database.Prepare(sql)
-	if err != nil {
-		appLog.DebugError(err, "Unable to prepare SELECT statement")
-		return nil, err
+	args := make([]interface{}, 0, len(guids))
+	for _, guid := range guids {
+		args = append(args, guid)
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(guid)
+	rows, err := stmt.QueryContext(s.ctx, args...)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -283,27 +433,47 @@ func feedItemByGUID(guid string) (*FeedItem, error) {
 	}
 	defer appDatabase.DeferRowsCloseFct(rows)()
 
-	if rows.Next() {
-		var authorId, imageId *appDatabase.PrimaryKey
+	for rows.Next() {
+		var feedId, authorId, imageId *appDatabase.PrimaryKey
 		var updatedRawValue, publishedRawValue interface{}
+		var simHash int64
 		result := new(FeedItem)
 
 		err = rows.Scan(
 			&result.Id,
+			&feedId,
 			&authorId,
 			&imageId,
 			&result.Title,
 			&result.Description,
 			&result.Content,
+			&result.RawDescription,
+			&result.RawContent,
 			&result.Link,
 			&updatedRawValue,
 			&publishedRawValue,
 			&result.GUID,
+			&result.Read,
+			&result.Starred,
+			&result.CanonicalURL,
+			&simHash,
+			&result.ClusterId,
 		)
 		if err != nil {
 			appLog.DebugError(err, "Unable to affect results")
 			return nil, err
 		}
+		result.SimHash = uint64(simHash)
+
+		if feedId != nil {
+			result.Feed = &Feed{Id: *feedId}
+		}
+		if authorId != nil {
+			result.Author = &FeedAuthor{Id: *authorId}
+		}
+		if imageId != nil {
+			result.Image = &FeedImage{Id: *imageId}
+		}
 
 		result.Updated, err = appDatabase.SqlDateParse(updatedRawValue)
 		if err != nil {
@@ -317,51 +487,62 @@ func feedItemByGUID(guid string) (*FeedItem, error) {
 			return nil, err
 		}
 
-		if authorId != nil {
-			result.Author, err = authorById(*authorId)
-			if err != nil {
-				appLog.DebugError(err, "Unable to fetch feed item author")
-				return nil, err
-			}
-		} else {
-			result.Author = nil
+		// The oldest item is kept when several share a GUID
+		if _, ok := items[result.GUID]; !ok {
+			items[result.GUID] = result
 		}
+	}
 
-		if imageId != nil {
-			result.Image, err = imageById(*imageId)
-			if err != nil {
-				appLog.DebugError(err, "Unable to fetch feed item image")
-				return nil, err
-			}
-		} else {
-			result.Image = nil
+	return items, nil
+}
+
+func itemsOfFeed(s *session, feed *Feed) ([]*FeedItem, error) {
+	return feedItemsOf(s, feed.Id)
+}
+
+func (store *SQLFeedStore) GetFeedItems(ctx context.Context, feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	return feedItemsOf(s, feedId)
+}
+
+func feedItemsOf(s *session, feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
+	return queryFeedItems(s, "id_feed = ?", feedId)
+}
+
+// Items are returned in id order, unknown ids being left out
+func (store *SQLFeedStore) GetFeedItemsByIds(ctx context.Context, itemIds []appDatabase.PrimaryKey) ([]*FeedItem, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	items := make([]*FeedItem, 0, len(itemIds))
+	for start := 0; start < len(itemIds); start += itemBatchSize {
+
+		end := start + itemBatchSize
+		if end > len(itemIds) {
+			end = len(itemIds)
 		}
 
-		result.Categories, err = categoriesOfFeedItem(result)
-		if err != nil {
-			appLog.DebugError(err, "Unable to fetch feed item categories")
-			return nil, err
+		args := make([]interface{}, 0, end-start)
+		for _, itemId := range itemIds[start:end] {
+			args = append(args, itemId)
 		}
 
-		result.Enclosures, err = enclosuresOfFeedItem(result)
+		chunk, err := queryFeedItems(s, "id IN "+appDatabase.InPlaceholders(len(args))+" ORDER BY id", args...)
 		if err != nil {
-			appLog.DebugError(err, "Unable to fetch feed item enclosure")
 			return nil, err
 		}
-
-		return result, nil
-	} else {
-		return nil, nil
+		items = append(items, chunk...)
 	}
+	return items, nil
 }
 
-func itemsOfFeed(feed *Feed) ([]*FeedItem, error) {
-	return GetFeedItems(feed.Id)
-}
-
-func GetFeedItems(feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
+func queryFeedItems(s *session, where string, args ...interface{}) ([]*FeedItem, error) {
 
-	sql, err := appDatabase.NormalizedSql(`
+	stmt, err := s.prepare(`
 		SELECT
 			id,
 			id_author,
@@ -369,26 +550,25 @@ func GetFeedItems(feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
 			title,
 			description,
 			content,
+			COALESCE(raw_description, ''),
+			COALESCE(raw_content, ''),
 			link,
 			updated,
 			published,
-			guid
+			guid,
+			is_read,
+			is_starred,
+			COALESCE(canonical_url, ''),
+			COALESCE(simhash, 0),
+			COALESCE(id_cluster, 0)
 		FROM feed_item
-		WHERE id_feed = ?
-	`)
-	if err != nil {
-		appLog.DebugError(err, err)
-		return nil, err
-	}
-
-	stmt, err := database.Prepare(sql)
+		WHERE ` + where)
 	if err != nil {
 		appLog.DebugError(err, "Unable to prepare SELECT statement")
 		return nil, err
 	}
-	defer appDatabase.DeferStmtCloseFct(stmt)()
 
-	rows, err := stmt.Query(feedId)
+	rows, err := stmt.QueryContext(s.ctx, args...)
 	if err != nil {
 		appLog.DebugError(err, "Unable to get result row")
 		return nil, err
@@ -403,6 +583,7 @@ func GetFeedItems(feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
 	for rows.Next() {
 		var authorId, imageId *appDatabase.PrimaryKey
 		var updatedRawValue, publishedRawValue interface{}
+		var simHash int64
 		v := new(FeedItem)
 
 		err = rows.Scan(
@@ -412,15 +593,23 @@ func GetFeedItems(feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
 			&v.Title,
 			&v.Description,
 			&v.Content,
+			&v.RawDescription,
+			&v.RawContent,
 			&v.Link,
 			&updatedRawValue,
 			&publishedRawValue,
 			&v.GUID,
+			&v.Read,
+			&v.Starred,
+			&v.CanonicalURL,
+			&simHash,
+			&v.ClusterId,
 		)
 		if err != nil {
 			appLog.DebugError(err, "Unable to affect results")
 			return nil, err
 		}
+		v.SimHash = uint64(simHash)
 
 		v.Updated, err = appDatabase.SqlDateParse(updatedRawValue)
 		if err != nil {
@@ -434,39 +623,57 @@ func GetFeedItems(feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
 			return nil, err
 		}
 
-		if authorId != nil {
-			v.Author, err = authorById(*authorId)
-			if err != nil {
-				appLog.DebugError(err, "Unable to fetch feed item author")
-				return nil, err
-			}
-		} else {
-			v.Author = nil
+		err = v.loadRelations(s, authorId, imageId)
+		if err != nil {
+			return nil, err
 		}
 
-		if imageId != nil {
-			v.Image, err = imageById(*imageId)
-			if err != nil {
-				appLog.DebugError(err, "Unable to fetch feed item image")
-				return nil, err
-			}
-		} else {
-			v.Image = nil
-		}
+		allValues = append(allValues, v)
+	}
+	return allValues, nil
+}
+
+func (f *FeedItem) loadRelations(s *session, authorId *appDatabase.PrimaryKey, imageId *appDatabase.PrimaryKey) error {
+
+	var err error
 
-		v.Categories, err = categoriesOfFeedItem(v)
+	if authorId != nil {
+		f.Author, err = authorById(s, *authorId)
 		if err != nil {
-			appLog.DebugError(err, "Unable to fetch feed item categories")
-			return nil, err
+			appLog.DebugError(err, "Unable to fetch feed item author")
+			return err
 		}
+	} else {
+		f.Author = nil
+	}
 
-		v.Enclosures, err = enclosuresOfFeedItem(v)
+	if imageId != nil {
+		f.Image, err = imageById(s, *imageId)
 		if err != nil {
-			appLog.DebugError(err, "Unable to fetch feed item enclosure")
-			return nil, err
+			appLog.DebugError(err, "Unable to fetch feed item image")
+			return err
 		}
+	} else {
+		f.Image = nil
+	}
 
-		allValues = append(allValues, v)
+	f.Categories, err = categoriesOfFeedItem(s, f)
+	if err != nil {
+		appLog.DebugError(err, "Unable to fetch feed item categories")
+		return err
 	}
-	return allValues, nil
+
+	f.Enclosures, err = enclosuresOfFeedItem(s, f)
+	if err != nil {
+		appLog.DebugError(err, "Unable to fetch feed item enclosure")
+		return err
+	}
+
+	f.Tags, err = tagsOfFeedItem(s, f)
+	if err != nil {
+		appLog.DebugError(err, "Unable to fetch feed item tags")
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,305 @@
+package dbfeed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dademo/rssreader/modules/config"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/dedup"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type FeedItemReference struct {
+	Id        uint64 `json:"id"`
+	FeedId    uint64 `json:"feedId"`
+	FeedTitle string `json:"feedTitle"`
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+}
+
+var duplicatesConfig *config.DuplicatesConfig
+
+func ConfigureDuplicates(config *config.DuplicatesConfig) {
+	duplicatesConfig = config
+}
+
+func (store *SQLFeedStore) CollapseClusters(ctx context.Context, items []*FeedItem) ([]*FeedItem, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	collapsed := make([]*FeedItem, 0, len(items))
+	seenClusters := map[uint64]bool{}
+
+	for _, item := range items {
+
+		if item.ClusterId == 0 {
+			collapsed = append(collapsed, item)
+			continue
+		}
+
+		if seenClusters[item.ClusterId] {
+			continue
+		}
+		seenClusters[item.ClusterId] = true
+
+		members, err := clusterMembers(s, item.ClusterId)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to fetch members of cluster (%d)", item.ClusterId))
+			return nil, err
+		}
+
+		item.AlsoIn = make([]*FeedItemReference, 0, len(members))
+		for _, member := range members {
+			if member.Id != item.Id {
+				item.AlsoIn = append(item.AlsoIn, member)
+			}
+		}
+
+		collapsed = append(collapsed, item)
+	}
+
+	return collapsed, nil
+}
+
+// Stored fingerprints are kept while duplicates are not detected
+func (f *FeedItem) computeFingerprints(existing *FeedItem) {
+
+	if duplicatesConfig == nil || !duplicatesConfig.Enabled {
+		if existing != nil {
+			f.CanonicalURL = existing.CanonicalURL
+			f.SimHash = existing.SimHash
+		}
+		return
+	}
+
+	f.CanonicalURL = dedup.CanonicalURL(f.Link)
+	f.SimHash = dedup.SimHash(f.Title, f.Description, f.Content)
+}
+
+// Bands are left empty for items without SimHash
+func (f *FeedItem) simHashBands() [dedup.SimHashBandCount]interface{} {
+
+	var values [dedup.SimHashBandCount]interface{}
+	if f.SimHash == 0 {
+		return values
+	}
+
+	for band, value := range dedup.SimHashBands(f.SimHash) {
+		values[band] = value
+	}
+	return values
+}
+
+func (f *FeedItem) assignCluster(s *session) error {
+
+	if duplicatesConfig == nil || !duplicatesConfig.Enabled {
+		return nil
+	}
+
+	clusterId, err := clusterByCanonicalURL(s, f)
+	if err != nil {
+		return err
+	}
+
+	if clusterId == 0 && f.SimHash != 0 {
+		clusterId, err = clusterBySimHash(s, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if clusterId == 0 {
+		// First of its kind
+		clusterId = f.Id
+	} else {
+		log.Debug(fmt.Sprintf("Feed item [%s] is a duplicate in cluster (%d)", f.Title, clusterId))
+	}
+
+	stmt, err := s.prepare(`
+		UPDATE feed_item SET
+			id_cluster = ?
+		WHERE id = ?
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare UPDATE statement")
+		return err
+	}
+
+	err = appDatabase.SqlExec(s.ctx, stmt, clusterId, f.Id)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("An error occured while updating the cluster of a feed item (%d)", f.Id))
+		return err
+	}
+
+	f.ClusterId = clusterId
+	return nil
+}
+
+func clusterByCanonicalURL(s *session, item *FeedItem) (appDatabase.PrimaryKey, error) {
+
+	if item.CanonicalURL == "" {
+		return 0, nil
+	}
+
+	stmt, err := s.prepare(`
+		SELECT
+			COALESCE(id_cluster, id)
+		FROM feed_item
+		WHERE
+			    canonical_url = ?
+			AND id <> ?
+		ORDER BY id
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return 0, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, appDatabase.StrWithMaxLength(item.CanonicalURL, 700), item.Id)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return 0, err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return 0, rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	if rows.Next() {
+		var clusterId appDatabase.PrimaryKey
+		err = rows.Scan(&clusterId)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return 0, err
+		}
+		return clusterId, nil
+	}
+	return 0, nil
+}
+
+// Candidates share a band of the SimHash, which every item close enough does. Larger distances are looked for among
+// the latest items.
+func clusterBySimHash(s *session, item *FeedItem) (appDatabase.PrimaryKey, error) {
+
+	var stmt *sql.Stmt
+	var args []interface{}
+	var err error
+
+	if duplicatesConfig.MaxDistance < dedup.SimHashBandCount {
+		stmt, err = s.prepare(`
+			SELECT
+				COALESCE(id_cluster, id),
+				simhash
+			FROM feed_item
+			WHERE
+				    (simhash_band0 = ? OR simhash_band1 = ? OR simhash_band2 = ? OR simhash_band3 = ?)
+				AND id <> ?
+			ORDER BY id DESC
+		`)
+		bands := item.simHashBands()
+		args = append(bands[:], item.Id)
+	} else {
+		stmt, err = s.prepare(`
+			SELECT
+				COALESCE(id_cluster, id),
+				simhash
+			FROM feed_item
+			WHERE
+				    simhash IS NOT NULL
+				AND simhash <> 0
+				AND id <> ?
+			ORDER BY id DESC
+			LIMIT ?
+		`)
+		args = []interface{}{item.Id, duplicatesConfig.CandidateWindow}
+	}
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return 0, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return 0, err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return 0, rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	bestClusterId := appDatabase.PrimaryKey(0)
+	bestDistance := duplicatesConfig.MaxDistance + 1
+
+	for rows.Next() {
+		var clusterId appDatabase.PrimaryKey
+		var simHash int64
+
+		err = rows.Scan(&clusterId, &simHash)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return 0, err
+		}
+
+		distance := dedup.HammingDistance(item.SimHash, uint64(simHash))
+		if distance < bestDistance {
+			bestDistance = distance
+			bestClusterId = clusterId
+		}
+	}
+
+	return bestClusterId, nil
+}
+
+func clusterMembers(s *session, clusterId appDatabase.PrimaryKey) ([]*FeedItemReference, error) {
+
+	stmt, err := s.prepare(`
+		SELECT
+			feed_item.id,
+			feed.id,
+			feed.title,
+			feed_item.title,
+			feed_item.link
+		FROM feed_item
+		INNER JOIN feed
+			ON feed.id = feed_item.id_feed
+		WHERE feed_item.id_cluster = ?
+		ORDER BY feed_item.id
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, clusterId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	allValues := make([]*FeedItemReference, 0)
+	for rows.Next() {
+
+		v := new(FeedItemReference)
+		err = rows.Scan(&v.Id, &v.FeedId, &v.FeedTitle, &v.Title, &v.Link)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		} else {
+			allValues = append(allValues, v)
+		}
+	}
+	return allValues, nil
+}
@@ -0,0 +1,198 @@
+package dbfeed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type FeedItemRevision struct {
+	Id          uint64     `json:"id"`
+	FeedItemId  uint64     `json:"itemId"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Content     string     `json:"content"`
+	ContentHash string     `json:"contentHash"`
+	Created     *time.Time `json:"created"`
+}
+
+func (f *FeedItem) ContentHash() string {
+	hasher := sha256.New()
+	for _, value := range []string{f.Title, f.Description, f.Content} {
+		hasher.Write([]byte(value))
+		// Separator, to distinguish moved text between fields
+		hasher.Write([]byte{0})
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func (f *FeedItem) saveRevision(s *session, previous *FeedItem) error {
+
+	if f.Id == 0 {
+		return errors.New("You must provide a saved feed item")
+	}
+
+	latestHash, err := latestRevisionHash(s, f.Id)
+	if err != nil {
+		return err
+	}
+
+	if latestHash == "" && previous != nil {
+		// Item saved before revisions were tracked, keeping its previous state
+		previousHash := previous.ContentHash()
+		if previousHash != f.ContentHash() {
+			err = insertRevision(s, f.Id, previous, previousHash)
+			if err != nil {
+				return err
+			}
+			latestHash = previousHash
+		}
+	}
+
+	contentHash := f.ContentHash()
+	if contentHash == latestHash {
+		return nil
+	}
+
+	if previous != nil {
+		s.recordChange(ChangeItemUpdated, f.Feed, f)
+	}
+
+	log.Debug(fmt.Sprintf("Adding a revision to feed item (%d)", f.Id))
+	return insertRevision(s, f.Id, f, contentHash)
+}
+
+func insertRevision(s *session, itemId appDatabase.PrimaryKey, item *FeedItem, contentHash string) error {
+
+	stmt, err := s.prepare(`
+		INSERT INTO feed_item_revision (id_feed_item, title, description, content, content_hash, created)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare INSERT statement")
+		return err
+	}
+
+	err = appDatabase.SqlExec(s.ctx, stmt,
+		itemId,
+		item.Title,
+		item.Description,
+		item.Content,
+		contentHash,
+		time.Now(),
+	)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("An error occured while saving a revision of feed item (%d)", itemId))
+		return err
+	}
+	return nil
+}
+
+func latestRevisionHash(s *session, itemId appDatabase.PrimaryKey) (string, error) {
+
+	stmt, err := s.prepare(`
+		SELECT
+			content_hash
+		FROM feed_item_revision
+		WHERE id_feed_item = ?
+		ORDER BY id DESC
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return "", err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, itemId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return "", err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return "", rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	if rows.Next() {
+		var contentHash string
+		err = rows.Scan(&contentHash)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return "", err
+		}
+		return contentHash, nil
+	}
+	return "", nil
+}
+
+func (store *SQLFeedStore) GetFeedItemRevisions(ctx context.Context, itemId appDatabase.PrimaryKey) ([]*FeedItemRevision, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	stmt, err := s.prepare(`
+		SELECT
+			id,
+			id_feed_item,
+			COALESCE(title, ''),
+			COALESCE(description, ''),
+			COALESCE(content, ''),
+			content_hash,
+			created
+		FROM feed_item_revision
+		WHERE id_feed_item = ?
+		ORDER BY id
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, itemId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	allValues := make([]*FeedItemRevision, 0)
+	for rows.Next() {
+		var createdRawValue interface{}
+		v := new(FeedItemRevision)
+
+		err = rows.Scan(
+			&v.Id,
+			&v.FeedItemId,
+			&v.Title,
+			&v.Description,
+			&v.Content,
+			&v.ContentHash,
+			&createdRawValue,
+		)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		v.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse created date")
+			return nil, err
+		}
+
+		allValues = append(allValues, v)
+	}
+	return allValues, nil
+}
@@ -0,0 +1,58 @@
+package dbfeed
+
+import (
+	"errors"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+func linkTagToFeedItem(s *session, tag string, item *FeedItem) error {
+
+	if item.Id == 0 {
+		return errors.New("You must provide a saved feed item")
+	}
+
+	s.queueLink(feedItemTagLink, item.Id, appDatabase.StrWithMaxLength(tag, 200))
+	return nil
+}
+
+func tagsOfFeedItem(s *session, feedItem *FeedItem) ([]string, error) {
+
+	stmt, err := s.prepare(`
+		SELECT
+			tag
+		FROM feed_item_tag
+		WHERE id_feed_item = ?
+		ORDER BY tag
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, feedItem.Id)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	allValues := make([]string, 0)
+	for rows.Next() {
+
+		var tag string
+		err = rows.Scan(&tag)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		} else {
+			allValues = append(allValues, tag)
+		}
+	}
+	return allValues, nil
+}
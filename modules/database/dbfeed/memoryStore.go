@@ -0,0 +1,569 @@
+package dbfeed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/dedup"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Keeps feeds in memory only, values are copied in and out so callers may alter them
+type MemoryFeedStore struct {
+	lock           sync.RWMutex
+	lastFeedId     appDatabase.PrimaryKey
+	lastItemId     appDatabase.PrimaryKey
+	lastRevisionId appDatabase.PrimaryKey
+	feeds          []*Feed
+	items          []*FeedItem
+	revisions      map[appDatabase.PrimaryKey][]*FeedItemRevision
+	purged         map[purgedItem]time.Time
+}
+
+// Purged GUID of a feed
+type purgedItem struct {
+	feedId appDatabase.PrimaryKey
+	guid   string
+}
+
+func NewMemoryFeedStore() *MemoryFeedStore {
+	return &MemoryFeedStore{
+		feeds:     make([]*Feed, 0),
+		items:     make([]*FeedItem, 0),
+		revisions: map[appDatabase.PrimaryKey][]*FeedItemRevision{},
+		purged:    map[purgedItem]time.Time{},
+	}
+}
+
+func (store *MemoryFeedStore) SaveFeed(ctx context.Context, feed *Feed) error {
+
+	changes, err := store.saveFeed(ctx, feed)
+	if err != nil {
+		return err
+	}
+
+	notifyChanges(ctx, changes)
+	return nil
+}
+
+func (store *MemoryFeedStore) saveFeed(ctx context.Context, feed *Feed) ([]*Change, error) {
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	log.Debug("Saving a feed")
+
+	changes := make([]*Change, 0)
+
+	stored := copyFeed(feed)
+	stored.Items = nil
+	stored.LastUpdate = timeRef(time.Now())
+
+	if existing := store.storedFeed(feed); existing != nil {
+		if feed.metadataChanged(existing) {
+			changes = append(changes, &Change{Type: ChangeFeedUpdated, Feed: feed})
+		}
+		feed.Id = existing.Id
+		stored.Id = existing.Id
+		*existing = *stored
+		stored = existing
+	} else {
+		store.lastFeedId++
+		feed.Id = store.lastFeedId
+		stored.Id = store.lastFeedId
+		store.feeds = append(store.feeds, stored)
+		changes = append(changes, &Change{Type: ChangeFeedCreated, Feed: feed})
+	}
+
+	for _, item := range feed.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		item.Feed = feed
+		change, err := store.saveItem(item, stored)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to save feed item [%s]", item.GUID))
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// Returns the change made to the item, nil when it is unchanged
+func (store *MemoryFeedStore) saveItem(item *FeedItem, feed *Feed) (*Change, error) {
+
+	err := item.Normalize()
+	if err != nil {
+		appLog.DebugError(err, "Unable to normalize item")
+		return nil, err
+	}
+
+	purged := purgedItem{feedId: feed.Id, guid: item.GUID}
+	if _, ok := store.purged[purged]; ok {
+		store.purged[purged] = time.Now()
+		log.Debug(fmt.Sprintf("Feed item [%s] has been purged, skipping", item.GUID))
+		return nil, nil
+	}
+
+	existing := store.itemByGUID(item.GUID)
+	item.computeFingerprints(existing)
+
+	stored := copyFeedItem(item)
+	stored.Feed = feed
+
+	if existing != nil {
+		item.Id = existing.Id
+		item.ClusterId = existing.ClusterId
+
+		stored.Id = existing.Id
+		stored.ClusterId = existing.ClusterId
+		// Rules only ever set the flags, changes of the reader being kept otherwise
+		stored.Read = existing.Read || item.Read
+		stored.Starred = existing.Starred || item.Starred
+		stored.Tags = unionTags(existing.Tags, stored.Tags)
+	} else {
+		store.lastItemId++
+		item.Id = store.lastItemId
+		stored.Id = store.lastItemId
+	}
+
+	revised := store.saveRevision(stored, existing)
+
+	if stored.ClusterId == 0 {
+		stored.ClusterId = store.clusterOf(stored)
+		item.ClusterId = stored.ClusterId
+	}
+
+	if existing != nil {
+		*existing = *stored
+		savedItemsCounter.Inc(feed.ConfigName, itemOperationUpdated)
+		if revised {
+			return &Change{Type: ChangeItemUpdated, Feed: item.Feed, Item: item}, nil
+		}
+		return nil, nil
+	}
+
+	store.items = append(store.items, stored)
+	savedItemsCounter.Inc(feed.ConfigName, itemOperationInserted)
+	return &Change{Type: ChangeItemInserted, Feed: item.Feed, Item: item}, nil
+}
+
+// Returns whether a revision was added
+func (store *MemoryFeedStore) saveRevision(item *FeedItem, previous *FeedItem) bool {
+
+	latestHash := ""
+	if revisions := store.revisions[item.Id]; len(revisions) > 0 {
+		latestHash = revisions[len(revisions)-1].ContentHash
+	}
+
+	if latestHash == "" && previous != nil {
+		previousHash := previous.ContentHash()
+		if previousHash != item.ContentHash() {
+			store.addRevision(item.Id, previous, previousHash)
+			latestHash = previousHash
+		}
+	}
+
+	contentHash := item.ContentHash()
+	if contentHash == latestHash {
+		return false
+	}
+
+	store.addRevision(item.Id, item, contentHash)
+	return true
+}
+
+func (store *MemoryFeedStore) addRevision(itemId appDatabase.PrimaryKey, item *FeedItem, contentHash string) {
+
+	store.lastRevisionId++
+	store.revisions[itemId] = append(store.revisions[itemId], &FeedItemRevision{
+		Id:          store.lastRevisionId,
+		FeedItemId:  itemId,
+		Title:       item.Title,
+		Description: item.Description,
+		Content:     item.Content,
+		ContentHash: contentHash,
+		Created:     timeRef(time.Now()),
+	})
+}
+
+func (store *MemoryFeedStore) clusterOf(item *FeedItem) appDatabase.PrimaryKey {
+
+	if duplicatesConfig == nil || !duplicatesConfig.Enabled {
+		return 0
+	}
+
+	if item.CanonicalURL != "" {
+		for _, other := range store.items {
+			if other.Id != item.Id && other.CanonicalURL == item.CanonicalURL {
+				return clusterIdOf(other)
+			}
+		}
+	}
+
+	if item.SimHash != 0 {
+		bestClusterId := appDatabase.PrimaryKey(0)
+		bestDistance := duplicatesConfig.MaxDistance + 1
+		candidates := 0
+
+		for it := len(store.items) - 1; it >= 0 && candidates < duplicatesConfig.CandidateWindow; it-- {
+			other := store.items[it]
+			if other.Id == item.Id || other.SimHash == 0 {
+				continue
+			}
+			candidates++
+
+			distance := dedup.HammingDistance(item.SimHash, other.SimHash)
+			if distance < bestDistance {
+				bestDistance = distance
+				bestClusterId = clusterIdOf(other)
+			}
+		}
+
+		if bestClusterId != 0 {
+			log.Debug(fmt.Sprintf("Feed item [%s] is a duplicate in cluster (%d)", item.Title, bestClusterId))
+			return bestClusterId
+		}
+	}
+
+	// First of its kind
+	return item.Id
+}
+
+func (store *MemoryFeedStore) GetAllFeeds(ctx context.Context, withFeedItems bool) ([]*Feed, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	allValues := make([]*Feed, 0, len(store.feeds))
+	for _, feed := range store.feeds {
+		v := copyFeed(feed)
+		if withFeedItems {
+			v.Items = store.itemsOf(feed.Id)
+		}
+		allValues = append(allValues, v)
+	}
+	return allValues, nil
+}
+
+func (store *MemoryFeedStore) GetFeed(ctx context.Context, feedId appDatabase.PrimaryKey) (*Feed, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for _, feed := range store.feeds {
+		if feed.Id == feedId {
+			return copyFeed(feed), nil
+		}
+	}
+	return nil, nil
+}
+
+func (store *MemoryFeedStore) GetFeedItems(ctx context.Context, feedId appDatabase.PrimaryKey) ([]*FeedItem, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.itemsOf(feedId), nil
+}
+
+func (store *MemoryFeedStore) GetFeedItemsByIds(ctx context.Context, itemIds []appDatabase.PrimaryKey) ([]*FeedItem, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	wanted := make(map[appDatabase.PrimaryKey]bool, len(itemIds))
+	for _, itemId := range itemIds {
+		wanted[itemId] = true
+	}
+
+	allValues := make([]*FeedItem, 0, len(itemIds))
+	for _, item := range store.items {
+		if wanted[item.Id] {
+			v := copyFeedItem(item)
+			v.Feed = nil
+			allValues = append(allValues, v)
+		}
+	}
+	return allValues, nil
+}
+
+func (store *MemoryFeedStore) FeedItemByGUID(ctx context.Context, guid string) (*FeedItem, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	item := store.itemByGUID(guid)
+	if item == nil {
+		return nil, nil
+	}
+
+	v := copyFeedItem(item)
+	v.Feed = nil
+	return v, nil
+}
+
+func (store *MemoryFeedStore) CollapseClusters(ctx context.Context, items []*FeedItem) ([]*FeedItem, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	collapsed := make([]*FeedItem, 0, len(items))
+	seenClusters := map[uint64]bool{}
+
+	for _, item := range items {
+
+		if item.ClusterId == 0 {
+			collapsed = append(collapsed, item)
+			continue
+		}
+
+		if seenClusters[item.ClusterId] {
+			continue
+		}
+		seenClusters[item.ClusterId] = true
+
+		item.AlsoIn = make([]*FeedItemReference, 0)
+		for _, member := range store.items {
+			if member.ClusterId == item.ClusterId && member.Id != item.Id {
+				item.AlsoIn = append(item.AlsoIn, &FeedItemReference{
+					Id:        member.Id,
+					FeedId:    member.Feed.Id,
+					FeedTitle: member.Feed.Title,
+					Title:     member.Title,
+					Link:      member.Link,
+				})
+			}
+		}
+
+		collapsed = append(collapsed, item)
+	}
+
+	return collapsed, nil
+}
+
+func (store *MemoryFeedStore) GetFeedItemRevisions(ctx context.Context, itemId appDatabase.PrimaryKey) ([]*FeedItemRevision, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	allValues := make([]*FeedItemRevision, 0, len(store.revisions[itemId]))
+	for _, revision := range store.revisions[itemId] {
+		v := *revision
+		allValues = append(allValues, &v)
+	}
+	return allValues, nil
+}
+
+func (store *MemoryFeedStore) PurgeCandidates(ctx context.Context, feedId appDatabase.PrimaryKey, policy RetentionPolicy) ([]*PurgeCandidate, error) {
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	items := make([]*PurgeCandidate, 0)
+	for _, item := range store.items {
+		if item.Feed.Id != feedId {
+			continue
+		}
+
+		date := item.Published
+		if date == nil {
+			date = item.Updated
+		}
+
+		items = append(items, &PurgeCandidate{
+			Id:      item.Id,
+			FeedId:  item.Feed.Id,
+			Title:   item.Title,
+			GUID:    item.GUID,
+			Date:    date,
+			Starred: item.Starred,
+		})
+	}
+
+	return selectPurgeCandidates(items, policy), nil
+}
+
+func (store *MemoryFeedStore) DeleteFeedItems(ctx context.Context, candidates []*PurgeCandidate) error {
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Remembering the GUID, the item would be fetched again otherwise
+		store.purged[purgedItem{feedId: candidate.FeedId, guid: candidate.GUID}] = time.Now()
+		delete(store.revisions, candidate.Id)
+
+		// Moving the other duplicates to a remaining cluster member
+		newClusterId := appDatabase.PrimaryKey(0)
+		remaining := make([]*FeedItem, 0, len(store.items))
+
+		for _, item := range store.items {
+			if item.Id == candidate.Id {
+				continue
+			}
+			if item.ClusterId == candidate.Id {
+				if newClusterId == 0 {
+					newClusterId = item.Id
+				}
+				item.ClusterId = newClusterId
+			}
+			remaining = append(remaining, item)
+		}
+		store.items = remaining
+	}
+
+	log.Debug(fmt.Sprintf("%d feed items deleted", len(candidates)))
+	return nil
+}
+
+func (store *MemoryFeedStore) PruneFeedItemTombstones(ctx context.Context, feedId appDatabase.PrimaryKey) error {
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	limit := time.Now().Add(-purgedItemRetention)
+	for purged, lastSeen := range store.purged {
+		if purged.feedId == feedId && lastSeen.Before(limit) {
+			delete(store.purged, purged)
+		}
+	}
+	return nil
+}
+
+func (store *MemoryFeedStore) PurgeOrphans(ctx context.Context, dryRun bool) ([]*OrphanCount, error) {
+
+	// Relations are kept within their feeds and items, and go away with them
+	counts := make([]*OrphanCount, 0, len(orphanDefinitions))
+	for _, definition := range orphanDefinitions {
+		counts = append(counts, &OrphanCount{
+			Table: definition.table,
+			Count: 0,
+		})
+	}
+	return counts, nil
+}
+
+// Same as for SQL stores, mailbox feeds being told apart by their mailto link
+func (store *MemoryFeedStore) storedFeed(feed *Feed) *Feed {
+
+	link := feed.mailtoLink()
+	for _, stored := range store.feeds {
+		if link != "" && stored.mailtoLink() == link || link == "" && stored.Title == feed.Title {
+			return stored
+		}
+	}
+	return nil
+}
+
+func (store *MemoryFeedStore) itemByGUID(guid string) *FeedItem {
+	for _, item := range store.items {
+		if item.GUID == guid {
+			return item
+		}
+	}
+	return nil
+}
+
+func (store *MemoryFeedStore) itemsOf(feedId appDatabase.PrimaryKey) []*FeedItem {
+
+	allValues := make([]*FeedItem, 0)
+	for _, item := range store.items {
+		if item.Feed.Id == feedId {
+			v := copyFeedItem(item)
+			v.Feed = nil
+			allValues = append(allValues, v)
+		}
+	}
+	return allValues
+}
+
+func clusterIdOf(item *FeedItem) appDatabase.PrimaryKey {
+	if item.ClusterId != 0 {
+		return item.ClusterId
+	}
+	return item.Id
+}
+
+func unionTags(tags []string, otherTags []string) []string {
+
+	seen := map[string]bool{}
+	allValues := make([]string, 0, len(tags)+len(otherTags))
+
+	for _, tag := range append(append([]string{}, tags...), otherTags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			allValues = append(allValues, tag)
+		}
+	}
+	return allValues
+}
+
+func timeRef(t time.Time) *time.Time {
+	return &t
+}
+
+func copyFeed(feed *Feed) *Feed {
+
+	v := *feed
+	v.Author = copyAuthor(feed.Author)
+	v.Image = copyImage(feed.Image)
+	v.Categories = copyCategories(feed.Categories)
+	v.Items = nil
+	return &v
+}
+
+func copyFeedItem(item *FeedItem) *FeedItem {
+
+	v := *item
+	v.Author = copyAuthor(item.Author)
+	v.Image = copyImage(item.Image)
+	v.Categories = copyCategories(item.Categories)
+	v.Enclosures = make([]*FeedEnclosure, 0, len(item.Enclosures))
+	for _, enclosure := range item.Enclosures {
+		enclosureCopy := *enclosure
+		v.Enclosures = append(v.Enclosures, &enclosureCopy)
+	}
+	v.Tags = append([]string{}, item.Tags...)
+	v.AlsoIn = nil
+	return &v
+}
+
+func copyAuthor(author *FeedAuthor) *FeedAuthor {
+	if author == nil {
+		return nil
+	}
+	v := *author
+	return &v
+}
+
+func copyImage(image *FeedImage) *FeedImage {
+	if image == nil {
+		return nil
+	}
+	v := *image
+	return &v
+}
+
+func copyCategories(categories []*FeedCategory) []*FeedCategory {
+	allValues := make([]*FeedCategory, 0, len(categories))
+	for _, category := range categories {
+		v := *category
+		allValues = append(allValues, &v)
+	}
+	return allValues
+}
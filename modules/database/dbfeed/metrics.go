@@ -0,0 +1,39 @@
+package dbfeed
+
+import (
+	"github.com/dademo/rssreader/modules/metrics"
+)
+
+const (
+	itemOperationInserted = "inserted"
+	itemOperationUpdated  = "updated"
+)
+
+var savedItemsCounter = metrics.NewCounterVec(
+	"rssreader_feed_items_saved_total",
+	"Feed items saved by feed and operation, inserted or updated.",
+	"feed", "operation",
+)
+
+type savedItemKey struct {
+	feed      string
+	operation string
+}
+
+func itemFeedName(item *FeedItem) string {
+	if item.Feed == nil {
+		return ""
+	}
+	return item.Feed.ConfigName
+}
+
+// Counted once the transaction is committed
+func (s *session) countSavedItem(item *FeedItem, operation string) {
+	s.savedItems[savedItemKey{feed: itemFeedName(item), operation: operation}]++
+}
+
+func (s *session) recordSavedItems() {
+	for key, count := range s.savedItems {
+		savedItemsCounter.Add(count, key.feed, key.operation)
+	}
+}
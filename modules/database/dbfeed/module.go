@@ -1,6 +1,7 @@
 package dbfeed
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -87,21 +88,225 @@ func getFeedSQL() []string {
 	}
 }
 
+func getFeedMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{
+		{
+			FromVersion: "0.0.1",
+			ToVersion:   "0.0.2",
+			SQL: []string{
+				`ALTER TABLE feed_item ADD COLUMN raw_description TEXT;`,
+				`ALTER TABLE feed_item ADD COLUMN raw_content TEXT;`,
+			},
+		},
+		{
+			FromVersion: "0.0.2",
+			ToVersion:   "0.0.3",
+			SQL: []string{
+				`ALTER TABLE feed_item ADD COLUMN is_read BOOLEAN NOT NULL DEFAULT FALSE;`,
+				`ALTER TABLE feed_item ADD COLUMN is_starred BOOLEAN NOT NULL DEFAULT FALSE;`,
+				`
+				CREATE TABLE feed_item_tag (
+					id_feed_item	INTEGER NOT NULL REFERENCES feed_item(id),
+					tag				VARCHAR(200) NOT NULL,
+					UNIQUE(id_feed_item, tag)
+				);`,
+			},
+		},
+		{
+			FromVersion: "0.0.3",
+			ToVersion:   "0.0.4",
+			SQL: []string{
+				`ALTER TABLE feed_item ADD COLUMN canonical_url VARCHAR(700);`,
+				`ALTER TABLE feed_item ADD COLUMN simhash BIGINT;`,
+				`ALTER TABLE feed_item ADD COLUMN id_cluster INTEGER REFERENCES feed_item(id);`,
+				`CREATE INDEX feed_item_canonical_url_idx ON feed_item(canonical_url);`,
+				`CREATE INDEX feed_item_cluster_idx ON feed_item(id_cluster);`,
+			},
+		},
+		{
+			FromVersion: "0.0.4",
+			ToVersion:   "0.0.5",
+			SQL: []string{
+				`
+				CREATE TABLE feed_item_revision (
+					id				{{.SqlPrimaryKey}},
+					id_feed_item	INTEGER NOT NULL REFERENCES feed_item(id),
+					title			TEXT,
+					description		TEXT,
+					content			TEXT,
+					content_hash	VARCHAR(64) NOT NULL,
+					created			{{.SqlTimestamp}}
+				);`,
+				`CREATE INDEX feed_item_revision_item_idx ON feed_item_revision(id_feed_item);`,
+			},
+		},
+		{
+			FromVersion: "0.0.5",
+			ToVersion:   "0.0.6",
+			SQL: []string{
+				`ALTER TABLE feed ADD COLUMN config_name VARCHAR(200);`,
+				`
+				CREATE TABLE feed_item_purged (
+					guid	VARCHAR(700) NOT NULL UNIQUE,
+					purged	{{.SqlTimestamp}}
+				);`,
+			},
+		},
+		{
+			FromVersion: "0.0.6",
+			ToVersion:   "0.0.7",
+			SQL: []string{
+				// Tombstones kept from before have no feed and match every feed until pruned
+				`ALTER TABLE feed_item_purged RENAME TO feed_item_purged_global;`,
+				`
+				CREATE TABLE feed_item_purged (
+					id_feed		INTEGER REFERENCES feed(id),
+					guid		VARCHAR(700) NOT NULL,
+					purged		{{.SqlTimestamp}},
+					last_seen	{{.SqlTimestamp}},
+					UNIQUE(id_feed, guid)
+				);`,
+				`INSERT INTO feed_item_purged (guid, purged, last_seen) SELECT guid, purged, purged FROM feed_item_purged_global;`,
+				`DROP TABLE feed_item_purged_global;`,
+				`CREATE INDEX feed_item_purged_last_seen_idx ON feed_item_purged(last_seen);`,
+			},
+		},
+		{
+			FromVersion: "0.0.7",
+			ToVersion:   "0.0.8",
+			SQL: []string{
+				// Quarters of the SimHash, as computed by dedup.SimHashBands
+				`ALTER TABLE feed_item ADD COLUMN simhash_band0 INTEGER;`,
+				`ALTER TABLE feed_item ADD COLUMN simhash_band1 INTEGER;`,
+				`ALTER TABLE feed_item ADD COLUMN simhash_band2 INTEGER;`,
+				`ALTER TABLE feed_item ADD COLUMN simhash_band3 INTEGER;`,
+				`
+				UPDATE feed_item SET
+					simhash_band0 = simhash & 65535,
+					simhash_band1 = (simhash >> 16) & 65535,
+					simhash_band2 = (simhash >> 32) & 65535,
+					simhash_band3 = (simhash >> 48) & 65535
+				WHERE
+					    simhash IS NOT NULL
+					AND simhash <> 0;`,
+				`CREATE INDEX feed_item_simhash_band0_idx ON feed_item(simhash_band0);`,
+				`CREATE INDEX feed_item_simhash_band1_idx ON feed_item(simhash_band1);`,
+				`CREATE INDEX feed_item_simhash_band2_idx ON feed_item(simhash_band2);`,
+				`CREATE INDEX feed_item_simhash_band3_idx ON feed_item(simhash_band3);`,
+			},
+		},
+	}
+}
+
+func getFeedTables() []appDatabase.TableDefinition {
+
+	text := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnText}
+	}
+	timestamp := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnTimestamp}
+	}
+	boolean := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnBoolean}
+	}
+	reference := func(name string, table string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnInteger, References: table}
+	}
+
+	return []appDatabase.TableDefinition{
+		{Name: "feed_category", HasId: true, Columns: []appDatabase.TableColumn{text("category")}},
+		{Name: "feed_author", HasId: true, Columns: []appDatabase.TableColumn{text("name"), text("email")}},
+		{Name: "feed_image", HasId: true, Columns: []appDatabase.TableColumn{text("url"), text("title")}},
+		{Name: "feed_enclosure", HasId: true, Columns: []appDatabase.TableColumn{text("url"), text("length"), text("type")}},
+		{Name: "feed", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_author", "feed_author"),
+			reference("id_image", "feed_image"),
+			text("title"),
+			text("description"),
+			text("link"),
+			text("feed_link"),
+			timestamp("updated"),
+			timestamp("published"),
+			text("language"),
+			text("copyright"),
+			text("generator"),
+			timestamp("last_update"),
+			text("config_name"),
+		}},
+		{Name: "feed_item", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_feed", "feed"),
+			reference("id_author", "feed_author"),
+			reference("id_image", "feed_image"),
+			text("title"),
+			text("description"),
+			text("content"),
+			text("raw_description"),
+			text("raw_content"),
+			text("link"),
+			timestamp("updated"),
+			timestamp("published"),
+			text("guid"),
+			boolean("is_read"),
+			boolean("is_starred"),
+			text("canonical_url"),
+			{Name: "simhash", Type: appDatabase.ColumnInteger},
+			{Name: "simhash_band0", Type: appDatabase.ColumnInteger},
+			{Name: "simhash_band1", Type: appDatabase.ColumnInteger},
+			{Name: "simhash_band2", Type: appDatabase.ColumnInteger},
+			{Name: "simhash_band3", Type: appDatabase.ColumnInteger},
+			reference("id_cluster", "feed_item"),
+		}},
+		{Name: "feed_category_feed", Columns: []appDatabase.TableColumn{
+			reference("id_feed_category", "feed_category"),
+			reference("id_feed", "feed"),
+		}},
+		{Name: "feed_category_item", Columns: []appDatabase.TableColumn{
+			reference("id_feed_category", "feed_category"),
+			reference("id_feed_item", "feed_item"),
+		}},
+		{Name: "feed_enclosure_item", Columns: []appDatabase.TableColumn{
+			reference("id_feed_enclosure", "feed_enclosure"),
+			reference("id_feed_item", "feed_item"),
+		}},
+		{Name: "feed_item_tag", Columns: []appDatabase.TableColumn{
+			reference("id_feed_item", "feed_item"),
+			text("tag"),
+		}},
+		{Name: "feed_item_revision", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_feed_item", "feed_item"),
+			text("title"),
+			text("description"),
+			text("content"),
+			text("content_hash"),
+			timestamp("created"),
+		}},
+		{Name: "feed_item_purged", Columns: []appDatabase.TableColumn{
+			reference("id_feed", "feed"),
+			text("guid"),
+			timestamp("purged"),
+			timestamp("last_seen"),
+		}},
+	}
+}
+
+const (
+	feedModuleInitialVersion = "0.0.1"
+	feedModuleVersion        = "0.0.8"
+)
+
 var feedModuleDef = appDatabase.DatabaseModuleTableCreationDef{
 	ModuleName:                 "Feed",
-	Version:                    "0.0.1",
+	Version:                    feedModuleVersion,
 	DatabaseModuleTableCreator: databaseFeedModuleCreator,
 	DatabaseModuleTableUpdater: databaseFeedModuleUpdater,
+	Tables:                     getFeedTables(),
 }
 
-var database *sql.DB
-
 func init() {
 	appDatabase.RegisterDatabaseTableCreator(feedModuleDef)
-	appDatabase.RegisterOnDatabaseSet(onDatabaseSet)
 }
 
-func databaseFeedModuleCreator(connection *sql.Tx) error {
+func databaseFeedModuleCreator(ctx context.Context, connection *sql.Tx) error {
 
 	log.Debug("Creating feed tables")
 
@@ -114,21 +319,33 @@ func databaseFeedModuleCreator(connection *sql.Tx) error {
 
 		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
 
-		_, err = connection.Exec(sql)
+		_, err = connection.ExecContext(ctx, sql)
 		if err != nil {
 			appLog.DebugError(err, "Unable to create feed tables")
 			return err
 		}
 	}
 
+	err := appDatabase.RunMigrations(ctx, connection, feedModuleInitialVersion, feedModuleVersion, getFeedMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate feed tables")
+		return err
+	}
+
 	log.Debug("Feed tables created")
 	return nil
 }
 
-func databaseFeedModuleUpdater(connection *sql.Tx, oldVersion string) error {
-	return nil
-}
+func databaseFeedModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating feed tables")
 
-func onDatabaseSet(db *sql.DB) {
-	database = db
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, feedModuleVersion, getFeedMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate feed tables")
+		return err
+	}
+
+	log.Debug("Feed tables updated")
+	return nil
 }
@@ -0,0 +1,463 @@
+package dbfeed
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PurgeReasonMaxItems = "maxItems"
+	PurgeReasonMaxAge   = "maxAge"
+
+	// Purged GUIDs are forgotten once their feed stopped serving them for this long
+	purgedItemRetention = 30 * 24 * time.Hour
+	// Last seen dates of purged GUIDs are not refreshed more often
+	purgedItemSeenResolution = 24 * time.Hour
+)
+
+type RetentionPolicy struct {
+	MaxItems    uint
+	MaxAge      time.Duration
+	KeepStarred bool
+}
+
+type PurgeCandidate struct {
+	Id      uint64     `json:"id"`
+	FeedId  uint64     `json:"feedId"`
+	Title   string     `json:"title"`
+	GUID    string     `json:"guid"`
+	Date    *time.Time `json:"date"`
+	Starred bool       `json:"starred"`
+	Reason  string     `json:"reason"`
+}
+
+type OrphanCount struct {
+	Table string `json:"table"`
+	Count int64  `json:"count"`
+}
+
+// Table of another module referencing feed items through an id_feed_item column
+type itemReference struct {
+	table string
+	// Boolean column flagging the item as starred, kept by retention policies
+	starredColumn string
+}
+
+var itemReferences []itemReference
+
+// Registers a table whose rows are removed along with the feed items they reference
+func RegisterItemReference(table string, starredColumn string) {
+	itemReferences = append(itemReferences, itemReference{table: table, starredColumn: starredColumn})
+}
+
+type orphanDefinition struct {
+	table string
+	where string
+}
+
+var orphanDefinitions = []orphanDefinition{
+	{
+		table: "feed_author",
+		where: `
+			    id NOT IN (SELECT id_author FROM feed WHERE id_author IS NOT NULL)
+			AND id NOT IN (SELECT id_author FROM feed_item WHERE id_author IS NOT NULL)`,
+	},
+	{
+		table: "feed_image",
+		where: `
+			    id NOT IN (SELECT id_image FROM feed WHERE id_image IS NOT NULL)
+			AND id NOT IN (SELECT id_image FROM feed_item WHERE id_image IS NOT NULL)`,
+	},
+	{
+		table: "feed_category",
+		where: `
+			    id NOT IN (SELECT id_feed_category FROM feed_category_feed)
+			AND id NOT IN (SELECT id_feed_category FROM feed_category_item)`,
+	},
+	{
+		table: "feed_enclosure",
+		where: `
+			id NOT IN (SELECT id_feed_enclosure FROM feed_enclosure_item)`,
+	},
+	{
+		table: "feed_item_purged",
+		where: `
+			    id_feed IS NOT NULL
+			AND id_feed NOT IN (SELECT id FROM feed)`,
+	},
+}
+
+func (store *SQLFeedStore) PurgeCandidates(ctx context.Context, feedId appDatabase.PrimaryKey, policy RetentionPolicy) ([]*PurgeCandidate, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	starred := "is_starred"
+	for _, reference := range itemReferences {
+		if reference.starredColumn != "" {
+			starred += fmt.Sprintf(" OR EXISTS (SELECT 1 FROM %s WHERE id_feed_item = feed_item.id AND %s = TRUE)", reference.table, reference.starredColumn)
+		}
+	}
+
+	stmt, err := s.prepare(`
+		SELECT
+			id,
+			id_feed,
+			title,
+			guid,
+			published,
+			updated,
+			(` + starred + `)
+		FROM feed_item
+		WHERE id_feed = ?
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, feedId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, err
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, rows.Err()
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	items := make([]*PurgeCandidate, 0)
+	for rows.Next() {
+		var publishedRawValue, updatedRawValue interface{}
+		v := new(PurgeCandidate)
+
+		err = rows.Scan(&v.Id, &v.FeedId, &v.Title, &v.GUID, &publishedRawValue, &updatedRawValue, &v.Starred)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		v.Date, err = appDatabase.SqlDateParse(publishedRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse published date")
+			return nil, err
+		}
+
+		if v.Date == nil {
+			v.Date, err = appDatabase.SqlDateParse(updatedRawValue)
+			if err != nil {
+				appLog.DebugError(err, "Unable to parse updated date")
+				return nil, err
+			}
+		}
+
+		items = append(items, v)
+	}
+
+	return selectPurgeCandidates(items, policy), nil
+}
+
+func selectPurgeCandidates(items []*PurgeCandidate, policy RetentionPolicy) []*PurgeCandidate {
+
+	// Newest first, undated items being considered the oldest
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Date == nil || items[j].Date == nil {
+			if items[i].Date != nil {
+				return true
+			}
+			if items[j].Date != nil {
+				return false
+			}
+			return items[i].Id > items[j].Id
+		}
+		if items[i].Date.Equal(*items[j].Date) {
+			return items[i].Id > items[j].Id
+		}
+		return items[i].Date.After(*items[j].Date)
+	})
+
+	maxAgeLimit := time.Now().Add(-policy.MaxAge)
+	candidates := make([]*PurgeCandidate, 0)
+
+	for it, item := range items {
+
+		if policy.KeepStarred && item.Starred {
+			continue
+		}
+
+		if policy.MaxItems > 0 && uint(it) >= policy.MaxItems {
+			item.Reason = PurgeReasonMaxItems
+		} else if policy.MaxAge > 0 && item.Date != nil && item.Date.Before(maxAgeLimit) {
+			item.Reason = PurgeReasonMaxAge
+		} else {
+			continue
+		}
+
+		candidates = append(candidates, item)
+	}
+
+	return candidates
+}
+
+func (store *SQLFeedStore) DeleteFeedItems(ctx context.Context, candidates []*PurgeCandidate) error {
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	err := store.inSession(ctx, func(s *session) error {
+		for _, candidate := range candidates {
+			err := deleteFeedItem(s, candidate)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to delete feed item (%d)", candidate.Id))
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debug(fmt.Sprintf("%d feed items deleted", len(candidates)))
+	return nil
+}
+
+func deleteFeedItem(s *session, candidate *PurgeCandidate) error {
+
+	// Remembering the GUID, the item would be fetched again otherwise
+	purged, err := isPurged(s, candidate.FeedId, candidate.GUID)
+	if err != nil {
+		return err
+	}
+
+	if !purged {
+		now := time.Now()
+		err = s.exec(`
+			INSERT INTO feed_item_purged (id_feed, guid, purged, last_seen)
+			VALUES (?, ?, ?, ?)
+		`, candidate.FeedId, appDatabase.StrWithMaxLength(candidate.GUID, 700), now, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	tables := []string{"feed_category_item", "feed_enclosure_item", "feed_item_tag", "feed_item_revision"}
+	for _, reference := range itemReferences {
+		tables = append(tables, reference.table)
+	}
+
+	for _, table := range tables {
+		err = s.exec(fmt.Sprintf(`DELETE FROM %s WHERE id_feed_item = ?`, table), candidate.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Moving the other duplicates to a remaining cluster member
+	newClusterId, err := remainingClusterMember(s, candidate.Id)
+	if err != nil {
+		return err
+	}
+
+	if newClusterId != 0 {
+		err = s.exec(`
+			UPDATE feed_item SET
+				id_cluster = ?
+			WHERE
+				    id_cluster = ?
+				AND id <> ?
+		`, newClusterId, candidate.Id, candidate.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.exec(`DELETE FROM feed_item WHERE id = ?`, candidate.Id)
+}
+
+func remainingClusterMember(s *session, itemId appDatabase.PrimaryKey) (appDatabase.PrimaryKey, error) {
+
+	stmt, err := s.prepare(`
+		SELECT
+			COALESCE(MIN(id), 0)
+		FROM feed_item
+		WHERE
+			    id_cluster = ?
+			AND id <> ?
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return 0, err
+	}
+
+	var clusterId appDatabase.PrimaryKey
+	err = stmt.QueryRowContext(s.ctx, itemId, itemId).Scan(&clusterId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to affect results")
+		return 0, err
+	}
+	return clusterId, nil
+}
+
+func (store *SQLFeedStore) PurgeOrphans(ctx context.Context, dryRun bool) ([]*OrphanCount, error) {
+
+	counts := make([]*OrphanCount, 0, len(orphanDefinitions))
+
+	err := store.inSession(ctx, func(s *session) error {
+		for _, definition := range orphanDefinitions {
+
+			count, err := countOrphans(s, definition)
+			if err == nil && !dryRun && count > 0 {
+				err = s.exec(fmt.Sprintf(`DELETE FROM %s WHERE %s`, definition.table, definition.where))
+			}
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to purge orphans of table [%s]", definition.table))
+				return err
+			}
+
+			counts = append(counts, &OrphanCount{
+				Table: definition.table,
+				Count: count,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func countOrphans(s *session, definition orphanDefinition) (int64, error) {
+
+	stmt, err := s.prepare(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, definition.table, definition.where))
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return 0, err
+	}
+
+	var count int64
+	err = stmt.QueryRowContext(s.ctx).Scan(&count)
+	if err != nil {
+		appLog.DebugError(err, "Unable to affect results")
+		return 0, err
+	}
+	return count, nil
+}
+
+// Forgets the purged GUIDs the feed no longer serves, they would not be fetched again
+func (store *SQLFeedStore) PruneFeedItemTombstones(ctx context.Context, feedId appDatabase.PrimaryKey) error {
+
+	return store.inSession(ctx, func(s *session) error {
+		err := s.exec(`
+			DELETE FROM feed_item_purged
+			WHERE
+				    (id_feed = ? OR id_feed IS NULL)
+				AND last_seen < ?
+		`, feedId, time.Now().Add(-purgedItemRetention))
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to prune the purged items of feed (%d)", feedId))
+		}
+		return err
+	})
+}
+
+func isPurged(s *session, feedId appDatabase.PrimaryKey, guid string) (bool, error) {
+
+	purged, err := purgedGUIDs(s, feedId, []string{guid})
+	if err != nil {
+		return false, err
+	}
+	return purged[appDatabase.StrWithMaxLength(guid, 700)], nil
+}
+
+// Purged GUIDs of the feed, or kept from before they were scoped per feed, by their stored value
+func purgedGUIDs(s *session, feedId appDatabase.PrimaryKey, guids []string) (map[string]bool, error) {
+
+	purged := make(map[string]bool)
+	if len(guids) == 0 {
+		return purged, nil
+	}
+
+	args := make([]interface{}, 0, len(guids)+2)
+	for _, guid := range guids {
+		args = append(args, appDatabase.StrWithMaxLength(guid, 700))
+	}
+	args = append(args, feedId)
+
+	stmt, err := s.prepare(`
+		SELECT
+			guid,
+			last_seen
+		FROM feed_item_purged
+		WHERE
+			    guid IN ` + appDatabase.InPlaceholders(len(guids)) + `
+			AND (id_feed = ? OR id_feed IS NULL)
+	`)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare SELECT statement")
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(s.ctx, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	staleArgs := []interface{}{time.Now()}
+	for rows.Next() {
+		var guid string
+		var lastSeenRawValue interface{}
+
+		err = rows.Scan(&guid, &lastSeenRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		lastSeen, err := appDatabase.SqlDateParse(lastSeenRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse last seen date")
+			return nil, err
+		}
+
+		if !purged[guid] && (lastSeen == nil || time.Since(*lastSeen) > purgedItemSeenResolution) {
+			staleArgs = append(staleArgs, guid)
+		}
+		purged[guid] = true
+	}
+	if rows.Err() != nil {
+		appLog.DebugError(rows.Err(), "Unable to get result row")
+		return nil, rows.Err()
+	}
+	rows.Close()
+
+	// Still served by the feed, the GUIDs are kept
+	if len(staleArgs) > 1 {
+		err = s.exec(`
+			UPDATE feed_item_purged SET
+				last_seen = ?
+			WHERE
+				    guid IN `+appDatabase.InPlaceholders(len(staleArgs)-1)+`
+				AND (id_feed = ? OR id_feed IS NULL)
+		`, append(staleArgs, feedId)...)
+		if err != nil {
+			appLog.DebugError(err, "Unable to update the last seen date of purged items")
+			return nil, err
+		}
+	}
+
+	return purged, nil
+}
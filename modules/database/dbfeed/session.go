@@ -0,0 +1,213 @@
+package dbfeed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+const (
+	// Rows count of a single multi-row INSERT, below the parameters limit of every driver
+	linkBatchSize = 250
+	// Items whose lookups are made with a single query
+	itemBatchSize = 200
+)
+
+type preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Statements are prepared once per session and reused until it ends
+type session struct {
+	ctx        context.Context
+	connection preparer
+	tx         *sql.Tx
+	statements map[string]*sql.Stmt
+	links      map[linkTable]*pendingLinks
+	savedItems map[savedItemKey]float64
+	changes    []*Change
+	// Authors, images, categories and enclosures saved during the session, by kind and natural key
+	savedIds map[savedIdKey]appDatabase.PrimaryKey
+}
+
+type savedIdKey struct {
+	table string
+	key   string
+}
+
+type linkTable struct {
+	table   string
+	columns [2]string
+}
+
+type pendingLinks struct {
+	seen map[[2]interface{}]bool
+	rows [][2]interface{}
+}
+
+var (
+	feedCategoryFeedLink  = linkTable{table: "feed_category_feed", columns: [2]string{"id_feed_category", "id_feed"}}
+	feedCategoryItemLink  = linkTable{table: "feed_category_item", columns: [2]string{"id_feed_category", "id_feed_item"}}
+	feedEnclosureItemLink = linkTable{table: "feed_enclosure_item", columns: [2]string{"id_feed_enclosure", "id_feed_item"}}
+	feedItemTagLink       = linkTable{table: "feed_item_tag", columns: [2]string{"id_feed_item", "tag"}}
+)
+
+func newSession(ctx context.Context, db *sql.DB) *session {
+	return &session{
+		ctx:        ctx,
+		connection: db,
+		statements: map[string]*sql.Stmt{},
+		links:      map[linkTable]*pendingLinks{},
+		savedItems: map[savedItemKey]float64{},
+		savedIds:   map[savedIdKey]appDatabase.PrimaryKey{},
+	}
+}
+
+func beginSession(ctx context.Context, db *sql.DB) (*session, error) {
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin a transaction")
+		return nil, err
+	}
+
+	s := newSession(ctx, db)
+	s.connection = tx
+	s.tx = tx
+	return s, nil
+}
+
+func (s *session) prepare(query string) (*sql.Stmt, error) {
+
+	if stmt, ok := s.statements[query]; ok {
+		return stmt, nil
+	}
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+
+	stmt, err := s.connection.PrepareContext(s.ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	s.statements[query] = stmt
+	return stmt, nil
+}
+
+// Prepares an INSERT statement whose generated id is fetched
+func (s *session) prepareInsert(query string) (*sql.Stmt, error) {
+	return s.prepare(appDatabase.PrepareExecSQL(query))
+}
+
+func (s *session) exec(query string, args ...interface{}) error {
+
+	stmt, err := s.prepare(query)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return err
+	}
+
+	return appDatabase.SqlExec(s.ctx, stmt, args...)
+}
+
+func (s *session) savedId(table string, key string) (appDatabase.PrimaryKey, bool) {
+	id, ok := s.savedIds[savedIdKey{table: table, key: key}]
+	return id, ok
+}
+
+func (s *session) rememberId(table string, key string, id appDatabase.PrimaryKey) {
+	s.savedIds[savedIdKey{table: table, key: key}] = id
+}
+
+func (s *session) queueLink(link linkTable, first interface{}, second interface{}) {
+
+	pending, ok := s.links[link]
+	if !ok {
+		pending = &pendingLinks{
+			seen: map[[2]interface{}]bool{},
+			rows: make([][2]interface{}, 0),
+		}
+		s.links[link] = pending
+	}
+
+	row := [2]interface{}{first, second}
+	if !pending.seen[row] {
+		pending.seen[row] = true
+		pending.rows = append(pending.rows, row)
+	}
+}
+
+func (s *session) flushLinks() error {
+
+	for link, pending := range s.links {
+		for start := 0; start < len(pending.rows); start += linkBatchSize {
+
+			end := start + linkBatchSize
+			if end > len(pending.rows) {
+				end = len(pending.rows)
+			}
+
+			args := make([]interface{}, 0, 2*(end-start))
+			for _, row := range pending.rows[start:end] {
+				args = append(args, row[0], row[1])
+			}
+
+			err := s.exec(appDatabase.InsertIgnoreSql(link.table, link.columns[:], end-start), args...)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to save links of table [%s]", link.table))
+				return err
+			}
+		}
+		delete(s.links, link)
+	}
+
+	return nil
+}
+
+func (s *session) close() {
+	for query, stmt := range s.statements {
+		appDatabase.DeferStmtCloseFct(stmt)()
+		delete(s.statements, query)
+	}
+}
+
+// Ends the session, committing the transaction when no error occured
+func (s *session) end(err error) error {
+
+	if err == nil {
+		err = s.flushLinks()
+	}
+
+	if err == nil && s.tx != nil {
+		err = writeChanges(s.ctx, s.tx, s.changes)
+	}
+
+	s.close()
+
+	if s.tx == nil {
+		return err
+	}
+
+	if err != nil {
+		if rollbackErr := s.tx.Rollback(); rollbackErr != nil {
+			appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+		}
+		return err
+	}
+
+	err = s.tx.Commit()
+	if err != nil {
+		appLog.DebugError(err, "Unable to commit the transaction")
+		return err
+	}
+
+	s.recordSavedItems()
+	return nil
+}
@@ -0,0 +1,90 @@
+package dbfeed
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+)
+
+type FeedStore interface {
+	SaveFeed(ctx context.Context, feed *Feed) error
+	GetAllFeeds(ctx context.Context, withFeedItems bool) ([]*Feed, error)
+	GetFeed(ctx context.Context, feedId appDatabase.PrimaryKey) (*Feed, error)
+	GetFeedItems(ctx context.Context, feedId appDatabase.PrimaryKey) ([]*FeedItem, error)
+	GetFeedItemsByIds(ctx context.Context, itemIds []appDatabase.PrimaryKey) ([]*FeedItem, error)
+	FeedItemByGUID(ctx context.Context, guid string) (*FeedItem, error)
+	CollapseClusters(ctx context.Context, items []*FeedItem) ([]*FeedItem, error)
+	GetFeedItemRevisions(ctx context.Context, itemId appDatabase.PrimaryKey) ([]*FeedItemRevision, error)
+	PurgeCandidates(ctx context.Context, feedId appDatabase.PrimaryKey, policy RetentionPolicy) ([]*PurgeCandidate, error)
+	DeleteFeedItems(ctx context.Context, candidates []*PurgeCandidate) error
+	PruneFeedItemTombstones(ctx context.Context, feedId appDatabase.PrimaryKey) error
+	PurgeOrphans(ctx context.Context, dryRun bool) ([]*OrphanCount, error)
+}
+
+type SQLFeedStore struct {
+	db *sql.DB
+	// Writes are serialized, sqlite allowing a single writing transaction
+	writeLock sync.Mutex
+}
+
+func NewSQLFeedStore(db *sql.DB) *SQLFeedStore {
+	return &SQLFeedStore{db: db}
+}
+
+func (store *SQLFeedStore) SaveFeed(ctx context.Context, feed *Feed) error {
+
+	var changes []*Change
+	err := store.inSession(ctx, func(s *session) error {
+		err := feed.save(s)
+		changes = s.changes
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyChanges(ctx, changes)
+	return nil
+}
+
+func (store *SQLFeedStore) FeedItemByGUID(ctx context.Context, guid string) (*FeedItem, error) {
+
+	s := newSession(ctx, store.db)
+	defer s.close()
+
+	item, err := feedItemByGUID(s, guid)
+	if err != nil || item == nil {
+		return item, err
+	}
+
+	var authorId, imageId *appDatabase.PrimaryKey
+	if item.Author != nil {
+		authorId = &item.Author.Id
+	}
+	if item.Image != nil {
+		imageId = &item.Image.Id
+	}
+	item.Feed = nil
+
+	err = item.loadRelations(s, authorId, imageId)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Runs fct in a transaction, committed when it returns without error
+func (store *SQLFeedStore) inSession(ctx context.Context, fct func(s *session) error) error {
+
+	store.writeLock.Lock()
+	defer store.writeLock.Unlock()
+
+	s, err := beginSession(ctx, store.db)
+	if err != nil {
+		return err
+	}
+
+	return s.end(fct(s))
+}
@@ -0,0 +1,176 @@
+package dbuser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+// Fields items are sorted on
+const (
+	ItemSortId        = "id"
+	ItemSortTimestamp = "timestamp"
+)
+
+// Selects items of the subscriptions of a user, unset fields leaving the items unfiltered
+type ItemQuery struct {
+	// Feeds whose items are read, every subscribed feed when nil
+	FeedIds []appDatabase.PrimaryKey
+	ItemIds []appDatabase.PrimaryKey
+	// Exclusive bounds of the item ids
+	AfterId  appDatabase.PrimaryKey
+	BeforeId appDatabase.PrimaryKey
+	// Bounds of the item timestamps, the newer one being inclusive
+	NewerThan *time.Time
+	OlderThan *time.Time
+	// Leaves out items known to be read or starred, user rules marking more items once they are loaded
+	Unread    bool
+	Unstarred bool
+	Page      appDatabase.PageQuery
+}
+
+// Item matched by a query, with the flags set by the user
+type ItemRef struct {
+	Id     appDatabase.PrimaryKey
+	FeedId appDatabase.PrimaryKey
+	State  *ItemState
+}
+
+// Publication date, or the best known approximation, as computed for the reader APIs
+const itemTimestampSql = `COALESCE(feed_item.published, feed_item.updated, feed.last_update, '1970-01-01 00:00:00')`
+
+// Items matching the query, in page order
+func (store *Store) QueryItems(ctx context.Context, userId appDatabase.PrimaryKey, query *ItemQuery) ([]*ItemRef, error) {
+
+	where, args := query.where(userId)
+	page, err := query.Page.Sql(map[string]string{
+		ItemSortId:        "feed_item.id",
+		ItemSortTimestamp: appDatabase.SqlComparableDate(itemTimestampSql),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.query(ctx, `
+		SELECT
+			feed_item.id,
+			feed_item.id_feed,
+			user_item_state.is_read,
+			user_item_state.is_starred
+		`+itemQueryFrom+`
+		WHERE `+where+page, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	refs := make([]*ItemRef, 0)
+	for rows.Next() {
+		var read, starred sql.NullBool
+		ref := &ItemRef{State: &ItemState{}}
+
+		err = rows.Scan(&ref.Id, &ref.FeedId, &read, &starred)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		if read.Valid {
+			ref.State.Read = &read.Bool
+		}
+		if starred.Valid {
+			ref.State.Starred = &starred.Bool
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// Number of items matching the query, items dropped by user rules being counted
+func (store *Store) CountItems(ctx context.Context, userId appDatabase.PrimaryKey, query *ItemQuery) (int64, error) {
+
+	where, args := query.where(userId)
+	rows, err := store.query(ctx, `SELECT COUNT(*) `+itemQueryFrom+` WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	var count int64
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return 0, err
+		}
+	}
+	return count, rows.Err()
+}
+
+const itemQueryFrom = `
+		FROM feed_item
+		JOIN feed
+			ON feed.id = feed_item.id_feed
+		JOIN user_subscription
+			ON  user_subscription.id_feed = feed_item.id_feed
+			AND user_subscription.id_user = ?
+		LEFT JOIN user_item_state
+			ON  user_item_state.id_feed_item = feed_item.id
+			AND user_item_state.id_user = user_subscription.id_user
+`
+
+func (query *ItemQuery) where(userId appDatabase.PrimaryKey) (string, []interface{}) {
+
+	conditions := []string{"1 = 1"}
+	args := []interface{}{userId}
+
+	if query.FeedIds != nil {
+		if len(query.FeedIds) == 0 {
+			return "1 = 0", args
+		}
+		conditions = append(conditions, "feed_item.id_feed IN "+appDatabase.InPlaceholders(len(query.FeedIds)))
+		for _, feedId := range query.FeedIds {
+			args = append(args, feedId)
+		}
+	}
+	if query.ItemIds != nil {
+		if len(query.ItemIds) == 0 {
+			return "1 = 0", args
+		}
+		conditions = append(conditions, "feed_item.id IN "+appDatabase.InPlaceholders(len(query.ItemIds)))
+		for _, itemId := range query.ItemIds {
+			args = append(args, itemId)
+		}
+	}
+	if query.AfterId > 0 {
+		conditions = append(conditions, "feed_item.id > ?")
+		args = append(args, query.AfterId)
+	}
+	if query.BeforeId > 0 {
+		conditions = append(conditions, "feed_item.id < ?")
+		args = append(args, query.BeforeId)
+	}
+	if query.NewerThan != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= %s", appDatabase.SqlComparableDate(itemTimestampSql), appDatabase.SqlComparableDate("?")))
+		args = append(args, *query.NewerThan)
+	}
+	if query.OlderThan != nil {
+		conditions = append(conditions, fmt.Sprintf("%s < %s", appDatabase.SqlComparableDate(itemTimestampSql), appDatabase.SqlComparableDate("?")))
+		args = append(args, *query.OlderThan)
+	}
+	if query.Unread {
+		conditions = append(conditions, "COALESCE(user_item_state.is_read, ?) = ?")
+		args = append(args, false, false)
+	}
+	if query.Unstarred {
+		conditions = append(conditions, "COALESCE(user_item_state.is_starred, ?) = ?")
+		args = append(args, false, false)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
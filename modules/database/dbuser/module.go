@@ -0,0 +1,180 @@
+package dbuser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbauth"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getUserSQL() []string {
+	return []string{
+		`
+		CREATE TABLE user_folder (
+			id		{{.SqlPrimaryKey}},
+			id_user	INTEGER NOT NULL REFERENCES auth_user(id),
+			name	VARCHAR(200) NOT NULL,
+			UNIQUE(id_user, name)
+		);`,
+		`
+		CREATE TABLE user_subscription (
+			id			{{.SqlPrimaryKey}},
+			id_user		INTEGER NOT NULL REFERENCES auth_user(id),
+			id_feed		INTEGER NOT NULL REFERENCES feed(id),
+			id_folder	INTEGER REFERENCES user_folder(id),
+			title		TEXT,
+			created		{{.SqlTimestamp}},
+			UNIQUE(id_user, id_feed)
+		);`,
+		`
+		CREATE TABLE user_item_state (
+			id_user			INTEGER NOT NULL REFERENCES auth_user(id),
+			id_feed_item	INTEGER NOT NULL REFERENCES feed_item(id),
+			is_read			BOOLEAN,
+			is_starred		BOOLEAN,
+			updated			{{.SqlTimestamp}},
+			UNIQUE(id_user, id_feed_item)
+		);`,
+		`
+		CREATE TABLE user_rule (
+			id			{{.SqlPrimaryKey}},
+			id_user		INTEGER NOT NULL REFERENCES auth_user(id),
+			id_feed		INTEGER REFERENCES feed(id),
+			definition	TEXT NOT NULL,
+			created		{{.SqlTimestamp}}
+		);`,
+	}
+}
+
+func getUserMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{
+		{
+			FromVersion: "0.0.1",
+			ToVersion:   "0.0.2",
+			SQL: []string{
+				// Subscribers stop reading the flags of the items
+				fillRuleMarksSql + `1 = 1`,
+				copyRuleMarksSql + `1 = 1`,
+			},
+		},
+	}
+}
+
+func getUserTables() []appDatabase.TableDefinition {
+
+	text := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnText}
+	}
+	timestamp := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnTimestamp}
+	}
+	boolean := func(name string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnBoolean}
+	}
+	reference := func(name string, table string) appDatabase.TableColumn {
+		return appDatabase.TableColumn{Name: name, Type: appDatabase.ColumnInteger, References: table}
+	}
+
+	return []appDatabase.TableDefinition{
+		{Name: "user_folder", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_user", "auth_user"),
+			text("name"),
+		}},
+		{Name: "user_subscription", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_user", "auth_user"),
+			reference("id_feed", "feed"),
+			reference("id_folder", "user_folder"),
+			text("title"),
+			timestamp("created"),
+		}},
+		{Name: "user_item_state", Columns: []appDatabase.TableColumn{
+			reference("id_user", "auth_user"),
+			reference("id_feed_item", "feed_item"),
+			boolean("is_read"),
+			boolean("is_starred"),
+			timestamp("updated"),
+		}},
+		{Name: "user_rule", HasId: true, Columns: []appDatabase.TableColumn{
+			reference("id_user", "auth_user"),
+			reference("id_feed", "feed"),
+			text("definition"),
+			timestamp("created"),
+		}},
+	}
+}
+
+const (
+	userModuleInitialVersion = "0.0.1"
+	userModuleVersion        = "0.0.2"
+)
+
+// Registered after the auth and feed modules, this package importing them
+var userModuleDef = appDatabase.DatabaseModuleTableCreationDef{
+	ModuleName:                 "User",
+	Version:                    userModuleVersion,
+	DatabaseModuleTableCreator: databaseUserModuleCreator,
+	DatabaseModuleTableUpdater: databaseUserModuleUpdater,
+	Tables:                     getUserTables(),
+}
+
+func init() {
+	appDatabase.RegisterDatabaseTableCreator(userModuleDef)
+
+	dbfeed.RegisterItemReference("user_item_state", "is_starred")
+	dbfeed.RegisterChangeWriter(copyRuleMarks)
+
+	// Subscriptions reference folders
+	for _, table := range []string{"user_item_state", "user_rule", "user_subscription", "user_folder"} {
+		dbauth.RegisterUserReference(table)
+	}
+}
+
+func databaseUserModuleCreator(ctx context.Context, connection *sql.Tx) error {
+
+	log.Debug("Creating user tables")
+
+	for _, row := range getUserSQL() {
+		sql, err := appDatabase.NormalizedSql(row)
+
+		if err != nil {
+			return err
+		}
+
+		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+		_, err = connection.ExecContext(ctx, sql)
+		if err != nil {
+			appLog.DebugError(err, "Unable to create user tables")
+			return err
+		}
+	}
+
+	err := appDatabase.RunMigrations(ctx, connection, userModuleInitialVersion, userModuleVersion, getUserMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate user tables")
+		return err
+	}
+
+	log.Debug("User tables created")
+	return nil
+}
+
+func databaseUserModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating user tables")
+
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, userModuleVersion, getUserMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate user tables")
+		return err
+	}
+
+	log.Debug("User tables updated")
+	return nil
+}
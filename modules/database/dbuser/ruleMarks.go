@@ -0,0 +1,85 @@
+package dbuser
+
+import (
+	"context"
+	"database/sql"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+const ruleMarksBatchSize = 200
+
+// Copies the read and starred marks feed rules set on items into the states of the subscribers having none,
+// the flags of the items only being shown when authentication is disabled. Completed by a condition on the items.
+const copyRuleMarksSql = `
+	INSERT INTO user_item_state (id_user, id_feed_item, is_read, is_starred, updated)
+	SELECT
+		user_subscription.id_user,
+		feed_item.id,
+		CASE WHEN feed_item.is_read = TRUE THEN TRUE END,
+		CASE WHEN feed_item.is_starred = TRUE THEN TRUE END,
+		CURRENT_TIMESTAMP
+	FROM feed_item
+	JOIN user_subscription
+		ON user_subscription.id_feed = feed_item.id_feed
+	WHERE
+		    (feed_item.is_read = TRUE OR feed_item.is_starred = TRUE)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM user_item_state existing
+			WHERE
+				    existing.id_user = user_subscription.id_user
+				AND existing.id_feed_item = feed_item.id
+		)
+		AND `
+
+// Completes the states left unset by subscribers with the marks of feed rules. Completed by a condition on the states.
+const fillRuleMarksSql = `
+	UPDATE user_item_state SET
+		is_read = COALESCE(is_read, (
+			SELECT CASE WHEN feed_item.is_read = TRUE THEN TRUE END
+			FROM feed_item
+			WHERE feed_item.id = user_item_state.id_feed_item
+		)),
+		is_starred = COALESCE(is_starred, (
+			SELECT CASE WHEN feed_item.is_starred = TRUE THEN TRUE END
+			FROM feed_item
+			WHERE feed_item.id = user_item_state.id_feed_item
+		))
+	WHERE
+		    (is_read IS NULL OR is_starred IS NULL)
+		AND `
+
+// Gives the subscribers of a feed the marks of its new items, in the transaction saving them
+func copyRuleMarks(ctx context.Context, tx *sql.Tx, changes []*dbfeed.Change) error {
+
+	itemIds := make([]interface{}, 0)
+	for _, change := range changes {
+		if change.Type == dbfeed.ChangeItemInserted && (change.Item.Read || change.Item.Starred) {
+			itemIds = append(itemIds, change.Item.Id)
+		}
+	}
+
+	for start := 0; start < len(itemIds); start += ruleMarksBatchSize {
+
+		end := start + ruleMarksBatchSize
+		if end > len(itemIds) {
+			end = len(itemIds)
+		}
+
+		normalized, err := appDatabase.NormalizedSql(copyRuleMarksSql + `feed_item.id IN ` + appDatabase.InPlaceholders(end-start))
+		if err != nil {
+			appLog.DebugError(err, err)
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, normalized, itemIds[start:end]...)
+		if err != nil {
+			appLog.DebugError(err, "Unable to copy the marks of feed rules to subscribers")
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,574 @@
+package dbuser
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+type Folder struct {
+	Id   appDatabase.PrimaryKey `json:"id"`
+	Name string                 `json:"name"`
+}
+
+// Subscription of a user to a shared feed
+type Subscription struct {
+	Id       appDatabase.PrimaryKey  `json:"id"`
+	FeedId   appDatabase.PrimaryKey  `json:"feedId"`
+	FolderId *appDatabase.PrimaryKey `json:"folderId"`
+	// Overrides the feed title when set
+	Title   string     `json:"title"`
+	Created *time.Time `json:"created"`
+}
+
+// Flags of an item for a user, nil values leaving them to the user rules
+type ItemState struct {
+	Read    *bool `json:"read"`
+	Starred *bool `json:"starred"`
+}
+
+// Rule applied to the items a user reads, on every subscription when FeedId is nil
+type Rule struct {
+	Id      appDatabase.PrimaryKey  `json:"id"`
+	FeedId  *appDatabase.PrimaryKey `json:"feedId"`
+	Rule    *config.Rule            `json:"rule"`
+	Created *time.Time              `json:"created"`
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (store *Store) GetFolders(ctx context.Context, userId appDatabase.PrimaryKey) ([]*Folder, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT
+			id,
+			name
+		FROM user_folder
+		WHERE id_user = ?
+		ORDER BY name
+	`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	folders := make([]*Folder, 0)
+	for rows.Next() {
+		folder := new(Folder)
+		err = rows.Scan(&folder.Id, &folder.Name)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
+
+func (store *Store) CreateFolder(ctx context.Context, userId appDatabase.PrimaryKey, folder *Folder) error {
+
+	newId, err := store.execGetId(ctx, `
+		INSERT INTO user_folder (id_user, name)
+		VALUES (?, ?)
+	`,
+		userId,
+		appDatabase.StrWithMaxLength(folder.Name, 200),
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a folder")
+		return err
+	}
+
+	folder.Id = appDatabase.PrimaryKey(newId)
+	return nil
+}
+
+// Removes a folder, its subscriptions being moved out of it, returns false when the user has no such folder
+func (store *Store) DeleteFolder(ctx context.Context, userId appDatabase.PrimaryKey, folderId appDatabase.PrimaryKey) (bool, error) {
+
+	var deleted bool
+
+	err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		_, err := store.txExec(ctx, tx, `
+			UPDATE user_subscription SET
+				id_folder = NULL
+			WHERE
+				    id_folder = ?
+				AND id_user = ?
+		`, folderId, userId)
+		if err != nil {
+			return err
+		}
+
+		affected, err := store.txExec(ctx, tx, `DELETE FROM user_folder WHERE id = ? AND id_user = ?`, folderId, userId)
+		deleted = affected > 0
+		return err
+	})
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the folder")
+	}
+	return deleted, err
+}
+
+const subscriptionColumns = `
+	id,
+	id_feed,
+	id_folder,
+	COALESCE(title, ''),
+	created
+`
+
+func (store *Store) GetSubscriptions(ctx context.Context, userId appDatabase.PrimaryKey) ([]*Subscription, error) {
+	return store.querySubscriptions(ctx, `SELECT `+subscriptionColumns+` FROM user_subscription WHERE id_user = ? ORDER BY id`, userId)
+}
+
+// Returns nil when the user is not subscribed to the feed
+func (store *Store) SubscriptionByFeed(ctx context.Context, userId appDatabase.PrimaryKey, feedId appDatabase.PrimaryKey) (*Subscription, error) {
+
+	subscriptions, err := store.querySubscriptions(ctx, `SELECT `+subscriptionColumns+` FROM user_subscription WHERE id_user = ? AND id_feed = ?`, userId, feedId)
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+	return subscriptions[0], nil
+}
+
+func (store *Store) CreateSubscription(ctx context.Context, userId appDatabase.PrimaryKey, subscription *Subscription) error {
+
+	err := store.checkFolder(ctx, userId, subscription.FolderId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	var newId int64
+	err = store.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		newId, err = store.txExecGetId(ctx, tx, `
+			INSERT INTO user_subscription (id_user, id_feed, id_folder, title, created)
+			VALUES (?, ?, ?, ?, ?)
+		`,
+			userId,
+			subscription.FeedId,
+			subscription.FolderId,
+			subscription.Title,
+			now,
+		)
+		if err != nil {
+			appLog.DebugError(err, "An error occured while saving a subscription")
+			return err
+		}
+
+		// States kept from a previous subscription to the feed are completed
+		_, err = store.txExec(ctx, tx, fillRuleMarksSql+`id_user = ? AND id_feed_item IN (SELECT id FROM feed_item WHERE id_feed = ?)`, userId, subscription.FeedId)
+		if err == nil {
+			_, err = store.txExec(ctx, tx, copyRuleMarksSql+`feed_item.id_feed = ? AND user_subscription.id_user = ?`, subscription.FeedId, userId)
+		}
+		if err != nil {
+			appLog.DebugError(err, "Unable to copy the marks of feed rules to the subscription")
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	subscription.Id = appDatabase.PrimaryKey(newId)
+	subscription.Created = &now
+	return nil
+}
+
+// Updates the folder and title of a subscription, returns false when the user has no such subscription
+func (store *Store) UpdateSubscription(ctx context.Context, userId appDatabase.PrimaryKey, subscription *Subscription) (bool, error) {
+
+	err := store.checkFolder(ctx, userId, subscription.FolderId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := store.exec(ctx, `
+		UPDATE user_subscription SET
+			id_folder = ?,
+			title = ?
+		WHERE
+			    id = ?
+			AND id_user = ?
+	`,
+		subscription.FolderId,
+		subscription.Title,
+		subscription.Id,
+		userId,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while updating a subscription")
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Returns false when the user has no such subscription
+func (store *Store) DeleteSubscription(ctx context.Context, userId appDatabase.PrimaryKey, subscriptionId appDatabase.PrimaryKey) (bool, error) {
+
+	affected, err := store.exec(ctx, `DELETE FROM user_subscription WHERE id = ? AND id_user = ?`, subscriptionId, userId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the subscription")
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Whether the item belongs to a feed the user is subscribed to
+func (store *Store) IsItemVisible(ctx context.Context, userId appDatabase.PrimaryKey, itemId appDatabase.PrimaryKey) (bool, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT
+			1
+		FROM feed_item
+		JOIN user_subscription
+			ON user_subscription.id_feed = feed_item.id_feed
+		WHERE
+			    feed_item.id = ?
+			AND user_subscription.id_user = ?
+	`, itemId, userId)
+	if err != nil {
+		return false, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	return rows.Next(), rows.Err()
+}
+
+// Returns the states set by the user on the items of a feed, by item id
+func (store *Store) GetItemStates(ctx context.Context, userId appDatabase.PrimaryKey, feedId appDatabase.PrimaryKey) (map[appDatabase.PrimaryKey]*ItemState, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT
+			user_item_state.id_feed_item,
+			user_item_state.is_read,
+			user_item_state.is_starred
+		FROM user_item_state
+		JOIN feed_item
+			ON feed_item.id = user_item_state.id_feed_item
+		WHERE
+			    user_item_state.id_user = ?
+			AND feed_item.id_feed = ?
+	`, userId, feedId)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	states := make(map[appDatabase.PrimaryKey]*ItemState)
+	for rows.Next() {
+		var itemId appDatabase.PrimaryKey
+		var read, starred sql.NullBool
+
+		err = rows.Scan(&itemId, &read, &starred)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		state := new(ItemState)
+		if read.Valid {
+			state.Read = &read.Bool
+		}
+		if starred.Valid {
+			state.Starred = &starred.Bool
+		}
+		states[itemId] = state
+	}
+	return states, rows.Err()
+}
+
+// Sets the flags of an item for a user, nil values being left unchanged
+func (store *Store) SetItemState(ctx context.Context, userId appDatabase.PrimaryKey, itemId appDatabase.PrimaryKey, state *ItemState) error {
+	return store.SetItemsState(ctx, userId, []appDatabase.PrimaryKey{itemId}, state)
+}
+
+// Sets the flags of several items at once, nil values being left unchanged
+func (store *Store) SetItemsState(ctx context.Context, userId appDatabase.PrimaryKey, itemIds []appDatabase.PrimaryKey, state *ItemState) error {
+
+	now := time.Now()
+
+	err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		for _, itemId := range itemIds {
+
+			affected, err := store.txExec(ctx, tx, `
+				UPDATE user_item_state SET
+					is_read = COALESCE(?, is_read),
+					is_starred = COALESCE(?, is_starred),
+					updated = ?
+				WHERE
+					    id_user = ?
+					AND id_feed_item = ?
+			`, state.Read, state.Starred, now, userId, itemId)
+			if err != nil {
+				return err
+			}
+			if affected > 0 {
+				continue
+			}
+
+			_, err = store.txExec(ctx, tx, `
+				INSERT INTO user_item_state (id_user, id_feed_item, is_read, is_starred, updated)
+				VALUES (?, ?, ?, ?, ?)
+			`, userId, itemId, state.Read, state.Starred, now)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		appLog.DebugError(err, "Unable to save the item state")
+	}
+	return err
+}
+
+func (store *Store) GetRules(ctx context.Context, userId appDatabase.PrimaryKey) ([]*Rule, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT
+			id,
+			id_feed,
+			definition,
+			created
+		FROM user_rule
+		WHERE id_user = ?
+		ORDER BY id
+	`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	userRules := make([]*Rule, 0)
+	for rows.Next() {
+		var definition string
+		var createdRawValue interface{}
+		userRule := new(Rule)
+
+		err = rows.Scan(&userRule.Id, &userRule.FeedId, &definition, &createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(definition), &userRule.Rule)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to read the definition of rule (%d)", userRule.Id))
+			return nil, err
+		}
+
+		userRule.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse created date")
+			return nil, err
+		}
+
+		userRules = append(userRules, userRule)
+	}
+	return userRules, rows.Err()
+}
+
+func (store *Store) CreateRule(ctx context.Context, userId appDatabase.PrimaryKey, userRule *Rule) error {
+
+	definition, err := json.Marshal(userRule.Rule)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	newId, err := store.execGetId(ctx, `
+		INSERT INTO user_rule (id_user, id_feed, definition, created)
+		VALUES (?, ?, ?, ?)
+	`,
+		userId,
+		userRule.FeedId,
+		string(definition),
+		now,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a rule")
+		return err
+	}
+
+	userRule.Id = appDatabase.PrimaryKey(newId)
+	userRule.Created = &now
+	return nil
+}
+
+// Returns false when the user has no such rule
+func (store *Store) DeleteRule(ctx context.Context, userId appDatabase.PrimaryKey, ruleId appDatabase.PrimaryKey) (bool, error) {
+
+	affected, err := store.exec(ctx, `DELETE FROM user_rule WHERE id = ? AND id_user = ?`, ruleId, userId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the rule")
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (store *Store) checkFolder(ctx context.Context, userId appDatabase.PrimaryKey, folderId *appDatabase.PrimaryKey) error {
+
+	if folderId == nil {
+		return nil
+	}
+
+	rows, err := store.query(ctx, `SELECT 1 FROM user_folder WHERE id = ? AND id_user = ?`, *folderId, userId)
+	if err != nil {
+		return err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	if !rows.Next() {
+		return fmt.Errorf("Folder (%d) not found", *folderId)
+	}
+	return rows.Err()
+}
+
+func (store *Store) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]*Subscription, error) {
+
+	rows, err := store.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	subscriptions := make([]*Subscription, 0)
+	for rows.Next() {
+		var createdRawValue interface{}
+		subscription := new(Subscription)
+
+		err = rows.Scan(&subscription.Id, &subscription.FeedId, &subscription.FolderId, &subscription.Title, &createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		subscription.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse created date")
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (store *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+
+	rows, err := store.db.QueryContext(ctx, normalized, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Returns the number of affected rows
+func (store *Store) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return 0, err
+	}
+
+	result, err := store.db.ExecContext(ctx, normalized, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (store *Store) execGetId(ctx context.Context, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(appDatabase.PrepareExecSQL(query))
+	if err != nil {
+		appLog.DebugError(err, err)
+		return 0, err
+	}
+
+	stmt, err := store.db.PrepareContext(ctx, normalized)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return 0, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	return appDatabase.SqlExecGetId(ctx, stmt, args...)
+}
+
+func (store *Store) txExecGetId(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(appDatabase.PrepareExecSQL(query))
+	if err != nil {
+		appLog.DebugError(err, err)
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, normalized)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return 0, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	return appDatabase.SqlExecGetId(ctx, stmt, args...)
+}
+
+func (store *Store) txExec(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, normalized, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (store *Store) inTransaction(ctx context.Context, fct func(tx *sql.Tx) error) error {
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin a transaction")
+		return err
+	}
+
+	err = fct(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,107 @@
+package dbwebhook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Items are not referenced, deliveries outliving purged items
+func getWebhookSQL() []string {
+	return []string{
+		`
+		CREATE TABLE webhook_delivery (
+			id				{{.SqlPrimaryKey}},
+			webhook			VARCHAR(200) NOT NULL,
+			event			VARCHAR(50) NOT NULL,
+			id_feed_item	INTEGER,
+			content_type	VARCHAR(200),
+			payload			TEXT NOT NULL,
+			status			VARCHAR(20) NOT NULL,
+			attempts		INTEGER NOT NULL DEFAULT 0,
+			next_attempt	{{.SqlTimestamp}},
+			created			{{.SqlTimestamp}},
+			updated			{{.SqlTimestamp}}
+		);`,
+		`CREATE INDEX webhook_delivery_status_idx ON webhook_delivery(status, next_attempt);`,
+		`
+		CREATE TABLE webhook_attempt (
+			id				{{.SqlPrimaryKey}},
+			id_delivery		INTEGER NOT NULL REFERENCES webhook_delivery(id),
+			attempted		{{.SqlTimestamp}},
+			status_code		INTEGER,
+			error			TEXT,
+			duration_ms		INTEGER
+		);`,
+		`CREATE INDEX webhook_attempt_delivery_idx ON webhook_attempt(id_delivery);`,
+	}
+}
+
+func getWebhookMigrations() []appDatabase.DatabaseModuleMigration {
+	return []appDatabase.DatabaseModuleMigration{}
+}
+
+const (
+	webhookModuleInitialVersion = "0.0.1"
+	webhookModuleVersion        = "0.0.1"
+)
+
+var webhookModuleDef = appDatabase.DatabaseModuleTableCreationDef{
+	ModuleName:                 "Webhook",
+	Version:                    webhookModuleVersion,
+	DatabaseModuleTableCreator: databaseWebhookModuleCreator,
+	DatabaseModuleTableUpdater: databaseWebhookModuleUpdater,
+}
+
+func init() {
+	appDatabase.RegisterDatabaseTableCreator(webhookModuleDef)
+}
+
+func databaseWebhookModuleCreator(ctx context.Context, connection *sql.Tx) error {
+
+	log.Debug("Creating webhook tables")
+
+	for _, row := range getWebhookSQL() {
+		sql, err := appDatabase.NormalizedSql(row)
+
+		if err != nil {
+			return err
+		}
+
+		log.Debug(fmt.Sprintf("Running command :\n%s", sql))
+
+		_, err = connection.ExecContext(ctx, sql)
+		if err != nil {
+			appLog.DebugError(err, "Unable to create webhook tables")
+			return err
+		}
+	}
+
+	err := appDatabase.RunMigrations(ctx, connection, webhookModuleInitialVersion, webhookModuleVersion, getWebhookMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate webhook tables")
+		return err
+	}
+
+	log.Debug("Webhook tables created")
+	return nil
+}
+
+func databaseWebhookModuleUpdater(ctx context.Context, connection *sql.Tx, oldVersion string) error {
+
+	log.Debug("Updating webhook tables")
+
+	err := appDatabase.RunMigrations(ctx, connection, oldVersion, webhookModuleVersion, getWebhookMigrations())
+	if err != nil {
+		appLog.DebugError(err, "Unable to migrate webhook tables")
+		return err
+	}
+
+	log.Debug("Webhook tables updated")
+	return nil
+}
@@ -0,0 +1,401 @@
+package dbwebhook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// Given up once every attempt failed
+	StatusFailed = "failed"
+)
+
+// Rendered payload sent to a webhook, kept until delivered or given up
+type Delivery struct {
+	Id          appDatabase.PrimaryKey  `json:"id"`
+	Webhook     string                  `json:"webhook"`
+	Event       string                  `json:"event"`
+	ItemId      *appDatabase.PrimaryKey `json:"itemId"`
+	ContentType string                  `json:"contentType"`
+	Payload     string                  `json:"payload"`
+	Status      string                  `json:"status"`
+	Attempts    uint                    `json:"attempts"`
+	NextAttempt *time.Time              `json:"nextAttempt"`
+	Created     *time.Time              `json:"created"`
+	Updated     *time.Time              `json:"updated"`
+	AttemptLog  []*Attempt              `json:"attemptLog,omitempty"`
+}
+
+type Attempt struct {
+	Id         appDatabase.PrimaryKey `json:"id"`
+	DeliveryId appDatabase.PrimaryKey `json:"deliveryId"`
+	Attempted  *time.Time             `json:"attempted"`
+	// Zero when no answer was received
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+type preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const deliveryColumns = `
+	id,
+	webhook,
+	event,
+	id_feed_item,
+	content_type,
+	payload,
+	status,
+	attempts,
+	next_attempt,
+	created,
+	updated
+`
+
+// Queues the delivery, for an immediate attempt unless its next attempt is set
+func (store *Store) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	return store.createDelivery(ctx, store.db, delivery)
+}
+
+// Queues the delivery in the given transaction, committed along with it
+func (store *Store) CreateDeliveryTx(ctx context.Context, tx *sql.Tx, delivery *Delivery) error {
+	return store.createDelivery(ctx, tx, delivery)
+}
+
+func (store *Store) createDelivery(ctx context.Context, connection preparer, delivery *Delivery) error {
+
+	now := time.Now()
+	nextAttempt := now
+	if delivery.NextAttempt != nil {
+		nextAttempt = *delivery.NextAttempt
+	}
+
+	newId, err := store.execGetId(ctx, connection, `
+		INSERT INTO webhook_delivery (webhook, event, id_feed_item, content_type, payload, status, attempts, next_attempt, created, updated)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?, ?)
+	`,
+		appDatabase.StrWithMaxLength(delivery.Webhook, 200),
+		delivery.Event,
+		delivery.ItemId,
+		delivery.ContentType,
+		delivery.Payload,
+		StatusPending,
+		nextAttempt,
+		now,
+		now,
+	)
+	if err != nil {
+		appLog.DebugError(err, "An error occured while saving a webhook delivery")
+		return err
+	}
+
+	delivery.Id = appDatabase.PrimaryKey(newId)
+	delivery.Status = StatusPending
+	delivery.NextAttempt = &nextAttempt
+	delivery.Created = &now
+	delivery.Updated = &now
+	return nil
+}
+
+// Pending deliveries whose next attempt is due, oldest first
+func (store *Store) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	return store.queryDeliveries(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_delivery
+		WHERE status = ? AND next_attempt <= ?
+		ORDER BY next_attempt, id
+		LIMIT ?
+	`, StatusPending, now, limit)
+}
+
+// Most recent deliveries first, filters being ignored when empty
+func (store *Store) GetDeliveries(ctx context.Context, webhook string, status string, limit int, offset int) ([]*Delivery, error) {
+
+	conditions := []string{"1 = 1"}
+	args := make([]interface{}, 0)
+	if webhook != "" {
+		conditions = append(conditions, "webhook = ?")
+		args = append(args, webhook)
+	}
+	if status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, status)
+	}
+	args = append(args, limit, offset)
+
+	deliveries, err := store.queryDeliveries(ctx, fmt.Sprintf(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_delivery
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delivery := range deliveries {
+		delivery.AttemptLog, err = store.getAttempts(ctx, delivery.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, nil
+}
+
+// Logs the attempt and saves the delivery state in a single transaction
+func (store *Store) RecordAttempt(ctx context.Context, delivery *Delivery, attempt *Attempt) error {
+
+	now := time.Now()
+	attempt.DeliveryId = delivery.Id
+
+	err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		_, err := store.txExec(ctx, tx, `
+			INSERT INTO webhook_attempt (id_delivery, attempted, status_code, error, duration_ms)
+			VALUES (?, ?, ?, ?, ?)
+		`,
+			delivery.Id,
+			attempt.Attempted,
+			attempt.StatusCode,
+			attempt.Error,
+			attempt.DurationMs,
+		)
+		if err != nil {
+			appLog.DebugError(err, "An error occured while saving a webhook attempt")
+			return err
+		}
+
+		_, err = store.txExec(ctx, tx, `
+			UPDATE webhook_delivery SET
+				status = ?,
+				attempts = ?,
+				next_attempt = ?,
+				updated = ?
+			WHERE id = ?
+		`,
+			delivery.Status,
+			delivery.Attempts,
+			delivery.NextAttempt,
+			now,
+			delivery.Id,
+		)
+		if err != nil {
+			appLog.DebugError(err, "An error occured while updating a webhook delivery")
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	delivery.Updated = &now
+	return nil
+}
+
+// Removes delivered and failed deliveries last updated before the given time, returning their count
+func (store *Store) DeleteEnded(ctx context.Context, before time.Time) (int64, error) {
+
+	var deleted int64
+	err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+
+		_, err := store.txExec(ctx, tx, `
+			DELETE FROM webhook_attempt WHERE id_delivery IN (
+				SELECT id FROM webhook_delivery WHERE status <> ? AND updated < ?
+			)
+		`, StatusPending, before)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = store.txExec(ctx, tx, `DELETE FROM webhook_delivery WHERE status <> ? AND updated < ?`, StatusPending, before)
+		return err
+	})
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete webhook deliveries")
+	}
+	return deleted, err
+}
+
+func (store *Store) getAttempts(ctx context.Context, deliveryId appDatabase.PrimaryKey) ([]*Attempt, error) {
+
+	rows, err := store.query(ctx, `
+		SELECT
+			id,
+			id_delivery,
+			attempted,
+			status_code,
+			error,
+			duration_ms
+		FROM webhook_attempt
+		WHERE id_delivery = ?
+		ORDER BY id
+	`, deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	attempts := make([]*Attempt, 0)
+	for rows.Next() {
+
+		attempt := new(Attempt)
+		var attemptedRawValue interface{}
+		var statusCode, durationMs sql.NullInt64
+		var attemptError sql.NullString
+
+		err = rows.Scan(&attempt.Id, &attempt.DeliveryId, &attemptedRawValue, &statusCode, &attemptError, &durationMs)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		attempt.StatusCode = int(statusCode.Int64)
+		attempt.Error = attemptError.String
+		attempt.DurationMs = durationMs.Int64
+		attempt.Attempted, err = appDatabase.SqlDateParse(attemptedRawValue)
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse attempt date")
+			return nil, err
+		}
+
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+func (store *Store) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]*Delivery, error) {
+
+	rows, err := store.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer appDatabase.DeferRowsCloseFct(rows)()
+
+	deliveries := make([]*Delivery, 0)
+	for rows.Next() {
+
+		delivery := new(Delivery)
+		var contentType sql.NullString
+		var nextAttemptRawValue, createdRawValue, updatedRawValue interface{}
+
+		err = rows.Scan(
+			&delivery.Id,
+			&delivery.Webhook,
+			&delivery.Event,
+			&delivery.ItemId,
+			&contentType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&nextAttemptRawValue,
+			&createdRawValue,
+			&updatedRawValue,
+		)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return nil, err
+		}
+
+		delivery.ContentType = contentType.String
+		delivery.NextAttempt, err = appDatabase.SqlDateParse(nextAttemptRawValue)
+		if err == nil {
+			delivery.Created, err = appDatabase.SqlDateParse(createdRawValue)
+		}
+		if err == nil {
+			delivery.Updated, err = appDatabase.SqlDateParse(updatedRawValue)
+		}
+		if err != nil {
+			appLog.DebugError(err, "Unable to parse delivery dates")
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (store *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		appLog.DebugError(err, err)
+		return nil, err
+	}
+
+	rows, err := store.db.QueryContext(ctx, normalized, args...)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result rows")
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (store *Store) execGetId(ctx context.Context, connection preparer, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(appDatabase.PrepareExecSQL(query))
+	if err != nil {
+		appLog.DebugError(err, err)
+		return 0, err
+	}
+
+	stmt, err := connection.PrepareContext(ctx, normalized)
+	if err != nil {
+		appLog.DebugError(err, "Unable to prepare statement")
+		return 0, err
+	}
+	defer appDatabase.DeferStmtCloseFct(stmt)()
+
+	return appDatabase.SqlExecGetId(ctx, stmt, args...)
+}
+
+// Returns the number of affected rows
+func (store *Store) txExec(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+
+	normalized, err := appDatabase.NormalizedSql(query)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, normalized, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (store *Store) inTransaction(ctx context.Context, fct func(tx *sql.Tx) error) error {
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin a transaction")
+		return err
+	}
+
+	err = fct(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,604 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	exportFormat        = "rssreader"
+	exportFormatVersion = 1
+
+	exportLineHeader = "header"
+	exportLineModule = "module"
+	exportLineRow    = "row"
+
+	// Rows of a single line may hold whole item contents
+	exportMaxLineSize = 64 * 1024 * 1024
+)
+
+type ColumnType int
+
+const (
+	ColumnInteger ColumnType = iota
+	ColumnText
+	ColumnBoolean
+	ColumnTimestamp
+)
+
+type TableColumn struct {
+	Name string
+	Type ColumnType
+	// Name of the table whose id this column holds, if any
+	References string
+	// Whether the referenced row may be gone, the column being emptied on import then
+	Loose bool
+}
+
+// Describes a module table for exports, tables being listed in dependency order.
+// Modules leave out the tables of transient rows, as queued deliveries or events.
+type TableDefinition struct {
+	Name string
+	// Whether the table has a generated [id] column, remapped on import
+	HasId   bool
+	Columns []TableColumn
+}
+
+type exportLine struct {
+	Type          string                 `json:"type"`
+	Format        string                 `json:"format,omitempty"`
+	FormatVersion int                    `json:"formatVersion,omitempty"`
+	Driver        string                 `json:"driver,omitempty"`
+	Created       *time.Time             `json:"created,omitempty"`
+	Module        string                 `json:"module,omitempty"`
+	Version       string                 `json:"version,omitempty"`
+	LastUpdate    *time.Time             `json:"lastUpdate,omitempty"`
+	Table         string                 `json:"table,omitempty"`
+	Values        map[string]interface{} `json:"values,omitempty"`
+}
+
+type ImportReport struct {
+	Modules map[string]string `json:"modules"`
+	Rows    map[string]int    `json:"rows"`
+}
+
+type pendingReference struct {
+	table  string
+	column TableColumn
+	id     int64
+	oldId  int64
+}
+
+func Export(ctx context.Context, writer io.Writer) error {
+
+	if database == nil {
+		return errors.New("No database connected")
+	}
+
+	log.Debug("Exporting the database")
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin transaction")
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			appLog.DebugError(err, "Unable to end the export transaction")
+		}
+	}()
+
+	buffered := bufio.NewWriter(writer)
+	encoder := json.NewEncoder(buffered)
+
+	now := time.Now()
+	err = encoder.Encode(exportLine{
+		Type:          exportLineHeader,
+		Format:        exportFormat,
+		FormatVersion: exportFormatVersion,
+		Driver:        dbDriver,
+		Created:       &now,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, databaseTableCreatorDef := range registeredTableCreatorDefs {
+
+		moduleDef, err := fetchModuleByName(ctx, tx, databaseTableCreatorDef.ModuleName)
+		if err != nil {
+			appLog.DebugError(err, "Unable to fetch module version")
+			return err
+		}
+		if moduleDef == nil {
+			continue
+		}
+
+		err = encoder.Encode(exportLine{
+			Type:       exportLineModule,
+			Module:     moduleDef.ModuleName,
+			Version:    moduleDef.Version,
+			LastUpdate: moduleDef.LastUpdate,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, table := range databaseTableCreatorDef.Tables {
+			err = exportTable(ctx, tx, encoder, table)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to export table [%s]", table.Name))
+				return err
+			}
+		}
+	}
+
+	return buffered.Flush()
+}
+
+func exportTable(ctx context.Context, tx *sql.Tx, encoder *json.Encoder, table TableDefinition) error {
+
+	columns := table.columnNames()
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), table.Name)
+	if table.HasId {
+		query += " ORDER BY id"
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get result row")
+		return err
+	}
+	defer DeferRowsCloseFct(rows)()
+
+	count := 0
+	for rows.Next() {
+
+		rawValues := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range rawValues {
+			pointers[i] = &rawValues[i]
+		}
+
+		err = rows.Scan(pointers...)
+		if err != nil {
+			appLog.DebugError(err, "Unable to affect results")
+			return err
+		}
+
+		values := make(map[string]interface{}, len(columns))
+		for i, column := range table.allColumns() {
+			values[column.Name], err = exportedValue(column, rawValues[i])
+			if err != nil {
+				return fmt.Errorf("Unable to export column [%s.%s], %s", table.Name, column.Name, err)
+			}
+		}
+
+		err = encoder.Encode(exportLine{
+			Type:   exportLineRow,
+			Table:  table.Name,
+			Values: values,
+		})
+		if err != nil {
+			return err
+		}
+		count++
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	log.Debug(fmt.Sprintf("%d rows exported from table [%s]", count, table.Name))
+	return nil
+}
+
+// Loads an export into the connected database, which must not hold any data yet
+func Import(ctx context.Context, reader io.Reader) (*ImportReport, error) {
+
+	if database == nil {
+		return nil, errors.New("No database connected")
+	}
+
+	log.Debug("Importing into the database")
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		appLog.DebugError(err, "Unable to begin transaction")
+		return nil, err
+	}
+
+	report, err := importLines(ctx, tx, reader)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			appLog.DebugError(rollbackErr, "Unable to rollback the transaction")
+		}
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		appLog.DebugError(err, "Unable to commit transaction")
+		return nil, err
+	}
+	return report, nil
+}
+
+func importLines(ctx context.Context, tx *sql.Tx, reader io.Reader) (*ImportReport, error) {
+
+	report := &ImportReport{
+		Modules: map[string]string{},
+		Rows:    map[string]int{},
+	}
+
+	tables := map[string]TableDefinition{}
+	tableModules := map[string]string{}
+	for _, databaseTableCreatorDef := range registeredTableCreatorDefs {
+		for _, table := range databaseTableCreatorDef.Tables {
+			tables[table.Name] = table
+			tableModules[table.Name] = databaseTableCreatorDef.ModuleName
+		}
+	}
+
+	err := checkEmptyTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]map[int64]int64{}
+	pending := make([]pendingReference, 0)
+	statements := map[string]*sql.Stmt{}
+	defer func() {
+		for _, stmt := range statements {
+			DeferStmtCloseFct(stmt)()
+		}
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), exportMaxLineSize)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var line exportLine
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		err = decoder.Decode(&line)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decode line %d, %s", lineNo, err)
+		}
+
+		if lineNo == 1 && line.Type != exportLineHeader {
+			return nil, errors.New("Missing export header, this is not a database export")
+		}
+
+		switch line.Type {
+		case exportLineHeader:
+			if line.Format != exportFormat || line.FormatVersion != exportFormatVersion {
+				return nil, fmt.Errorf("Unsupported export format [%s:%d]", line.Format, line.FormatVersion)
+			}
+			log.Debug(fmt.Sprintf("Importing an export of a [%s] database", line.Driver))
+
+		case exportLineModule:
+			installed, err := fetchModuleByName(ctx, tx, line.Module)
+			if err != nil {
+				appLog.DebugError(err, "Unable to fetch module version")
+				return nil, err
+			}
+			if installed == nil {
+				return nil, fmt.Errorf("Module [%s] of the export is not installed", line.Module)
+			}
+			if installed.Version != line.Version {
+				return nil, fmt.Errorf("Module [%s] is exported in version [%s] while version [%s] is installed", line.Module, line.Version, installed.Version)
+			}
+			report.Modules[line.Module] = line.Version
+
+			if line.LastUpdate != nil {
+				err = updateModuleLastUpdate(ctx, tx, line.Module, *line.LastUpdate)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		case exportLineRow:
+			table, ok := tables[line.Table]
+			if !ok {
+				return nil, fmt.Errorf("Unknown table [%s] at line %d", line.Table, lineNo)
+			}
+			if _, ok := report.Modules[tableModules[line.Table]]; !ok {
+				return nil, fmt.Errorf("Rows of table [%s] given before its module version", line.Table)
+			}
+
+			rowPending, err := importRow(ctx, tx, statements, ids, table, line.Values)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to import line %d, %s", lineNo, err)
+			}
+			pending = append(pending, rowPending...)
+			report.Rows[line.Table]++
+
+		default:
+			return nil, fmt.Errorf("Unknown line type [%s] at line %d", line.Type, lineNo)
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	// References to rows of the same table, which may come later
+	for _, reference := range pending {
+		newId, ok := ids[reference.column.References][reference.oldId]
+		if !ok {
+			return nil, fmt.Errorf("Column [%s.%s] references an unknown id (%d)", reference.table, reference.column.Name, reference.oldId)
+		}
+
+		key := reference.table + "." + reference.column.Name
+		stmt, ok := statements[key]
+		if !ok {
+			query, err := NormalizedSql(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", reference.table, reference.column.Name))
+			if err != nil {
+				return nil, err
+			}
+
+			stmt, err = tx.PrepareContext(ctx, query)
+			if err != nil {
+				appLog.DebugError(err, "Unable to prepare UPDATE statement")
+				return nil, err
+			}
+			statements[key] = stmt
+		}
+
+		err = SqlExec(ctx, stmt, newId, reference.id)
+		if err != nil {
+			appLog.DebugError(err, "Unable to update a reference")
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+func importRow(ctx context.Context, tx *sql.Tx, statements map[string]*sql.Stmt, ids map[string]map[int64]int64, table TableDefinition, values map[string]interface{}) ([]pendingReference, error) {
+
+	args := make([]interface{}, 0, len(table.Columns))
+	selfReferences := make([]pendingReference, 0)
+
+	for _, column := range table.Columns {
+
+		value, err := importedValue(column, values[column.Name])
+		if err != nil {
+			return nil, fmt.Errorf("Bad value for column [%s.%s], %s", table.Name, column.Name, err)
+		}
+
+		if column.References != "" && value != nil {
+			oldId := value.(int64)
+			if column.References == table.Name {
+				selfReferences = append(selfReferences, pendingReference{
+					table:  table.Name,
+					column: column,
+					oldId:  oldId,
+				})
+				value = nil
+			} else {
+				newId, ok := ids[column.References][oldId]
+				switch {
+				case ok:
+					value = newId
+				case column.Loose:
+					value = nil
+				default:
+					return nil, fmt.Errorf("Column [%s.%s] references an unknown id (%d)", table.Name, column.Name, oldId)
+				}
+			}
+		}
+		args = append(args, value)
+	}
+
+	stmt, ok := statements[table.Name]
+	if !ok {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(table.Columns)), ", ")
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table.Name, strings.Join(table.dataColumnNames(), ", "), placeholders)
+		if table.HasId {
+			query = PrepareExecSQL(query)
+		}
+
+		normalized, err := NormalizedSql(query)
+		if err != nil {
+			return nil, err
+		}
+
+		stmt, err = tx.PrepareContext(ctx, normalized)
+		if err != nil {
+			appLog.DebugError(err, "Unable to prepare INSERT statement")
+			return nil, err
+		}
+		statements[table.Name] = stmt
+	}
+
+	if !table.HasId {
+		return nil, SqlExec(ctx, stmt, args...)
+	}
+
+	newId, err := SqlExecGetId(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	oldId, err := importedValue(TableColumn{Name: "id", Type: ColumnInteger}, values["id"])
+	if err != nil || oldId == nil {
+		return nil, fmt.Errorf("Missing id for a row of table [%s]", table.Name)
+	}
+
+	if _, ok := ids[table.Name]; !ok {
+		ids[table.Name] = map[int64]int64{}
+	}
+	ids[table.Name][oldId.(int64)] = newId
+
+	for i := range selfReferences {
+		selfReferences[i].id = newId
+	}
+	return selfReferences, nil
+}
+
+// The export keeps when modules were last migrated, the import having just set it
+func updateModuleLastUpdate(ctx context.Context, tx *sql.Tx, module string, lastUpdate time.Time) error {
+
+	query, err := NormalizedSql("UPDATE system_information_table SET last_update = ? WHERE module = ?")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, lastUpdate, module)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to update the version date of module [%s]", module))
+		return err
+	}
+	return nil
+}
+
+func checkEmptyTables(ctx context.Context, tx *sql.Tx) error {
+
+	for _, databaseTableCreatorDef := range registeredTableCreatorDefs {
+		for _, table := range databaseTableCreatorDef.Tables {
+
+			var count int64
+			err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table.Name)).Scan(&count)
+			if err != nil {
+				appLog.DebugError(err, "Unable to count rows")
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("Table [%s] already holds data, imports are only allowed into an empty database", table.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func exportedValue(column TableColumn, value interface{}) (interface{}, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+
+	switch column.Type {
+	case ColumnInteger:
+		return toInt64(value)
+	case ColumnBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case []byte:
+			return strconv.ParseBool(string(v))
+		}
+	case ColumnTimestamp:
+		if v, ok := value.([]byte); ok {
+			value = string(v)
+		}
+		date, err := SqlDateParse(value)
+		if err != nil || date == nil {
+			return nil, err
+		}
+		return date.Format(time.RFC3339Nano), nil
+	case ColumnText:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value of type %T", value)
+}
+
+func importedValue(column TableColumn, value interface{}) (interface{}, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+
+	switch column.Type {
+	case ColumnInteger:
+		if v, ok := value.(json.Number); ok {
+			return v.Int64()
+		}
+	case ColumnBoolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case ColumnTimestamp:
+		if v, ok := value.(string); ok {
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	case ColumnText:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value of type %T", value)
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows a signed integer", v)
+		}
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %f is not an integer", v)
+		}
+		return int64(v), nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected value of type %T", value)
+}
+
+func (table TableDefinition) allColumns() []TableColumn {
+	if !table.HasId {
+		return table.Columns
+	}
+	return append([]TableColumn{{Name: "id", Type: ColumnInteger}}, table.Columns...)
+}
+
+func (table TableDefinition) columnNames() []string {
+	names := make([]string, 0, len(table.Columns)+1)
+	for _, column := range table.allColumns() {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func (table TableDefinition) dataColumnNames() []string {
+	names := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func Ping(ctx context.Context) error {
+
+	if database == nil {
+		return errors.New("The database is not connected")
+	}
+	return database.PingContext(ctx)
+}
+
+// Checks that every registered module is installed in its expected version
+func CheckModuleVersions(ctx context.Context) error {
+
+	if database == nil {
+		return errors.New("The database is not connected")
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, databaseTableCreatorDef := range registeredTableCreatorDefs {
+
+		installed, err := fetchModuleByName(ctx, tx, databaseTableCreatorDef.ModuleName)
+		if err != nil {
+			return err
+		}
+
+		if installed == nil {
+			return fmt.Errorf("Module [%s] is not installed", databaseTableCreatorDef.ModuleName)
+		}
+		if installed.Version != databaseTableCreatorDef.Version {
+			return fmt.Errorf("Module [%s] is in version [%s], [%s] expected", databaseTableCreatorDef.ModuleName, installed.Version, databaseTableCreatorDef.Version)
+		}
+	}
+	return nil
+}
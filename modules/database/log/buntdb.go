@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,7 +36,7 @@ func buntDBLogBackend() (LogBackend, error) {
 	}, nil
 }
 
-func (backendDefinition *buntDBBackendDefinition) QueryForLogs(query *LogQueryOpts) (*LogEntriesPage, error) {
+func (backendDefinition *buntDBBackendDefinition) QueryForLogs(ctx context.Context, query *LogQueryOpts) (*LogEntriesPage, error) {
 
 	//var expectedLogLevel logrus.Level
 	var err error
@@ -68,6 +69,12 @@ func (backendDefinition *buntDBBackendDefinition) QueryForLogs(query *LogQueryOp
 			var logEntry LogEntry
 			var keyMatches bool
 
+			// Stopping the scan when the request is cancelled
+			internalError = ctx.Err()
+			if internalError != nil {
+				return false
+			}
+
 			keyMatches, internalError = comparator.compareEntryKey(key)
 
 			if internalError != nil {
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"errors"
 
 	"github.com/dademo/rssreader/modules/log/hook"
@@ -23,6 +24,6 @@ func elasticsearchLogBackend() (LogBackend, error) {
 	}, nil
 }
 
-func (backendDefinition *elasticsearchBackendDefinition) QueryForLogs(query *LogQueryOpts) (*LogEntriesPage, error) {
+func (backendDefinition *elasticsearchBackendDefinition) QueryForLogs(ctx context.Context, query *LogQueryOpts) (*LogEntriesPage, error) {
 	return nil, nil
 }
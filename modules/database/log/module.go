@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -64,7 +65,7 @@ type LogEntry struct {
 }
 
 type LogBackend interface {
-	QueryForLogs(query *LogQueryOpts) (*LogEntriesPage, error)
+	QueryForLogs(ctx context.Context, query *LogQueryOpts) (*LogEntriesPage, error)
 }
 
 func GetLogBackendFromString(backendName string) (LogBackend, error) {
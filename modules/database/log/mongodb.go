@@ -62,7 +62,7 @@ func mongoDBLogBackend() (LogBackend, error) {
 	}, nil
 }
 
-func (backendDefinition *mongoDBBackendDefinition) QueryForLogs(query *LogQueryOpts) (*LogEntriesPage, error) {
+func (backendDefinition *mongoDBBackendDefinition) QueryForLogs(ctx context.Context, query *LogQueryOpts) (*LogEntriesPage, error) {
 
 	var logEntriesPage *LogEntriesPage
 	var totalElements int64
@@ -75,7 +75,7 @@ func (backendDefinition *mongoDBBackendDefinition) QueryForLogs(query *LogQueryO
 	}
 
 	ctxPing, cancelPing := context.WithTimeout(
-		context.Background(),
+		ctx,
 		backendTimeout,
 	)
 	defer cancelPing()
@@ -89,11 +89,11 @@ func (backendDefinition *mongoDBBackendDefinition) QueryForLogs(query *LogQueryO
 	logDatabase := backendDefinition.client.Database(backendDefinition.config.Database)
 	logCollection := logDatabase.Collection(backendDefinition.config.Collection)
 
-	logEntriesPage, err = executeQuery(backendDefinition, query, logCollection, mongoQuery)
+	logEntriesPage, err = executeQuery(ctx, backendDefinition, query, logCollection, mongoQuery)
 	if err != nil {
 		return nil, err
 	}
-	totalElements, err = getElementsCount(backendDefinition, query, logCollection, mongoQuery)
+	totalElements, err = getElementsCount(ctx, backendDefinition, query, logCollection, mongoQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +102,7 @@ func (backendDefinition *mongoDBBackendDefinition) QueryForLogs(query *LogQueryO
 	return logEntriesPage, nil
 }
 
-func executeQuery(backendDefinition *mongoDBBackendDefinition,
+func executeQuery(ctx context.Context, backendDefinition *mongoDBBackendDefinition,
 	query *LogQueryOpts, logCollection *mongo.Collection, mongoQuery *bson.M) (*LogEntriesPage, error) {
 
 	var err error
@@ -119,7 +119,7 @@ func executeQuery(backendDefinition *mongoDBBackendDefinition,
 	queryOpts.SetSort(bson.M{"_id": -1})
 
 	ctxQuery, cancelCtxQuery := context.WithTimeout(
-		context.Background(),
+		ctx,
 		backendTimeout,
 	)
 	defer cancelCtxQuery()
@@ -146,13 +146,13 @@ func executeQuery(backendDefinition *mongoDBBackendDefinition,
 	return &logEntriesPage, nil
 }
 
-func getElementsCount(backendDefinition *mongoDBBackendDefinition,
+func getElementsCount(ctx context.Context, backendDefinition *mongoDBBackendDefinition,
 	query *LogQueryOpts, logCollection *mongo.Collection, mongoQuery *bson.M) (int64, error) {
 
 	backendTimeout := time.Duration(backendDefinition.config.TimeoutSeconds) * time.Second
 
 	ctxQuery, cancelCtxQuery := context.WithTimeout(
-		context.Background(),
+		ctx,
 		backendTimeout,
 	)
 	defer cancelCtxQuery()
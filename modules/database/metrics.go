@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/dademo/rssreader/modules/metrics"
+)
+
+func databaseStats() sql.DBStats {
+	if database == nil {
+		return sql.DBStats{}
+	}
+	return database.Stats()
+}
+
+func init() {
+
+	metrics.NewGaugeFunc("rssreader_db_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(databaseStats().MaxOpenConnections)
+	})
+	metrics.NewGaugeFunc("rssreader_db_open_connections", "Established connections, in use or idle.", func() float64 {
+		return float64(databaseStats().OpenConnections)
+	})
+	metrics.NewGaugeFunc("rssreader_db_in_use_connections", "Connections currently in use.", func() float64 {
+		return float64(databaseStats().InUse)
+	})
+	metrics.NewGaugeFunc("rssreader_db_idle_connections", "Idle connections.", func() float64 {
+		return float64(databaseStats().Idle)
+	})
+	metrics.NewCounterFunc("rssreader_db_wait_count_total", "Connections waited for.", func() float64 {
+		return float64(databaseStats().WaitCount)
+	})
+	metrics.NewCounterFunc("rssreader_db_wait_duration_seconds_total", "Time spent waiting for a connection.", func() float64 {
+		return databaseStats().WaitDuration.Seconds()
+	})
+	metrics.NewCounterFunc("rssreader_db_max_idle_closed_total", "Connections closed because of the idle connections limit.", func() float64 {
+		return float64(databaseStats().MaxIdleClosed)
+	})
+	metrics.NewCounterFunc("rssreader_db_max_idle_time_closed_total", "Connections closed because of the idle time limit.", func() float64 {
+		return float64(databaseStats().MaxIdleTimeClosed)
+	})
+	metrics.NewCounterFunc("rssreader_db_max_lifetime_closed_total", "Connections closed because of the connection lifetime limit.", func() float64 {
+		return float64(databaseStats().MaxLifetimeClosed)
+	})
+}
@@ -40,6 +40,8 @@ type PageQuery struct {
 	PageNo   uint
 	PageSize uint
 	Sort     []SortOption
+	// Rows skipped before the first page, continuations not always starting on a page boundary
+	Offset uint
 }
 
 type SortOption struct {
@@ -47,6 +49,43 @@ type SortOption struct {
 	sortOrder SortOrder
 }
 
+func NewSortOption(field string, sortOrder SortOrder) SortOption {
+	return SortOption{
+		field:     field,
+		sortOrder: sortOrder,
+	}
+}
+
+// ORDER BY, LIMIT and OFFSET clauses of the page, sorted fields being mapped to their SQL expression
+func (query PageQuery) Sql(columns map[string]string) (string, error) {
+
+	orderBy := make([]string, 0, len(query.Sort))
+	for _, sortOption := range query.Sort {
+
+		column, ok := columns[sortOption.field]
+		if !ok {
+			return "", fmt.Errorf("Unable to sort on field [%s]", sortOption.field)
+		}
+
+		switch sortOption.sortOrder {
+		case PageSortAsc:
+			column += " ASC"
+		case PageSortDesc:
+			column += " DESC"
+		}
+		orderBy = append(orderBy, column)
+	}
+
+	clauses := ""
+	if len(orderBy) > 0 {
+		clauses = " ORDER BY " + strings.Join(orderBy, ", ")
+	}
+	if query.PageSize > 0 {
+		clauses += fmt.Sprintf(" LIMIT %d OFFSET %d", query.PageSize, query.Offset+query.PageNo*query.PageSize)
+	}
+	return clauses, nil
+}
+
 func ParseSortOptions(value string) ([]SortOption, error) {
 
 	allSortOptions := []SortOption{}
@@ -0,0 +1,151 @@
+package dedup
+
+import (
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	shingleSize       = 3
+	minimumTokenCount = 8
+
+	// Hashes less than SimHashBandCount bits apart share at least one band
+	SimHashBandCount = 4
+	simHashBandBits  = 64 / SimHashBandCount
+)
+
+// Query parameters only used to track readers
+var trackingParameters = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"ref":     true,
+	"ref_src": true,
+	"igshid":  true,
+}
+
+func CanonicalURL(link string) string {
+
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	port := parsed.Port()
+	if port != "" && port != "80" && port != "443" {
+		host = host + ":" + port
+	}
+
+	path := strings.TrimRight(parsed.EscapedPath(), "/")
+
+	query := parsed.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		lowerKey := strings.ToLower(key)
+		if !strings.HasPrefix(lowerKey, "utm_") && !trackingParameters[lowerKey] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	queryParts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			queryParts = append(queryParts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	// The scheme is ignored, the same article is often served with both http and https
+	canonical := "//" + host + path
+	if len(queryParts) > 0 {
+		canonical += "?" + strings.Join(queryParts, "&")
+	}
+	return canonical
+}
+
+func SimHash(texts ...string) uint64 {
+
+	tokens := make([]string, 0)
+	for _, text := range texts {
+		tokens = append(tokens, tokenize(extractText(text))...)
+	}
+
+	if len(tokens) < minimumTokenCount {
+		// Not enough content to get a meaningful fingerprint
+		return 0
+	}
+
+	var weights [64]int
+	for it := 0; it+shingleSize <= len(tokens); it++ {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(strings.Join(tokens[it:it+shingleSize], " ")))
+		hash := hasher.Sum64()
+
+		for bit := uint(0); bit < 64; bit++ {
+			if hash&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := uint(0); bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Splits the hash in bands, near-duplicates being looked up by them
+func SimHashBands(hash uint64) [SimHashBandCount]int64 {
+
+	var bands [SimHashBandCount]int64
+	for band := 0; band < SimHashBandCount; band++ {
+		bands[band] = int64(hash >> (band * simHashBandBits) & (1<<simHashBandBits - 1))
+	}
+	return bands
+}
+
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func extractText(content string) string {
+
+	var builder strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return builder.String()
+			}
+			// Not parseable as HTML, using it as text
+			return content
+		case html.TextToken:
+			builder.Write(tokenizer.Text())
+			builder.WriteString(" ")
+		}
+	}
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,272 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/ioutil"
+	"strings"
+	textTemplate "text/template"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbdigest"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/mail"
+	"github.com/dademo/rssreader/modules/scheduler"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	GroupByFeed     = "feed"
+	GroupByCategory = "category"
+
+	defaultSchedule = "@daily"
+	defaultSubject  = "{{ .Name }}: {{ .ItemCount }} new item{{ if ne .ItemCount 1 }}s{{ end }}"
+)
+
+// Configured digest, with its schedule and templates parsed
+type digest struct {
+	config     *config.Digest
+	schedule   *scheduler.Cron
+	feeds      map[string]bool
+	categories map[string]bool
+	subject    *textTemplate.Template
+	html       *htmlTemplate.Template
+	text       *textTemplate.Template
+}
+
+var (
+	digests     []*digest
+	digestStore *dbdigest.Store
+)
+
+var ErrUnknownDigest = errors.New("Unknown digest")
+
+func init() {
+	dbfeed.RegisterChangeListener(onChanges)
+}
+
+// Pending items being recorded in the database, digests are disabled without one, the mail module being configured first
+func Configure(config *config.EmailConfig, store *dbdigest.Store) error {
+
+	configured, err := newDigests(config)
+	if err != nil {
+		return err
+	}
+
+	digests = configured
+	digestStore = store
+
+	if len(digests) > 0 && !mail.Enabled() {
+		log.Warn("Digests need an SMTP server, they are disabled")
+	}
+	if len(digests) > 0 && store == nil {
+		log.Warn("Digests need a database, they are disabled")
+	}
+	return nil
+}
+
+// Checks the SMTP settings and the digests, nothing being configured
+func Validate(config *config.EmailConfig) error {
+
+	err := mail.Validate(config.Smtp)
+	if err != nil {
+		return err
+	}
+	_, err = newDigests(config)
+	return err
+}
+
+func Enabled() bool {
+	return digestStore != nil && mail.Enabled() && len(digests) > 0
+}
+
+func GetStore() *dbdigest.Store {
+	return digestStore
+}
+
+// Configured digests, in their configuration order
+func Digests() []*config.Digest {
+
+	configs := make([]*config.Digest, 0, len(digests))
+	for _, configured := range digests {
+		configs = append(configs, configured.config)
+	}
+	return configs
+}
+
+// Nil for unknown digests
+func Schedule(name string) *scheduler.Cron {
+	if configured := digestByName(name); configured != nil {
+		return configured.schedule
+	}
+	return nil
+}
+
+func newDigests(config *config.EmailConfig) ([]*digest, error) {
+
+	configured := make([]*digest, 0, len(config.Digests))
+
+	names := map[string]bool{}
+	for _, digestConfig := range config.Digests {
+
+		if names[digestConfig.Name] {
+			return nil, fmt.Errorf("Digest [%s] is defined twice", digestConfig.Name)
+		}
+		names[digestConfig.Name] = true
+
+		newDigest, err := newDigest(digestConfig)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Invalid digest [%s]", digestConfig.Name))
+			return nil, err
+		}
+		configured = append(configured, newDigest)
+	}
+	return configured, nil
+}
+
+func newDigest(digestConfig *config.Digest) (*digest, error) {
+
+	if digestConfig.Name == "" {
+		return nil, errors.New("Digests must be named")
+	}
+
+	if len(digestConfig.To) == 0 {
+		return nil, errors.New("Digests need at least one recipient")
+	}
+	_, err := mail.ParseAddresses(digestConfig.To)
+	if err != nil {
+		return nil, err
+	}
+
+	switch digestConfig.GroupBy {
+	case "", GroupByFeed, GroupByCategory:
+	default:
+		return nil, fmt.Errorf("Invalid digest grouping [%s], expecting %s or %s", digestConfig.GroupBy, GroupByFeed, GroupByCategory)
+	}
+
+	configured := &digest{
+		config:     digestConfig,
+		feeds:      map[string]bool{},
+		categories: map[string]bool{},
+	}
+
+	schedule := digestConfig.Schedule
+	if schedule == "" {
+		schedule = defaultSchedule
+	}
+	configured.schedule, err = scheduler.ParseCron(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, feed := range digestConfig.Feeds {
+		configured.feeds[feed] = true
+	}
+	for _, category := range digestConfig.Categories {
+		configured.categories[strings.ToLower(category)] = true
+	}
+
+	subject := digestConfig.Subject
+	if subject == "" {
+		subject = defaultSubject
+	}
+	configured.subject, err = textTemplate.New("subject").Funcs(textFuncs).Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid digest subject: %s", err)
+	}
+
+	htmlSource, err := templateSource(digestConfig.HtmlTemplate, defaultHtmlTemplate)
+	if err == nil {
+		configured.html, err = htmlTemplate.New("html").Funcs(htmlTemplate.FuncMap(textFuncs)).Parse(htmlSource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid digest HTML template: %s", err)
+	}
+
+	textSource, err := templateSource(digestConfig.TextTemplate, defaultTextTemplate)
+	if err == nil {
+		configured.text, err = textTemplate.New("text").Funcs(textFuncs).Parse(textSource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid digest text template: %s", err)
+	}
+
+	return configured, nil
+}
+
+func templateSource(path string, defaultSource string) (string, error) {
+
+	if path == "" {
+		return defaultSource, nil
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+func digestByName(name string) *digest {
+	for _, configured := range digests {
+		if configured.config.Name == name {
+			return configured
+		}
+	}
+	return nil
+}
+
+// Feeds are designated by their configuration name or their title
+func (configured *digest) matchesFeed(feed *dbfeed.Feed) bool {
+	return len(configured.feeds) == 0 || configured.feeds[feed.ConfigName] || configured.feeds[feed.Title]
+}
+
+func (configured *digest) matches(feed *dbfeed.Feed, item *dbfeed.FeedItem) bool {
+
+	if !configured.matchesFeed(feed) {
+		return false
+	}
+	if len(configured.categories) == 0 {
+		return true
+	}
+
+	categories := append(append([]*dbfeed.FeedCategory{}, feed.Categories...), item.Categories...)
+	for _, category := range categories {
+		if configured.categories[strings.ToLower(category.Category)] {
+			return true
+		}
+	}
+	for _, tag := range item.Tags {
+		if configured.categories[strings.ToLower(tag)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Remembers the new items for the next digests
+func onChanges(ctx context.Context, changes []*dbfeed.Change) {
+
+	if !Enabled() {
+		return
+	}
+
+	for _, change := range changes {
+		if change.Type != dbfeed.ChangeItemInserted {
+			continue
+		}
+		for _, configured := range digests {
+			if !configured.matches(change.Feed, change.Item) {
+				continue
+			}
+			err := digestStore.AddItem(ctx, configured.config.Name, change.Item.Id)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to add an item to digest [%s]", configured.config.Name))
+			}
+		}
+	}
+}
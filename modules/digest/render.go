@@ -0,0 +1,214 @@
+package digest
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/textdiff"
+)
+
+const (
+	summaryLength       = 300
+	uncategorizedGroup  = "Uncategorized"
+	defaultTextTemplate = `{{ .Name }}, {{ .ItemCount }} new item{{ if ne .ItemCount 1 }}s{{ end }}{{ if .Since }} since {{ date .Since }}{{ end }}
+{{ range .Groups }}
+== {{ .Name }} ==
+{{ range .Items }}
+* {{ .Title }}{{ if .Published }} ({{ date .Published }}){{ end }}
+  {{ .Link }}
+{{- if .Summary }}
+  {{ .Summary }}
+{{- end }}
+{{ end }}{{ end }}{{ if .Truncated }}
+{{ .Truncated }} more item{{ if ne .Truncated 1 }}s{{ end }} left out.
+{{ end }}`
+	defaultHtmlTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Name }}</title></head>
+<body style="font-family: sans-serif; max-width: 40em;">
+<h1>{{ .Name }}</h1>
+<p>{{ .ItemCount }} new item{{ if ne .ItemCount 1 }}s{{ end }}{{ if .Since }} since {{ date .Since }}{{ end }}</p>
+{{ range .Groups }}
+<h2>{{ if .Link }}<a href="{{ .Link }}">{{ .Name }}</a>{{ else }}{{ .Name }}{{ end }}</h2>
+<ul>
+{{ range .Items }}<li>
+<a href="{{ .Link }}">{{ .Title }}</a>{{ if .Published }} <small>{{ date .Published }}</small>{{ end }}
+{{ if .Summary }}<p>{{ .Summary }}</p>{{ end }}
+</li>
+{{ end }}</ul>
+{{ end }}{{ if .Truncated }}<p>{{ .Truncated }} more item{{ if ne .Truncated 1 }}s{{ end }} left out.</p>{{ end }}
+</body>
+</html>
+`
+)
+
+// Values given to the digest templates
+type Data struct {
+	Name string
+	// Nil for the first digest
+	Since     *time.Time
+	Until     time.Time
+	ItemCount int
+	// Items left out past the maximum
+	Truncated int
+	Groups    []*Group
+}
+
+type Group struct {
+	Name  string
+	Link  string
+	Items []*Item
+}
+
+type Item struct {
+	Title      string
+	Link       string
+	Summary    string
+	Feed       string
+	Published  *time.Time
+	Categories []string
+}
+
+var textFuncs = map[string]interface{}{
+	"date": func(date *time.Time) string {
+		if date == nil {
+			return ""
+		}
+		return date.Local().Format("2006-01-02 15:04")
+	},
+}
+
+type selectedItem struct {
+	item *dbfeed.FeedItem
+	feed *dbfeed.Feed
+	date time.Time
+}
+
+func newData(configured *digest, selected []*selectedItem, since *time.Time, until time.Time) *Data {
+
+	data := &Data{
+		Name:      configured.config.Name,
+		Since:     since,
+		Until:     until,
+		ItemCount: len(selected),
+		Groups:    []*Group{},
+	}
+
+	// Most recent first
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].date.After(selected[j].date)
+	})
+
+	if max := int(configured.config.MaxItems); max > 0 && len(selected) > max {
+		data.Truncated = len(selected) - max
+		selected = selected[:max]
+	}
+
+	groups := map[string]*Group{}
+	addTo := func(name string, link string, item *Item) {
+		group, ok := groups[name]
+		if !ok {
+			group = &Group{Name: name, Link: link, Items: []*Item{}}
+			groups[name] = group
+			data.Groups = append(data.Groups, group)
+		}
+		group.Items = append(group.Items, item)
+	}
+
+	for _, entry := range selected {
+		item := newItem(entry)
+		if configured.config.GroupBy != GroupByCategory {
+			addTo(entry.feed.Title, entry.feed.Link, item)
+			continue
+		}
+		if len(item.Categories) == 0 {
+			addTo(uncategorizedGroup, "", item)
+		}
+		for _, category := range item.Categories {
+			addTo(category, "", item)
+		}
+	}
+
+	sort.SliceStable(data.Groups, func(i, j int) bool {
+		return strings.ToLower(data.Groups[i].Name) < strings.ToLower(data.Groups[j].Name)
+	})
+	return data
+}
+
+// Categories of the item, those of its feed otherwise
+func newItem(entry *selectedItem) *Item {
+
+	categories := entry.item.Categories
+	if len(categories) == 0 {
+		categories = entry.feed.Categories
+	}
+
+	item := &Item{
+		Title:      entry.item.Title,
+		Link:       entry.item.Link,
+		Feed:       entry.feed.Title,
+		Published:  entry.item.Published,
+		Categories: make([]string, 0, len(categories)),
+	}
+	if item.Published == nil {
+		item.Published = entry.item.Updated
+	}
+
+	seen := map[string]bool{}
+	for _, category := range categories {
+		if !seen[category.Category] {
+			seen[category.Category] = true
+			item.Categories = append(item.Categories, category.Category)
+		}
+	}
+
+	summary := entry.item.Description
+	if summary == "" {
+		summary = entry.item.Content
+	}
+	item.Summary = shorten(strings.Join(strings.Fields(textdiff.HTMLToText(summary)), " "), summaryLength)
+	return item
+}
+
+func shorten(text string, length int) string {
+
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	runes := []rune(text)[:length]
+	if space := strings.LastIndex(string(runes), " "); space > 0 {
+		return string(runes)[:space] + "…"
+	}
+	return string(runes) + "…"
+}
+
+func (configured *digest) render(data *Data) (subject string, text string, html string, err error) {
+
+	var buffer bytes.Buffer
+
+	err = configured.subject.Execute(&buffer, data)
+	if err != nil {
+		return
+	}
+	subject = strings.TrimSpace(buffer.String())
+
+	buffer.Reset()
+	err = configured.text.Execute(&buffer, data)
+	if err != nil {
+		return
+	}
+	text = buffer.String()
+
+	buffer.Reset()
+	err = configured.html.Execute(&buffer, data)
+	if err != nil {
+		return
+	}
+	html = buffer.String()
+	return
+}
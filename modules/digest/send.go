@@ -0,0 +1,168 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbdigest"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/mail"
+	"github.com/dademo/rssreader/modules/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var digestsCounter = metrics.NewCounterVec(
+	"rssreader_digests_total",
+	"Digests sent by digest and result, sent, empty or failed.",
+	"digest", "result",
+)
+
+// Digest ready to be sent, along with the pending items it covers
+type Rendered struct {
+	Message *mail.Message
+	Data    *Data
+	itemIds []appDatabase.PrimaryKey
+}
+
+// Renders the digest of the pending items, nil when there is nothing to send
+func Render(ctx context.Context, store dbfeed.FeedStore, name string) (*Rendered, error) {
+
+	configured := digestByName(name)
+	if configured == nil || digestStore == nil {
+		return nil, ErrUnknownDigest
+	}
+
+	itemIds, err := digestStore.PendingItemIds(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(itemIds) == 0 && !configured.config.SendEmpty {
+		return nil, nil
+	}
+
+	selected, err := selectItems(ctx, store, configured, itemIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 && !configured.config.SendEmpty {
+		return nil, nil
+	}
+
+	since, err := digestStore.LastSent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := newData(configured, selected, since, time.Now())
+	subject, text, html, err := configured.render(data)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to render digest [%s]", name))
+		return nil, err
+	}
+
+	return &Rendered{
+		Message: &mail.Message{
+			To:      configured.config.To,
+			Subject: subject,
+			Text:    text,
+			Html:    html,
+		},
+		Data:    data,
+		itemIds: itemIds,
+	}, nil
+}
+
+// Sends the digest of the pending items, the run being nil when there was nothing to send
+func Send(ctx context.Context, store dbfeed.FeedStore, name string) (*dbdigest.Run, error) {
+
+	rendered, err := Render(ctx, store, name)
+	if err != nil {
+		return nil, err
+	}
+	if rendered == nil {
+		digestsCounter.Inc(name, "empty")
+		log.Debug(fmt.Sprintf("No new item for digest [%s]", name))
+		return nil, nil
+	}
+
+	now := time.Now()
+	run := &dbdigest.Run{
+		Digest:    name,
+		Sent:      &now,
+		ItemCount: rendered.Data.ItemCount,
+	}
+
+	sendErr := mail.Send(ctx, rendered.Message)
+	if sendErr != nil {
+		run.Error = sendErr.Error()
+		digestsCounter.Inc(name, "failed")
+	} else {
+		digestsCounter.Inc(name, "sent")
+	}
+
+	// Items stay pending when the digest could not be sent
+	err = digestStore.RecordRun(ctx, run, rendered.itemIds)
+	if err != nil {
+		return nil, err
+	}
+	if sendErr != nil {
+		return nil, sendErr
+	}
+
+	log.Info(fmt.Sprintf("Digest [%s] sent with %d items", name, run.ItemCount))
+	return run, nil
+}
+
+// Pending items still in the store, purged ones being dropped
+func selectItems(ctx context.Context, store dbfeed.FeedStore, configured *digest, itemIds []appDatabase.PrimaryKey) ([]*selectedItem, error) {
+
+	pending := make(map[appDatabase.PrimaryKey]bool, len(itemIds))
+	for _, itemId := range itemIds {
+		pending[itemId] = true
+	}
+
+	feeds, err := store.GetAllFeeds(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]*selectedItem, 0, len(itemIds))
+	for _, feed := range feeds {
+
+		if len(selected) == len(itemIds) {
+			break
+		}
+		if !configured.matchesFeed(feed) {
+			continue
+		}
+
+		items, err := store.GetFeedItems(ctx, feed.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			if pending[item.Id] {
+				selected = append(selected, &selectedItem{item: item, feed: feed, date: itemDate(item, feed)})
+			}
+		}
+	}
+	return selected, nil
+}
+
+func itemDate(item *dbfeed.FeedItem, feed *dbfeed.Feed) time.Time {
+	switch {
+	case item.Published != nil:
+		return *item.Published
+	case item.Updated != nil:
+		return *item.Updated
+	case feed.LastUpdate != nil:
+		return *feed.LastUpdate
+	default:
+		return time.Unix(0, 0)
+	}
+}
@@ -0,0 +1,299 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbevent"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Feed and item events are named after the dbfeed changes
+const TypeFetchFailed = "fetch.failed"
+
+const purgeInterval = time.Hour
+
+type Event struct {
+	Id         appDatabase.PrimaryKey  `json:"id"`
+	Type       string                  `json:"type"`
+	FeedId     *appDatabase.PrimaryKey `json:"feedId,omitempty"`
+	ItemId     *appDatabase.PrimaryKey `json:"itemId,omitempty"`
+	Categories []string                `json:"categories,omitempty"`
+	Created    *time.Time              `json:"created"`
+	Data       json.RawMessage         `json:"data"`
+}
+
+// Only the configuration name is known for feeds never fetched
+type feedData struct {
+	Id         appDatabase.PrimaryKey `json:"id,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Link       string                 `json:"link,omitempty"`
+	ConfigName string                 `json:"configName,omitempty"`
+}
+
+type itemData struct {
+	Id          appDatabase.PrimaryKey `json:"id"`
+	Title       string                 `json:"title"`
+	Link        string                 `json:"link"`
+	Description string                 `json:"description"`
+	Published   *time.Time             `json:"published"`
+	Updated     *time.Time             `json:"updated"`
+	Tags        []string               `json:"tags,omitempty"`
+}
+
+type eventData struct {
+	Feed  *feedData `json:"feed,omitempty"`
+	Item  *itemData `json:"item,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+var (
+	eventsConfig *config.EventsConfig
+	eventStore   *dbevent.Store
+
+	lastPurge time.Time
+	purgeLock sync.Mutex
+
+	// Channels of the waiting readers, signalled when events are appended
+	waiters     = map[chan struct{}]bool{}
+	waitersLock sync.Mutex
+
+	closed    = make(chan struct{})
+	closeOnce sync.Once
+)
+
+func init() {
+	dbfeed.RegisterChangeListener(onChanges)
+}
+
+// Events are only recorded when a database is used
+func Configure(config *config.EventsConfig, store *dbevent.Store) {
+	eventsConfig = config
+	eventStore = store
+}
+
+func Enabled() bool {
+	return eventStore != nil
+}
+
+// The fetched feed may not be stored yet, its id being resolved from its configuration name
+func FetchFailed(ctx context.Context, store dbfeed.FeedStore, feedConfig *config.Feed, fetchErr error) {
+
+	if !Enabled() || ctx.Err() != nil {
+		return
+	}
+
+	data := &eventData{
+		Feed:  &feedData{ConfigName: feedConfig.Name},
+		Error: fetchErr.Error(),
+	}
+	event := &dbevent.Event{Type: TypeFetchFailed}
+
+	feeds, err := store.GetAllFeeds(ctx, false)
+	if err != nil {
+		appLog.DebugError(err, "Unable to resolve the failing feed")
+	}
+	for _, feed := range feeds {
+		if feed.ConfigName == feedConfig.Name {
+			data.Feed = toFeedData(feed)
+			event.FeedId = &feed.Id
+			event.Categories = feedCategories(feed)
+			break
+		}
+	}
+
+	err = appendEvents(ctx, []*dbevent.Event{event}, []*eventData{data})
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to record the failure of feed [%s]", feedConfig.Name))
+	}
+}
+
+func LastId(ctx context.Context) (appDatabase.PrimaryKey, error) {
+	return eventStore.LastId(ctx)
+}
+
+// Events following lastId, oldest first
+func Since(ctx context.Context, lastId appDatabase.PrimaryKey, limit int) ([]*Event, error) {
+
+	stored, err := eventStore.Since(ctx, lastId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, len(stored))
+	for _, event := range stored {
+		events = append(events, &Event{
+			Id:         event.Id,
+			Type:       event.Type,
+			FeedId:     event.FeedId,
+			ItemId:     event.ItemId,
+			Categories: event.Categories,
+			Created:    event.Created,
+			Data:       json.RawMessage(event.Data),
+		})
+	}
+	return events, nil
+}
+
+// Returns a channel signalled when events are appended, to release with the returned function
+func Wait() (<-chan struct{}, func()) {
+
+	waiter := make(chan struct{}, 1)
+
+	waitersLock.Lock()
+	waiters[waiter] = true
+	waitersLock.Unlock()
+
+	return waiter, func() {
+		waitersLock.Lock()
+		delete(waiters, waiter)
+		waitersLock.Unlock()
+	}
+}
+
+// Tells the waiting readers to stop, the server shutting down
+func Close() {
+	closeOnce.Do(func() {
+		close(closed)
+	})
+}
+
+func Closed() <-chan struct{} {
+	return closed
+}
+
+func onChanges(ctx context.Context, changes []*dbfeed.Change) {
+
+	if !Enabled() {
+		return
+	}
+
+	events := make([]*dbevent.Event, 0, len(changes))
+	datas := make([]*eventData, 0, len(changes))
+	for _, change := range changes {
+
+		event := &dbevent.Event{
+			Type:       change.Type,
+			FeedId:     &change.Feed.Id,
+			Categories: feedCategories(change.Feed),
+		}
+		data := &eventData{Feed: toFeedData(change.Feed)}
+
+		if change.Item != nil {
+			event.ItemId = &change.Item.Id
+			event.Categories = appendCategories(event.Categories, change.Item.Categories)
+			data.Item = toItemData(change.Item)
+		}
+
+		events = append(events, event)
+		datas = append(datas, data)
+	}
+
+	err := appendEvents(ctx, events, datas)
+	if err != nil {
+		appLog.DebugError(err, "Unable to record feed changes")
+	}
+}
+
+func appendEvents(ctx context.Context, events []*dbevent.Event, datas []*eventData) error {
+
+	for i, event := range events {
+		data, err := json.Marshal(datas[i])
+		if err != nil {
+			return err
+		}
+		event.Data = string(data)
+	}
+
+	err := eventStore.Append(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	waitersLock.Lock()
+	for waiter := range waiters {
+		select {
+		case waiter <- struct{}{}:
+		default:
+			// Already signalled
+		}
+	}
+	waitersLock.Unlock()
+
+	purgeExpired(ctx)
+	return nil
+}
+
+// Runs at most once per purge interval
+func purgeExpired(ctx context.Context) {
+
+	if eventsConfig == nil || eventsConfig.RetentionHours == 0 {
+		return
+	}
+
+	purgeLock.Lock()
+	defer purgeLock.Unlock()
+
+	now := time.Now()
+	if now.Sub(lastPurge) < purgeInterval {
+		return
+	}
+	lastPurge = now
+
+	deleted, err := eventStore.DeleteBefore(ctx, now.Add(-time.Duration(eventsConfig.RetentionHours)*time.Hour))
+	if err != nil {
+		appLog.DebugError(err, "Unable to purge events")
+		return
+	}
+	log.Debug(fmt.Sprintf("%d events purged", deleted))
+}
+
+func toFeedData(feed *dbfeed.Feed) *feedData {
+	return &feedData{
+		Id:         feed.Id,
+		Title:      feed.Title,
+		Link:       feed.Link,
+		ConfigName: feed.ConfigName,
+	}
+}
+
+func toItemData(item *dbfeed.FeedItem) *itemData {
+	return &itemData{
+		Id:          item.Id,
+		Title:       item.Title,
+		Link:        item.Link,
+		Description: item.Description,
+		Published:   item.Published,
+		Updated:     item.Updated,
+		Tags:        item.Tags,
+	}
+}
+
+func feedCategories(feed *dbfeed.Feed) []string {
+	return appendCategories(nil, feed.Categories)
+}
+
+func appendCategories(names []string, categories []*dbfeed.FeedCategory) []string {
+
+	for _, category := range categories {
+		found := false
+		for _, name := range names {
+			if name == category.Category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, category.Category)
+		}
+	}
+	return names
+}
@@ -0,0 +1,176 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCommandTimeoutSeconds = 60
+	// Larger outputs are not parsed
+	maxCommandOutputBytes = 64 * 1024 * 1024
+	// Only the end of the error output is kept, for the error message
+	maxCommandErrorBytes = 4 * 1024
+
+	// Given to the commands along with their environment
+	commandFeedNameVariable = "RSSREADER_FEED_NAME"
+)
+
+// Runs the feed command, parsing its standard output
+func fetchCommand(ctx context.Context, feedConfig *config.Feed) (*gofeed.Feed, error) {
+
+	startedAt := time.Now()
+	status := fetchStatusError
+
+	defer func() {
+		fetchCounter.Inc(feedConfig.Name, status)
+		fetchDuration.Observe(time.Since(startedAt).Seconds(), feedConfig.Name)
+	}()
+
+	command := feedConfig.Command
+	if command.Path == "" {
+		return nil, fmt.Errorf("No command path given for feed [%s]", feedConfig.Name)
+	}
+
+	timeout := time.Duration(command.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultCommandTimeoutSeconds * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Files rather than pipes, waiting for pipes to be closed would outlast the timeout when children inherit them
+	stdout, err := outputFile()
+	if err != nil {
+		return nil, err
+	}
+	defer removeOutputFile(stdout)
+
+	stderr, err := outputFile()
+	if err != nil {
+		return nil, err
+	}
+	defer removeOutputFile(stderr)
+
+	cmd := exec.CommandContext(ctx, command.Path, command.Args...)
+	cmd.Dir = command.Dir
+	cmd.Env = commandEnv(feedConfig)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	log.Debug(fmt.Sprintf("Running command [%s] of feed [%s]", command.Path, feedConfig.Name))
+
+	err = cmd.Run()
+	if cmd.ProcessState != nil {
+		status = fmt.Sprintf("exit_%d", cmd.ProcessState.ExitCode())
+	}
+
+	errorOutput := strings.TrimSpace(tail(stderr, maxCommandErrorBytes))
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("Command of feed [%s] timed out after %s", feedConfig.Name, timeout)
+	case err != nil && errorOutput != "":
+		return nil, fmt.Errorf("Command of feed [%s] failed: %s: %s", feedConfig.Name, err, errorOutput)
+	case err != nil:
+		return nil, fmt.Errorf("Command of feed [%s] failed: %s", feedConfig.Name, err)
+	}
+
+	if errorOutput != "" {
+		log.Debug(fmt.Sprintf("Command of feed [%s] wrote on its error output: %s", feedConfig.Name, errorOutput))
+	}
+
+	info, err := stdout.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fetchBytes.Add(float64(info.Size()), feedConfig.Name)
+	if info.Size() > maxCommandOutputBytes {
+		return nil, fmt.Errorf("Command of feed [%s] wrote more than %d bytes", feedConfig.Name, maxCommandOutputBytes)
+	}
+
+	_, err = stdout.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := gofeed.NewParser().Parse(stdout)
+	if err != nil {
+		status = fetchStatusInvalid
+		return nil, err
+	}
+	return feed, nil
+}
+
+func outputFile() (*os.File, error) {
+
+	file, err := ioutil.TempFile("", "rssreader-command-")
+	if err != nil {
+		appLog.DebugError(err, "Unable to create the command output file")
+		return nil, err
+	}
+	return file, nil
+}
+
+func removeOutputFile(file *os.File) {
+
+	if err := file.Close(); err != nil {
+		appLog.DebugError(err, "Unable to close the command output file")
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		appLog.DebugError(err, "Unable to remove the command output file")
+	}
+}
+
+// Last bytes of the file, where the reason of a failure usually is
+func tail(file *os.File, length int64) string {
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+
+	offset := info.Size() - length
+	if offset < 0 {
+		offset = 0
+	}
+
+	content := make([]byte, info.Size()-offset)
+	_, err = file.ReadAt(content, offset)
+	if err != nil && err != io.EOF {
+		return ""
+	}
+	return string(content)
+}
+
+func commandEnv(feedConfig *config.Feed) []string {
+
+	env := make([]string, 0)
+	if !feedConfig.Command.ClearEnv {
+		env = append(env, os.Environ()...)
+	}
+
+	names := make([]string, 0, len(feedConfig.Command.Env))
+	for name := range feedConfig.Command.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Later values override the earlier ones
+	for _, name := range names {
+		env = append(env, name+"="+feedConfig.Command.Env[name])
+	}
+	return append(env, commandFeedNameVariable+"="+feedConfig.Name)
+}
@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/metrics"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	fetchStatusError   = "error"
+	fetchStatusInvalid = "invalid"
+
+	defaultFetchTimeoutSeconds = 30
+)
+
+var (
+	fetchCounter = metrics.NewCounterVec(
+		"rssreader_feed_fetches_total",
+		"Feed fetches by feed and HTTP status, exit_N for commands, file for local files, error when no response was received and invalid when the content could not be parsed.",
+		"feed", "status",
+	)
+	fetchDuration = metrics.NewHistogramVec(
+		"rssreader_feed_fetch_duration_seconds",
+		"Duration of feed fetches, parsing included.",
+		metrics.DefaultBuckets,
+		"feed",
+	)
+	fetchBytes = metrics.NewCounterVec(
+		"rssreader_feed_fetch_bytes_total",
+		"Bytes read from feed responses.",
+		"feed",
+	)
+)
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	reader.count += int64(n)
+	return n, err
+}
+
+// Local file URLs may match several files, each one giving a feed, and mailboxes give a feed for each sender or list
+func fetchFeeds(ctx context.Context, feedConfig *config.Feed) ([]*gofeed.Feed, error) {
+
+	if feedConfig.Mailbox != nil {
+		if feedConfig.Url != "" || feedConfig.Command != nil || feedConfig.Scrape != nil {
+			return nil, fmt.Errorf("Feed [%s] defines a mailbox along with another source", feedConfig.Name)
+		}
+		return fetchMailbox(ctx, feedConfig)
+	}
+
+	if !isFileUrl(feedConfig.Url) {
+		feed, err := fetchFeed(ctx, feedConfig)
+		if err != nil {
+			return nil, err
+		}
+		return []*gofeed.Feed{feed}, nil
+	}
+
+	switch {
+	case feedConfig.Command != nil:
+		return nil, fmt.Errorf("Feed [%s] defines both a URL and a command", feedConfig.Name)
+	case feedConfig.Scrape != nil:
+		return nil, fmt.Errorf("Feed [%s] can not scrape local files", feedConfig.Name)
+	default:
+		return fetchFiles(ctx, feedConfig)
+	}
+}
+
+// Feeds are read from their URL, from the HTML page at their URL, or from the output of their command
+func fetchFeed(ctx context.Context, feedConfig *config.Feed) (*gofeed.Feed, error) {
+
+	switch {
+	case feedConfig.Command != nil && feedConfig.Url != "":
+		return nil, fmt.Errorf("Feed [%s] defines both a URL and a command", feedConfig.Name)
+	case feedConfig.Command != nil && feedConfig.Scrape != nil:
+		return nil, fmt.Errorf("Feed [%s] can not scrape the output of a command", feedConfig.Name)
+	case feedConfig.Command != nil:
+		return fetchCommand(ctx, feedConfig)
+	case feedConfig.Scrape != nil:
+		return fetchScrape(ctx, feedConfig)
+	default:
+		return fetchURL(ctx, feedConfig)
+	}
+}
+
+// Same as gofeed.Parser.ParseURLWithContext, measuring the request
+func fetchURL(ctx context.Context, feedConfig *config.Feed) (*gofeed.Feed, error) {
+	return fetchHTTP(ctx, feedConfig, gofeed.NewParser().Parse)
+}
+
+func fetchHTTP(ctx context.Context, feedConfig *config.Feed, parse func(io.Reader) (*gofeed.Feed, error)) (*gofeed.Feed, error) {
+
+	startedAt := time.Now()
+	status := fetchStatusError
+
+	defer func() {
+		fetchCounter.Inc(feedConfig.Name, status)
+		fetchDuration.Observe(time.Since(startedAt).Seconds(), feedConfig.Name)
+	}()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, feedConfig.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", "Gofeed/1.0")
+
+	timeout := time.Duration(feedConfig.FetchTimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultFetchTimeoutSeconds * time.Second
+	}
+
+	client := http.Client{Timeout: timeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	status = strconv.Itoa(response.StatusCode)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, gofeed.HTTPError{
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+		}
+	}
+
+	body := &countingReader{reader: response.Body}
+	feed, err := parse(body)
+	fetchBytes.Add(float64(body.count), feedConfig.Name)
+	if err != nil {
+		status = fetchStatusInvalid
+		return nil, err
+	}
+	return feed, nil
+}
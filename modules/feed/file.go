@@ -0,0 +1,132 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	fileUrlScheme = "file://"
+
+	fetchStatusFile = "file"
+)
+
+func isFileUrl(feedUrl string) bool {
+	return strings.HasPrefix(strings.ToLower(feedUrl), fileUrlScheme)
+}
+
+// The path follows the scheme as is, it may be relative, a glob pattern or a directory whose files are all read
+func fileUrlPath(feedUrl string) string {
+
+	path := feedUrl[len(fileUrlScheme):]
+	if strings.HasPrefix(strings.ToLower(path), "localhost/") {
+		path = path[len("localhost"):]
+	}
+	return filepath.FromSlash(path)
+}
+
+// Reads every file matched by the feed URL, in name order
+func fetchFiles(ctx context.Context, feedConfig *config.Feed) ([]*gofeed.Feed, error) {
+
+	paths, err := feedFilePaths(fileUrlPath(feedConfig.Url))
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to list the files of feed [%s]", feedConfig.Name))
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No file matches the URL of feed [%s]", feedConfig.Name)
+	}
+
+	feeds := make([]*gofeed.Feed, 0, len(paths))
+	for _, path := range paths {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		feed, err := fetchFile(feedConfig, path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read file [%s] of feed [%s]: %s", path, feedConfig.Name, err)
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+func fetchFile(feedConfig *config.Feed, path string) (*gofeed.Feed, error) {
+
+	startedAt := time.Now()
+	status := fetchStatusError
+
+	defer func() {
+		fetchCounter.Inc(feedConfig.Name, status)
+		fetchDuration.Observe(time.Since(startedAt).Seconds(), feedConfig.Name)
+	}()
+
+	log.Debug(fmt.Sprintf("Reading file [%s] of feed [%s]", path, feedConfig.Name))
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content := &countingReader{reader: file}
+	feed, err := gofeed.NewParser().Parse(content)
+	fetchBytes.Add(float64(content.count), feedConfig.Name)
+	if err != nil {
+		status = fetchStatusInvalid
+		return nil, err
+	}
+
+	status = fetchStatusFile
+	return feed, nil
+}
+
+// Directories are read without their sub-directories, hidden files being left out
+func feedFilePaths(pattern string) ([]string, error) {
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(matches))
+	for _, match := range matches {
+
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			paths = append(paths, match)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(match)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				paths = append(paths, filepath.Join(match, entry.Name()))
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
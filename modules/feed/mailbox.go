@@ -0,0 +1,356 @@
+package feed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+
+	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	mailboxFormatMaildir = "maildir"
+	mailboxFormatMbox    = "mbox"
+
+	mailboxGroupBySender = "sender"
+	mailboxGroupByList   = "list"
+
+	mailboxFeedType = "mail"
+
+	// Larger messages are skipped
+	maxMailMessageBytes = 32 * 1024 * 1024
+
+	defaultMaxMailboxMessages = 1000
+)
+
+// Escaped From_ lines of mbox bodies, a '>' being removed when reading them
+var mboxEscapedFromPattern = regexp.MustCompile(`^>+From `)
+
+// Reads the mailbox of the feed, making a feed for each sender or list
+func fetchMailbox(ctx context.Context, feedConfig *config.Feed) ([]*gofeed.Feed, error) {
+
+	startedAt := time.Now()
+	status := fetchStatusError
+
+	defer func() {
+		fetchCounter.Inc(feedConfig.Name, status)
+		fetchDuration.Observe(time.Since(startedAt).Seconds(), feedConfig.Name)
+	}()
+
+	mailbox := feedConfig.Mailbox
+	if mailbox.Path == "" {
+		return nil, fmt.Errorf("No mailbox path given for feed [%s]", feedConfig.Name)
+	}
+
+	groupBy := mailbox.GroupBy
+	if groupBy == "" {
+		groupBy = mailboxGroupBySender
+	}
+	if groupBy != mailboxGroupBySender && groupBy != mailboxGroupByList {
+		return nil, fmt.Errorf("Unknown grouping [%s] for the mailbox of feed [%s], expected %s or %s", groupBy, feedConfig.Name, mailboxGroupBySender, mailboxGroupByList)
+	}
+
+	format, err := mailboxFormat(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the mailbox of feed [%s]: %s", feedConfig.Name, err)
+	}
+
+	maxMessages := int(mailbox.MaxMessages)
+	if maxMessages == 0 {
+		maxMessages = defaultMaxMailboxMessages
+	}
+
+	var readBytes int
+	messages := make([]*mailMessage, 0)
+
+	readMessage := func(raw []byte) error {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		readBytes += len(raw)
+
+		message, err := parseMessage(raw)
+		if err != nil {
+			// A malformed message should not prevent reading the others
+			log.Debug(fmt.Sprintf("Unable to parse a message of feed [%s], skipping: %s", feedConfig.Name, err))
+			return nil
+		}
+
+		if mailboxAccepts(mailbox, message) {
+			messages = append(messages, message)
+		}
+		// Twice as many messages as kept are held at most
+		if len(messages) >= 2*maxMessages {
+			messages = mostRecentMessages(messages, maxMessages)
+		}
+		return nil
+	}
+
+	log.Debug(fmt.Sprintf("Reading %s [%s] of feed [%s]", format, mailbox.Path, feedConfig.Name))
+
+	switch format {
+	case mailboxFormatMaildir:
+		err = readMaildir(mailbox.Path, readMessage)
+	default:
+		err = readMbox(mailbox.Path, readMessage)
+	}
+	fetchBytes.Add(float64(readBytes), feedConfig.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the mailbox of feed [%s]: %s", feedConfig.Name, err)
+	}
+
+	status = fetchStatusFile
+	return groupMessages(mostRecentMessages(messages, maxMessages), groupBy), nil
+}
+
+// Undated messages come last
+func mostRecentMessages(messages []*mailMessage, count int) []*mailMessage {
+
+	if len(messages) <= count {
+		return messages
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		switch {
+		case messages[j].Date == nil:
+			return messages[i].Date != nil
+		case messages[i].Date == nil:
+			return false
+		default:
+			return messages[i].Date.After(*messages[j].Date)
+		}
+	})
+	return messages[:count:count]
+}
+
+// Directories are Maildirs and files mboxes, unless the format is given
+func mailboxFormat(mailbox *config.FeedMailbox) (string, error) {
+
+	switch mailbox.Format {
+	case mailboxFormatMaildir, mailboxFormatMbox:
+		return mailbox.Format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("Unknown mailbox format [%s], expected %s or %s", mailbox.Format, mailboxFormatMaildir, mailboxFormatMbox)
+	}
+
+	info, err := os.Stat(mailbox.Path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return mailboxFormatMaildir, nil
+	}
+	return mailboxFormatMbox, nil
+}
+
+// Messages are read from new and cur, the Maildir being left as is
+func readMaildir(path string, handle func([]byte) error) error {
+
+	found := false
+	for _, folder := range []string{"new", "cur"} {
+
+		entries, err := ioutil.ReadDir(filepath.Join(path, folder))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		for _, entry := range entries {
+
+			if !entry.Mode().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if entry.Size() > maxMailMessageBytes {
+				log.Debug(fmt.Sprintf("Message [%s] is larger than %d bytes, skipping", entry.Name(), maxMailMessageBytes))
+				continue
+			}
+
+			raw, err := ioutil.ReadFile(filepath.Join(path, folder, entry.Name()))
+			if err != nil {
+				return err
+			}
+
+			err = handle(raw)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("[%s] is not a Maildir, it has neither new nor cur folder", path)
+	}
+	return nil
+}
+
+// Messages start with a From_ line, at the beginning of the file or after an empty line
+func readMbox(path string, handle func([]byte) error) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var message *bytes.Buffer
+	skipped := false
+	previousEmpty := true
+
+	flush := func() error {
+		if message == nil || skipped {
+			return nil
+		}
+		return handle(message.Bytes())
+	}
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+
+			trimmed := bytes.TrimRight(line, "\r\n")
+			switch {
+			case previousEmpty && bytes.HasPrefix(line, []byte("From ")):
+				if err := flush(); err != nil {
+					return err
+				}
+				message = &bytes.Buffer{}
+				skipped = false
+			case message == nil || skipped:
+			case message.Len()+len(line) > maxMailMessageBytes:
+				log.Debug(fmt.Sprintf("A message of [%s] is larger than %d bytes, skipping", path, maxMailMessageBytes))
+				skipped = true
+			case mboxEscapedFromPattern.Match(line):
+				message.Write(line[1:])
+			default:
+				message.Write(line)
+			}
+			previousEmpty = len(trimmed) == 0
+		}
+
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Every message is accepted when no filter is set
+func mailboxAccepts(mailbox *config.FeedMailbox, message *mailMessage) bool {
+
+	if len(mailbox.From) == 0 && len(mailbox.ListIds) == 0 {
+		return true
+	}
+
+	address := strings.ToLower(message.From.Address)
+	for _, from := range mailbox.From {
+		from = strings.ToLower(strings.TrimSpace(from))
+		if strings.HasPrefix(from, "@") && strings.HasSuffix(address, from) || address == from {
+			return true
+		}
+	}
+
+	if message.ListId == "" {
+		return false
+	}
+	for _, id := range mailbox.ListIds {
+		if strings.ToLower(strings.Trim(strings.TrimSpace(id), "<>")) == message.ListId {
+			return true
+		}
+	}
+	return false
+}
+
+func groupMessages(messages []*mailMessage, groupBy string) []*gofeed.Feed {
+
+	feedsByKey := make(map[string]*gofeed.Feed)
+
+	for _, message := range messages {
+
+		key := "sender:" + strings.ToLower(message.From.Address)
+		if groupBy == mailboxGroupByList && message.ListId != "" {
+			key = "list:" + message.ListId
+		}
+
+		feed, ok := feedsByKey[key]
+		if !ok {
+			feed = messageFeed(message, groupBy)
+			feedsByKey[key] = feed
+		}
+
+		items := append(feed.Items, message.item())
+		if message.Date != nil && (feed.UpdatedParsed == nil || message.Date.After(*feed.UpdatedParsed)) {
+			// Named after the most recent message, whichever order they are read in
+			*feed = *messageFeed(message, groupBy)
+			feed.UpdatedParsed = message.Date
+		}
+		feed.Items = items
+	}
+
+	keys := make([]string, 0, len(feedsByKey))
+	for key := range feedsByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	feeds := make([]*gofeed.Feed, 0, len(keys))
+	for _, key := range keys {
+		feeds = append(feeds, feedsByKey[key])
+	}
+	return feeds
+}
+
+// Virtual feed of the sender or of the list of the message
+func messageFeed(message *mailMessage, groupBy string) *gofeed.Feed {
+
+	if groupBy == mailboxGroupByList && message.ListId != "" {
+
+		title := message.ListName
+		if title == "" {
+			title = message.ListId
+		}
+		return &gofeed.Feed{
+			Title:       title,
+			Description: message.ListId,
+			Link:        message.ListArchive,
+			FeedType:    mailboxFeedType,
+			Items:       []*gofeed.Item{},
+		}
+	}
+
+	// Senders sharing a name are told apart, their feeds being found by their mailto link
+	address := strings.ToLower(message.From.Address)
+	title := address
+	if message.From.Name != "" {
+		title = fmt.Sprintf("%s <%s>", message.From.Name, address)
+	}
+
+	author := message.author()
+	return &gofeed.Feed{
+		Title:       title,
+		Description: address,
+		Link:        "mailto:" + address,
+		FeedType:    mailboxFeedType,
+		Author:      author,
+		Items:       []*gofeed.Item{},
+	}
+}
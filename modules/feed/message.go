@@ -0,0 +1,235 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// Larger bodies are cut
+	maxMessageBodyBytes = 8 * 1024 * 1024
+	// Multipart messages are not read deeper
+	maxMessageDepth = 10
+)
+
+var messageWordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+type mailMessage struct {
+	From        *mail.Address
+	ListId      string
+	ListName    string
+	ListArchive string
+	Subject     string
+	MessageId   string
+	Date        *time.Time
+	Html        string
+}
+
+func parseMessage(raw []byte) (*mailMessage, error) {
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	addressParser := &mail.AddressParser{WordDecoder: messageWordDecoder}
+	from, err := addressParser.Parse(parsed.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sender [%s]: %s", parsed.Header.Get("From"), err)
+	}
+
+	message := &mailMessage{
+		From:        from,
+		Subject:     decodeHeader(parsed.Header.Get("Subject")),
+		MessageId:   strings.TrimSpace(parsed.Header.Get("Message-Id")),
+		ListArchive: listArchive(parsed.Header.Get("List-Archive")),
+	}
+	message.ListId, message.ListName = listId(parsed.Header.Get("List-Id"))
+
+	// Messages without identifier keep the same one across reads
+	if message.MessageId == "" {
+		sum := sha256.Sum256(raw)
+		message.MessageId = hex.EncodeToString(sum[:])
+	}
+
+	if date, err := parsed.Header.Date(); err == nil {
+		message.Date = &date
+	}
+
+	bodies := &messageBodies{}
+	err = bodies.read(textproto.MIMEHeader(parsed.Header), parsed.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+	message.Html = bodies.html()
+
+	return message, nil
+}
+
+func (message *mailMessage) item() *gofeed.Item {
+
+	item := &gofeed.Item{
+		Title:   message.Subject,
+		Content: message.Html,
+		GUID:    message.MessageId,
+		Author:  message.author(),
+	}
+	if message.Date != nil {
+		item.Published = message.Date.Format(time.RFC1123Z)
+		item.PublishedParsed = message.Date
+	}
+	return item
+}
+
+// Authors are told apart by their name, the address is used when there is none
+func (message *mailMessage) author() *gofeed.Person {
+
+	name := message.From.Name
+	if name == "" {
+		name = message.From.Address
+	}
+	return &gofeed.Person{
+		Name:  name,
+		Email: message.From.Address,
+	}
+}
+
+// The first HTML and text parts which are not attachments
+type messageBodies struct {
+	htmlBody string
+	textBody string
+}
+
+func (bodies *messageBodies) html() string {
+
+	if bodies.htmlBody != "" {
+		return bodies.htmlBody
+	}
+
+	paragraphs := make([]string, 0)
+	for _, paragraph := range strings.Split(strings.ReplaceAll(bodies.textBody, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph != "" {
+			paragraphs = append(paragraphs, "<p>"+strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>")+"</p>")
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+func (bodies *messageBodies) read(header textproto.MIMEHeader, body io.Reader, depth int) error {
+
+	if depth > maxMessageDepth {
+		return nil
+	}
+
+	disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if disposition == "attachment" {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = bodies.read(part.Header, part, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case mediaType == "text/html" && bodies.htmlBody == "":
+		bodies.htmlBody, err = decodeBody(header, params, body)
+	case mediaType == "text/plain" && bodies.textBody == "":
+		bodies.textBody, err = decodeBody(header, params, body)
+	}
+	return err
+}
+
+func decodeBody(header textproto.MIMEHeader, params map[string]string, body io.Reader) (string, error) {
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if label := params["charset"]; label != "" {
+		decoded, err := charset.NewReaderLabel(label, body)
+		if err != nil {
+			return "", err
+		}
+		body = decoded
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(body, maxMessageBodyBytes))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(content), "�"), nil
+}
+
+func decodeHeader(value string) string {
+
+	decoded, err := messageWordDecoder.DecodeHeader(value)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(decoded)
+}
+
+// Identifier and description of "Description <identifier>" values
+func listId(value string) (string, string) {
+
+	value = strings.TrimSpace(value)
+	start, end := strings.LastIndex(value, "<"), strings.LastIndex(value, ">")
+	if start < 0 || end < start {
+		return strings.ToLower(value), ""
+	}
+
+	name := strings.Trim(decodeHeader(value[:start]), ` "`)
+	return strings.ToLower(strings.TrimSpace(value[start+1 : end])), name
+}
+
+// First web link of the header, mailto links being left out
+func listArchive(value string) string {
+
+	for _, link := range strings.Split(value, ",") {
+		link = strings.Trim(strings.TrimSpace(link), "<>")
+		if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+			return link
+		}
+	}
+	return ""
+}
@@ -1,45 +1,103 @@
 package feed
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/dademo/rssreader/modules/config"
 	databaseFeed "github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/rules"
+	"github.com/dademo/rssreader/modules/sanitizer"
 
 	"github.com/mmcdole/gofeed"
 	log "github.com/sirupsen/logrus"
 )
 
-func FetchAll(config *config.Config) ([]*databaseFeed.Feed, error) {
+func FetchAll(ctx context.Context, config *config.Config) ([]*databaseFeed.Feed, error) {
 
 	log.Debug("Fetching all feeds")
 
 	feeds := make([]*databaseFeed.Feed, 0, len(config.Feeds))
 
 	for _, feed := range config.Feeds {
-		fetchedFeed, err := Fetch(feed)
+		fetchedFeeds, err := Fetch(ctx, feed)
 
 		if err != nil {
 			return nil, err
 		}
 
-		feeds = append(feeds, fetchedFeed)
+		feeds = append(feeds, fetchedFeeds...)
 	}
 
 	return feeds, nil
 }
 
-func Fetch(feedConfig *config.Feed) (*databaseFeed.Feed, error) {
+// Several feeds are returned when the feed URL matches several local files
+func Fetch(ctx context.Context, feedConfig *config.Feed) ([]*databaseFeed.Feed, error) {
 
 	log.Debug(fmt.Sprintf("Fetching feed [%s]", feedConfig.Name))
 
-	fp := gofeed.NewParser()
+	feeds, err := fetchFeeds(ctx, feedConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	feed, err := fp.ParseURL(feedConfig.Url)
+	fetchedFeeds := make([]*databaseFeed.Feed, 0, len(feeds))
+	for _, feed := range feeds {
+		fetchedFeed, err := prepare(feedConfig, feed)
+		if err != nil {
+			return nil, err
+		}
+		fetchedFeeds = append(fetchedFeeds, fetchedFeed)
+	}
 
+	return fetchedFeeds, nil
+}
+
+// Parses a feed document, the rules of the feed configuration, if any, being applied
+func Read(feedConfig *config.Feed, reader io.Reader) (*databaseFeed.Feed, error) {
+
+	feed, err := gofeed.NewParser().Parse(reader)
 	if err != nil {
+		appLog.DebugError(err, "Unable to parse the feed document")
 		return nil, err
 	}
 
-	return databaseFeed.FromFeed(feed), nil
+	return prepare(feedConfig, feed)
+}
+
+func prepare(feedConfig *config.Feed, feed *gofeed.Feed) (*databaseFeed.Feed, error) {
+
+	preparedFeed := databaseFeed.FromFeed(feed)
+	if feedConfig != nil {
+		preparedFeed.ConfigName = feedConfig.Name
+	}
+
+	_, err := rules.Apply(feedConfig, preparedFeed)
+	if err != nil {
+		return nil, err
+	}
+
+	sanitizer.SanitizeFeed(preparedFeed)
+
+	return preparedFeed, nil
+}
+
+func Sync(ctx context.Context, store databaseFeed.FeedStore, feedConfig *config.Feed) error {
+
+	fetchedFeeds, err := Fetch(ctx, feedConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, fetchedFeed := range fetchedFeeds {
+		err = store.SaveFeed(ctx, fetchedFeed)
+		if err != nil {
+			log.Debug(fmt.Sprintf("An error occured while saving feed [%s]", fetchedFeed.Title))
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,243 @@
+package feed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	scrapeFeedType = "html"
+
+	defaultScrapeLinkAttribute  = "href"
+	defaultScrapeImageAttribute = "src"
+)
+
+// Tried in order when no date format is configured
+var scrapeDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02/01/2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// Reads the HTML page at the feed URL, extracting the items with the feed selectors
+func fetchScrape(ctx context.Context, feedConfig *config.Feed) (*gofeed.Feed, error) {
+	return Scrape(ctx, feedConfig, nil)
+}
+
+// Extracts the items of the given page, the page being fetched from the feed URL when nil
+func Scrape(ctx context.Context, feedConfig *config.Feed, page io.Reader) (*gofeed.Feed, error) {
+
+	if feedConfig.Scrape == nil {
+		return nil, fmt.Errorf("Feed [%s] has no scrape selectors", feedConfig.Name)
+	}
+	if feedConfig.Scrape.Items == "" {
+		return nil, fmt.Errorf("No item selector given for feed [%s]", feedConfig.Name)
+	}
+	if feedConfig.Url == "" {
+		return nil, fmt.Errorf("No page URL given for feed [%s]", feedConfig.Name)
+	}
+
+	pageUrl, err := url.Parse(feedConfig.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	if page != nil {
+		return scrape(page, pageUrl, feedConfig)
+	}
+
+	return fetchHTTP(ctx, feedConfig, func(reader io.Reader) (*gofeed.Feed, error) {
+		return scrape(reader, pageUrl, feedConfig)
+	})
+}
+
+func scrape(reader io.Reader, pageUrl *url.URL, feedConfig *config.Feed) (*gofeed.Feed, error) {
+
+	document, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Relative links are resolved against the page base, when it declares one
+	baseUrl := pageUrl
+	if base, ok := document.Find("head base[href]").First().Attr("href"); ok {
+		if parsedBase, err := pageUrl.Parse(strings.TrimSpace(base)); err == nil {
+			baseUrl = parsedBase
+		}
+	}
+
+	feed := &gofeed.Feed{
+		Title:    strings.TrimSpace(document.Find("head title").First().Text()),
+		Link:     pageUrl.String(),
+		FeedLink: pageUrl.String(),
+		FeedType: scrapeFeedType,
+		Items:    []*gofeed.Item{},
+	}
+	if description, ok := document.Find(`head meta[name="description"]`).First().Attr("content"); ok {
+		feed.Description = strings.TrimSpace(description)
+	}
+	if feed.Title == "" {
+		feed.Title = feedConfig.Name
+	}
+
+	scrapeConfig := feedConfig.Scrape
+
+	document.Find(scrapeConfig.Items).Each(func(index int, selection *goquery.Selection) {
+
+		item, err := scrapeItem(selection, baseUrl, scrapeConfig)
+		if err != nil {
+			// The item is kept undated
+			appLog.DebugError(err, fmt.Sprintf("Unable to read the date of item %d of feed [%s]", index, feedConfig.Name))
+		}
+
+		if item.Title == "" && item.Link == "" {
+			log.Debug(fmt.Sprintf("Item %d of feed [%s] has neither title nor link, skipping", index, feedConfig.Name))
+			return
+		}
+
+		item.GUID = scrapedGUID(pageUrl, item)
+		feed.Items = append(feed.Items, item)
+	})
+
+	return feed, nil
+}
+
+// The item is returned along with the error of its date
+func scrapeItem(selection *goquery.Selection, baseUrl *url.URL, scrapeConfig *config.FeedScrape) (*gofeed.Item, error) {
+
+	item := &gofeed.Item{
+		Title: scrapeText(selection, scrapeConfig.Title),
+	}
+
+	if scrapeConfig.Link != "" {
+		link := scrapeAttribute(selection, scrapeConfig.Link, defaultAttribute(scrapeConfig.LinkAttribute, defaultScrapeLinkAttribute))
+		item.Link = resolveUrl(baseUrl, link)
+	}
+
+	if scrapeConfig.Summary != "" {
+		if scrapeConfig.SummaryAttribute != "" {
+			item.Description = scrapeAttribute(selection, scrapeConfig.Summary, scrapeConfig.SummaryAttribute)
+		} else {
+			item.Description = scrapeHtml(selection, scrapeConfig.Summary)
+		}
+	}
+
+	if scrapeConfig.Image != "" {
+		image := scrapeAttribute(selection, scrapeConfig.Image, defaultAttribute(scrapeConfig.ImageAttribute, defaultScrapeImageAttribute))
+		if image != "" {
+			item.Image = &gofeed.Image{URL: resolveUrl(baseUrl, image)}
+		}
+	}
+
+	if scrapeConfig.Date != "" {
+		var date string
+		if scrapeConfig.DateAttribute != "" {
+			date = scrapeAttribute(selection, scrapeConfig.Date, scrapeConfig.DateAttribute)
+		} else {
+			date = scrapeText(selection, scrapeConfig.Date)
+		}
+
+		if date != "" {
+			published, err := parseScrapedDate(date, scrapeConfig.DateFormat)
+			if err != nil {
+				return item, err
+			}
+			item.Published = date
+			item.PublishedParsed = &published
+		}
+	}
+
+	return item, nil
+}
+
+// Items are identified by their link, or by their page and content when they have none
+func scrapedGUID(pageUrl *url.URL, item *gofeed.Item) string {
+
+	if item.Link != "" {
+		return item.Link
+	}
+
+	sum := sha256.Sum256([]byte(pageUrl.String() + "\n" + item.Title + "\n" + item.Description))
+	return "scrape:" + hex.EncodeToString(sum[:])
+}
+
+// The selection itself is used when no selector is given
+func scrapeFind(selection *goquery.Selection, selector string) *goquery.Selection {
+	if selector == "" {
+		return selection
+	}
+	return selection.Find(selector).First()
+}
+
+func scrapeText(selection *goquery.Selection, selector string) string {
+	return strings.Join(strings.Fields(scrapeFind(selection, selector).Text()), " ")
+}
+
+func scrapeHtml(selection *goquery.Selection, selector string) string {
+	content, err := scrapeFind(selection, selector).Html()
+	if err != nil {
+		log.Debug(fmt.Sprintf("Unable to render the content of [%s]: %s", selector, err))
+		return ""
+	}
+	return strings.TrimSpace(content)
+}
+
+func scrapeAttribute(selection *goquery.Selection, selector string, attribute string) string {
+	return strings.TrimSpace(scrapeFind(selection, selector).AttrOr(attribute, ""))
+}
+
+func defaultAttribute(attribute string, defaultValue string) string {
+	if attribute == "" {
+		return defaultValue
+	}
+	return attribute
+}
+
+func resolveUrl(baseUrl *url.URL, reference string) string {
+	if reference == "" {
+		return ""
+	}
+	resolved, err := baseUrl.Parse(reference)
+	if err != nil {
+		return reference
+	}
+	return resolved.String()
+}
+
+// Dates without zone are read in the local one
+func parseScrapedDate(value string, layout string) (time.Time, error) {
+
+	if layout != "" {
+		return time.ParseInLocation(layout, value, time.Local)
+	}
+
+	for _, layout := range scrapeDateLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("Unable to parse date [%s], a date format should be given", value))
+}
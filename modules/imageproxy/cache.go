@@ -0,0 +1,191 @@
+package imageproxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const contentTypeFileSuffix = ".type"
+
+type CachedImage struct {
+	ContentType string
+	Content     []byte
+}
+
+type diskCache struct {
+	directory string
+	maxSize   int64
+	// Images are fetched again once this old, kept forever when zero
+	ttl  time.Duration
+	lock sync.Mutex
+}
+
+func newDiskCache(directory string, maxSize int64, ttl time.Duration) (*diskCache, error) {
+
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskCache{
+		directory: directory,
+		maxSize:   maxSize,
+		ttl:       ttl,
+	}, nil
+}
+
+func (cache *diskCache) get(imageURL string) (*CachedImage, error) {
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	dataPath := cache.path(imageURL)
+
+	// The content type file is written along with the image and left untouched, telling when it was fetched
+	if cache.ttl > 0 {
+		info, err := os.Stat(dataPath + contentTypeFileSuffix)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if time.Since(info.ModTime()) > cache.ttl {
+			log.Debug(fmt.Sprintf("Cached image [%s] has expired", imageURL))
+			return nil, cache.remove(dataPath)
+		}
+	}
+
+	content, err := ioutil.ReadFile(dataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	contentType, err := ioutil.ReadFile(dataPath + contentTypeFileSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Used as the last access time for eviction
+	now := time.Now()
+	err = os.Chtimes(dataPath, now, now)
+	if err != nil {
+		appLog.DebugError(err, "Unable to touch a cached image")
+	}
+
+	return &CachedImage{
+		ContentType: string(contentType),
+		Content:     content,
+	}, nil
+}
+
+func (cache *diskCache) put(imageURL string, image *CachedImage) error {
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if int64(len(image.Content)) > cache.maxSize {
+		log.Debug(fmt.Sprintf("Image [%s] is bigger than the whole cache, not caching it", imageURL))
+		return nil
+	}
+
+	dataPath := cache.path(imageURL)
+
+	err := ioutil.WriteFile(dataPath+contentTypeFileSuffix, []byte(image.ContentType), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(dataPath, image.Content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return cache.evict()
+}
+
+func (cache *diskCache) evict() error {
+
+	files, err := ioutil.ReadDir(cache.directory)
+	if err != nil {
+		return err
+	}
+
+	sizes := map[string]int64{}
+	entries := make([]os.FileInfo, 0, len(files))
+	totalSize := int64(0)
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		totalSize += file.Size()
+		if strings.HasSuffix(file.Name(), contentTypeFileSuffix) {
+			sizes[strings.TrimSuffix(file.Name(), contentTypeFileSuffix)] += file.Size()
+		} else {
+			sizes[file.Name()] += file.Size()
+			entries = append(entries, file)
+		}
+	}
+
+	if totalSize <= cache.maxSize {
+		return nil
+	}
+
+	// Least recently used first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+
+	for _, entry := range entries {
+		if totalSize <= cache.maxSize {
+			break
+		}
+
+		log.Debug(fmt.Sprintf("Evicting cached image [%s]", entry.Name()))
+
+		err = cache.remove(filepath.Join(cache.directory, entry.Name()))
+		if err != nil {
+			return err
+		}
+		totalSize -= sizes[entry.Name()]
+	}
+
+	return nil
+}
+
+func (cache *diskCache) remove(dataPath string) error {
+
+	err := os.Remove(dataPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Remove(dataPath + contentTypeFileSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (cache *diskCache) path(imageURL string) string {
+	hash := sha256.Sum256([]byte(imageURL))
+	return filepath.Join(cache.directory, hex.EncodeToString(hash[:]))
+}
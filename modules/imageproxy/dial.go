@@ -0,0 +1,121 @@
+package imageproxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const maxImageRedirects = 5
+
+// Ranges images are never fetched from, the proxy would let clients reach the internal network otherwise
+var forbiddenNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func isForbiddenIP(ip net.IP) bool {
+
+	if ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client checking the address of every connection, whether it follows a redirect or not
+func newImageClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+	if !allowPrivateNetworks {
+		dialer.Control = checkDialedAddress
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		// Proxies from the environment are left out, they would be dialed instead of the image host
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= maxImageRedirects {
+				return fmt.Errorf("Stopped after %d redirects", maxImageRedirects)
+			}
+			return checkImageURL(request.URL, allowPrivateNetworks)
+		},
+	}
+}
+
+// Called once the host is resolved, right before connecting
+func checkDialedAddress(network string, address string, connection syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || isForbiddenIP(ip) {
+		return fmt.Errorf("Images of address [%s] are not proxied", host)
+	}
+	return nil
+}
+
+// Early check of the URL, resolved hosts being checked when dialing
+func checkImageURL(imageURL *url.URL, allowPrivateNetworks bool) error {
+
+	if imageURL.Scheme != "http" && imageURL.Scheme != "https" {
+		return fmt.Errorf("Images of scheme [%s] are not proxied", imageURL.Scheme)
+	}
+	if imageURL.Hostname() == "" {
+		return errors.New("No image host given")
+	}
+	if allowPrivateNetworks {
+		return nil
+	}
+
+	host := strings.ToLower(imageURL.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("Images of host [%s] are not proxied", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && isForbiddenIP(ip) {
+		return fmt.Errorf("Images of address [%s] are not proxied", host)
+	}
+	return nil
+}
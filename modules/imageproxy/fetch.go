@@ -0,0 +1,83 @@
+package imageproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const unknownBinaryContentType = "application/octet-stream"
+
+// Images that may embed scripts are not proxied
+var forbiddenContentTypes = map[string]bool{
+	"image/svg+xml": true,
+}
+
+func fetchImage(ctx context.Context, client *http.Client, imageURL string, maxSize int64) (*CachedImage, error) {
+
+	log.Debug(fmt.Sprintf("Fetching image [%s]", imageURL))
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkImageURL(request.URL, proxyConfig.AllowPrivateNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to fetch image [%s], got status %d", imageURL, response.StatusCode)
+	}
+
+	declaredContentType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse content type of image [%s]", imageURL)
+	}
+
+	if !isAllowedContentType(declaredContentType) {
+		return nil, fmt.Errorf("Bad content type [%s] for image [%s]", declaredContentType, imageURL)
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("Image [%s] exceeds the maximum allowed size", imageURL)
+	}
+
+	// We do not trust the remote server
+	sniffedContentType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	if sniffedContentType == unknownBinaryContentType {
+		// Image format unknown to the sniffer, but binary anyway
+		sniffedContentType = declaredContentType
+	}
+	if !isAllowedContentType(sniffedContentType) {
+		return nil, fmt.Errorf("Content of image [%s] is not an image (%s)", imageURL, sniffedContentType)
+	}
+
+	return &CachedImage{
+		ContentType: sniffedContentType,
+		Content:     content,
+	}, nil
+}
+
+func isAllowedContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "image/") && !forbiddenContentTypes[contentType]
+}
@@ -0,0 +1,141 @@
+package imageproxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const ProxyPath = "/api/image-proxy"
+
+var (
+	proxyConfig *config.ImageProxyConfig
+	proxySecret []byte
+	imageCache  *diskCache
+	imageClient *http.Client
+)
+
+func Configure(config *config.ImageProxyConfig) error {
+
+	proxyConfig = config
+
+	if !config.Enabled {
+		return nil
+	}
+
+	if config.Secret != "" {
+		proxySecret = []byte(config.Secret)
+	} else {
+		log.Warn("No image proxy secret set, generating a random one; proxied URLs will not survive a restart")
+		proxySecret = make([]byte, 32)
+		_, err := rand.Read(proxySecret)
+		if err != nil {
+			appLog.DebugError(err, "Unable to generate an image proxy secret")
+			return err
+		}
+	}
+
+	var err error
+	imageCache, err = newDiskCache(config.CacheDir, int64(config.MaxCacheSizeMB)*1024*1024, time.Duration(config.CacheTTLHours)*time.Hour)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to initialize the image cache in [%s]", config.CacheDir))
+		return err
+	}
+
+	if config.AllowPrivateNetworks {
+		log.Warn("The image proxy fetches images of private networks")
+	}
+	imageClient = newImageClient(time.Duration(config.TimeoutSeconds)*time.Second, config.AllowPrivateNetworks)
+
+	return nil
+}
+
+func IsEnabled() bool {
+	return proxyConfig != nil && proxyConfig.Enabled
+}
+
+func ProxiedURL(imageURL string) string {
+
+	if !IsEnabled() || imageURL == "" {
+		return imageURL
+	}
+
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return imageURL
+	}
+
+	values := url.Values{}
+	values.Set("url", imageURL)
+	values.Set("sig", sign(imageURL))
+
+	return fmt.Sprintf("%s?%s", ProxyPath, values.Encode())
+}
+
+func VerifySignature(imageURL string, signature string) error {
+
+	if !IsEnabled() {
+		return errors.New("The image proxy is disabled")
+	}
+
+	decodedSignature, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.New("Malformed image proxy signature")
+	}
+
+	if !hmac.Equal(decodedSignature, rawSignature(imageURL)) {
+		return errors.New("Bad image proxy signature")
+	}
+
+	return nil
+}
+
+func Get(ctx context.Context, imageURL string) (*CachedImage, error) {
+
+	if !IsEnabled() {
+		return nil, errors.New("The image proxy is disabled")
+	}
+
+	cached, err := imageCache.get(imageURL)
+	if err != nil {
+		appLog.DebugError(err, "Unable to read a cached image")
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	fetched, err := fetchImage(ctx, imageClient, imageURL, int64(proxyConfig.MaxImageSizeMB)*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	err = imageCache.put(imageURL, fetched)
+	if err != nil {
+		// The image is still usable
+		appLog.DebugError(err, "Unable to cache an image")
+	}
+
+	return fetched, nil
+}
+
+func sign(imageURL string) string {
+	return hex.EncodeToString(rawSignature(imageURL))
+}
+
+func rawSignature(imageURL string) []byte {
+	mac := hmac.New(sha256.New, proxySecret)
+	mac.Write([]byte(imageURL))
+	return mac.Sum(nil)
+}
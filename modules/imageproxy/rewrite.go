@@ -0,0 +1,101 @@
+package imageproxy
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	"golang.org/x/net/html"
+)
+
+func RewriteFeeds(feeds []*dbfeed.Feed) {
+
+	if !IsEnabled() {
+		return
+	}
+
+	for _, feed := range feeds {
+		RewriteFeed(feed)
+	}
+}
+
+func RewriteFeed(feed *dbfeed.Feed) {
+
+	if !IsEnabled() {
+		return
+	}
+
+	rewriteImage(feed.Image)
+	RewriteItems(feed.Items)
+}
+
+func RewriteItems(items []*dbfeed.FeedItem) {
+
+	if !IsEnabled() {
+		return
+	}
+
+	for _, item := range items {
+		rewriteImage(item.Image)
+
+		if proxyConfig.RewriteContent {
+			item.Description = rewriteContentOrKeep(item.Description)
+			item.Content = rewriteContentOrKeep(item.Content)
+		}
+	}
+}
+
+func RewriteContent(content string) (string, error) {
+
+	var buffer bytes.Buffer
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+
+	for {
+		tokenType := tokenizer.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return buffer.String(), nil
+			}
+			return "", tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "img" {
+				for i, attribute := range token.Attr {
+					if attribute.Key == "src" {
+						token.Attr[i].Val = ProxiedURL(attribute.Val)
+					}
+				}
+				buffer.WriteString(token.String())
+			} else {
+				buffer.Write(tokenizer.Raw())
+			}
+		default:
+			buffer.Write(tokenizer.Raw())
+		}
+	}
+}
+
+func rewriteImage(image *dbfeed.FeedImage) {
+	if image != nil {
+		image.URL = ProxiedURL(image.URL)
+	}
+}
+
+func rewriteContentOrKeep(content string) string {
+
+	if content == "" {
+		return content
+	}
+
+	rewritten, err := RewriteContent(content)
+	if err != nil {
+		appLog.DebugError(err, "Unable to rewrite images of a content")
+		return content
+	}
+	return rewritten
+}
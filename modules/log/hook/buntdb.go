@@ -61,7 +61,7 @@ func (hook BuntDBLogHook) Fire(entry *logrus.Entry) error {
 		return err
 	}
 
-	hook.db.Update(func(tx *buntdb.Tx) error {
+	return hook.db.Update(func(tx *buntdb.Tx) error {
 		_, _, err = tx.Set(
 			makeBuntKey(entry),
 			buntBody,
@@ -73,8 +73,6 @@ func (hook BuntDBLogHook) Fire(entry *logrus.Entry) error {
 		}
 		return nil
 	})
-
-	return nil
 }
 
 func (hook BuntDBLogHook) Levels() []logrus.Level {
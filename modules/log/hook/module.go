@@ -6,6 +6,7 @@ import (
 
 	"github.com/dademo/rssreader/modules/config"
 	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/metrics"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/go-extras/elogrus.v7"
 
@@ -15,6 +16,18 @@ import (
 
 var enabledBackends = []string{}
 
+var hookErrorsCounter = metrics.NewCounterVec(
+	"rssreader_log_hook_errors_total",
+	"Log entries a backend failed to save.",
+	"backend",
+)
+
+// Counts the errors of the wrapped hook
+type countingHook struct {
+	backendName string
+	wrappedHook logrus.Hook
+}
+
 const (
 	BackendNameElasticsearch = "elasticsearch"
 	BackendNameInfluxDB      = "influxdb"
@@ -162,8 +175,12 @@ func GetEnabledBackends() []string {
 }
 
 func addHook(backendName string, hook logrus.Hook) {
-	logrus.AddHook(hook)
-	enabledBackends = append(enabledBackends, strings.ToLower(backendName))
+	backendName = strings.ToLower(backendName)
+	logrus.AddHook(countingHook{
+		backendName: backendName,
+		wrappedHook: hook,
+	})
+	enabledBackends = append(enabledBackends, backendName)
 }
 
 func getLevelsGreaterThan(levelStr string) ([]logrus.Level, error) {
@@ -194,3 +211,16 @@ func mergeAdditionalTags(tags ...map[string]interface{}) map[string]interface{}
 	}
 	return finalTags
 }
+
+func (hook countingHook) Levels() []logrus.Level {
+	return hook.wrappedHook.Levels()
+}
+
+func (hook countingHook) Fire(entry *logrus.Entry) error {
+
+	err := hook.wrappedHook.Fire(entry)
+	if err != nil {
+		hookErrorsCounter.Inc(hook.backendName)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tidwall/buntdb"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const defaultPingTimeout = 5 * time.Second
+
+// Checks that an enabled backend is reachable
+func PingBackend(ctx context.Context, backendName string) error {
+
+	switch backendName {
+	case BackendNameElasticsearch:
+		return pingElasticsearch(ctx)
+	case BackendNameInfluxDB:
+		return pingInfluxDB(ctx)
+	case BackendNameMongoDB:
+		return pingMongoDB(ctx)
+	case BackendNameBuntDB:
+		return pingBuntDB()
+	default:
+		return fmt.Errorf("Unknown log backend [%s]", backendName)
+	}
+}
+
+func pingElasticsearch(ctx context.Context) error {
+
+	if elasticsearchClient == nil {
+		return errors.New("Elasticsearch client is not connected")
+	}
+
+	response, err := elasticsearchClient.Ping(elasticsearchClient.Ping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.IsError() {
+		return fmt.Errorf("Elasticsearch answered [%s]", response.Status())
+	}
+	return nil
+}
+
+func pingInfluxDB(ctx context.Context) error {
+
+	if influxdbClient == nil {
+		return errors.New("InfluxDB client is not connected")
+	}
+
+	timeout := defaultPingTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	_, _, err := (*influxdbClient).Ping(timeout)
+	return err
+}
+
+func pingMongoDB(ctx context.Context) error {
+
+	if mongoDBClient == nil {
+		return errors.New("MongoDB client is not connected")
+	}
+	return mongoDBClient.Ping(ctx, readpref.Primary())
+}
+
+func pingBuntDB() error {
+
+	if buntDBClient == nil {
+		return errors.New("BuntDB database is not opened")
+	}
+	return buntDBClient.View(func(tx *buntdb.Tx) error {
+		return nil
+	})
+}
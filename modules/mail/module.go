@@ -0,0 +1,301 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netMail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+const (
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityNone     = "none"
+
+	defaultTimeoutSeconds = 30
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	// Sent as an alternative to the text when set
+	Html string
+}
+
+var (
+	smtpConfig *config.SmtpConfig
+	from       *netMail.Address
+)
+
+var ErrMailDisabled = errors.New("No SMTP server configured")
+
+// Sending is disabled without SMTP host
+func Configure(config *config.SmtpConfig) error {
+
+	smtpConfig = config
+	from = nil
+
+	if !Enabled() {
+		return nil
+	}
+
+	err := Validate(config)
+	if err != nil {
+		return err
+	}
+
+	from, err = netMail.ParseAddress(config.From)
+	return err
+}
+
+// Checks the settings without connecting, nothing being checked without host
+func Validate(config *config.SmtpConfig) error {
+
+	if config == nil || config.Host == "" {
+		return nil
+	}
+
+	switch config.Security {
+	case SecurityStartTLS, SecurityTLS, SecurityNone:
+	default:
+		return fmt.Errorf("Invalid SMTP security [%s], expecting %s, %s or %s", config.Security, SecurityStartTLS, SecurityTLS, SecurityNone)
+	}
+
+	_, err := netMail.ParseAddress(config.From)
+	if err != nil {
+		return fmt.Errorf("Invalid SMTP from address [%s]: %s", config.From, err)
+	}
+	return nil
+}
+
+func Enabled() bool {
+	return smtpConfig != nil && smtpConfig.Host != ""
+}
+
+// Checks the recipient addresses, as given in the configuration
+func ParseAddresses(addresses []string) ([]*netMail.Address, error) {
+
+	parsed := make([]*netMail.Address, 0, len(addresses))
+	for _, address := range addresses {
+		recipient, err := netMail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid address [%s]: %s", address, err)
+		}
+		parsed = append(parsed, recipient)
+	}
+	return parsed, nil
+}
+
+func Send(ctx context.Context, message *Message) error {
+
+	if !Enabled() {
+		return ErrMailDisabled
+	}
+
+	recipients, err := ParseAddresses(message.To)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return errors.New("No recipient given")
+	}
+
+	body, err := build(message, recipients)
+	if err != nil {
+		appLog.DebugError(err, "Unable to build the message")
+		return err
+	}
+
+	client, err := connect(ctx)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to connect to the SMTP server [%s]", smtpConfig.Host))
+		return err
+	}
+	defer client.Close()
+
+	err = client.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		err = client.Rcpt(recipient.Address)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Recipient [%s] refused", recipient.Address))
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(body)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		appLog.DebugError(err, "The SMTP server refused the message")
+		return err
+	}
+
+	return client.Quit()
+}
+
+func connect(ctx context.Context) (*smtp.Client, error) {
+
+	timeout := time.Duration(smtpConfig.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeoutSeconds * time.Second
+	}
+
+	address := net.JoinHostPort(smtpConfig.Host, strconv.Itoa(int(smtpConfig.Port)))
+	tlsConfig := &tls.Config{
+		ServerName:         smtpConfig.Host,
+		InsecureSkipVerify: smtpConfig.InsecureSkipVerify,
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	var err error
+	if smtpConfig.Security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Bounding the whole exchange
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, smtpConfig.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if smtpConfig.Security == SecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("The SMTP server does not support STARTTLS")
+		}
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	// Refused by PlainAuth over unencrypted connections, but to localhost
+	if smtpConfig.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, smtpConfig.Host))
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func build(message *Message, recipients []*netMail.Address) ([]byte, error) {
+
+	var buffer bytes.Buffer
+
+	to := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		to = append(to, recipient.String())
+	}
+
+	messageId, err := newMessageId()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageId},
+		{"MIME-Version", "1.0"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buffer, "%s: %s\r\n", header[0], header[1])
+	}
+
+	if message.Html == "" {
+		fmt.Fprintf(&buffer, "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err = writeQuotedPrintable(&buffer, message.Text)
+		return buffer.Bytes(), err
+	}
+
+	parts := multipart.NewWriter(&buffer)
+	fmt.Fprintf(&buffer, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+
+	for _, part := range [][2]string{{"text/plain", message.Text}, {"text/html", message.Html}} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part[0] + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = writeQuotedPrintable(writer, part[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = parts.Close()
+	return buffer.Bytes(), err
+}
+
+func writeQuotedPrintable(writer io.Writer, content string) error {
+
+	encoder := quotedprintable.NewWriter(writer)
+	_, err := encoder.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func newMessageId() (string, error) {
+
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain), nil
+}
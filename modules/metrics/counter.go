@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+type CounterVec struct {
+	metricName string
+	help       string
+	labelNames []string
+	lock       sync.Mutex
+	values     map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// Creates and registers a counter, label values being given in the order of labelNames
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+
+	counter := &CounterVec{
+		metricName: name,
+		help:       help,
+		labelNames: labelNames,
+		values:     map[string]*counterValue{},
+	}
+	register(counter)
+	return counter
+}
+
+func (counter *CounterVec) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+func (counter *CounterVec) Add(value float64, labelValues ...string) {
+
+	if value < 0 {
+		panic("Counters can not decrease")
+	}
+
+	key := labelKey(counter.labelNames, labelValues)
+
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	current, ok := counter.values[key]
+	if !ok {
+		current = &counterValue{labelValues: append([]string(nil), labelValues...)}
+		counter.values[key] = current
+	}
+	current.value += value
+}
+
+func (counter *CounterVec) name() string {
+	return counter.metricName
+}
+
+func (counter *CounterVec) write(writer io.Writer) error {
+
+	err := writeHeader(writer, counter.metricName, counter.help, typeCounter)
+	if err != nil {
+		return err
+	}
+
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	keys := make([]string, 0, len(counter.values))
+	for key := range counter.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := counter.values[key]
+		err = writeSample(writer, counter.metricName, formatLabels(counter.labelNames, value.labelValues), value.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
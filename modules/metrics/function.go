@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"io"
+)
+
+// Value read when metrics are collected
+type funcMetric struct {
+	metricName string
+	help       string
+	metricType string
+	fct        func() float64
+}
+
+func NewGaugeFunc(name string, help string, fct func() float64) {
+	register(&funcMetric{
+		metricName: name,
+		help:       help,
+		metricType: typeGauge,
+		fct:        fct,
+	})
+}
+
+// fct must never return a lower value than before
+func NewCounterFunc(name string, help string, fct func() float64) {
+	register(&funcMetric{
+		metricName: name,
+		help:       help,
+		metricType: typeCounter,
+		fct:        fct,
+	})
+}
+
+func (m *funcMetric) name() string {
+	return m.metricName
+}
+
+func (m *funcMetric) write(writer io.Writer) error {
+
+	err := writeHeader(writer, m.metricName, m.help, m.metricType)
+	if err != nil {
+		return err
+	}
+	return writeSample(writer, m.metricName, "", m.fct())
+}
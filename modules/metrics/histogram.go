@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Upper bounds in seconds, fitting HTTP requests and feed fetches
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+type HistogramVec struct {
+	metricName string
+	help       string
+	labelNames []string
+	buckets    []float64
+	lock       sync.Mutex
+	values     map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	// Per bucket, not cumulated
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+// Creates and registers a histogram, buckets being sorted upper bounds
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+
+	histogram := &HistogramVec{
+		metricName: name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		values:     map[string]*histogramValue{},
+	}
+	register(histogram)
+	return histogram
+}
+
+func (histogram *HistogramVec) Observe(value float64, labelValues ...string) {
+
+	key := labelKey(histogram.labelNames, labelValues)
+
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+
+	current, ok := histogram.values[key]
+	if !ok {
+		current = &histogramValue{
+			labelValues:  append([]string(nil), labelValues...),
+			bucketCounts: make([]uint64, len(histogram.buckets)),
+		}
+		histogram.values[key] = current
+	}
+
+	bucket := sort.SearchFloat64s(histogram.buckets, value)
+	if bucket < len(histogram.buckets) {
+		current.bucketCounts[bucket]++
+	}
+	current.count++
+	current.sum += value
+}
+
+func (histogram *HistogramVec) name() string {
+	return histogram.metricName
+}
+
+func (histogram *HistogramVec) write(writer io.Writer) error {
+
+	err := writeHeader(writer, histogram.metricName, histogram.help, typeHistogram)
+	if err != nil {
+		return err
+	}
+
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+
+	keys := make([]string, 0, len(histogram.values))
+	for key := range histogram.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bucketLabelNames := append(append([]string(nil), histogram.labelNames...), "le")
+
+	for _, key := range keys {
+		value := histogram.values[key]
+		bucketLabelValues := append(append([]string(nil), value.labelValues...), "")
+
+		var cumulated uint64
+		for i, upperBound := range histogram.buckets {
+			cumulated += value.bucketCounts[i]
+			bucketLabelValues[len(bucketLabelValues)-1] = formatValue(upperBound)
+			err = writeSample(writer, histogram.metricName+"_bucket", formatLabels(bucketLabelNames, bucketLabelValues), float64(cumulated))
+			if err != nil {
+				return err
+			}
+		}
+
+		bucketLabelValues[len(bucketLabelValues)-1] = formatValue(math.Inf(1))
+		err = writeSample(writer, histogram.metricName+"_bucket", formatLabels(bucketLabelNames, bucketLabelValues), float64(value.count))
+		if err != nil {
+			return err
+		}
+
+		labels := formatLabels(histogram.labelNames, value.labelValues)
+		err = writeSample(writer, histogram.metricName+"_sum", labels, value.sum)
+		if err != nil {
+			return err
+		}
+		err = writeSample(writer, histogram.metricName+"_count", labels, float64(value.count))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
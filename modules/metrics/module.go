@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+// Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+type metric interface {
+	name() string
+	write(writer io.Writer) error
+}
+
+var (
+	registryLock sync.Mutex
+	registered   = map[string]metric{}
+)
+
+func register(m metric) {
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registered[m.name()]; ok {
+		panic(fmt.Sprintf("Metric [%s] is already registered", m.name()))
+	}
+	registered[m.name()] = m
+}
+
+func Handler() http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+
+		responseWriter.Header().Set("Content-Type", ContentType)
+
+		err := Write(responseWriter)
+		if err != nil {
+			appLog.DebugError(err, "Unable to write metrics")
+		}
+	})
+}
+
+// Writes every registered metric, sorted by name
+func Write(writer io.Writer) error {
+
+	registryLock.Lock()
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]metric, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, registered[name])
+	}
+	registryLock.Unlock()
+
+	bufferedWriter := bufio.NewWriter(writer)
+	for _, m := range metrics {
+		err := m.write(bufferedWriter)
+		if err != nil {
+			return err
+		}
+	}
+	return bufferedWriter.Flush()
+}
+
+func writeHeader(writer io.Writer, name string, help string, metricType string) error {
+	_, err := fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, metricType)
+	return err
+}
+
+func writeSample(writer io.Writer, name string, labels string, value float64) error {
+	_, err := fmt.Fprintf(writer, "%s%s %s\n", name, labels, formatValue(value))
+	return err
+}
+
+func formatLabels(labelNames []string, labelValues []string) string {
+
+	if len(labelNames) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labelNames))
+	for i, labelName := range labelNames {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labelName, escapeLabelValue(labelValues[i])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func labelKey(labelNames []string, labelValues []string) string {
+
+	if len(labelValues) != len(labelNames) {
+		panic(fmt.Sprintf("Expected %d label values, got %d", len(labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
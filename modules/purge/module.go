@@ -0,0 +1,123 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type FeedReport struct {
+	FeedId     uint64                   `json:"feedId"`
+	FeedTitle  string                   `json:"feedTitle"`
+	Candidates []*dbfeed.PurgeCandidate `json:"candidates"`
+}
+
+type Report struct {
+	DryRun  bool                  `json:"dryRun"`
+	Feeds   []*FeedReport         `json:"feeds"`
+	Orphans []*dbfeed.OrphanCount `json:"orphans"`
+}
+
+func Run(ctx context.Context, appConfig *config.Config, store dbfeed.FeedStore, dryRun bool) (*Report, error) {
+
+	log.Debug("Purging feed items")
+
+	feeds, err := store.GetAllFeeds(ctx, false)
+	if err != nil {
+		appLog.DebugError(err, "Unable to fetch feeds")
+		return nil, err
+	}
+
+	report := &Report{
+		DryRun: dryRun,
+		Feeds:  make([]*FeedReport, 0, len(feeds)),
+	}
+
+	for _, feed := range feeds {
+
+		policy := PolicyOf(appConfig, feedConfigByName(appConfig, feed.ConfigName))
+
+		candidates, err := store.PurgeCandidates(ctx, feed.Id, policy)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to find items to purge in feed [%s]", feed.Title))
+			return nil, err
+		}
+
+		if !dryRun {
+			err = store.DeleteFeedItems(ctx, candidates)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to purge items of feed [%s]", feed.Title))
+				return nil, err
+			}
+
+			err = store.PruneFeedItemTombstones(ctx, feed.Id)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to prune purged items of feed [%s]", feed.Title))
+				return nil, err
+			}
+		}
+
+		report.Feeds = append(report.Feeds, &FeedReport{
+			FeedId:     feed.Id,
+			FeedTitle:  feed.Title,
+			Candidates: candidates,
+		})
+	}
+
+	report.Orphans, err = store.PurgeOrphans(ctx, dryRun)
+	if err != nil {
+		appLog.DebugError(err, "Unable to purge orphan rows")
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func PolicyOf(appConfig *config.Config, feedConfig *config.Feed) dbfeed.RetentionPolicy {
+
+	retentionConfig := appConfig.RetentionConfig
+	if feedConfig != nil {
+		retentionConfig = retentionConfig.Merge(feedConfig.Retention)
+	}
+
+	policy := dbfeed.RetentionPolicy{}
+	if retentionConfig.MaxItems != nil {
+		policy.MaxItems = *retentionConfig.MaxItems
+	}
+	if retentionConfig.MaxAgeDays != nil {
+		policy.MaxAge = time.Duration(*retentionConfig.MaxAgeDays) * 24 * time.Hour
+	}
+	if retentionConfig.KeepStarred != nil {
+		policy.KeepStarred = *retentionConfig.KeepStarred
+	}
+
+	return policy
+}
+
+func (report *Report) ItemCount() int {
+	count := 0
+	for _, feedReport := range report.Feeds {
+		count += len(feedReport.Candidates)
+	}
+	return count
+}
+
+func feedConfigByName(appConfig *config.Config, name string) *config.Feed {
+
+	if name == "" {
+		return nil
+	}
+
+	for _, feed := range appConfig.Feeds {
+		if feed.Name == name {
+			return feed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ItemResult struct {
+	Item         *dbfeed.FeedItem
+	MatchedRules []string
+	Dropped      bool
+}
+
+var (
+	globalRules []*compiledRule
+	// Rules of the configured feeds, by feed configuration as names may be shared or empty
+	feedRules map[*config.Feed][]*compiledRule
+)
+
+// Rules are compiled once, the global ones being applied before those of each feed
+func Configure(rules []*config.Rule, feeds []*config.Feed) error {
+
+	compiled, err := compileRules(rules)
+	if err != nil {
+		appLog.DebugError(err, "Unable to compile global rules")
+		return err
+	}
+
+	compiledFeedRules := make(map[*config.Feed][]*compiledRule, len(feeds))
+	for _, feedConfig := range feeds {
+		compiledFeedRules[feedConfig], err = compileRules(feedConfig.Rules)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Unable to compile rules of feed [%s]", feedConfig.Name))
+			return fmt.Errorf("Feed [%s] : %s", feedConfig.Name, err)
+		}
+	}
+
+	globalRules = compiled
+	feedRules = compiledFeedRules
+	return nil
+}
+
+func Apply(feedConfig *config.Feed, feed *dbfeed.Feed) ([]*ItemResult, error) {
+
+	var rulesOfFeed []*compiledRule
+
+	if feedConfig != nil {
+		compiled, ok := feedRules[feedConfig]
+		if !ok {
+			// Feed given outside of the configuration
+			var err error
+			compiled, err = compileRules(feedConfig.Rules)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to compile rules of feed [%s]", feedConfig.Name))
+				return nil, err
+			}
+		}
+		rulesOfFeed = compiled
+	}
+
+	return applyRules(append(append([]*compiledRule{}, globalRules...), rulesOfFeed...), feed)
+}
+
+// Applies the rules of a user to a feed read from the database, items being rewritten for this user only
+func ApplyUserRules(userRules []*config.Rule, feed *dbfeed.Feed) ([]*ItemResult, error) {
+
+	compiled, err := compileRules(userRules)
+	if err != nil {
+		appLog.DebugError(err, "Unable to compile user rules")
+		return nil, err
+	}
+	return applyRules(compiled, feed)
+}
+
+func Validate(rule *config.Rule) error {
+	_, err := compileRules([]*config.Rule{rule})
+	return err
+}
+
+func applyRules(allRules []*compiledRule, feed *dbfeed.Feed) ([]*ItemResult, error) {
+
+	results := make([]*ItemResult, 0, len(feed.Items))
+	keptItems := make([]*dbfeed.FeedItem, 0, len(feed.Items))
+
+	feedLink, err := url.Parse(feed.Link)
+	if err != nil {
+		log.Debug(fmt.Sprintf("Unable to parse link of feed [%s]", feed.Title))
+		feedLink = nil
+	}
+
+	for _, item := range feed.Items {
+
+		result := &ItemResult{
+			Item:         item,
+			MatchedRules: []string{},
+		}
+
+		for _, rule := range allRules {
+
+			if !rule.matches(item) {
+				continue
+			}
+
+			log.Debug(fmt.Sprintf("Rule [%s] matched item [%s]", rule.name, item.Title))
+			result.MatchedRules = append(result.MatchedRules, rule.name)
+
+			err := rule.apply(item, feedLink)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to apply rule [%s]", rule.name))
+				return nil, err
+			}
+
+			if rule.actions.Drop {
+				result.Dropped = true
+				break
+			}
+		}
+
+		if !result.Dropped {
+			keptItems = append(keptItems, item)
+		}
+		results = append(results, result)
+	}
+
+	feed.Items = keptItems
+
+	return results, nil
+}
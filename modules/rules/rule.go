@@ -0,0 +1,315 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+)
+
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldContent     = "content"
+	FieldAuthor      = "author"
+	FieldCategory    = "category"
+	FieldLink        = "link"
+
+	RewriteStripUTM         = "stripUtm"
+	RewriteFixRelativeLinks = "fixRelativeLinks"
+	RewriteReplace          = "replace"
+)
+
+type compiledRule struct {
+	name     string
+	include  []*compiledCondition
+	exclude  []*compiledCondition
+	rewrites []*compiledRewrite
+	actions  config.RuleActions
+}
+
+type compiledCondition struct {
+	field   string
+	pattern *regexp.Regexp
+}
+
+type compiledRewrite struct {
+	rewriteType string
+	field       string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func compileRules(rules []*config.Rule) ([]*compiledRule, error) {
+
+	compiled := make([]*compiledRule, 0, len(rules))
+
+	for it, rule := range rules {
+
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", it)
+		}
+
+		compiledRule := &compiledRule{
+			name: name,
+		}
+
+		if rule.Actions != nil {
+			compiledRule.actions = *rule.Actions
+		}
+
+		for _, condition := range rule.Include {
+			compiledCondition, err := compileCondition(condition)
+			if err != nil {
+				return nil, fmt.Errorf("Rule [%s] : %s", name, err)
+			}
+			compiledRule.include = append(compiledRule.include, compiledCondition)
+		}
+
+		for _, condition := range rule.Exclude {
+			compiledCondition, err := compileCondition(condition)
+			if err != nil {
+				return nil, fmt.Errorf("Rule [%s] : %s", name, err)
+			}
+			compiledRule.exclude = append(compiledRule.exclude, compiledCondition)
+		}
+
+		for _, rewrite := range rule.Rewrites {
+			compiledRewrite, err := compileRewrite(rewrite)
+			if err != nil {
+				return nil, fmt.Errorf("Rule [%s] : %s", name, err)
+			}
+			compiledRule.rewrites = append(compiledRule.rewrites, compiledRewrite)
+		}
+
+		compiled = append(compiled, compiledRule)
+	}
+
+	return compiled, nil
+}
+
+func compileCondition(condition *config.RuleCondition) (*compiledCondition, error) {
+
+	if !isKnownField(condition.Field) {
+		return nil, fmt.Errorf("Unknown field [%s]", condition.Field)
+	}
+
+	pattern, err := regexp.Compile(condition.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiledCondition{
+		field:   condition.Field,
+		pattern: pattern,
+	}, nil
+}
+
+func compileRewrite(rewrite *config.RuleRewrite) (*compiledRewrite, error) {
+
+	switch rewrite.Type {
+	case RewriteStripUTM, RewriteFixRelativeLinks:
+		return &compiledRewrite{
+			rewriteType: rewrite.Type,
+		}, nil
+	case RewriteReplace:
+		if !isKnownField(rewrite.Field) || rewrite.Field == FieldAuthor || rewrite.Field == FieldCategory {
+			return nil, fmt.Errorf("Unable to replace values of field [%s]", rewrite.Field)
+		}
+
+		pattern, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		return &compiledRewrite{
+			rewriteType: rewrite.Type,
+			field:       rewrite.Field,
+			pattern:     pattern,
+			replacement: rewrite.Replacement,
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown rewrite type [%s]", rewrite.Type)
+	}
+}
+
+func (rule *compiledRule) matches(item *dbfeed.FeedItem) bool {
+
+	included := len(rule.include) == 0
+	for _, condition := range rule.include {
+		if condition.matches(item) {
+			included = true
+			break
+		}
+	}
+
+	if !included {
+		return false
+	}
+
+	for _, condition := range rule.exclude {
+		if condition.matches(item) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (rule *compiledRule) apply(item *dbfeed.FeedItem, feedLink *url.URL) error {
+
+	for _, rewrite := range rule.rewrites {
+		err := rewrite.apply(item, feedLink)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rule.actions.MarkRead {
+		item.Read = true
+	}
+
+	if rule.actions.Star {
+		item.Starred = true
+	}
+
+	for _, tag := range rule.actions.Tags {
+		if !containsStr(item.Tags, tag) {
+			item.Tags = append(item.Tags, tag)
+		}
+	}
+
+	return nil
+}
+
+func (condition *compiledCondition) matches(item *dbfeed.FeedItem) bool {
+	for _, value := range fieldValues(item, condition.field) {
+		if condition.pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rewrite *compiledRewrite) apply(item *dbfeed.FeedItem, feedLink *url.URL) error {
+
+	switch rewrite.rewriteType {
+	case RewriteStripUTM:
+		item.Link = stripUTM(item.Link)
+	case RewriteFixRelativeLinks:
+		item.Link = resolveLink(item.Link, feedLink)
+		for _, enclosure := range item.Enclosures {
+			enclosure.URL = resolveLink(enclosure.URL, feedLink)
+		}
+		if item.Image != nil {
+			item.Image.URL = resolveLink(item.Image.URL, feedLink)
+		}
+	case RewriteReplace:
+		setFieldValue(item, rewrite.field, rewrite.pattern.ReplaceAllString(fieldValue(item, rewrite.field), rewrite.replacement))
+	}
+
+	return nil
+}
+
+func isKnownField(field string) bool {
+	switch field {
+	case FieldTitle, FieldDescription, FieldContent, FieldAuthor, FieldCategory, FieldLink:
+		return true
+	default:
+		return false
+	}
+}
+
+func fieldValues(item *dbfeed.FeedItem, field string) []string {
+
+	switch field {
+	case FieldAuthor:
+		if item.Author == nil {
+			return []string{}
+		}
+		return []string{item.Author.Name, item.Author.Email}
+	case FieldCategory:
+		values := make([]string, 0, len(item.Categories))
+		for _, category := range item.Categories {
+			values = append(values, category.Category)
+		}
+		return values
+	default:
+		return []string{fieldValue(item, field)}
+	}
+}
+
+func fieldValue(item *dbfeed.FeedItem, field string) string {
+
+	switch field {
+	case FieldTitle:
+		return item.Title
+	case FieldDescription:
+		return item.Description
+	case FieldContent:
+		return item.Content
+	case FieldLink:
+		return item.Link
+	default:
+		return ""
+	}
+}
+
+func setFieldValue(item *dbfeed.FeedItem, field string, value string) {
+
+	switch field {
+	case FieldTitle:
+		item.Title = value
+	case FieldDescription:
+		item.Description = value
+	case FieldContent:
+		item.Content = value
+	case FieldLink:
+		item.Link = value
+	}
+}
+
+func stripUTM(link string) string {
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.RawQuery == "" {
+		return link
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+func resolveLink(link string, feedLink *url.URL) string {
+
+	if link == "" || feedLink == nil {
+		return link
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsed.IsAbs() {
+		return link
+	}
+
+	return feedLink.ResolveReference(parsed).String()
+}
+
+func containsStr(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
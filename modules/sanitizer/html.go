@@ -0,0 +1,314 @@
+package sanitizer
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dademo/rssreader/modules/config"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type htmlSanitizer struct {
+	config  *config.SanitizerConfig
+	baseURL *url.URL
+}
+
+// Elements removed with all their content
+var droppedElements = map[string]bool{
+	"applet":   true,
+	"base":     true,
+	"button":   true,
+	"embed":    true,
+	"form":     true,
+	"frame":    true,
+	"frameset": true,
+	"head":     true,
+	"iframe":   true,
+	"input":    true,
+	"link":     true,
+	"math":     true,
+	"meta":     true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+// Allowed elements with their allowed attributes; other elements are unwrapped
+var allowedElements = map[string][]string{
+	"a":          {"href"},
+	"abbr":       {},
+	"audio":      {"src", "controls"},
+	"b":          {},
+	"blockquote": {"cite"},
+	"br":         {},
+	"caption":    {},
+	"cite":       {},
+	"code":       {},
+	"dd":         {},
+	"del":        {"cite", "datetime"},
+	"details":    {},
+	"dfn":        {},
+	"div":        {},
+	"dl":         {},
+	"dt":         {},
+	"em":         {},
+	"figcaption": {},
+	"figure":     {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"i":          {},
+	"img":        {"src", "alt", "width", "height"},
+	"ins":        {"cite", "datetime"},
+	"kbd":        {},
+	"li":         {},
+	"mark":       {},
+	"ol":         {"start"},
+	"p":          {},
+	"pre":        {},
+	"q":          {"cite"},
+	"s":          {},
+	"samp":       {},
+	"small":      {},
+	"source":     {"src", "type"},
+	"span":       {},
+	"strike":     {},
+	"strong":     {},
+	"sub":        {},
+	"summary":    {},
+	"sup":        {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      {},
+	"th":         {"colspan", "rowspan"},
+	"thead":      {},
+	"time":       {"datetime"},
+	"tr":         {},
+	"u":          {},
+	"ul":         {},
+	"video":      {"src", "poster", "controls"},
+}
+
+var globalAttributes = []string{"title", "lang", "dir"}
+
+var urlAttributes = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"cite":   true,
+	"poster": true,
+}
+
+var allowedURLSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+func newHtmlSanitizer(config *config.SanitizerConfig, baseURL *url.URL) *htmlSanitizer {
+	return &htmlSanitizer{
+		config:  config,
+		baseURL: baseURL,
+	}
+}
+
+func (sanitizer *htmlSanitizer) sanitize(content string) (string, error) {
+
+	container := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(content), container)
+	if err != nil {
+		return "", err
+	}
+
+	for _, node := range nodes {
+		container.AppendChild(node)
+	}
+
+	sanitizer.cleanChildren(container)
+
+	var buffer bytes.Buffer
+	for child := container.FirstChild; child != nil; child = child.NextSibling {
+		err = html.Render(&buffer, child)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return buffer.String(), nil
+}
+
+func (sanitizer *htmlSanitizer) cleanChildren(parent *html.Node) {
+
+	for child := parent.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		switch child.Type {
+		case html.TextNode:
+			break
+		case html.ElementNode:
+			element := strings.ToLower(child.Data)
+
+			if droppedElements[element] {
+				parent.RemoveChild(child)
+			} else if allowedAttributes, ok := allowedElements[element]; ok {
+				child.Attr = sanitizer.cleanAttributes(element, child.Attr, allowedAttributes)
+				if sanitizer.isTracker(element, child) || isEmptyMedia(element, child) {
+					parent.RemoveChild(child)
+				} else {
+					sanitizer.cleanChildren(child)
+				}
+			} else {
+				// Unknown element, we keep its (cleaned) content only
+				sanitizer.cleanChildren(child)
+				for grandChild := child.FirstChild; grandChild != nil; {
+					nextGrandChild := grandChild.NextSibling
+					child.RemoveChild(grandChild)
+					parent.InsertBefore(grandChild, child)
+					grandChild = nextGrandChild
+				}
+				parent.RemoveChild(child)
+			}
+		default:
+			// Comments, doctypes, ...
+			parent.RemoveChild(child)
+		}
+
+		child = next
+	}
+}
+
+func (sanitizer *htmlSanitizer) cleanAttributes(element string, attributes []html.Attribute, allowedAttributes []string) []html.Attribute {
+
+	cleaned := make([]html.Attribute, 0, len(attributes))
+
+	for _, attribute := range attributes {
+
+		key := strings.ToLower(attribute.Key)
+		if attribute.Namespace != "" || !(containsStr(allowedAttributes, key) || containsStr(globalAttributes, key)) {
+			continue
+		}
+
+		value := attribute.Val
+		if urlAttributes[key] {
+			resolved, ok := sanitizer.resolveURL(value)
+			if !ok {
+				continue
+			}
+			value = resolved
+		}
+
+		cleaned = append(cleaned, html.Attribute{Key: key, Val: value})
+	}
+
+	if element == "a" {
+		cleaned = append(cleaned, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+	}
+
+	return cleaned
+}
+
+func (sanitizer *htmlSanitizer) resolveURL(value string) (string, bool) {
+
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+
+	if sanitizer.baseURL != nil {
+		parsed = sanitizer.baseURL.ResolveReference(parsed)
+	}
+
+	if parsed.Scheme == "" {
+		// Still relative, we keep it as-is as we can't do better
+		return parsed.String(), true
+	}
+
+	if !allowedURLSchemes[strings.ToLower(parsed.Scheme)] {
+		return "", false
+	}
+
+	return parsed.String(), true
+}
+
+func (sanitizer *htmlSanitizer) isTracker(element string, node *html.Node) bool {
+
+	if element != "img" || sanitizer.config == nil || !sanitizer.config.StripTrackers {
+		return false
+	}
+
+	width, hasWidth := pixelSize(attributeValue(node, "width"))
+	height, hasHeight := pixelSize(attributeValue(node, "height"))
+	if hasWidth && hasHeight && width <= 1 && height <= 1 {
+		return true
+	}
+
+	src, err := url.Parse(attributeValue(node, "src"))
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(src.Hostname())
+	for _, trackerHost := range sanitizer.config.TrackerHosts {
+		trackerHost = strings.ToLower(trackerHost)
+		if host == trackerHost || strings.HasSuffix(host, "."+trackerHost) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEmptyMedia(element string, node *html.Node) bool {
+	return (element == "img" || element == "source") && attributeValue(node, "src") == ""
+}
+
+func attributeValue(node *html.Node, key string) string {
+	for _, attribute := range node.Attr {
+		if attribute.Key == key {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+func pixelSize(value string) (int, bool) {
+
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	if value == "" {
+		return 0, false
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+func containsStr(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
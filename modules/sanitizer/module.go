@@ -0,0 +1,85 @@
+package sanitizer
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var sanitizerConfig *config.SanitizerConfig
+
+func Configure(config *config.SanitizerConfig) {
+	sanitizerConfig = config
+}
+
+func SanitizeFeed(feed *dbfeed.Feed) {
+
+	if sanitizerConfig == nil || !sanitizerConfig.Enabled {
+		return
+	}
+
+	log.Debug(fmt.Sprintf("Sanitizing feed [%s]", feed.Title))
+
+	feedLink := parseLink(feed.Link, nil)
+
+	for _, item := range feed.Items {
+		SanitizeItem(item, parseLink(item.Link, feedLink))
+	}
+}
+
+func SanitizeItem(item *dbfeed.FeedItem, baseURL *url.URL) {
+
+	if sanitizerConfig == nil || !sanitizerConfig.Enabled {
+		return
+	}
+
+	if sanitizerConfig.KeepRaw {
+		item.RawDescription = item.Description
+		item.RawContent = item.Content
+	}
+
+	item.Description = sanitizeOrEmpty(item.Description, baseURL)
+	item.Content = sanitizeOrEmpty(item.Content, baseURL)
+}
+
+func Sanitize(content string, baseURL *url.URL) (string, error) {
+	return newHtmlSanitizer(sanitizerConfig, baseURL).sanitize(content)
+}
+
+func sanitizeOrEmpty(content string, baseURL *url.URL) string {
+
+	if content == "" {
+		return content
+	}
+
+	sanitized, err := Sanitize(content, baseURL)
+	if err != nil {
+		// Better losing the content than storing something we were not able to clean
+		appLog.DebugError(err, "Unable to sanitize content, dropping it")
+		return ""
+	}
+	return sanitized
+}
+
+func parseLink(link string, baseURL *url.URL) *url.URL {
+
+	if link == "" {
+		return baseURL
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		log.Debug(fmt.Sprintf("Unable to parse link [%s]", link))
+		return baseURL
+	}
+
+	if baseURL != nil {
+		return baseURL.ResolveReference(parsed)
+	}
+	return parsed
+}
@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gives up looking for a matching time past this many years, for schedules like February 30th
+const maxCronYears = 5
+
+// Five fields schedule, minute hour day-of-month month day-of-week, evaluated in the local time zone
+type Cron struct {
+	spec     string
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// Days match on either field when both are restricted, as cron does
+	anyDay     bool
+	anyWeekday bool
+}
+
+type cronField struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	dayField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday being either 0 or 7
+	weekdayField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Parses a cron expression, the @hourly, @daily, @weekly, @monthly and @yearly shortcuts being accepted
+func ParseCron(spec string) (*Cron, error) {
+
+	expression := strings.TrimSpace(spec)
+	if shortcut, ok := cronShortcuts[strings.ToLower(expression)]; ok {
+		expression = shortcut
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid cron expression [%s], expecting 5 fields", spec)
+	}
+
+	cron := &Cron{
+		spec:       spec,
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}
+
+	var err error
+	for i, definition := range []struct {
+		field  cronField
+		target *uint64
+	}{
+		{minuteField, &cron.minutes},
+		{hourField, &cron.hours},
+		{dayField, &cron.days},
+		{monthField, &cron.months},
+		{weekdayField, &cron.weekdays},
+	} {
+		*definition.target, err = definition.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron expression [%s]: %s", spec, err)
+		}
+	}
+
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+
+	return cron, nil
+}
+
+func (cron *Cron) String() string {
+	return cron.spec
+}
+
+// First matching minute strictly after the given time, zero when there is none
+func (cron *Cron) Next(after time.Time) time.Time {
+
+	next := after.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(maxCronYears, 0, 0)
+
+	for next.Before(limit) {
+		switch {
+		case !has(cron.months, int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !cron.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !has(cron.hours, next.Hour()):
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !has(cron.minutes, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (cron *Cron) matchesDay(date time.Time) bool {
+
+	day := has(cron.days, date.Day())
+	weekday := has(cron.weekdays, int(date.Weekday()))
+
+	switch {
+	case cron.anyDay && cron.anyWeekday:
+		return true
+	case cron.anyDay:
+		return weekday
+	case cron.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+// Comma separated values, ranges and steps, like 1,15 or 9-17 or */10
+func (field cronField) parse(expression string) (uint64, error) {
+
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+
+		rangeExpression, step := part, 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			parsedStep, err := strconv.Atoi(part[slash+1:])
+			if err != nil || parsedStep <= 0 {
+				return 0, fmt.Errorf("invalid step in [%s]", part)
+			}
+			rangeExpression, step = part[:slash], parsedStep
+		}
+
+		start, end := field.min, field.max
+		if rangeExpression != "*" {
+			bounds := strings.SplitN(rangeExpression, "-", 2)
+
+			var err error
+			start, err = field.value(bounds[0])
+			if err != nil {
+				return 0, err
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = field.value(bounds[1])
+				if err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 5/15 meaning from 5 up to the maximum
+				end = field.max
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range [%s]", rangeExpression)
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (field cronField) value(expression string) (int, error) {
+
+	if value, ok := field.names[strings.ToLower(expression)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(expression)
+	if err != nil || value < field.min || value > field.max {
+		return 0, fmt.Errorf("value [%s] out of range %d-%d", expression, field.min, field.max)
+	}
+	return value, nil
+}
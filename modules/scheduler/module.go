@@ -1,29 +1,45 @@
 package scheduler
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/dademo/rssreader/modules/config"
-	"github.com/dademo/rssreader/modules/feed"
+	"github.com/dademo/rssreader/modules/metrics"
 
 	log "github.com/sirupsen/logrus"
 )
 
+var schedulerLag = metrics.NewHistogramVec(
+	"rssreader_scheduler_lag_seconds",
+	"Delay between a job tick and the start of its run.",
+	metrics.DefaultBuckets,
+	"job",
+)
+
 type Scheduler struct {
 	scheduledJobs []ScheduledJob
 	waitGroup     sync.WaitGroup
+	cancel        context.CancelFunc
+	running       int32
 }
 
+// The context is cancelled when the scheduler stops
 type Job interface {
-	Run()
+	Run(ctx context.Context)
 }
 
 type ScheduledJob struct {
+	// Labels the metrics of the job, as the feed it fetches
+	Name         string
 	Job          Job
 	Tickduration time.Duration
-	jobControl   jobControl
+	// Runs the job at the matching times instead of every tick duration
+	Cron       *Cron
+	RunAtStart bool
+	jobControl jobControl
 }
 
 type jobControl struct {
@@ -34,7 +50,7 @@ type jobControl struct {
 }
 
 type defaultJob struct {
-	fct func()
+	fct func(ctx context.Context)
 }
 
 func New() *Scheduler {
@@ -45,17 +61,45 @@ func New() *Scheduler {
 }
 
 func (scheduler *Scheduler) Schedule(scheduledJob ScheduledJob) {
-	scheduledJob.jobControl.waitGroup = &scheduler.waitGroup
+	scheduledJob.jobControl = jobControl{
+		lock:      &sync.Mutex{},
+		reset:     make(chan time.Duration, 1),
+		quit:      make(chan bool),
+		waitGroup: &scheduler.waitGroup,
+	}
 	scheduler.scheduledJobs = append(scheduler.scheduledJobs, scheduledJob)
 }
 
-func (scheduler *Scheduler) Run() {
+func (scheduler *Scheduler) Run(ctx context.Context) {
+
+	ctx, scheduler.cancel = context.WithCancel(ctx)
+	atomic.StoreInt32(&scheduler.running, 1)
 
 	for _, job := range scheduler.scheduledJobs {
-		go tickerRunner(job)
+		scheduler.waitGroup.Add(1)
+		go tickerRunner(ctx, job)
 	}
 }
 
+func (scheduler *Scheduler) Stop() {
+
+	atomic.StoreInt32(&scheduler.running, 0)
+
+	if scheduler.cancel != nil {
+		// Aborting running jobs
+		scheduler.cancel()
+	}
+
+	for _, job := range scheduler.scheduledJobs {
+		close(job.jobControl.quit)
+	}
+}
+
+// Whether jobs have been started and not stopped yet
+func (scheduler *Scheduler) Running() bool {
+	return atomic.LoadInt32(&scheduler.running) == 1
+}
+
 func (scheduler *Scheduler) Wait() {
 	scheduler.waitGroup.Wait()
 }
@@ -86,7 +130,13 @@ func (job *ScheduledJob) Days(d int) *ScheduledJob {
 
 func (job *ScheduledJob) setDuration(duration time.Duration) *ScheduledJob {
 	job.Tickduration = duration
-	job.jobControl.reset <- job.Tickduration
+	if job.jobControl.reset != nil {
+		select {
+		case job.jobControl.reset <- job.Tickduration:
+		default:
+			// A reset is already pending
+		}
+	}
 	return job
 }
 
@@ -94,50 +144,91 @@ func (job *ScheduledJob) plusDuration(duration time.Duration) *ScheduledJob {
 	return job.setDuration(job.Tickduration + duration)
 }
 
-func functionJobBuilder(fct func()) Job {
+func functionJobBuilder(fct func(ctx context.Context)) Job {
 	return defaultJob{
 		fct: fct,
 	}
 }
 
-func tickerRunner(scheduledJob ScheduledJob) {
+func tickerRunner(ctx context.Context, scheduledJob ScheduledJob) {
 
 	defer scheduledJob.jobControl.waitGroup.Done()
 
-	ticker := time.Ticker{}
+	if scheduledJob.Cron != nil {
+		cronRunner(ctx, scheduledJob)
+		return
+	}
+
+	if scheduledJob.Tickduration <= 0 {
+		log.Warn("Job scheduled without duration, ignoring it")
+		return
+	}
+
+	ticker := time.NewTicker(scheduledJob.Tickduration)
 	tick := ticker.C
 
+	if scheduledJob.RunAtStart {
+		scheduledJob.jobControl.lock.Lock()
+		scheduledJob.Job.Run(ctx)
+		scheduledJob.jobControl.lock.Unlock()
+	}
+
 	for {
 		select {
-		case <-tick:
+		case tickedAt := <-tick:
 			scheduledJob.jobControl.lock.Lock()
-			scheduledJob.Job.Run()
+			// Ticks are delayed by runs lasting longer than the job interval
+			schedulerLag.Observe(time.Since(tickedAt).Seconds(), jobName(scheduledJob))
+			scheduledJob.Job.Run(ctx)
 			scheduledJob.jobControl.lock.Unlock()
 		case newDuration := <-scheduledJob.jobControl.reset:
 			ticker.Reset(newDuration)
 		case <-scheduledJob.jobControl.quit:
 			scheduledJob.jobControl.lock.Lock()
 			ticker.Stop()
+			scheduledJob.jobControl.lock.Unlock()
 			return
 		}
 	}
 }
 
-func do(feedConfig *config.Feed) {
+func cronRunner(ctx context.Context, scheduledJob ScheduledJob) {
 
-	feed, err := feed.Fetch(feedConfig)
-
-	if err != nil {
-		log.WithError(err).Error(fmt.Sprintf("Unable to fetch feed [%s] at URL [%s]", feedConfig.Name, feedConfig.Url))
+	if scheduledJob.RunAtStart {
+		scheduledJob.jobControl.lock.Lock()
+		scheduledJob.Job.Run(ctx)
+		scheduledJob.jobControl.lock.Unlock()
 	}
 
-	err = feed.Save()
+	for {
+		next := scheduledJob.Cron.Next(time.Now())
+		if next.IsZero() {
+			log.Warn(fmt.Sprintf("Cron schedule [%s] never matches, ignoring the job", scheduledJob.Cron))
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			scheduledJob.jobControl.lock.Lock()
+			schedulerLag.Observe(time.Since(next).Seconds(), jobName(scheduledJob))
+			scheduledJob.Job.Run(ctx)
+			scheduledJob.jobControl.lock.Unlock()
+		case <-scheduledJob.jobControl.quit:
+			timer.Stop()
+			return
+		}
+	}
+}
 
-	if err != nil {
-		log.WithError(err).Error(fmt.Sprintf("Unable to persist feed [%s] :\n%s", feedConfig.Name, err))
+// Unnamed jobs are labelled by their type
+func jobName(scheduledJob ScheduledJob) string {
+	if scheduledJob.Name != "" {
+		return scheduledJob.Name
 	}
+	return fmt.Sprintf("%T", scheduledJob.Job)
 }
 
-func (job defaultJob) Run() {
-	job.fct()
+func (job defaultJob) Run(ctx context.Context) {
+	job.fct(ctx)
 }
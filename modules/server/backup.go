@@ -0,0 +1,27 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type scheduledBackupJob struct {
+	Config *config.BackupConfig
+}
+
+func (scheduledBackupJob scheduledBackupJob) Run(ctx context.Context) {
+
+	backupPath, err := database.Backup(ctx, scheduledBackupJob.Config.Directory, scheduledBackupJob.Config.Keep)
+	if err != nil {
+		appLog.DebugError(err, "Unable to back up the database")
+		return
+	}
+
+	log.Info(fmt.Sprintf("Database backed up to [%s]", backupPath))
+}
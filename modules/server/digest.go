@@ -0,0 +1,23 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/digest"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+type scheduledDigestJob struct {
+	Name  string
+	Store dbfeed.FeedStore
+}
+
+func (scheduledDigestJob scheduledDigestJob) Run(ctx context.Context) {
+
+	_, err := digest.Send(ctx, scheduledDigestJob.Store, scheduledDigestJob.Name)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to send digest [%s]", scheduledDigestJob.Name))
+	}
+}
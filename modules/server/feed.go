@@ -1,39 +1,101 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/digest"
+	"github.com/dademo/rssreader/modules/events"
 	"github.com/dademo/rssreader/modules/feed"
 	appLog "github.com/dademo/rssreader/modules/log"
 	"github.com/dademo/rssreader/modules/scheduler"
+	"github.com/dademo/rssreader/modules/webhook"
+
+	log "github.com/sirupsen/logrus"
 )
 
+// Feeds are fetched when the server starts, then at this interval unless they configure one
+const defaultFetchIntervalMinutes = 60
+
 type scheduledFeedReaderJob struct {
-	Feed *config.Feed
+	Feed  *config.Feed
+	Store dbfeed.FeedStore
 }
 
-func ScheduleFromConfig(jobScheduler *scheduler.Scheduler, config *config.Config) {
+func ScheduleFromConfig(jobScheduler *scheduler.Scheduler, config *config.Config, store dbfeed.FeedStore) {
 
 	for _, feed := range config.Feeds {
+
+		fetchIntervalMinutes := feed.FetchIntervalMinutes
+		if fetchIntervalMinutes == 0 {
+			fetchIntervalMinutes = defaultFetchIntervalMinutes
+		}
+		log.Info(fmt.Sprintf("Fetching feed [%s] now, then every %d minutes", feed.Name, fetchIntervalMinutes))
+
 		jobScheduler.Schedule(scheduler.ScheduledJob{
 			Job: scheduledFeedReaderJob{
-				Feed: feed,
+				Feed:  feed,
+				Store: store,
 			},
+			Name:         "feed:" + feed.Name,
+			Tickduration: time.Duration(fetchIntervalMinutes) * time.Minute,
+			RunAtStart:   true,
 		})
 	}
-}
 
-func (scheduledFeedReaderJob scheduledFeedReaderJob) Run() {
+	if config.RetentionConfig.PurgeIntervalMinutes > 0 {
+		jobScheduler.Schedule(scheduler.ScheduledJob{
+			Job: scheduledPurgeJob{
+				Config: config,
+				Store:  store,
+			},
+			Name:         "purge",
+			Tickduration: time.Duration(config.RetentionConfig.PurgeIntervalMinutes) * time.Minute,
+		})
+	}
 
-	fetchedFeed, err := feed.Fetch(scheduledFeedReaderJob.Feed)
-	if err != nil {
-		appLog.DebugError(err, fmt.Sprintf("Unable to fetch feed [%s]", scheduledFeedReaderJob.Feed.Name))
-		return
+	if config.BackupConfig.IntervalMinutes > 0 && database.IsSQLite() {
+		jobScheduler.Schedule(scheduler.ScheduledJob{
+			Job: scheduledBackupJob{
+				Config: config.BackupConfig,
+			},
+			Name:         "backup",
+			Tickduration: time.Duration(config.BackupConfig.IntervalMinutes) * time.Minute,
+		})
+	}
+
+	if webhook.Enabled() && config.WebhooksConfig.DeliveryIntervalSeconds > 0 {
+		jobScheduler.Schedule(scheduler.ScheduledJob{
+			Job:          scheduledWebhookJob{},
+			Name:         "webhooks",
+			Tickduration: time.Duration(config.WebhooksConfig.DeliveryIntervalSeconds) * time.Second,
+			RunAtStart:   true,
+		})
+	}
+
+	if digest.Enabled() {
+		for _, digestConfig := range digest.Digests() {
+			jobScheduler.Schedule(scheduler.ScheduledJob{
+				Job: scheduledDigestJob{
+					Name:  digestConfig.Name,
+					Store: store,
+				},
+				Name: "digest:" + digestConfig.Name,
+				Cron: digest.Schedule(digestConfig.Name),
+			})
+		}
 	}
+}
+
+func (scheduledFeedReaderJob scheduledFeedReaderJob) Run(ctx context.Context) {
 
-	err = fetchedFeed.Save()
+	err := feed.Sync(ctx, scheduledFeedReaderJob.Store, scheduledFeedReaderJob.Feed)
 	if err != nil {
-		appLog.DebugError(err, "Unable to persist values")
+		appLog.DebugError(err, fmt.Sprintf("Unable to synchronize feed [%s]", scheduledFeedReaderJob.Feed.Name))
+		events.FetchFailed(ctx, scheduledFeedReaderJob.Store, scheduledFeedReaderJob.Feed, err)
 	}
 }
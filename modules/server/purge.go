@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/purge"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type scheduledPurgeJob struct {
+	Config *config.Config
+	Store  dbfeed.FeedStore
+}
+
+func (scheduledPurgeJob scheduledPurgeJob) Run(ctx context.Context) {
+
+	report, err := purge.Run(ctx, scheduledPurgeJob.Config, scheduledPurgeJob.Store, false)
+	if err != nil {
+		appLog.DebugError(err, "Unable to purge feed items")
+		return
+	}
+
+	log.Info(fmt.Sprintf("%d feed items purged", report.ItemCount()))
+}
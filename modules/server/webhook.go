@@ -0,0 +1,18 @@
+package server
+
+import (
+	"context"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/webhook"
+)
+
+type scheduledWebhookJob struct{}
+
+func (scheduledWebhookJob scheduledWebhookJob) Run(ctx context.Context) {
+
+	err := webhook.DeliverDue(ctx)
+	if err != nil {
+		appLog.DebugError(err, "Unable to deliver webhooks")
+	}
+}
@@ -0,0 +1,182 @@
+package subscription
+
+import (
+	"context"
+
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/config"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	"github.com/dademo/rssreader/modules/rules"
+)
+
+var (
+	feedStore dbfeed.FeedStore
+	userStore *dbuser.Store
+)
+
+// Feeds are shared by every user, read through their subscriptions
+func Configure(feeds dbfeed.FeedStore, users *dbuser.Store) {
+	feedStore = feeds
+	userStore = users
+}
+
+func GetUserStore() *dbuser.Store {
+	return userStore
+}
+
+// Feeds the user is subscribed to, in subscription order, with their items as seen by this user
+func Feeds(ctx context.Context, userId appDatabase.PrimaryKey, withFeedItems bool) ([]*dbfeed.Feed, error) {
+
+	subscriptions, err := userStore.GetSubscriptions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, err := feedStore.GetAllFeeds(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribedFeeds := make([]*dbfeed.Feed, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		feed := FindFeed(feeds, subscription.FeedId)
+		if feed == nil {
+			continue
+		}
+		if subscription.Title != "" {
+			feed.Title = subscription.Title
+		}
+		subscribedFeeds = append(subscribedFeeds, feed)
+	}
+
+	if withFeedItems {
+		userRules, err := userStore.GetRules(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, feed := range subscribedFeeds {
+			err = loadItems(ctx, userId, userRules, feed)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return subscribedFeeds, nil
+}
+
+// Items of a feed as seen by the user, false when the user is not subscribed to it
+func FeedItems(ctx context.Context, userId appDatabase.PrimaryKey, feedId appDatabase.PrimaryKey) ([]*dbfeed.FeedItem, bool, error) {
+
+	userSubscription, err := userStore.SubscriptionByFeed(ctx, userId, feedId)
+	if err != nil || userSubscription == nil {
+		return nil, false, err
+	}
+
+	feed, err := feedStore.GetFeed(ctx, feedId)
+	if err != nil || feed == nil {
+		return nil, false, err
+	}
+	if userSubscription.Title != "" {
+		feed.Title = userSubscription.Title
+	}
+
+	userRules, err := userStore.GetRules(ctx, userId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = loadItems(ctx, userId, userRules, feed)
+	if err != nil {
+		return nil, false, err
+	}
+	return feed.Items, true, nil
+}
+
+func IsItemVisible(ctx context.Context, userId appDatabase.PrimaryKey, itemId appDatabase.PrimaryKey) (bool, error) {
+	return userStore.IsItemVisible(ctx, userId, itemId)
+}
+
+// Nil when every feed is visible, users only seeing their subscriptions unless they are administrators
+func VisibleFeeds(ctx context.Context, principal *auth.Principal) (map[appDatabase.PrimaryKey]bool, error) {
+
+	if principal == nil || auth.HasPermission(principal.User, auth.PermissionAdmin) || userStore == nil {
+		return nil, nil
+	}
+
+	subscriptions, err := userStore.GetSubscriptions(ctx, principal.User.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(map[appDatabase.PrimaryKey]bool, len(subscriptions))
+	for _, userSubscription := range subscriptions {
+		visible[userSubscription.FeedId] = true
+	}
+	return visible, nil
+}
+
+func FindFeed(feeds []*dbfeed.Feed, feedId appDatabase.PrimaryKey) *dbfeed.Feed {
+	for _, feed := range feeds {
+		if feed.Id == feedId {
+			return feed
+		}
+	}
+	return nil
+}
+
+// Items are read for this request only, user rules may rewrite them
+func loadItems(ctx context.Context, userId appDatabase.PrimaryKey, userRules []*dbuser.Rule, feed *dbfeed.Feed) error {
+
+	items, err := feedStore.GetFeedItems(ctx, feed.Id)
+	if err != nil {
+		return err
+	}
+	feed.Items = items
+
+	states, err := userStore.GetItemStates(ctx, userId, feed.Id)
+	if err != nil {
+		return err
+	}
+	return applyUserState(userRules, states, feed)
+}
+
+// Applies the rules of the user to the items of the feed, then the flags the user set.
+// Marks of the feed rules are left out, being copied into the states of subscribers when the items are saved.
+func applyUserState(userRules []*dbuser.Rule, states map[appDatabase.PrimaryKey]*dbuser.ItemState, feed *dbfeed.Feed) error {
+
+	for _, item := range feed.Items {
+		item.Read = false
+		item.Starred = false
+	}
+
+	feedRules := make([]*config.Rule, 0, len(userRules))
+	for _, userRule := range userRules {
+		if userRule.FeedId == nil || *userRule.FeedId == feed.Id {
+			feedRules = append(feedRules, userRule.Rule)
+		}
+	}
+
+	_, err := rules.ApplyUserRules(feedRules, feed)
+	if err != nil {
+		return err
+	}
+
+	// Explicit states win over rule actions
+	for _, item := range feed.Items {
+		state, ok := states[item.Id]
+		if !ok {
+			continue
+		}
+		if state.Read != nil {
+			item.Read = *state.Read
+		}
+		if state.Starred != nil {
+			item.Starred = *state.Starred
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package subscription
+
+import (
+	"context"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+)
+
+// Item of a subscribed feed, as seen by the user
+type Item struct {
+	*dbfeed.FeedItem
+	Feed *dbfeed.Feed
+	// Nil when the subscription is not in a folder
+	Folder *dbuser.Folder
+	// Publication date, or the best known approximation
+	Timestamp time.Time
+}
+
+// Everything a user reads, loaded at once for the reader APIs
+type View struct {
+	Feeds   []*dbfeed.Feed
+	Folders []*dbuser.Folder
+	Items   []*Item
+	// Folder of the subscriptions, by feed id
+	FeedFolders map[appDatabase.PrimaryKey]*dbuser.Folder
+}
+
+func LoadView(ctx context.Context, userId appDatabase.PrimaryKey, withFeedItems bool) (*View, error) {
+
+	feeds, err := Feeds(ctx, userId, withFeedItems)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := userStore.GetSubscriptions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := userStore.GetFolders(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	foldersById := make(map[appDatabase.PrimaryKey]*dbuser.Folder, len(folders))
+	for _, folder := range folders {
+		foldersById[folder.Id] = folder
+	}
+
+	view := &View{
+		Feeds:       feeds,
+		Folders:     folders,
+		Items:       make([]*Item, 0),
+		FeedFolders: make(map[appDatabase.PrimaryKey]*dbuser.Folder, len(subscriptions)),
+	}
+
+	for _, userSubscription := range subscriptions {
+		if userSubscription.FolderId != nil {
+			view.FeedFolders[userSubscription.FeedId] = foldersById[*userSubscription.FolderId]
+		}
+	}
+
+	for _, feed := range feeds {
+		for _, item := range feed.Items {
+			view.Items = append(view.Items, &Item{
+				FeedItem:  item,
+				Feed:      feed,
+				Folder:    view.FeedFolders[feed.Id],
+				Timestamp: itemTimestamp(item, feed),
+			})
+		}
+	}
+
+	return view, nil
+}
+
+// Items are read from the database by batches of at least this size, some being left out once rules are applied
+const minItemBatchSize = 50
+
+// Loads into the view up to count items matching the query and accepted, starting at the offset of its page.
+// Returns the offset following the last read item, zero once every matching item has been read.
+func (view *View) LoadItems(ctx context.Context, userId appDatabase.PrimaryKey, query *dbuser.ItemQuery, count int, accept func(item *Item) bool) (uint, error) {
+
+	userRules, err := userStore.GetRules(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	feedsById := make(map[appDatabase.PrimaryKey]*dbfeed.Feed, len(view.Feeds))
+	for _, feed := range view.Feeds {
+		feedsById[feed.Id] = feed
+	}
+
+	batchSize := count
+	if batchSize < minItemBatchSize {
+		batchSize = minItemBatchSize
+	}
+	page := query.Page
+	page.PageNo = 0
+	page.PageSize = uint(batchSize)
+
+	view.Items = make([]*Item, 0, count)
+	for {
+		batchQuery := *query
+		batchQuery.Page = page
+
+		refs, err := userStore.QueryItems(ctx, userId, &batchQuery)
+		if err != nil {
+			return 0, err
+		}
+
+		items, err := view.userItems(ctx, userRules, feedsById, refs)
+		if err != nil {
+			return 0, err
+		}
+
+		for i, ref := range refs {
+			page.Offset++
+
+			item, ok := items[ref.Id]
+			if !ok || !accept(item) {
+				continue
+			}
+
+			view.Items = append(view.Items, item)
+			if len(view.Items) < count {
+				continue
+			}
+			if i == len(refs)-1 && len(refs) < batchSize {
+				return 0, nil
+			}
+			return page.Offset, nil
+		}
+
+		if len(refs) < batchSize {
+			return 0, nil
+		}
+	}
+}
+
+// Items as seen by the user, by id, those dropped by the user rules being left out
+func (view *View) userItems(ctx context.Context, userRules []*dbuser.Rule, feedsById map[appDatabase.PrimaryKey]*dbfeed.Feed, refs []*dbuser.ItemRef) (map[appDatabase.PrimaryKey]*Item, error) {
+
+	itemIds := make([]appDatabase.PrimaryKey, 0, len(refs))
+	states := make(map[appDatabase.PrimaryKey]*dbuser.ItemState, len(refs))
+	for _, ref := range refs {
+		itemIds = append(itemIds, ref.Id)
+		states[ref.Id] = ref.State
+	}
+
+	feedItems, err := feedStore.GetFeedItemsByIds(ctx, itemIds)
+	if err != nil {
+		return nil, err
+	}
+
+	itemFeeds := make(map[appDatabase.PrimaryKey]appDatabase.PrimaryKey, len(refs))
+	for _, ref := range refs {
+		itemFeeds[ref.Id] = ref.FeedId
+	}
+
+	// Rules are applied feed by feed, on copies keeping the feeds of the view as they are
+	feedsOfBatch := make(map[appDatabase.PrimaryKey]*dbfeed.Feed)
+	for _, feedItem := range feedItems {
+		feedId := itemFeeds[feedItem.Id]
+		feed, ok := feedsOfBatch[feedId]
+		if !ok {
+			subscribedFeed, ok := feedsById[feedId]
+			if !ok {
+				continue
+			}
+			feedCopy := *subscribedFeed
+			feedCopy.Items = make([]*dbfeed.FeedItem, 0)
+			feed = &feedCopy
+			feedsOfBatch[feedId] = feed
+		}
+		feed.Items = append(feed.Items, feedItem)
+	}
+
+	items := make(map[appDatabase.PrimaryKey]*Item, len(feedItems))
+	for feedId, feed := range feedsOfBatch {
+
+		err = applyUserState(userRules, states, feed)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, feedItem := range feed.Items {
+			items[feedItem.Id] = &Item{
+				FeedItem:  feedItem,
+				Feed:      feedsById[feedId],
+				Folder:    view.FeedFolders[feedId],
+				Timestamp: itemTimestamp(feedItem, feed),
+			}
+		}
+	}
+	return items, nil
+}
+
+func itemTimestamp(item *dbfeed.FeedItem, feed *dbfeed.Feed) time.Time {
+	switch {
+	case item.Published != nil:
+		return *item.Published
+	case item.Updated != nil:
+		return *item.Updated
+	case feed.LastUpdate != nil:
+		return *feed.LastUpdate
+	default:
+		return time.Unix(0, 0)
+	}
+}
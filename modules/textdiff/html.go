@@ -0,0 +1,64 @@
+package textdiff
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Elements rendered on their own line
+var blockElements = map[string]bool{
+	"p":          true,
+	"div":        true,
+	"br":         true,
+	"li":         true,
+	"tr":         true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"blockquote": true,
+	"pre":        true,
+	"figure":     true,
+	"figcaption": true,
+	"hr":         true,
+}
+
+func HTMLToText(content string) string {
+
+	var builder strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return normalizeLines(builder.String())
+			}
+			// Not parseable as HTML, using it as text
+			return normalizeLines(content)
+		case html.TextToken:
+			builder.Write(tokenizer.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			if blockElements[string(name)] {
+				builder.WriteString("\n")
+			}
+		}
+	}
+}
+
+func normalizeLines(text string) string {
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
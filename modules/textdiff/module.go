@@ -0,0 +1,189 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	contextLines = 3
+	// Above this number of compared line pairs, the whole text is reported as changed
+	maxComparisons = 4000000
+)
+
+type operationType int
+
+const (
+	operationEqual = operationType(iota)
+	operationDelete
+	operationInsert
+)
+
+type operation struct {
+	operationType operationType
+	line          string
+	fromLine      int
+	toLine        int
+}
+
+func Unified(fromName string, toName string, from string, to string) string {
+
+	fromLines := splitLines(from)
+	toLines := splitLines(to)
+
+	operations := diffLines(fromLines, toLines)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	for _, hunk := range makeHunks(operations) {
+		writeHunk(&builder, hunk)
+	}
+
+	return builder.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func diffLines(from []string, to []string) []operation {
+
+	if len(from)*len(to) > maxComparisons {
+		operations := make([]operation, 0, len(from)+len(to))
+		for it, line := range from {
+			operations = append(operations, operation{operationType: operationDelete, line: line, fromLine: it, toLine: 0})
+		}
+		for it, line := range to {
+			operations = append(operations, operation{operationType: operationInsert, line: line, fromLine: len(from), toLine: it})
+		}
+		return operations
+	}
+
+	// Longest common subsequence lengths of the suffixes
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	operations := make([]operation, 0, len(from)+len(to))
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		if from[i] == to[j] {
+			operations = append(operations, operation{operationType: operationEqual, line: from[i], fromLine: i, toLine: j})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			operations = append(operations, operation{operationType: operationDelete, line: from[i], fromLine: i, toLine: j})
+			i++
+		} else {
+			operations = append(operations, operation{operationType: operationInsert, line: to[j], fromLine: i, toLine: j})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		operations = append(operations, operation{operationType: operationDelete, line: from[i], fromLine: i, toLine: j})
+	}
+	for ; j < len(to); j++ {
+		operations = append(operations, operation{operationType: operationInsert, line: to[j], fromLine: i, toLine: j})
+	}
+
+	return operations
+}
+
+func makeHunks(operations []operation) [][]operation {
+
+	hunks := make([][]operation, 0)
+	start, end := -1, -1
+
+	for it, op := range operations {
+		if op.operationType == operationEqual {
+			continue
+		}
+
+		hunkStart := maxInt(0, it-contextLines)
+		hunkEnd := minInt(len(operations), it+contextLines+1)
+
+		if start >= 0 && hunkStart <= end {
+			end = hunkEnd
+		} else {
+			if start >= 0 {
+				hunks = append(hunks, operations[start:end])
+			}
+			start, end = hunkStart, hunkEnd
+		}
+	}
+
+	if start >= 0 {
+		hunks = append(hunks, operations[start:end])
+	}
+
+	return hunks
+}
+
+func writeHunk(builder *strings.Builder, hunk []operation) {
+
+	fromCount, toCount := 0, 0
+	for _, op := range hunk {
+		if op.operationType != operationInsert {
+			fromCount++
+		}
+		if op.operationType != operationDelete {
+			toCount++
+		}
+	}
+
+	builder.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n",
+		hunkStartLine(hunk[0].fromLine, fromCount), fromCount,
+		hunkStartLine(hunk[0].toLine, toCount), toCount,
+	))
+
+	for _, op := range hunk {
+		switch op.operationType {
+		case operationEqual:
+			builder.WriteString(" ")
+		case operationDelete:
+			builder.WriteString("-")
+		case operationInsert:
+			builder.WriteString("+")
+		}
+		builder.WriteString(op.line)
+		builder.WriteString("\n")
+	}
+}
+
+func hunkStartLine(line int, count int) int {
+	// Unified diffs are 1-indexed, except for empty ranges
+	if count == 0 {
+		return line
+	}
+	return line + 1
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
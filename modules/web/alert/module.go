@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+
+	appAlert "github.com/dademo/rssreader/modules/alert"
+	"github.com/dademo/rssreader/modules/auth"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbalert"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+const alertsPageSize = 50
+
+// Configured watchlist, notification targets left out
+type watchlistDescription struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+	Patterns []string `json:"patterns"`
+	Feeds    []string `json:"feeds"`
+}
+
+var errAlertsDisabled = errors.New("Alerts need a database")
+
+func init() {
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: web.AppApiPrefix + "/alerts", Handler: getAlerts, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: web.AppApiPrefix + "/alerts/watchlists", Handler: getWatchlists, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+	)
+}
+
+// Most recent alerts first, users only seeing those of their subscriptions unless they are administrators
+func getAlerts(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		Watchlist string                 `httpParameter:"watchlist" httpParameterDefaultValue:""`
+		Since     appDatabase.PrimaryKey `httpParameter:"since" httpParameterDefaultValue:"0"`
+		Page      uint                   `httpParameter:"page" httpParameterDefaultValue:"0"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	if appAlert.GetStore() == nil {
+		web.AnswerError(errAlertsDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	ctx := request.Context()
+	visible, err := subscription.VisibleFeeds(ctx, auth.PrincipalFromContext(ctx))
+	if err != nil {
+		appLog.DebugError(err, "Unable to get the visible feeds")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	filter := &dbalert.Filter{
+		Watchlist: requestParameters.Watchlist,
+		SinceId:   requestParameters.Since,
+		Limit:     alertsPageSize,
+		Offset:    int(requestParameters.Page) * alertsPageSize,
+	}
+	if visible != nil {
+		filter.FeedIds = make([]appDatabase.PrimaryKey, 0, len(visible))
+		for feedId := range visible {
+			filter.FeedIds = append(filter.FeedIds, feedId)
+		}
+	}
+
+	alerts, err := appAlert.GetStore().GetAlerts(ctx, filter)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get the alerts")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	web.MarshallWriteJson(responseWriter, alerts)
+}
+
+func getWatchlists(responseWriter http.ResponseWriter, request *http.Request) {
+
+	watchlists := appAlert.Watchlists()
+	descriptions := make([]*watchlistDescription, 0, len(watchlists))
+	for _, watchlist := range watchlists {
+		descriptions = append(descriptions, &watchlistDescription{
+			Name:     watchlist.Name,
+			Keywords: watchlist.Keywords,
+			Patterns: watchlist.Patterns,
+			Feeds:    watchlist.Feeds,
+		})
+	}
+
+	web.MarshallWriteJson(responseWriter, descriptions)
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dademo/rssreader/modules/auth"
+	appLog "github.com/dademo/rssreader/modules/log"
+)
+
+// Answers 401 to anonymous requests and 403 to users missing the permission
+func RequirePermission(permission auth.Permission, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+
+		if !auth.Enabled() || permission == auth.PermissionPublic {
+			handler.ServeHTTP(responseWriter, request)
+			return
+		}
+
+		principal, err := auth.Authenticate(request)
+		if err != nil {
+			appLog.DebugError(err, "Unable to authenticate the request")
+			AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+
+		if principal == nil {
+			responseWriter.Header().Set("WWW-Authenticate", `Bearer realm="rssreader"`)
+			AnswerError(errors.New("Authentication required"), http.StatusUnauthorized, responseWriter)
+			return
+		}
+
+		if !auth.HasPermission(principal.User, permission) {
+			AnswerError(errors.New("Permission denied"), http.StatusForbidden, responseWriter)
+			return
+		}
+
+		handler.ServeHTTP(responseWriter, request.WithContext(auth.WithPrincipal(request.Context(), principal)))
+	})
+}
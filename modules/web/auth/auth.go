@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	appAuth "github.com/dademo/rssreader/modules/auth"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbauth"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+type createdToken struct {
+	*dbauth.Token
+	// Only returned once
+	Secret string `json:"token"`
+}
+
+var errAuthDisabled = errors.New("Authentication is disabled")
+
+func login(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		Username string `httpParameter:"username"`
+		Password string `httpParameter:"password"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	if !appAuth.Enabled() {
+		web.AnswerError(errAuthDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	if err := web.SecretInQuery(request, "password"); err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	user, session, secret, err := appAuth.Login(request.Context(), requestParameters.Username, requestParameters.Password)
+	if err == appAuth.ErrInvalidCredentials {
+		web.AnswerError(err, http.StatusUnauthorized, responseWriter)
+		return
+	}
+	if err != nil {
+		appLog.DebugError(err, "Unable to open a session")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	http.SetCookie(responseWriter, appAuth.SessionCookie(secret, session))
+	web.MarshallWriteJson(responseWriter, user)
+}
+
+func logout(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	err := appAuth.Logout(request.Context(), appAuth.PrincipalFromContext(request.Context()))
+	if err != nil {
+		appLog.DebugError(err, "Unable to close the session")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	if appAuth.Enabled() {
+		http.SetCookie(responseWriter, appAuth.ExpiredSessionCookie())
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+func getCurrentUser(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+	web.MarshallWriteJson(responseWriter, principal.User)
+}
+
+func getTokens(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	tokens, err := appAuth.GetStore().GetUserTokens(request.Context(), principal.User.Id)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJson(responseWriter, tokens)
+}
+
+func createToken(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		Name string `httpParameter:"name"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	token, secret, err := appAuth.CreateToken(request.Context(), principal.User.Id, requestParameters.Name)
+	if err != nil {
+		appLog.DebugError(err, "Unable to create a token")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJsonWithStatus(responseWriter, http.StatusCreated, createdToken{Token: token, Secret: secret})
+}
+
+func revokeToken(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		TokenId appDatabase.PrimaryKey `httpParameter:"tokenId"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	deleted, err := appAuth.GetStore().DeleteToken(request.Context(), principal.User.Id, requestParameters.TokenId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to revoke the token")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !deleted {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// Users only exist when authentication is enabled
+func requirePrincipal(responseWriter http.ResponseWriter, request *http.Request) (*appAuth.Principal, bool) {
+
+	principal := appAuth.PrincipalFromContext(request.Context())
+	if principal == nil {
+		web.AnswerError(errAuthDisabled, http.StatusNotFound, responseWriter)
+		return nil, false
+	}
+	return principal, true
+}
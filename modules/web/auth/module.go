@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"net/http"
+
+	appAuth "github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+func init() {
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: "/api/auth/login", Handler: login, Methods: []string{http.MethodPost}, Permission: appAuth.PermissionPublic},
+		web.RegisteredRoute{Pattern: "/api/auth/logout", Handler: logout, Methods: []string{http.MethodPost}},
+		web.RegisteredRoute{Pattern: "/api/auth/me", Handler: getCurrentUser, Methods: []string{http.MethodGet}},
+		web.RegisteredRoute{Pattern: "/api/auth/tokens", Handler: getTokens, Methods: []string{http.MethodGet}},
+		web.RegisteredRoute{Pattern: "/api/auth/tokens", Handler: createToken, Methods: []string{http.MethodPost}},
+		web.RegisteredRoute{Pattern: "/api/auth/tokens/{tokenId}", Handler: revokeToken, Methods: []string{http.MethodDelete}},
+	)
+}
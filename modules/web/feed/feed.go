@@ -1,9 +1,11 @@
 package feed
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/imageproxy"
 	appLog "github.com/dademo/rssreader/modules/log"
 	"github.com/dademo/rssreader/modules/web"
 )
@@ -12,6 +14,7 @@ func getFeeds(responseWriter http.ResponseWriter, request *http.Request) {
 
 	var requestParameters struct {
 		WithFeedItems bool `httpParameter:"withFeedItems" httpParameterDefaultValue:"false"`
+		Collapse      bool `httpParameter:"collapse" httpParameterDefaultValue:"false"`
 	}
 
 	web.DisableClientCache(responseWriter)
@@ -22,12 +25,22 @@ func getFeeds(responseWriter http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	feeds, err := dbfeed.GetAllFeeds(requestParameters.WithFeedItems)
+	feeds, err := visibleFeeds(request.Context(), requestParameters.WithFeedItems)
 	if err != nil {
 		appLog.DebugError(err, "An error occured when fetching values")
 		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
 		return
 	}
+
+	if requestParameters.WithFeedItems && requestParameters.Collapse {
+		err = collapseFeeds(request.Context(), feeds)
+		if err != nil {
+			appLog.DebugError(err, "An error occured when collapsing duplicates")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+	}
+	imageproxy.RewriteFeeds(feeds)
 	web.MarshallWriteJson(responseWriter, feeds)
 }
 
@@ -37,6 +50,7 @@ func filterFeeds(responseWriter http.ResponseWriter, request *http.Request) {
 		WithFeedItems bool   `httpParameter:"withFeedItems" httpParameterDefaultValue:"false"`
 		Field         string `httpParameter:"field" httpParameterDefaultValue:""`
 		Filter        string `httpParameter:"filter"`
+		Collapse      bool   `httpParameter:"collapse" httpParameterDefaultValue:"false"`
 	}
 
 	web.DisableClientCache(responseWriter)
@@ -47,11 +61,51 @@ func filterFeeds(responseWriter http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	feeds, err := dbfeed.GetAllFeeds(requestParameters.WithFeedItems)
+	feeds, err := visibleFeeds(request.Context(), requestParameters.WithFeedItems)
 	if err != nil {
 		appLog.DebugError(err, "An error occured when fetching values")
 		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
 		return
 	}
+
+	if requestParameters.WithFeedItems && requestParameters.Collapse {
+		err = collapseFeeds(request.Context(), feeds)
+		if err != nil {
+			appLog.DebugError(err, "An error occured when collapsing duplicates")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+	}
+	imageproxy.RewriteFeeds(feeds)
 	web.MarshallWriteJson(responseWriter, feeds)
 }
+
+// Duplicates are collapsed across feeds, each cluster being shown in the first feed holding one of its items
+func collapseFeeds(ctx context.Context, feeds []*dbfeed.Feed) error {
+
+	items := make([]*dbfeed.FeedItem, 0)
+	for _, feed := range feeds {
+		items = append(items, feed.Items...)
+	}
+
+	collapsed, err := feedStore.CollapseClusters(ctx, items)
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[*dbfeed.FeedItem]bool, len(collapsed))
+	for _, item := range collapsed {
+		kept[item] = true
+	}
+
+	for _, feed := range feeds {
+		feedItems := make([]*dbfeed.FeedItem, 0, len(feed.Items))
+		for _, item := range feed.Items {
+			if kept[item] {
+				feedItems = append(feedItems, item)
+			}
+		}
+		feed.Items = feedItems
+	}
+	return nil
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 
 	appDatabase "github.com/dademo/rssreader/modules/database"
-	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/imageproxy"
 	appLog "github.com/dademo/rssreader/modules/log"
 	"github.com/dademo/rssreader/modules/web"
 )
@@ -12,7 +12,8 @@ import (
 func getFeedItems(responseWriter http.ResponseWriter, request *http.Request) {
 
 	var requestParameters struct {
-		FeedId appDatabase.PrimaryKey `httpParameter:"feedId"`
+		FeedId   appDatabase.PrimaryKey `httpParameter:"feedId"`
+		Collapse bool                   `httpParameter:"collapse" httpParameterDefaultValue:"false"`
 	}
 
 	web.DisableClientCache(responseWriter)
@@ -24,12 +25,26 @@ func getFeedItems(responseWriter http.ResponseWriter, request *http.Request) {
 	}
 
 	if requestParameters.FeedId != 0 {
-		feeds, err := dbfeed.GetFeedItems(requestParameters.FeedId)
+		feeds, subscribed, err := visibleFeedItems(request.Context(), requestParameters.FeedId)
 		if err != nil {
 			appLog.DebugError(err, "An error occured when fetching values")
 			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
 			return
 		}
+		if !subscribed {
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if requestParameters.Collapse {
+			feeds, err = feedStore.CollapseClusters(request.Context(), feeds)
+			if err != nil {
+				appLog.DebugError(err, "An error occured when collapsing duplicates")
+				web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+				return
+			}
+		}
+		imageproxy.RewriteItems(feeds)
 		web.MarshallWriteJson(responseWriter, feeds)
 	} else {
 		responseWriter.WriteHeader(http.StatusBadRequest)
@@ -40,9 +55,10 @@ func getFeedItems(responseWriter http.ResponseWriter, request *http.Request) {
 func filterFeedItems(responseWriter http.ResponseWriter, request *http.Request) {
 
 	var requestParameters struct {
-		FeedId appDatabase.PrimaryKey `httpParameter:"feedId"`
-		Field  string                 `httpParameter:"field" httpParameterDefaultValue:""`
-		Filter string                 `httpParameter:"filter"`
+		FeedId   appDatabase.PrimaryKey `httpParameter:"feedId"`
+		Field    string                 `httpParameter:"field" httpParameterDefaultValue:""`
+		Filter   string                 `httpParameter:"filter"`
+		Collapse bool                   `httpParameter:"collapse" httpParameterDefaultValue:"false"`
 	}
 
 	web.DisableClientCache(responseWriter)
@@ -54,17 +70,31 @@ func filterFeedItems(responseWriter http.ResponseWriter, request *http.Request)
 	}
 
 	if requestParameters.FeedId != 0 {
-		feeds, err := dbfeed.GetFeedItems(requestParameters.FeedId)
+		feeds, subscribed, err := visibleFeedItems(request.Context(), requestParameters.FeedId)
 		if err != nil {
 			appLog.DebugError(err, "An error occured when fetching values")
 			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
 			return
 		}
+		if !subscribed {
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if requestParameters.Collapse {
+			feeds, err = feedStore.CollapseClusters(request.Context(), feeds)
+			if err != nil {
+				appLog.DebugError(err, "An error occured when collapsing duplicates")
+				web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+				return
+			}
+		}
 
 		if len(feeds) == 0 {
 			responseWriter.WriteHeader(http.StatusNotFound)
 		}
 
+		imageproxy.RewriteItems(feeds)
 		web.MarshallWriteJson(responseWriter, feeds)
 	} else {
 		responseWriter.WriteHeader(http.StatusBadRequest)
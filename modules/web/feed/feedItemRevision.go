@@ -0,0 +1,159 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/textdiff"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+func getFeedItemRevisions(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		ItemId appDatabase.PrimaryKey `httpParameter:"itemId"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	visible, err := isItemVisible(request.Context(), requestParameters.ItemId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !visible {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	revisions, err := feedStore.GetFeedItemRevisions(request.Context(), requestParameters.ItemId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	// Items never edited have no revision, unlike missing ones
+	if len(revisions) == 0 {
+		items, err := feedStore.GetFeedItemsByIds(request.Context(), []appDatabase.PrimaryKey{requestParameters.ItemId})
+		if err != nil {
+			appLog.DebugError(err, "An error occured when fetching values")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+		if len(items) == 0 {
+			web.AnswerError(fmt.Errorf("Item (%d) not found", requestParameters.ItemId), http.StatusNotFound, responseWriter)
+			return
+		}
+	}
+
+	web.MarshallWriteJson(responseWriter, revisions)
+}
+
+func diffFeedItemRevisions(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		ItemId appDatabase.PrimaryKey `httpParameter:"itemId"`
+		From   appDatabase.PrimaryKey `httpParameter:"from" httpParameterDefaultValue:"0"`
+		To     appDatabase.PrimaryKey `httpParameter:"to" httpParameterDefaultValue:"0"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	visible, err := isItemVisible(request.Context(), requestParameters.ItemId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !visible {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	revisions, err := feedStore.GetFeedItemRevisions(request.Context(), requestParameters.ItemId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	if len(revisions) == 0 {
+		web.AnswerError(errors.New("No revision found for this item"), http.StatusNotFound, responseWriter)
+		return
+	}
+
+	// By default, comparing the latest revision with the one before
+	toIndex := len(revisions) - 1
+	if requestParameters.To != 0 {
+		toIndex = revisionIndex(revisions, requestParameters.To)
+	}
+	fromIndex := toIndex - 1
+	if requestParameters.From != 0 {
+		fromIndex = revisionIndex(revisions, requestParameters.From)
+		if fromIndex < 0 {
+			web.AnswerError(fmt.Errorf("Revision (%d) not found", requestParameters.From), http.StatusNotFound, responseWriter)
+			return
+		}
+	}
+	if toIndex < 0 {
+		web.AnswerError(fmt.Errorf("Revision (%d) not found", requestParameters.To), http.StatusNotFound, responseWriter)
+		return
+	}
+
+	fromName, fromText := "/dev/null", ""
+	if fromIndex >= 0 {
+		fromName = fmt.Sprintf("revision-%d", revisions[fromIndex].Id)
+		fromText = revisionText(revisions[fromIndex])
+	}
+	toName := fmt.Sprintf("revision-%d", revisions[toIndex].Id)
+	toText := revisionText(revisions[toIndex])
+
+	responseWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = responseWriter.Write([]byte(textdiff.Unified(fromName, toName, fromText, toText)))
+	if err != nil {
+		appLog.DebugError(err, "Unable to write answer")
+	}
+}
+
+func revisionIndex(revisions []*dbfeed.FeedItemRevision, revisionId appDatabase.PrimaryKey) int {
+	for it, revision := range revisions {
+		if revision.Id == revisionId {
+			return it
+		}
+	}
+	return -1
+}
+
+func revisionText(revision *dbfeed.FeedItemRevision) string {
+
+	var builder strings.Builder
+
+	builder.WriteString("# Title\n")
+	builder.WriteString(revision.Title)
+	builder.WriteString("\n# Description\n")
+	builder.WriteString(textdiff.HTMLToText(revision.Description))
+	builder.WriteString("\n# Content\n")
+	builder.WriteString(textdiff.HTMLToText(revision.Content))
+	builder.WriteString("\n")
+
+	return builder.String()
+}
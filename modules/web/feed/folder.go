@@ -0,0 +1,105 @@
+package feed
+
+import (
+	"errors"
+	"net/http"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+func getFolders(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	folders, err := subscription.GetUserStore().GetFolders(request.Context(), principal.User.Id)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJson(responseWriter, folders)
+}
+
+func createFolder(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		Name string `httpParameter:"name"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	folders, err := subscription.GetUserStore().GetFolders(request.Context(), principal.User.Id)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	for _, folder := range folders {
+		if folder.Name == requestParameters.Name {
+			web.AnswerError(errors.New("A folder with this name already exists"), http.StatusConflict, responseWriter)
+			return
+		}
+	}
+
+	folder := &dbuser.Folder{Name: requestParameters.Name}
+
+	err = subscription.GetUserStore().CreateFolder(request.Context(), principal.User.Id, folder)
+	if err != nil {
+		appLog.DebugError(err, "Unable to save the folder")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJsonWithStatus(responseWriter, http.StatusCreated, folder)
+}
+
+func deleteFolder(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		FolderId appDatabase.PrimaryKey `httpParameter:"folderId"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	deleted, err := subscription.GetUserStore().DeleteFolder(request.Context(), principal.User.Id, requestParameters.FolderId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the folder")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !deleted {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package feed
+
+import (
+	"net/http"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+// PUT sets the flag, DELETE clears it
+func setItemRead(responseWriter http.ResponseWriter, request *http.Request) {
+	value := request.Method == http.MethodPut
+	setItemState(responseWriter, request, &dbuser.ItemState{Read: &value})
+}
+
+func setItemStarred(responseWriter http.ResponseWriter, request *http.Request) {
+	value := request.Method == http.MethodPut
+	setItemState(responseWriter, request, &dbuser.ItemState{Starred: &value})
+}
+
+func setItemState(responseWriter http.ResponseWriter, request *http.Request, state *dbuser.ItemState) {
+
+	var requestParameters struct {
+		ItemId appDatabase.PrimaryKey `httpParameter:"itemId"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	visible, err := isItemVisible(request.Context(), requestParameters.ItemId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !visible {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = subscription.GetUserStore().SetItemState(request.Context(), principal.User.Id, requestParameters.ItemId, state)
+	if err != nil {
+		appLog.DebugError(err, "Unable to save the item state")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
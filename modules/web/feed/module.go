@@ -1,12 +1,39 @@
 package feed
 
-import "github.com/dademo/rssreader/modules/web"
+import (
+	"net/http"
+
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+var feedStore dbfeed.FeedStore
+
+func Configure(store dbfeed.FeedStore) {
+	feedStore = store
+}
 
 func init() {
 	web.RegisterRoutes(
-		web.RegisteredRoute{Pattern: "/api/feed", Handler: getFeeds},
-		web.RegisteredRoute{Pattern: "/api/feed/filter", Handler: filterFeeds},
-		web.RegisteredRoute{Pattern: "/api/feed/{feedId}/items", Handler: getFeedItems},
-		web.RegisteredRoute{Pattern: "/api/feed/{feedId}/items/filter", Handler: filterFeedItems},
+		web.RegisteredRoute{Pattern: "/api/feed", Handler: getFeeds, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/feed/filter", Handler: filterFeeds, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/feed/{feedId}/items", Handler: getFeedItems, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/feed/{feedId}/items/filter", Handler: filterFeedItems, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/item/{itemId}/revisions", Handler: getFeedItemRevisions, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/item/{itemId}/revisions/diff", Handler: diffFeedItemRevisions, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/item/{itemId}/read", Handler: setItemRead, Methods: []string{http.MethodPut, http.MethodDelete}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/item/{itemId}/starred", Handler: setItemStarred, Methods: []string{http.MethodPut, http.MethodDelete}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/subscriptions", Handler: getSubscriptions, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/subscriptions", Handler: subscribe, Methods: []string{http.MethodPost}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/subscriptions/available", Handler: getAvailableFeeds, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/subscriptions/{subscriptionId}", Handler: updateSubscription, Methods: []string{http.MethodPut}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/subscriptions/{subscriptionId}", Handler: unsubscribe, Methods: []string{http.MethodDelete}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/folders", Handler: getFolders, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/folders", Handler: createFolder, Methods: []string{http.MethodPost}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/folders/{folderId}", Handler: deleteFolder, Methods: []string{http.MethodDelete}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/rules", Handler: getUserRules, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/rules", Handler: createUserRule, Methods: []string{http.MethodPost}, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: "/api/rules/{ruleId}", Handler: deleteUserRule, Methods: []string{http.MethodDelete}, Permission: auth.PermissionFeedsRead},
 	)
 }
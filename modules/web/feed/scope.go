@@ -0,0 +1,56 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dademo/rssreader/modules/auth"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+var errUsersDisabled = errors.New("Users are only available when authentication is enabled")
+
+// Feeds as seen by the user of the request, every feed when authentication is disabled
+func visibleFeeds(ctx context.Context, withFeedItems bool) ([]*dbfeed.Feed, error) {
+
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil {
+		return feedStore.GetAllFeeds(ctx, withFeedItems)
+	}
+	return subscription.Feeds(ctx, principal.User.Id, withFeedItems)
+}
+
+// Items of a feed as seen by the user of the request, false when the user is not subscribed to it
+func visibleFeedItems(ctx context.Context, feedId appDatabase.PrimaryKey) ([]*dbfeed.FeedItem, bool, error) {
+
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil {
+		items, err := feedStore.GetFeedItems(ctx, feedId)
+		return items, true, err
+	}
+	return subscription.FeedItems(ctx, principal.User.Id, feedId)
+}
+
+func isItemVisible(ctx context.Context, itemId appDatabase.PrimaryKey) (bool, error) {
+
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil {
+		return true, nil
+	}
+	return subscription.IsItemVisible(ctx, principal.User.Id, itemId)
+}
+
+// Users only exist when authentication is enabled
+func requirePrincipal(responseWriter http.ResponseWriter, request *http.Request) (*auth.Principal, bool) {
+
+	principal := auth.PrincipalFromContext(request.Context())
+	if principal == nil {
+		web.AnswerError(errUsersDisabled, http.StatusNotFound, responseWriter)
+		return nil, false
+	}
+	return principal, true
+}
@@ -0,0 +1,182 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+func getSubscriptions(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := subscription.GetUserStore().GetSubscriptions(request.Context(), principal.User.Id)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJson(responseWriter, subscriptions)
+}
+
+// Every feed fetched by the server, which users may subscribe to
+func getAvailableFeeds(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	feeds, err := feedStore.GetAllFeeds(request.Context(), false)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJson(responseWriter, feeds)
+}
+
+func subscribe(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		FeedId   appDatabase.PrimaryKey `httpParameter:"feedId"`
+		FolderId appDatabase.PrimaryKey `httpParameter:"folderId" httpParameterDefaultValue:"0"`
+		Title    string                 `httpParameter:"title" httpParameterDefaultValue:""`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	feed, err := feedStore.GetFeed(request.Context(), requestParameters.FeedId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if feed == nil {
+		web.AnswerError(fmt.Errorf("Feed (%d) not found", requestParameters.FeedId), http.StatusNotFound, responseWriter)
+		return
+	}
+
+	existing, err := subscription.GetUserStore().SubscriptionByFeed(request.Context(), principal.User.Id, requestParameters.FeedId)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if existing != nil {
+		web.AnswerError(fmt.Errorf("Already subscribed to feed (%d)", requestParameters.FeedId), http.StatusConflict, responseWriter)
+		return
+	}
+
+	newSubscription := &dbuser.Subscription{
+		FeedId:   requestParameters.FeedId,
+		FolderId: optionalId(requestParameters.FolderId),
+		Title:    requestParameters.Title,
+	}
+
+	err = subscription.GetUserStore().CreateSubscription(request.Context(), principal.User.Id, newSubscription)
+	if err != nil {
+		appLog.DebugError(err, "Unable to save the subscription")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+	web.MarshallWriteJsonWithStatus(responseWriter, http.StatusCreated, newSubscription)
+}
+
+func updateSubscription(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		SubscriptionId appDatabase.PrimaryKey `httpParameter:"subscriptionId"`
+		FolderId       appDatabase.PrimaryKey `httpParameter:"folderId" httpParameterDefaultValue:"0"`
+		Title          string                 `httpParameter:"title" httpParameterDefaultValue:""`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	changedSubscription := &dbuser.Subscription{
+		Id:       requestParameters.SubscriptionId,
+		FolderId: optionalId(requestParameters.FolderId),
+		Title:    requestParameters.Title,
+	}
+
+	updated, err := subscription.GetUserStore().UpdateSubscription(request.Context(), principal.User.Id, changedSubscription)
+	if err != nil {
+		appLog.DebugError(err, "Unable to update the subscription")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+	if !updated {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+func unsubscribe(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		SubscriptionId appDatabase.PrimaryKey `httpParameter:"subscriptionId"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	deleted, err := subscription.GetUserStore().DeleteSubscription(request.Context(), principal.User.Id, requestParameters.SubscriptionId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the subscription")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !deleted {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// Zero meaning no value
+func optionalId(id appDatabase.PrimaryKey) *appDatabase.PrimaryKey {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/rules"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+const maxRuleSize = 64 * 1024
+
+func getUserRules(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	userRules, err := subscription.GetUserStore().GetRules(request.Context(), principal.User.Id)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJson(responseWriter, userRules)
+}
+
+// Rules are posted as JSON, with the same fields as the configuration ones
+func createUserRule(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	userRule := new(dbuser.Rule)
+	err := json.NewDecoder(http.MaxBytesReader(responseWriter, request.Body, maxRuleSize)).Decode(userRule)
+	if err != nil {
+		appLog.DebugError(err, "Unable to read the rule")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	if userRule.Rule == nil {
+		web.AnswerError(errors.New("Missing rule definition"), http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	err = rules.Validate(userRule.Rule)
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	if userRule.FeedId != nil {
+		existing, err := subscription.GetUserStore().SubscriptionByFeed(request.Context(), principal.User.Id, *userRule.FeedId)
+		if err != nil {
+			appLog.DebugError(err, "An error occured when fetching values")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+		if existing == nil {
+			web.AnswerError(errors.New("Not subscribed to this feed"), http.StatusBadRequest, responseWriter)
+			return
+		}
+	}
+
+	err = subscription.GetUserStore().CreateRule(request.Context(), principal.User.Id, userRule)
+	if err != nil {
+		appLog.DebugError(err, "Unable to save the rule")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	web.MarshallWriteJsonWithStatus(responseWriter, http.StatusCreated, userRule)
+}
+
+func deleteUserRule(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		RuleId appDatabase.PrimaryKey `httpParameter:"ruleId"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	deleted, err := subscription.GetUserStore().DeleteRule(request.Context(), principal.User.Id, requestParameters.RuleId)
+	if err != nil {
+		appLog.DebugError(err, "Unable to delete the rule")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if !deleted {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
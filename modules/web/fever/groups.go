@@ -0,0 +1,107 @@
+package fever
+
+import (
+	"strconv"
+	"strings"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/subscription"
+)
+
+// Feeds have no icon of their own, they all share a blank one
+const (
+	defaultFaviconId   = 1
+	defaultFaviconData = "image/gif;base64,R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7"
+)
+
+// Folders are exposed as groups
+type group struct {
+	Id    appDatabase.PrimaryKey `json:"id"`
+	Title string                 `json:"title"`
+}
+
+type feedsGroup struct {
+	GroupId appDatabase.PrimaryKey `json:"group_id"`
+	FeedIds string                 `json:"feed_ids"`
+}
+
+type feed struct {
+	Id                appDatabase.PrimaryKey `json:"id"`
+	FaviconId         appDatabase.PrimaryKey `json:"favicon_id"`
+	Title             string                 `json:"title"`
+	Url               string                 `json:"url"`
+	SiteUrl           string                 `json:"site_url"`
+	IsSpark           int                    `json:"is_spark"`
+	LastUpdatedOnTime int64                  `json:"last_updated_on_time"`
+}
+
+type favicon struct {
+	Id   appDatabase.PrimaryKey `json:"id"`
+	Data string                 `json:"data"`
+}
+
+func groups(view *subscription.View) []*group {
+
+	groups := make([]*group, 0, len(view.Folders))
+	for _, folder := range view.Folders {
+		groups = append(groups, &group{Id: folder.Id, Title: folder.Name})
+	}
+	return groups
+}
+
+func feedsGroups(view *subscription.View) []*feedsGroup {
+
+	feedIds := make(map[appDatabase.PrimaryKey][]string)
+	for _, feed := range view.Feeds {
+		if folder := view.FeedFolders[feed.Id]; folder != nil {
+			feedIds[folder.Id] = append(feedIds[folder.Id], strconv.FormatUint(feed.Id, 10))
+		}
+	}
+
+	feedsGroups := make([]*feedsGroup, 0, len(feedIds))
+	for _, folder := range view.Folders {
+		if ids, ok := feedIds[folder.Id]; ok {
+			feedsGroups = append(feedsGroups, &feedsGroup{GroupId: folder.Id, FeedIds: strings.Join(ids, ",")})
+		}
+	}
+	return feedsGroups
+}
+
+func feeds(view *subscription.View) []*feed {
+
+	feeds := make([]*feed, 0, len(view.Feeds))
+	for _, subscribedFeed := range view.Feeds {
+
+		v := &feed{
+			Id:        subscribedFeed.Id,
+			FaviconId: defaultFaviconId,
+			Title:     subscribedFeed.Title,
+			Url:       subscribedFeed.FeedLink,
+			SiteUrl:   subscribedFeed.Link,
+		}
+		if v.Url == "" {
+			v.Url = subscribedFeed.Link
+		}
+		if subscribedFeed.LastUpdate != nil {
+			v.LastUpdatedOnTime = subscribedFeed.LastUpdate.Unix()
+		}
+
+		feeds = append(feeds, v)
+	}
+	return feeds
+}
+
+func favicons() []*favicon {
+	return []*favicon{{Id: defaultFaviconId, Data: defaultFaviconData}}
+}
+
+func lastRefresh(view *subscription.View) int64 {
+
+	var lastRefresh int64
+	for _, feed := range view.Feeds {
+		if feed.LastUpdate != nil && feed.LastUpdate.Unix() > lastRefresh {
+			lastRefresh = feed.LastUpdate.Unix()
+		}
+	}
+	return lastRefresh
+}
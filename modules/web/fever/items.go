@@ -0,0 +1,264 @@
+package fever
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	"github.com/dademo/rssreader/modules/imageproxy"
+	"github.com/dademo/rssreader/modules/subscription"
+)
+
+const maxItemCount = 50
+
+type item struct {
+	Id            appDatabase.PrimaryKey `json:"id"`
+	FeedId        appDatabase.PrimaryKey `json:"feed_id"`
+	Title         string                 `json:"title"`
+	Author        string                 `json:"author"`
+	Html          string                 `json:"html"`
+	Url           string                 `json:"url"`
+	IsSaved       int                    `json:"is_saved"`
+	IsRead        int                    `json:"is_read"`
+	CreatedOnTime int64                  `json:"created_on_time"`
+}
+
+// Change requested by the mark, as and id parameters
+type markAction struct {
+	target string
+	as     string
+	id     appDatabase.PrimaryKey
+	// Items of feeds and groups newer than this are left unread
+	before *time.Time
+}
+
+// Query of the items listed by with_ids, following since_id in ascending order or preceding max_id in descending order
+func itemsQuery(request *http.Request) (*dbuser.ItemQuery, error) {
+
+	query := &dbuser.ItemQuery{
+		Page: appDatabase.PageQuery{
+			Sort: []appDatabase.SortOption{appDatabase.NewSortOption(dbuser.ItemSortId, appDatabase.PageSortAsc)},
+		},
+	}
+
+	switch {
+	case request.Form.Get("with_ids") != "":
+		itemIds, err := parseIds(request.Form.Get("with_ids"))
+		if err != nil {
+			return nil, err
+		}
+		if len(itemIds) > maxItemCount {
+			itemIds = itemIds[:maxItemCount]
+		}
+		query.ItemIds = itemIds
+
+	case request.Form.Get("max_id") != "":
+		maxId, err := parseId(request.Form.Get("max_id"))
+		if err != nil {
+			return nil, err
+		}
+		if maxId == 0 {
+			query.ItemIds = []appDatabase.PrimaryKey{}
+		}
+		query.BeforeId = maxId
+		query.Page.Sort = []appDatabase.SortOption{appDatabase.NewSortOption(dbuser.ItemSortId, appDatabase.PageSortDesc)}
+
+	case request.Form.Get("since_id") != "":
+		sinceId, err := parseId(request.Form.Get("since_id"))
+		if err != nil {
+			return nil, err
+		}
+		query.AfterId = sinceId
+	}
+
+	return query, nil
+}
+
+func acceptAll(*subscription.Item) bool {
+	return true
+}
+
+func toFeverItems(items []*subscription.Item) []*item {
+
+	feedItems := make([]*dbfeed.FeedItem, 0, len(items))
+	for _, selected := range items {
+		feedItems = append(feedItems, selected.FeedItem)
+	}
+	imageproxy.RewriteItems(feedItems)
+
+	feverItems := make([]*item, 0, len(items))
+	for _, selected := range items {
+
+		v := &item{
+			Id:            selected.Id,
+			FeedId:        selected.Feed.Id,
+			Title:         selected.Title,
+			Html:          selected.Content,
+			Url:           selected.Link,
+			IsSaved:       flag(selected.Starred),
+			IsRead:        flag(selected.Read),
+			CreatedOnTime: selected.Timestamp.Unix(),
+		}
+		if v.Html == "" {
+			v.Html = selected.Description
+		}
+		if selected.Author != nil {
+			v.Author = selected.Author.Name
+		}
+
+		feverItems = append(feverItems, v)
+	}
+	return feverItems
+}
+
+func unreadItemIds(view *subscription.View) string {
+
+	itemIds := make([]string, 0)
+	for _, item := range view.Items {
+		if !item.Read {
+			itemIds = append(itemIds, strconv.FormatUint(item.Id, 10))
+		}
+	}
+	return strings.Join(itemIds, ",")
+}
+
+func savedItemIds(view *subscription.View) string {
+
+	itemIds := make([]string, 0)
+	for _, item := range view.Items {
+		if item.Starred {
+			itemIds = append(itemIds, strconv.FormatUint(item.Id, 10))
+		}
+	}
+	return strings.Join(itemIds, ",")
+}
+
+func parseMarkAction(request *http.Request) (*markAction, error) {
+
+	action := &markAction{
+		target: request.Form.Get("mark"),
+		as:     request.Form.Get("as"),
+	}
+
+	id, err := parseId(request.Form.Get("id"))
+	if err != nil {
+		return nil, err
+	}
+	action.id = id
+
+	switch action.target {
+	case "item":
+		switch action.as {
+		case "read", "unread", "saved", "unsaved":
+		default:
+			return nil, fmt.Errorf("Unsupported item mark [%s]", action.as)
+		}
+	case "feed", "group":
+		if action.as != "read" {
+			return nil, fmt.Errorf("Unsupported %s mark [%s]", action.target, action.as)
+		}
+		if value := request.Form.Get("before"); value != "" {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Bad timestamp [%s]", value)
+			}
+			before := time.Unix(seconds, 0)
+			action.before = &before
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported mark [%s]", action.target)
+	}
+
+	return action, nil
+}
+
+// Returns the name of the id list changed by the action
+func (action *markAction) apply(ctx context.Context, userId appDatabase.PrimaryKey) (string, error) {
+
+	if action.target == "item" {
+
+		visible, err := subscription.IsItemVisible(ctx, userId, action.id)
+		if err != nil || !visible {
+			// Unknown items are ignored, as they are by Fever
+			return "", err
+		}
+
+		value := action.as == "read" || action.as == "saved"
+		state := &dbuser.ItemState{}
+		changedList := "unread_item_ids"
+		if action.as == "read" || action.as == "unread" {
+			state.Read = &value
+		} else {
+			state.Starred = &value
+			changedList = "saved_item_ids"
+		}
+		return changedList, subscription.GetUserStore().SetItemState(ctx, userId, action.id, state)
+	}
+
+	view, err := subscription.LoadView(ctx, userId, true)
+	if err != nil {
+		return "", err
+	}
+
+	itemIds := make([]appDatabase.PrimaryKey, 0)
+	for _, item := range view.Items {
+		if item.Read || !action.matches(item) {
+			continue
+		}
+		if action.before != nil && item.Timestamp.After(*action.before) {
+			continue
+		}
+		itemIds = append(itemIds, item.Id)
+	}
+
+	read := true
+	return "unread_item_ids", subscription.GetUserStore().SetItemsState(ctx, userId, itemIds, &dbuser.ItemState{Read: &read})
+}
+
+// The group 0 holds every feed
+func (action *markAction) matches(item *subscription.Item) bool {
+
+	switch {
+	case action.target == "feed":
+		return item.Feed.Id == action.id
+	case action.id == 0:
+		return true
+	default:
+		return item.Folder != nil && item.Folder.Id == action.id
+	}
+}
+
+func parseIds(value string) ([]appDatabase.PrimaryKey, error) {
+
+	ids := make([]appDatabase.PrimaryKey, 0)
+	for _, part := range strings.Split(value, ",") {
+		id, err := parseId(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseId(value string) (appDatabase.PrimaryKey, error) {
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Bad id [%s]", value)
+	}
+	return id, nil
+}
+
+func flag(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package fever
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+// Clients are given http://<server>/fever/ as the API address
+const (
+	pathPrefix = "/fever"
+	apiVersion = 3
+
+	// Memory given to multipart forms, which only hold a few values
+	maxFormMemory = 1 << 20
+)
+
+var errUsersDisabled = errors.New("The Fever API is only available when authentication is enabled")
+
+// Every answer is a JSON object holding the requested members next to the common ones
+type response map[string]interface{}
+
+func init() {
+
+	methods := []string{http.MethodGet, http.MethodPost}
+
+	// Clients authenticate with their api_key parameter instead of the usual means
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: pathPrefix, Handler: handleApi, Methods: methods, Permission: auth.PermissionPublic},
+		web.RegisteredRoute{Pattern: pathPrefix + "/", Handler: handleApi, Methods: methods, Permission: auth.PermissionPublic},
+	)
+}
+
+func handleApi(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	if !auth.Enabled() {
+		web.AnswerError(errUsersDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	// The api_key is posted while the requested members are part of the query
+	if err := web.SecretInQuery(request, "api_key"); err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+	if err := parseForm(request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	answer := response{"api_version": apiVersion, "auth": 0}
+
+	principal, err := auth.FeverPrincipal(request.Context(), postedValue(request, "api_key"))
+	if err != nil {
+		appLog.DebugError(err, "Unable to authenticate the request")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	if principal == nil || !auth.HasPermission(principal.User, auth.PermissionFeedsRead) {
+		web.MarshallWriteJson(responseWriter, answer)
+		return
+	}
+	answer["auth"] = 1
+
+	ctx := request.Context()
+	userId := principal.User.Id
+
+	// Marking answers with the list it changed
+	changedList := ""
+	if hasParameter(request, "mark") {
+		action, err := parseMarkAction(request)
+		if err != nil {
+			web.AnswerError(err, http.StatusBadRequest, responseWriter)
+			return
+		}
+		changedList, err = action.apply(ctx, userId)
+		if err != nil {
+			appLog.DebugError(err, "Unable to save the item state")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+	}
+	wanted := func(name string) bool {
+		return hasParameter(request, name) || name == changedList
+	}
+
+	// Item lists are read here, the items themselves being loaded by pages
+	withItems := wanted("unread_item_ids") || wanted("saved_item_ids")
+
+	// Loaded after the marks so that the answer reflects them
+	view, err := subscription.LoadView(ctx, userId, withItems)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	answer["last_refreshed_on_time"] = lastRefresh(view)
+
+	if wanted("groups") {
+		answer["groups"] = groups(view)
+		answer["feeds_groups"] = feedsGroups(view)
+	}
+	if wanted("feeds") {
+		answer["feeds"] = feeds(view)
+		answer["feeds_groups"] = feedsGroups(view)
+	}
+	if wanted("favicons") {
+		answer["favicons"] = favicons()
+	}
+	if wanted("links") {
+		// Hot links are not computed
+		answer["links"] = []interface{}{}
+	}
+	if wanted("unread_item_ids") {
+		answer["unread_item_ids"] = unreadItemIds(view)
+	}
+	if wanted("saved_item_ids") {
+		answer["saved_item_ids"] = savedItemIds(view)
+	}
+	if wanted("items") {
+		query, err := itemsQuery(request)
+		if err != nil {
+			web.AnswerError(err, http.StatusBadRequest, responseWriter)
+			return
+		}
+
+		total, err := subscription.GetUserStore().CountItems(ctx, userId, &dbuser.ItemQuery{})
+		if err != nil {
+			appLog.DebugError(err, "An error occured when fetching values")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+
+		_, err = view.LoadItems(ctx, userId, query, maxItemCount, acceptAll)
+		if err != nil {
+			appLog.DebugError(err, "An error occured when fetching values")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+		answer["total_items"] = total
+		answer["items"] = toFeverItems(view.Items)
+	}
+
+	web.MarshallWriteJson(responseWriter, answer)
+}
+
+// Clients post URL encoded or multipart forms
+func parseForm(request *http.Request) error {
+
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return request.ParseMultipartForm(maxFormMemory)
+	}
+	return request.ParseForm()
+}
+
+// Read from the request body only, whatever its encoding
+func postedValue(request *http.Request, name string) string {
+
+	if request.MultipartForm != nil {
+		if values := request.MultipartForm.Value[name]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return request.PostForm.Get(name)
+}
+
+// Members are requested by flags without any value, as in ?api&items
+func hasParameter(request *http.Request, name string) bool {
+	_, ok := request.Form[name]
+	return ok
+}
@@ -0,0 +1,94 @@
+package greader
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dademo/rssreader/modules/auth"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+type userInfo struct {
+	UserId        string `json:"userId"`
+	UserName      string `json:"userName"`
+	UserProfileId string `json:"userProfileId"`
+	UserEmail     string `json:"userEmail"`
+}
+
+// Opens a session, its secret being sent back by clients in a GoogleLogin authorization header
+func clientLogin(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	if !auth.Enabled() {
+		web.AnswerError(errUsersDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	if err := web.SecretInQuery(request, "Email", "Passwd"); err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	if err := request.ParseForm(); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	_, _, secret, err := auth.Login(request.Context(), request.PostForm.Get("Email"), request.PostForm.Get("Passwd"))
+	if err == auth.ErrInvalidCredentials {
+		responseWriter.WriteHeader(http.StatusUnauthorized)
+		writeText(responseWriter, "Error=BadAuthentication\n")
+		return
+	}
+	if err != nil {
+		appLog.DebugError(err, "Unable to open a session")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	writeText(responseWriter, fmt.Sprintf("SID=%s\nLSID=%s\nAuth=%s\n", secret, secret, secret))
+}
+
+// Clients send this token back with their edits, which are not checked against it:
+// edits are only accepted with an authorization header, which other sites can not make browsers send
+func getToken(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	if _, ok := requirePrincipal(responseWriter, request); !ok {
+		return
+	}
+
+	buffer := make([]byte, 16)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		appLog.DebugError(err, "Unable to generate a token")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+	writeText(responseWriter, hex.EncodeToString(buffer))
+}
+
+func getUserInfo(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	userId := strconv.FormatUint(principal.User.Id, 10)
+	web.MarshallWriteJson(responseWriter, userInfo{
+		UserId:        userId,
+		UserName:      principal.User.Username,
+		UserProfileId: userId,
+		UserEmail:     principal.User.Username,
+	})
+}
@@ -0,0 +1,85 @@
+package greader
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dademo/rssreader/modules/auth"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+// Clients are given http://<server>/api/greader as the API address
+const (
+	pathPrefix = web.AppApiPrefix + "/greader"
+	apiPrefix  = pathPrefix + "/reader/api/0"
+)
+
+var (
+	errUsersDisabled = errors.New("The Google Reader API is only available when authentication is enabled")
+	errCookieEdit    = errors.New("Edits need the GoogleLogin or Bearer authorization header")
+)
+
+func init() {
+
+	readMethods := []string{http.MethodGet, http.MethodPost}
+	editMethods := []string{http.MethodPost}
+
+	web.RegisterRoutes(
+		// Credentials are only read from the posted form
+		web.RegisteredRoute{Pattern: pathPrefix + "/accounts/ClientLogin", Handler: clientLogin, Methods: editMethods, Permission: auth.PermissionPublic},
+		web.RegisteredRoute{Pattern: apiPrefix + "/token", Handler: getToken, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/user-info", Handler: getUserInfo, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/subscription/list", Handler: getSubscriptionList, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/tag/list", Handler: getTagList, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/unread-count", Handler: getUnreadCount, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/stream/items/ids", Handler: getStreamItemIds, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/stream/items/contents", Handler: getStreamItemContents, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/stream/contents", Handler: getStreamContents, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/stream/contents/{streamId:.+}", Handler: getStreamContents, Methods: readMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/edit-tag", Handler: editTag, Methods: editMethods, Permission: auth.PermissionFeedsRead},
+		web.RegisteredRoute{Pattern: apiPrefix + "/mark-all-as-read", Handler: markAllAsRead, Methods: editMethods, Permission: auth.PermissionFeedsRead},
+	)
+}
+
+// Users only exist when authentication is enabled
+func requirePrincipal(responseWriter http.ResponseWriter, request *http.Request) (*auth.Principal, bool) {
+
+	principal := auth.PrincipalFromContext(request.Context())
+	if principal == nil {
+		web.AnswerError(errUsersDisabled, http.StatusNotFound, responseWriter)
+		return nil, false
+	}
+
+	// Multi-valued parameters are read from the form
+	if err := request.ParseForm(); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return nil, false
+	}
+	return principal, true
+}
+
+// Browsers send the session cookie along with cross-site requests, edits are only accepted with an authorization header
+func requireEditPrincipal(responseWriter http.ResponseWriter, request *http.Request) (*auth.Principal, bool) {
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return nil, false
+	}
+
+	if request.Header.Get("Authorization") == "" {
+		web.AnswerError(errCookieEdit, http.StatusForbidden, responseWriter)
+		return nil, false
+	}
+	return principal, true
+}
+
+func writeText(responseWriter http.ResponseWriter, text string) {
+
+	responseWriter.Header().Set("Content-Type", web.TextPlainUTF8)
+	_, err := responseWriter.Write([]byte(text))
+	if err != nil {
+		appLog.DebugError(err, "Unable to write answer")
+	}
+}
@@ -0,0 +1,519 @@
+package greader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	"github.com/dademo/rssreader/modules/imageproxy"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	streamReadingList = "user/-/state/com.google/reading-list"
+	streamStarred     = "user/-/state/com.google/starred"
+	streamRead        = "user/-/state/com.google/read"
+	streamKeptUnread  = "user/-/state/com.google/kept-unread"
+	feedStreamPrefix  = "feed/"
+	labelStreamPrefix = "user/-/label/"
+
+	itemIdPrefix = "tag:google.com,2005:reader/item/"
+
+	defaultItemCount = 20
+	maxItemCount     = 10000
+)
+
+type link struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type enclosure struct {
+	Href   string `json:"href"`
+	Type   string `json:"type,omitempty"`
+	Length string `json:"length,omitempty"`
+}
+
+type origin struct {
+	StreamId string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl"`
+}
+
+type content struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type streamItem struct {
+	Id            string      `json:"id"`
+	CrawlTimeMsec string      `json:"crawlTimeMsec"`
+	TimestampUsec string      `json:"timestampUsec"`
+	Published     int64       `json:"published"`
+	Updated       int64       `json:"updated"`
+	Title         string      `json:"title"`
+	Author        string      `json:"author,omitempty"`
+	Canonical     []link      `json:"canonical"`
+	Alternate     []link      `json:"alternate"`
+	Enclosure     []enclosure `json:"enclosure,omitempty"`
+	Categories    []string    `json:"categories"`
+	Origin        origin      `json:"origin"`
+	Summary       content     `json:"summary"`
+}
+
+type streamContents struct {
+	Id           string        `json:"id"`
+	Updated      int64         `json:"updated"`
+	Items        []*streamItem `json:"items"`
+	Continuation string        `json:"continuation,omitempty"`
+}
+
+type itemRef struct {
+	Id              string   `json:"id"`
+	DirectStreamIds []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+type streamItemIds struct {
+	ItemRefs     []*itemRef `json:"itemRefs"`
+	Continuation string     `json:"continuation,omitempty"`
+}
+
+func getStreamContents(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	state, err := subscription.LoadView(request.Context(), principal.User.Id, false)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	streamId := requestStreamId(request)
+	query, count, err := streamQuery(state, streamId, request, 1000)
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	items, continuation, err := selectItems(request.Context(), state, principal.User.Id, streamId, request, query, count)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	web.MarshallWriteJson(responseWriter, streamContents{
+		Id:           streamId,
+		Updated:      time.Now().Unix(),
+		Items:        toStreamItems(items),
+		Continuation: continuation,
+	})
+}
+
+func getStreamItemIds(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	state, err := subscription.LoadView(request.Context(), principal.User.Id, false)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	streamId := requestStreamId(request)
+	query, count, err := streamQuery(state, streamId, request, maxItemCount)
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	items, continuation, err := selectItems(request.Context(), state, principal.User.Id, streamId, request, query, count)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	refs := make([]*itemRef, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, &itemRef{
+			Id:              strconv.FormatUint(item.Id, 10),
+			DirectStreamIds: []string{feedStreamId(item.Feed)},
+			TimestampUsec:   strconv.FormatInt(item.Timestamp.UnixNano()/int64(time.Microsecond), 10),
+		})
+	}
+
+	web.MarshallWriteJson(responseWriter, streamItemIds{
+		ItemRefs:     refs,
+		Continuation: continuation,
+	})
+}
+
+// Items listed by the i parameters, in the requested order
+func getStreamItemContents(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	itemIds, err := parseItemIds(request.Form["i"])
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	if len(itemIds) > maxItemCount {
+		itemIds = itemIds[:maxItemCount]
+	}
+
+	state, err := subscription.LoadView(request.Context(), principal.User.Id, false)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	_, err = state.LoadItems(request.Context(), principal.User.Id, &dbuser.ItemQuery{ItemIds: itemIds}, len(itemIds), acceptAll)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	byId := make(map[appDatabase.PrimaryKey]*subscription.Item, len(state.Items))
+	for _, item := range state.Items {
+		byId[item.Id] = item
+	}
+
+	items := make([]*subscription.Item, 0, len(itemIds))
+	for _, itemId := range itemIds {
+		if item, ok := byId[itemId]; ok {
+			items = append(items, item)
+		}
+	}
+
+	web.MarshallWriteJson(responseWriter, streamContents{
+		Id:      streamReadingList,
+		Updated: time.Now().Unix(),
+		Items:   toStreamItems(items),
+	})
+}
+
+func acceptAll(*subscription.Item) bool {
+	return true
+}
+
+// Query of the stream, inclusion, exclusion, time and pagination parameters, the continuation being an offset in the stream
+func streamQuery(state *subscription.View, streamId string, request *http.Request, maxCount int) (*dbuser.ItemQuery, int, error) {
+
+	count := defaultItemCount
+	if value := request.Form.Get("n"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return nil, 0, fmt.Errorf("Bad item count [%s]", value)
+		}
+		count = parsed
+	}
+	if count > maxCount {
+		count = maxCount
+	}
+
+	var offset uint
+	if value := request.Form.Get("c"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Bad continuation [%s]", value)
+		}
+		offset = uint(parsed)
+	}
+
+	newerThan, err := parseUnixTime(request.Form.Get("ot"))
+	if err != nil {
+		return nil, 0, err
+	}
+	olderThan, err := parseUnixTime(request.Form.Get("nt"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortOrder := appDatabase.PageSortDesc
+	if request.Form.Get("r") == "o" {
+		sortOrder = appDatabase.PageSortAsc
+	}
+
+	query := &dbuser.ItemQuery{
+		FeedIds:   streamFeedIds(state, streamId),
+		NewerThan: newerThan,
+		OlderThan: olderThan,
+		Page: appDatabase.PageQuery{
+			Offset: offset,
+			Sort: []appDatabase.SortOption{
+				appDatabase.NewSortOption(dbuser.ItemSortTimestamp, sortOrder),
+				appDatabase.NewSortOption(dbuser.ItemSortId, sortOrder),
+			},
+		},
+	}
+
+	// Flags set by the user are filtered in the database, rules of the user being only applied to loaded items
+	excluded := append([]string{}, request.Form["xt"]...)
+	if normalizeStreamId(streamId) == streamKeptUnread {
+		excluded = append(excluded, streamRead)
+	}
+	for _, excludedStreamId := range excluded {
+		switch normalizeStreamId(excludedStreamId) {
+		case streamRead:
+			query.Unread = true
+		case streamStarred:
+			query.Unstarred = true
+		}
+	}
+
+	return query, count, nil
+}
+
+// Items of the query matching the stream, inclusion and exclusion parameters, once user rules are applied
+func selectItems(ctx context.Context, state *subscription.View, userId appDatabase.PrimaryKey, streamId string, request *http.Request, query *dbuser.ItemQuery, count int) ([]*subscription.Item, string, error) {
+
+	next, err := state.LoadItems(ctx, userId, query, count, func(item *subscription.Item) bool {
+		return matches(item, streamId) && matchesAll(item, request.Form["it"]) && !matchesAny(item, request.Form["xt"])
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	continuation := ""
+	if next > 0 {
+		continuation = strconv.FormatUint(uint64(next), 10)
+	}
+	return state.Items, continuation, nil
+}
+
+// Feeds of the feed and label streams, nil for the other streams which span every subscription
+func streamFeedIds(state *subscription.View, streamId string) []appDatabase.PrimaryKey {
+
+	streamId = normalizeStreamId(streamId)
+
+	switch {
+	case strings.HasPrefix(streamId, feedStreamPrefix):
+		feedId, err := strconv.ParseUint(strings.TrimPrefix(streamId, feedStreamPrefix), 10, 64)
+		if err != nil {
+			return []appDatabase.PrimaryKey{}
+		}
+		return []appDatabase.PrimaryKey{feedId}
+	case strings.HasPrefix(streamId, labelStreamPrefix):
+		feedIds := make([]appDatabase.PrimaryKey, 0)
+		for feedId, folder := range state.FeedFolders {
+			if folder != nil && folder.Name == strings.TrimPrefix(streamId, labelStreamPrefix) {
+				feedIds = append(feedIds, feedId)
+			}
+		}
+		return feedIds
+	default:
+		return nil
+	}
+}
+
+func matches(item *subscription.Item, streamId string) bool {
+
+	streamId = normalizeStreamId(streamId)
+
+	switch {
+	case streamId == streamReadingList:
+		return true
+	case streamId == streamStarred:
+		return item.Starred
+	case streamId == streamRead:
+		return item.Read
+	case streamId == streamKeptUnread:
+		return !item.Read
+	case strings.HasPrefix(streamId, feedStreamPrefix):
+		return streamId == feedStreamId(item.Feed)
+	case strings.HasPrefix(streamId, labelStreamPrefix):
+		return item.Folder != nil && item.Folder.Name == strings.TrimPrefix(streamId, labelStreamPrefix)
+	default:
+		return false
+	}
+}
+
+func matchesAll(item *subscription.Item, streamIds []string) bool {
+	for _, streamId := range streamIds {
+		if !matches(item, streamId) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(item *subscription.Item, streamIds []string) bool {
+	for _, streamId := range streamIds {
+		if matches(item, streamId) {
+			return true
+		}
+	}
+	return false
+}
+
+func toStreamItems(items []*subscription.Item) []*streamItem {
+
+	feedItems := make([]*dbfeed.FeedItem, 0, len(items))
+	for _, item := range items {
+		feedItems = append(feedItems, item.FeedItem)
+	}
+	imageproxy.RewriteItems(feedItems)
+
+	streamItems := make([]*streamItem, 0, len(items))
+	for _, item := range items {
+
+		categories := []string{streamReadingList}
+		if item.Folder != nil {
+			categories = append(categories, labelStreamPrefix+item.Folder.Name)
+		}
+		if item.Read {
+			categories = append(categories, streamRead)
+		}
+		if item.Starred {
+			categories = append(categories, streamStarred)
+		}
+
+		summary := item.Content
+		if summary == "" {
+			summary = item.Description
+		}
+
+		published := item.Timestamp.Unix()
+		updated := published
+		if item.Updated != nil {
+			updated = item.Updated.Unix()
+		}
+
+		v := &streamItem{
+			Id:            fmt.Sprintf("%s%016x", itemIdPrefix, item.Id),
+			CrawlTimeMsec: strconv.FormatInt(item.Timestamp.UnixNano()/int64(time.Millisecond), 10),
+			TimestampUsec: strconv.FormatInt(item.Timestamp.UnixNano()/int64(time.Microsecond), 10),
+			Published:     published,
+			Updated:       updated,
+			Title:         item.Title,
+			Canonical:     []link{{Href: item.Link}},
+			Alternate:     []link{{Href: item.Link, Type: "text/html"}},
+			Categories:    categories,
+			Origin: origin{
+				StreamId: feedStreamId(item.Feed),
+				Title:    item.Feed.Title,
+				HtmlUrl:  item.Feed.Link,
+			},
+			Summary: content{Direction: "ltr", Content: summary},
+		}
+
+		if item.Author != nil {
+			v.Author = item.Author.Name
+		}
+
+		for _, itemEnclosure := range item.Enclosures {
+			v.Enclosure = append(v.Enclosure, enclosure{
+				Href:   itemEnclosure.URL,
+				Type:   itemEnclosure.Type,
+				Length: itemEnclosure.Length,
+			})
+		}
+
+		streamItems = append(streamItems, v)
+	}
+	return streamItems
+}
+
+// The stream is either part of the path or given as the s parameter
+func requestStreamId(request *http.Request) string {
+
+	if streamId := mux.Vars(request)["streamId"]; streamId != "" {
+		return streamId
+	}
+	if streamId := request.Form.Get("s"); streamId != "" {
+		return streamId
+	}
+	return streamReadingList
+}
+
+// Clients may use their user id instead of the - placeholder
+func normalizeStreamId(streamId string) string {
+
+	if strings.HasPrefix(streamId, "user/") {
+		parts := strings.SplitN(streamId, "/", 3)
+		if len(parts) == 3 {
+			return "user/-/" + parts[2]
+		}
+	}
+	return streamId
+}
+
+func feedStreamId(feed *dbfeed.Feed) string {
+	return feedStreamPrefix + strconv.FormatUint(feed.Id, 10)
+}
+
+// Items are accepted in their long hexadecimal form or as signed or unsigned decimal numbers
+func parseItemIds(values []string) ([]appDatabase.PrimaryKey, error) {
+
+	itemIds := make([]appDatabase.PrimaryKey, 0, len(values))
+	for _, value := range values {
+
+		if strings.HasPrefix(value, itemIdPrefix) {
+			itemId, err := strconv.ParseUint(strings.TrimPrefix(value, itemIdPrefix), 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Bad item id [%s]", value)
+			}
+			itemIds = append(itemIds, itemId)
+			continue
+		}
+
+		if itemId, err := strconv.ParseUint(value, 10, 64); err == nil {
+			itemIds = append(itemIds, itemId)
+			continue
+		}
+
+		itemId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad item id [%s]", value)
+		}
+		itemIds = append(itemIds, appDatabase.PrimaryKey(itemId))
+	}
+	return itemIds, nil
+}
+
+func parseUnixTime(value string) (*time.Time, error) {
+
+	if value == "" {
+		return nil, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Bad timestamp [%s]", value)
+	}
+	parsed := time.Unix(seconds, 0)
+	return &parsed, nil
+}
@@ -0,0 +1,131 @@
+package greader
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+type subscriptionCategory struct {
+	Id    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type subscriptionEntry struct {
+	Id         string                  `json:"id"`
+	Title      string                  `json:"title"`
+	Categories []*subscriptionCategory `json:"categories"`
+	Url        string                  `json:"url"`
+	HtmlUrl    string                  `json:"htmlUrl"`
+	IconUrl    string                  `json:"iconUrl"`
+}
+
+type subscriptionList struct {
+	Subscriptions []*subscriptionEntry `json:"subscriptions"`
+}
+
+type unreadCount struct {
+	Id                      string `json:"id"`
+	Count                   int    `json:"count"`
+	NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+}
+
+type unreadCounts struct {
+	Max          int            `json:"max"`
+	UnreadCounts []*unreadCount `json:"unreadcounts"`
+}
+
+func getSubscriptionList(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	state, err := subscription.LoadView(request.Context(), principal.User.Id, false)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	entries := make([]*subscriptionEntry, 0, len(state.Feeds))
+	for _, feed := range state.Feeds {
+
+		entry := &subscriptionEntry{
+			Id:         feedStreamId(feed),
+			Title:      feed.Title,
+			Categories: []*subscriptionCategory{},
+			Url:        feed.FeedLink,
+			HtmlUrl:    feed.Link,
+		}
+		if entry.Url == "" {
+			entry.Url = feed.Link
+		}
+		if folder := state.FeedFolders[feed.Id]; folder != nil {
+			entry.Categories = append(entry.Categories, &subscriptionCategory{Id: labelStreamPrefix + folder.Name, Label: folder.Name})
+		}
+
+		entries = append(entries, entry)
+	}
+
+	web.MarshallWriteJson(responseWriter, subscriptionList{Subscriptions: entries})
+}
+
+// Unread items by feed, by label and in total
+func getUnreadCount(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	state, err := subscription.LoadView(request.Context(), principal.User.Id, true)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	counts := make([]*unreadCount, 0)
+	byStream := make(map[string]*unreadCount)
+	newest := make(map[string]time.Time)
+
+	count := func(streamId string, item *subscription.Item) {
+		v, ok := byStream[streamId]
+		if !ok {
+			v = &unreadCount{Id: streamId}
+			byStream[streamId] = v
+			counts = append(counts, v)
+		}
+		v.Count++
+		if item.Timestamp.After(newest[streamId]) {
+			newest[streamId] = item.Timestamp
+		}
+	}
+
+	for _, item := range state.Items {
+		if item.Read {
+			continue
+		}
+		count(feedStreamId(item.Feed), item)
+		if item.Folder != nil {
+			count(labelStreamPrefix+item.Folder.Name, item)
+		}
+		count(streamReadingList, item)
+	}
+
+	for _, v := range counts {
+		v.NewestItemTimestampUsec = strconv.FormatInt(newest[v.Id].UnixNano()/int64(time.Microsecond), 10)
+	}
+
+	web.MarshallWriteJson(responseWriter, unreadCounts{Max: maxItemCount, UnreadCounts: counts})
+}
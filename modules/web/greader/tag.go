@@ -0,0 +1,163 @@
+package greader
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbuser"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+type tag struct {
+	Id   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type tagList struct {
+	Tags []*tag `json:"tags"`
+}
+
+// Folders are exposed as labels
+func getTagList(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requirePrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	folders, err := subscription.GetUserStore().GetFolders(request.Context(), principal.User.Id)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	tags := []*tag{{Id: streamStarred}}
+	for _, folder := range folders {
+		tags = append(tags, &tag{Id: labelStreamPrefix + folder.Name, Type: "folder"})
+	}
+
+	web.MarshallWriteJson(responseWriter, tagList{Tags: tags})
+}
+
+// Only the read and starred states are stored, other tags are ignored
+func editTag(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requireEditPrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	itemIds, err := parseItemIds(request.Form["i"])
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	state := new(dbuser.ItemState)
+	for _, added := range request.Form["a"] {
+		applyTag(state, normalizeStreamId(added), true)
+	}
+	for _, removed := range request.Form["r"] {
+		applyTag(state, normalizeStreamId(removed), false)
+	}
+
+	for _, itemId := range itemIds {
+		visible, err := subscription.IsItemVisible(request.Context(), principal.User.Id, itemId)
+		if err != nil {
+			appLog.DebugError(err, "An error occured when fetching values")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+		if !visible {
+			web.AnswerError(fmt.Errorf("Item (%d) not found", itemId), http.StatusNotFound, responseWriter)
+			return
+		}
+	}
+
+	if state.Read != nil || state.Starred != nil {
+		err = subscription.GetUserStore().SetItemsState(request.Context(), principal.User.Id, itemIds, state)
+		if err != nil {
+			appLog.DebugError(err, "Unable to save the item state")
+			web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+			return
+		}
+	}
+
+	writeText(responseWriter, "OK")
+}
+
+// Marks the items of a stream as read, up to the ts timestamp in microseconds when given
+func markAllAsRead(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	principal, ok := requireEditPrincipal(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	var olderThan *time.Time
+	if value := request.Form.Get("ts"); value != "" {
+		microseconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			web.AnswerError(fmt.Errorf("Bad timestamp [%s]", value), http.StatusBadRequest, responseWriter)
+			return
+		}
+		parsed := time.Unix(0, microseconds*int64(time.Microsecond))
+		olderThan = &parsed
+	}
+
+	state, err := subscription.LoadView(request.Context(), principal.User.Id, true)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching values")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	streamId := requestStreamId(request)
+	itemIds := make([]appDatabase.PrimaryKey, 0)
+	for _, item := range state.Items {
+		if item.Read || !matches(item, streamId) {
+			continue
+		}
+		if olderThan != nil && item.Timestamp.After(*olderThan) {
+			continue
+		}
+		itemIds = append(itemIds, item.Id)
+	}
+
+	read := true
+	err = subscription.GetUserStore().SetItemsState(request.Context(), principal.User.Id, itemIds, &dbuser.ItemState{Read: &read})
+	if err != nil {
+		appLog.DebugError(err, "Unable to save the item state")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	writeText(responseWriter, "OK")
+}
+
+func applyTag(state *dbuser.ItemState, streamId string, added bool) {
+
+	value := added
+	switch streamId {
+	case streamRead:
+		state.Read = &value
+	case streamKeptUnread:
+		// Keeping an item unread is the opposite of reading it
+		unread := !added
+		state.Read = &unread
+	case streamStarred:
+		state.Starred = &value
+	}
+}
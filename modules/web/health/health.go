@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dademo/rssreader/modules/database"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/log/hook"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	checkTimeout = 5 * time.Second
+)
+
+// Errors are only logged, the endpoint being public
+type ComponentStatus struct {
+	Status string `json:"status"`
+}
+
+type Report struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentStatus `json:"components,omitempty"`
+}
+
+type check = func(ctx context.Context) error
+
+func getHealth(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+	web.MarshallWriteJson(responseWriter, Report{Status: StatusUp})
+}
+
+func getReadiness(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	ctx, cancel := context.WithTimeout(request.Context(), checkTimeout)
+	defer cancel()
+
+	report := runChecks(ctx, readinessChecks())
+
+	statusCode := http.StatusOK
+	if report.Status != StatusUp {
+		statusCode = http.StatusServiceUnavailable
+	}
+	web.MarshallWriteJsonWithStatus(responseWriter, statusCode, report)
+}
+
+func readinessChecks() map[string]check {
+
+	checks := map[string]check{
+		"scheduler": checkScheduler,
+	}
+
+	// The in-memory store has no database
+	if database.GetDatabase() != nil {
+		checks["database"] = database.Ping
+		checks["schema"] = database.CheckModuleVersions
+	}
+
+	for _, backendName := range hook.GetEnabledBackends() {
+		backendName := backendName
+		checks["log."+backendName] = func(ctx context.Context) error {
+			return hook.PingBackend(ctx, backendName)
+		}
+	}
+
+	return checks
+}
+
+func checkScheduler(ctx context.Context) error {
+	if jobScheduler == nil || !jobScheduler.Running() {
+		return errors.New("The scheduler is not running")
+	}
+	return nil
+}
+
+// Checks run concurrently, a slow component not delaying the others
+func runChecks(ctx context.Context, checks map[string]check) *Report {
+
+	report := &Report{
+		Status:     StatusUp,
+		Components: make(map[string]*ComponentStatus, len(checks)),
+	}
+
+	var lock sync.Mutex
+	var waitGroup sync.WaitGroup
+
+	for name, componentCheck := range checks {
+		waitGroup.Add(1)
+		go func(name string, componentCheck check) {
+			defer waitGroup.Done()
+
+			status := &ComponentStatus{Status: StatusUp}
+			if err := componentCheck(ctx); err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Readiness check [%s] failed", name))
+				status.Status = StatusDown
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			report.Components[name] = status
+			if status.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, componentCheck)
+	}
+
+	waitGroup.Wait()
+	return report
+}
@@ -0,0 +1,20 @@
+package health
+
+import (
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/scheduler"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+var jobScheduler *scheduler.Scheduler
+
+func Configure(scheduler *scheduler.Scheduler) {
+	jobScheduler = scheduler
+}
+
+func init() {
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: "/healthz", Handler: getHealth, Permission: auth.PermissionPublic},
+		web.RegisteredRoute{Pattern: "/readyz", Handler: getReadiness, Permission: auth.PermissionPublic},
+	)
+}
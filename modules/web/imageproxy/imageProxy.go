@@ -0,0 +1,56 @@
+package imageproxy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dademo/rssreader/modules/imageproxy"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+const cacheMaxAgeSeconds = 7 * 24 * 3600
+
+func getImage(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		URL       string `httpParameter:"url"`
+		Signature string `httpParameter:"sig"`
+	}
+
+	if !imageproxy.IsEnabled() {
+		web.AnswerError(errors.New("The image proxy is disabled"), http.StatusNotFound, responseWriter)
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	if err := imageproxy.VerifySignature(requestParameters.URL, requestParameters.Signature); err != nil {
+		appLog.DebugError(err, "Bad image proxy signature")
+		web.AnswerError(err, http.StatusForbidden, responseWriter)
+		return
+	}
+
+	image, err := imageproxy.Get(request.Context(), requestParameters.URL)
+	if err != nil {
+		appLog.DebugError(err, "An error occured when fetching an image")
+		web.AnswerError(err, http.StatusBadGateway, responseWriter)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", image.ContentType)
+	responseWriter.Header().Set("Content-Length", strconv.Itoa(len(image.Content)))
+	responseWriter.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(cacheMaxAgeSeconds))
+	responseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+	responseWriter.Header().Set("Content-Security-Policy", "default-src 'none'")
+
+	_, err = responseWriter.Write(image.Content)
+	if err != nil {
+		appLog.DebugError(err, "Unable to write answer")
+	}
+}
@@ -0,0 +1,14 @@
+package imageproxy
+
+import (
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/imageproxy"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+// Proxied URLs are signed, readers loading them without credentials
+func init() {
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: imageproxy.ProxyPath, Handler: getImage, Permission: auth.PermissionPublic},
+	)
+}
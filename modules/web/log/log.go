@@ -78,7 +78,7 @@ func getLogs(responseWriter http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	logPage, err := backend.QueryForLogs(query)
+	logPage, err := backend.QueryForLogs(request.Context(), query)
 	if err != nil {
 		appLog.DebugError(err, "An error occured when querying for values")
 		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
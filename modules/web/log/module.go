@@ -1,12 +1,13 @@
 package log
 
 import (
+	"github.com/dademo/rssreader/modules/auth"
 	"github.com/dademo/rssreader/modules/web"
 )
 
 func init() {
 	web.RegisterRoutes(
-		web.RegisteredRoute{Pattern: "/api/log/backends", Handler: getLogBackends},
-		web.RegisteredRoute{Pattern: "/api/log", Handler: getLogs},
+		web.RegisteredRoute{Pattern: "/api/log/backends", Handler: getLogBackends, Permission: auth.PermissionLogsRead},
+		web.RegisteredRoute{Pattern: "/api/log", Handler: getLogs, Permission: auth.PermissionLogsRead},
 	)
 }
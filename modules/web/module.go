@@ -12,13 +12,19 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/dademo/rssreader/modules/auth"
 	"github.com/dademo/rssreader/modules/config"
 	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/metrics"
 )
 
 type RegisteredRoute struct {
 	Pattern string
 	Handler func(http.ResponseWriter, *http.Request)
+	// Any method when empty
+	Methods []string
+	// Any authenticated user when empty
+	Permission auth.Permission
 }
 
 var (
@@ -28,6 +34,7 @@ var (
 
 const (
 	AppApiPrefix                     = "/api"
+	MetricsPath                      = "/metrics"
 	HTTPParameterTagName             = "httpParameter"
 	HTTPParameterDefaultValueTagName = "httpParameterDefaultValue"
 	JSONContentTypeUtf8              = "application/json; charset=utf-8"
@@ -70,16 +77,28 @@ func RegisterServerHandlers(serveMux *http.ServeMux, httpConfig *config.HttpConf
 
 	router := mux.NewRouter()
 	for _, registeredRoute := range registeredRoutes {
-		router.HandleFunc(registeredRoute.Pattern, registeredRoute.Handler)
+		route := router.Handle(registeredRoute.Pattern, RequirePermission(registeredRoute.Permission, http.HandlerFunc(registeredRoute.Handler)))
+		if len(registeredRoute.Methods) > 0 {
+			route.Methods(registeredRoute.Methods...)
+		}
 	}
 
+	// Static files are public, the login page being one of them
 	router.PathPrefix("/").Handler(http.FileServer(dotFileHidingFileSystem{http.Dir(fileServerDir)}))
+	router.Use(routeTemplateMiddleware)
 	serveMux.Handle("/", HttpLogInterceptorFor(router))
 
+	// Not logged, scrapes would flood the logs
+	serveMux.Handle(MetricsPath, RequirePermission(auth.PermissionMetricsRead, metrics.Handler()))
+
 	return nil
 }
 
 func MarshallWriteJson(responseWriter http.ResponseWriter, value interface{}) {
+	MarshallWriteJsonWithStatus(responseWriter, http.StatusOK, value)
+}
+
+func MarshallWriteJsonWithStatus(responseWriter http.ResponseWriter, statusCode int, value interface{}) {
 
 	marshalledValue, err := json.Marshal(value)
 	if err != nil {
@@ -89,6 +108,7 @@ func MarshallWriteJson(responseWriter http.ResponseWriter, value interface{}) {
 	}
 
 	responseWriter.Header().Add("Content-Type", JSONContentTypeUtf8)
+	responseWriter.WriteHeader(statusCode)
 
 	_, err = responseWriter.Write(marshalledValue)
 	if err != nil {
@@ -98,6 +118,18 @@ func MarshallWriteJson(responseWriter http.ResponseWriter, value interface{}) {
 	}
 }
 
+// Secrets are only read from request bodies, query strings ending up in logs and browser histories
+func SecretInQuery(request *http.Request, names ...string) error {
+
+	query := request.URL.Query()
+	for _, name := range names {
+		if _, ok := query[name]; ok {
+			return fmt.Errorf("The [%s] parameter must be sent in the request body", name)
+		}
+	}
+	return nil
+}
+
 func RegisterRoutes(routes ...RegisteredRoute) {
 	registeredRoutes = append(registeredRoutes, routes...)
 }
@@ -3,11 +3,15 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/metrics"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,6 +19,34 @@ type LogHandler struct {
 	wrappedHandler http.Handler
 }
 
+// Keeps the answered status and the matched route template
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	route      string
+}
+
+// Query parameters holding credentials, compared in lower case, whose values are not logged
+var secretParameters = map[string]bool{
+	"access_token": true,
+	"api_key":      true,
+	"auth":         true,
+	"passwd":       true,
+	"password":     true,
+	"secret":       true,
+	"t":            true,
+	"token":        true,
+}
+
+const redactedValue = "REDACTED"
+
+var requestDuration = metrics.NewHistogramVec(
+	"rssreader_http_request_duration_seconds",
+	"Duration of HTTP requests by method, route and status.",
+	metrics.DefaultBuckets,
+	"method", "route", "status",
+)
+
 func (handler LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	var uuidStr string
@@ -28,9 +60,10 @@ func (handler LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		uuidStr = "-"
 	}
 
+	rawQuery := redactQuery(r.URL.RawQuery)
 	queried := r.URL.Path
-	if len(r.URL.RawQuery) > 0 {
-		queried += "?" + r.URL.RawQuery
+	if len(rawQuery) > 0 {
+		queried += "?" + rawQuery
 	}
 	if len(r.URL.RawFragment) > 0 {
 		queried += "#" + r.URL.RawFragment
@@ -43,19 +76,19 @@ func (handler LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"scheme":    r.URL.Scheme,
 		"host":      r.Host,
 		"url":       r.URL.Path,
-		"query":     r.URL.RawQuery,
+		"query":     rawQuery,
 		"fragment":  r.URL.RawFragment,
 		"userAgent": r.UserAgent(),
 	}).Trace("Received request")
 
 	startedAt := time.Now()
-	handler.wrappedHandler.ServeHTTP(w, r)
-
-	if r.Response != nil {
-		strStatusCode = strconv.Itoa(r.Response.StatusCode)
-	} else {
-		strStatusCode = ""
+	recorder := &responseRecorder{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
 	}
+	handler.wrappedHandler.ServeHTTP(recorder, r)
+
+	strStatusCode = strconv.Itoa(recorder.statusCode)
 
 	logrus.Info(
 		fmt.Sprintf("[%s]\t%s %s %d",
@@ -67,6 +100,8 @@ func (handler LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	)
 
 	endedAt := time.Now()
+	requestDuration.Observe(endedAt.Sub(startedAt).Seconds(), r.Method, recorder.route, strStatusCode)
+
 	logrus.WithFields(logrus.Fields{
 		"uuid":                uuidStr,
 		"method":              r.Method,
@@ -74,7 +109,7 @@ func (handler LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"scheme":              r.URL.Scheme,
 		"host":                r.URL.Host,
 		"url":                 r.URL.Path,
-		"query":               r.URL.RawQuery,
+		"query":               rawQuery,
 		"fragment":            r.URL.RawFragment,
 		"statusCode":          strStatusCode,
 		"contentLength":       r.ContentLength,
@@ -82,6 +117,57 @@ func (handler LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}).Trace("Request processed")
 }
 
+// Keeps the order and the encoding of the other parameters
+func redactQuery(rawQuery string) string {
+
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	parameters := strings.Split(rawQuery, "&")
+	for index, parameter := range parameters {
+
+		name := parameter
+		if separator := strings.Index(parameter, "="); separator >= 0 {
+			name = parameter[:separator]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if secretParameters[strings.ToLower(name)] {
+			parameters[index] = url.QueryEscape(name) + "=" + redactedValue
+		}
+	}
+	return strings.Join(parameters, "&")
+}
+
+func (recorder *responseRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Streamed answers are flushed as they are written
+func (recorder *responseRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Sets the route label of the request metrics, the request path having an unbounded cardinality
+func routeTemplateMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recorder, ok := w.(*responseRecorder); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					recorder.route = template
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func HttpLogInterceptorFor(handler http.Handler) http.Handler {
 	return LogHandler{
 		wrappedHandler: handler,
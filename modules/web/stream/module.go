@@ -0,0 +1,254 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dademo/rssreader/modules/auth"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/events"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/subscription"
+	"github.com/dademo/rssreader/modules/web"
+)
+
+const (
+	// Events written by other processes are noticed on the next poll
+	pollInterval = 15 * time.Second
+	batchSize    = 500
+	// Delay before EventSource clients reconnect
+	retryMilliseconds = 5000
+
+	eventStreamContentType = "text/event-stream"
+	lastEventIdHeader      = "Last-Event-ID"
+)
+
+var (
+	errEventsDisabled   = errors.New("Events are only available with a database")
+	errStreamingMissing = errors.New("Streaming is not supported by the connection")
+)
+
+// Empty filters match every event
+type filter struct {
+	feedIds    map[appDatabase.PrimaryKey]bool
+	categories map[string]bool
+	types      []string
+}
+
+func init() {
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: web.AppApiPrefix + "/stream", Handler: getStream, Methods: []string{http.MethodGet}, Permission: auth.PermissionFeedsRead},
+	)
+}
+
+// Streams events as they are recorded, from the Last-Event-ID header or lastEventId parameter when given
+func getStream(responseWriter http.ResponseWriter, request *http.Request) {
+
+	if !events.Enabled() {
+		web.AnswerError(errEventsDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	flusher, ok := responseWriter.(http.Flusher)
+	if !ok {
+		web.AnswerError(errStreamingMissing, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	// Multi-valued parameters are read from the form
+	if err := request.ParseForm(); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	eventFilter, err := parseFilter(request)
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	ctx := request.Context()
+	lastId, err := requestLastEventId(ctx, request)
+	if err != nil {
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	// Registered before reading, so that no event is missed in between
+	wake, release := events.Wait()
+	defer release()
+
+	web.DisableClientCache(responseWriter)
+	responseWriter.Header().Set("Content-Type", eventStreamContentType)
+	responseWriter.Header().Set("X-Accel-Buffering", "no")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	_, err = fmt.Fprintf(responseWriter, "retry: %d\n\n", retryMilliseconds)
+	if err != nil {
+		appLog.DebugError(err, "Unable to write answer")
+		return
+	}
+	flusher.Flush()
+
+	principal := auth.PrincipalFromContext(ctx)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+
+		batch, err := events.Since(ctx, lastId, batchSize)
+		if err != nil {
+			appLog.DebugError(err, "Unable to read events")
+			return
+		}
+
+		visible, err := subscription.VisibleFeeds(ctx, principal)
+		if err != nil {
+			appLog.DebugError(err, "Unable to get the user subscriptions")
+			return
+		}
+
+		for _, event := range batch {
+			lastId = event.Id
+			if !eventFilter.matches(event) || !isVisible(event, visible) {
+				continue
+			}
+			err = writeEvent(responseWriter, event)
+			if err != nil {
+				appLog.DebugError(err, "Unable to write answer")
+				return
+			}
+		}
+		flusher.Flush()
+
+		if len(batch) == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-events.Closed():
+			return
+		case <-wake:
+		case <-ticker.C:
+			// Comments keep proxies from closing idle connections
+			_, err = fmt.Fprint(responseWriter, ": keepalive\n\n")
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func parseFilter(request *http.Request) (*filter, error) {
+
+	eventFilter := &filter{
+		feedIds:    map[appDatabase.PrimaryKey]bool{},
+		categories: map[string]bool{},
+		types:      splitValues(request.Form["type"]),
+	}
+
+	for _, value := range splitValues(request.Form["feed"]) {
+		feedId, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad feed id [%s]", value)
+		}
+		eventFilter.feedIds[feedId] = true
+	}
+
+	for _, value := range splitValues(request.Form["category"]) {
+		eventFilter.categories[strings.ToLower(value)] = true
+	}
+
+	return eventFilter, nil
+}
+
+// Types match exactly or by their prefix, item matching item.inserted
+func (eventFilter *filter) matches(event *events.Event) bool {
+
+	if len(eventFilter.feedIds) > 0 && (event.FeedId == nil || !eventFilter.feedIds[*event.FeedId]) {
+		return false
+	}
+
+	if len(eventFilter.categories) > 0 {
+		found := false
+		for _, category := range event.Categories {
+			if eventFilter.categories[strings.ToLower(category)] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(eventFilter.types) > 0 {
+		for _, eventType := range eventFilter.types {
+			if event.Type == eventType || strings.HasPrefix(event.Type, eventType+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// Without a Last-Event-ID, only the events to come are sent
+func requestLastEventId(ctx context.Context, request *http.Request) (appDatabase.PrimaryKey, error) {
+
+	value := request.Header.Get(lastEventIdHeader)
+	if value == "" {
+		value = request.Form.Get("lastEventId")
+	}
+	if value == "" {
+		return events.LastId(ctx)
+	}
+
+	lastId, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Bad event id [%s]", value)
+	}
+	return lastId, nil
+}
+
+func isVisible(event *events.Event, visible map[appDatabase.PrimaryKey]bool) bool {
+	if visible == nil {
+		return true
+	}
+	return event.FeedId != nil && visible[*event.FeedId]
+}
+
+func writeEvent(responseWriter http.ResponseWriter, event *events.Event) error {
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(responseWriter, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+	return err
+}
+
+// Values may be repeated or comma separated
+func splitValues(values []string) []string {
+
+	split := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				split = append(split, part)
+			}
+		}
+	}
+	return split
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/dademo/rssreader/modules/auth"
+	"github.com/dademo/rssreader/modules/config"
+	"github.com/dademo/rssreader/modules/database/dbwebhook"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/web"
+	appWebhook "github.com/dademo/rssreader/modules/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+const deliveriesPageSize = 50
+
+// Configured webhook, secrets and header values left out
+type webhookDescription struct {
+	Name        string   `json:"name"`
+	Url         string   `json:"url"`
+	Signed      bool     `json:"signed"`
+	Events      []string `json:"events"`
+	Feeds       []string `json:"feeds"`
+	Categories  []string `json:"categories"`
+	Match       string   `json:"match"`
+	Templated   bool     `json:"templated"`
+	ContentType string   `json:"contentType"`
+	Headers     []string `json:"headers"`
+	MaxAttempts uint     `json:"maxAttempts"`
+}
+
+var errWebhooksDisabled = errors.New("Webhooks need a database")
+
+func init() {
+	web.RegisterRoutes(
+		web.RegisteredRoute{Pattern: web.AppApiPrefix + "/webhooks", Handler: getWebhooks, Methods: []string{http.MethodGet}, Permission: auth.PermissionAdmin},
+		web.RegisteredRoute{Pattern: web.AppApiPrefix + "/webhooks/deliveries", Handler: getDeliveries, Methods: []string{http.MethodGet}, Permission: auth.PermissionAdmin},
+		web.RegisteredRoute{Pattern: web.AppApiPrefix + "/webhooks/{name}/test", Handler: testWebhook, Methods: []string{http.MethodPost}, Permission: auth.PermissionAdmin},
+	)
+}
+
+func getWebhooks(responseWriter http.ResponseWriter, request *http.Request) {
+
+	hooks := appWebhook.Hooks()
+	descriptions := make([]*webhookDescription, 0, len(hooks))
+	for _, hook := range hooks {
+		descriptions = append(descriptions, describe(hook))
+	}
+
+	web.MarshallWriteJson(responseWriter, descriptions)
+}
+
+// Most recent deliveries first, optionally filtered by webhook and status
+func getDeliveries(responseWriter http.ResponseWriter, request *http.Request) {
+
+	var requestParameters struct {
+		Webhook string `httpParameter:"webhook" httpParameterDefaultValue:""`
+		Status  string `httpParameter:"status" httpParameterDefaultValue:""`
+		Page    uint   `httpParameter:"page" httpParameterDefaultValue:"0"`
+	}
+
+	web.DisableClientCache(responseWriter)
+
+	if appWebhook.GetStore() == nil {
+		web.AnswerError(errWebhooksDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	if err := web.ParseArgs(&requestParameters, request); err != nil {
+		appLog.DebugError(err, "An error occured when fetching parsing values")
+		web.AnswerError(err, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	switch requestParameters.Status {
+	case "", dbwebhook.StatusPending, dbwebhook.StatusDelivered, dbwebhook.StatusFailed:
+	default:
+		web.AnswerError(errors.New("Unknown delivery status"), http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	deliveries, err := appWebhook.GetStore().GetDeliveries(
+		request.Context(),
+		requestParameters.Webhook,
+		requestParameters.Status,
+		deliveriesPageSize,
+		int(requestParameters.Page)*deliveriesPageSize,
+	)
+	if err != nil {
+		appLog.DebugError(err, "Unable to get the webhook deliveries")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	web.MarshallWriteJson(responseWriter, deliveries)
+}
+
+// Sends a sample item right away and answers the delivery with its attempt
+func testWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+
+	web.DisableClientCache(responseWriter)
+
+	if appWebhook.GetStore() == nil {
+		web.AnswerError(errWebhooksDisabled, http.StatusNotFound, responseWriter)
+		return
+	}
+
+	delivery, err := appWebhook.Test(request.Context(), mux.Vars(request)["name"])
+	if err == appWebhook.ErrUnknownWebhook {
+		web.AnswerError(err, http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		appLog.DebugError(err, "Unable to test the webhook")
+		web.AnswerError(err, http.StatusInternalServerError, responseWriter)
+		return
+	}
+
+	web.MarshallWriteJson(responseWriter, delivery)
+}
+
+func describe(hook *config.Webhook) *webhookDescription {
+
+	description := &webhookDescription{
+		Name:        hook.Name,
+		Url:         hook.Url,
+		Signed:      hook.Secret != "",
+		Events:      hook.Events,
+		Feeds:       hook.Feeds,
+		Categories:  hook.Categories,
+		Match:       hook.Match,
+		Templated:   hook.Template != "",
+		ContentType: hook.ContentType,
+		Headers:     make([]string, 0, len(hook.Headers)),
+		MaxAttempts: hook.MaxAttempts,
+	}
+	for name := range hook.Headers {
+		description.Headers = append(description.Headers, name)
+	}
+	sort.Strings(description.Headers)
+	return description
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbwebhook"
+	appLog "github.com/dademo/rssreader/modules/log"
+	"github.com/dademo/rssreader/modules/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventTest = "test"
+
+	EventHeader     = "X-Rssreader-Event"
+	DeliveryHeader  = "X-Rssreader-Delivery"
+	SignatureHeader = "X-Rssreader-Signature"
+
+	deliveryBatchSize = 100
+	// Doubled on each failure
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = time.Hour
+	purgeInterval   = time.Hour
+	// Answers are only read to reuse connections
+	maxAnswerBytes = 64 * 1024
+)
+
+var deliveriesCounter = metrics.NewCounterVec(
+	"rssreader_webhook_deliveries_total",
+	"Webhook delivery attempts by webhook and result, delivered, retried or failed.",
+	"webhook", "result",
+)
+
+// Sends the pending deliveries whose attempt is due, called by the scheduler
+func DeliverDue(ctx context.Context) error {
+
+	if !Enabled() {
+		return nil
+	}
+
+	deliveries, err := hookStore.DueDeliveries(ctx, time.Now(), deliveryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err = attempt(ctx, delivery)
+		if err != nil {
+			return err
+		}
+	}
+
+	purgeLog(ctx)
+	return nil
+}
+
+// Sends a sample item to the webhook right away, test deliveries are not retried
+func Test(ctx context.Context, name string) (*dbwebhook.Delivery, error) {
+
+	configured := hookByName(name)
+	if configured == nil || hookStore == nil {
+		return nil, ErrUnknownWebhook
+	}
+
+	now := time.Now()
+	feed := &dbfeed.Feed{
+		Title:      "Test feed",
+		Link:       "https://example.org/",
+		ConfigName: "test",
+	}
+	item := &dbfeed.FeedItem{
+		Title:       "Test item",
+		Link:        "https://example.org/test",
+		GUID:        "rssreader-webhook-test",
+		Description: "Sent to check the webhook configuration",
+		Published:   &now,
+		Categories:  []*dbfeed.FeedCategory{},
+		Tags:        []string{},
+		Feed:        feed,
+	}
+
+	// Kept away from the scheduled deliveries while sent here
+	nextAttempt := now.Add(firstRetryDelay)
+	delivery, err := queue(ctx, configured, EventTest, feed, item, nil, &nextAttempt)
+	if err != nil {
+		return nil, err
+	}
+
+	sent, err := attempt(ctx, delivery)
+	if err != nil {
+		return nil, err
+	}
+	delivery.AttemptLog = []*dbwebhook.Attempt{sent}
+	return delivery, nil
+}
+
+// Sends the delivery once, saving its new state
+func attempt(ctx context.Context, delivery *dbwebhook.Delivery) (*dbwebhook.Attempt, error) {
+
+	configured := hookByName(delivery.Webhook)
+
+	var sent *dbwebhook.Attempt
+	if configured == nil {
+		// Removed from the configuration since queued
+		now := time.Now()
+		sent = &dbwebhook.Attempt{DeliveryId: delivery.Id, Attempted: &now, Error: ErrUnknownWebhook.Error()}
+	} else {
+		sent = send(ctx, configured, delivery)
+	}
+	delivery.Attempts++
+
+	result := ""
+	switch {
+	case sent.Error == "":
+		delivery.Status = dbwebhook.StatusDelivered
+		delivery.NextAttempt = nil
+		result = "delivered"
+	case delivery.Attempts >= configuredMaxAttempts(configured, delivery):
+		delivery.Status = dbwebhook.StatusFailed
+		delivery.NextAttempt = nil
+		result = "failed"
+		log.Warn(fmt.Sprintf("Giving up delivery (%d) to webhook [%s]: %s", delivery.Id, delivery.Webhook, sent.Error))
+	default:
+		next := time.Now().Add(retryDelay(delivery.Attempts))
+		delivery.NextAttempt = &next
+		result = "retried"
+	}
+	deliveriesCounter.Inc(delivery.Webhook, result)
+
+	err := hookStore.RecordAttempt(ctx, delivery, sent)
+	if err != nil {
+		appLog.DebugError(err, fmt.Sprintf("Unable to save the delivery (%d) state", delivery.Id))
+		return nil, err
+	}
+	return sent, nil
+}
+
+func send(ctx context.Context, configured *hook, delivery *dbwebhook.Delivery) *dbwebhook.Attempt {
+
+	start := time.Now()
+	sent := &dbwebhook.Attempt{DeliveryId: delivery.Id, Attempted: &start}
+	defer func() {
+		sent.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, configured.config.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		sent.Error = err.Error()
+		return sent
+	}
+
+	for name, value := range configured.config.Headers {
+		request.Header.Set(name, value)
+	}
+	request.Header.Set("Content-Type", delivery.ContentType)
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.Id, 10))
+	if configured.config.Secret != "" {
+		request.Header.Set(SignatureHeader, "sha256="+Sign(configured.config.Secret, delivery.Payload))
+	}
+
+	client := http.Client{Timeout: configured.timeout()}
+	response, err := client.Do(request)
+	if err != nil {
+		sent.Error = err.Error()
+		return sent
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(ioutil.Discard, io.LimitReader(response.Body, maxAnswerBytes))
+	if err != nil {
+		appLog.DebugError(err, "Unable to read the webhook answer")
+	}
+
+	sent.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		sent.Error = fmt.Sprintf("Unexpected status %d", response.StatusCode)
+	}
+	return sent
+}
+
+// Hex encoded HMAC-SHA256 of the payload, sent prefixed by sha256=
+func Sign(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func configuredMaxAttempts(configured *hook, delivery *dbwebhook.Delivery) uint {
+	if configured == nil || delivery.Event == EventTest {
+		return 1
+	}
+	return configured.maxAttempts()
+}
+
+func retryDelay(attempts uint) time.Duration {
+
+	delay := firstRetryDelay
+	for i := uint(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Runs at most once per purge interval
+func purgeLog(ctx context.Context) {
+
+	if webhooksConfig.LogRetentionDays == 0 {
+		return
+	}
+
+	purgeLock.Lock()
+	defer purgeLock.Unlock()
+
+	now := time.Now()
+	if now.Sub(lastPurge) < purgeInterval {
+		return
+	}
+	lastPurge = now
+
+	deleted, err := hookStore.DeleteEnded(ctx, now.AddDate(0, 0, -int(webhooksConfig.LogRetentionDays)))
+	if err != nil {
+		appLog.DebugError(err, "Unable to purge the webhook deliveries")
+		return
+	}
+	log.Debug(fmt.Sprintf("%d webhook deliveries purged", deleted))
+}
@@ -0,0 +1,338 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dademo/rssreader/modules/config"
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+	"github.com/dademo/rssreader/modules/database/dbwebhook"
+	appLog "github.com/dademo/rssreader/modules/log"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultContentType    = "application/json"
+	defaultMaxAttempts    = 5
+	defaultTimeoutSeconds = 10
+)
+
+var defaultEvents = []string{dbfeed.ChangeItemInserted, dbfeed.ChangeItemUpdated}
+
+// Configured webhook, with its filters compiled
+type hook struct {
+	config     *config.Webhook
+	events     map[string]bool
+	feeds      map[string]bool
+	categories map[string]bool
+	match      *regexp.Regexp
+	template   *template.Template
+}
+
+var (
+	webhooksConfig *config.WebhooksConfig
+	hooks          []*hook
+	hookStore      *dbwebhook.Store
+
+	lastPurge time.Time
+	purgeLock sync.Mutex
+)
+
+var ErrUnknownWebhook = errors.New("Unknown webhook")
+
+func init() {
+	dbfeed.RegisterChangeWriter(writeDeliveries)
+}
+
+// The delivery queue being persisted, webhooks are disabled without a database
+func Configure(config *config.WebhooksConfig, store *dbwebhook.Store) error {
+
+	configured, err := newHooks(config)
+	if err != nil {
+		return err
+	}
+
+	webhooksConfig = config
+	hooks = configured
+	hookStore = store
+
+	if store == nil && len(hooks) > 0 {
+		log.Warn("Webhooks need a database, they are disabled")
+	}
+	return nil
+}
+
+// Checks the webhooks, nothing being configured
+func Validate(config *config.WebhooksConfig) error {
+	_, err := newHooks(config)
+	return err
+}
+
+func Enabled() bool {
+	return hookStore != nil && len(hooks) > 0
+}
+
+func GetStore() *dbwebhook.Store {
+	return hookStore
+}
+
+// Configured webhooks, in their configuration order
+func Hooks() []*config.Webhook {
+
+	configs := make([]*config.Webhook, 0, len(hooks))
+	for _, configured := range hooks {
+		configs = append(configs, configured.config)
+	}
+	return configs
+}
+
+func newHooks(config *config.WebhooksConfig) ([]*hook, error) {
+
+	configured := make([]*hook, 0, len(config.Hooks))
+
+	names := map[string]bool{}
+	for _, hookConfig := range config.Hooks {
+
+		if names[hookConfig.Name] {
+			return nil, fmt.Errorf("Webhook [%s] is defined twice", hookConfig.Name)
+		}
+		names[hookConfig.Name] = true
+
+		newHook, err := newHook(hookConfig)
+		if err != nil {
+			appLog.DebugError(err, fmt.Sprintf("Invalid webhook [%s]", hookConfig.Name))
+			return nil, err
+		}
+		configured = append(configured, newHook)
+	}
+	return configured, nil
+}
+
+func newHook(hookConfig *config.Webhook) (*hook, error) {
+
+	if hookConfig.Name == "" {
+		return nil, errors.New("Webhooks must be named")
+	}
+
+	parsed, err := url.Parse(hookConfig.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("Invalid webhook URL [%s]", hookConfig.Url)
+	}
+
+	configured := &hook{
+		config:     hookConfig,
+		events:     map[string]bool{},
+		feeds:      map[string]bool{},
+		categories: map[string]bool{},
+	}
+
+	events := hookConfig.Events
+	if len(events) == 0 {
+		events = defaultEvents
+	}
+	for _, event := range events {
+		if event != dbfeed.ChangeItemInserted && event != dbfeed.ChangeItemUpdated {
+			return nil, fmt.Errorf("Unsupported webhook event [%s]", event)
+		}
+		configured.events[event] = true
+	}
+
+	for _, feed := range hookConfig.Feeds {
+		configured.feeds[feed] = true
+	}
+	for _, category := range hookConfig.Categories {
+		configured.categories[strings.ToLower(category)] = true
+	}
+
+	if hookConfig.Match != "" {
+		configured.match, err = regexp.Compile(hookConfig.Match)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid webhook match [%s]: %s", hookConfig.Match, err)
+		}
+	}
+
+	if hookConfig.Template != "" {
+		configured.template, err = template.New(hookConfig.Name).Funcs(templateFuncs).Parse(hookConfig.Template)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid webhook template: %s", err)
+		}
+	}
+
+	return configured, nil
+}
+
+func hookByName(name string) *hook {
+	for _, configured := range hooks {
+		if configured.config.Name == name {
+			return configured
+		}
+	}
+	return nil
+}
+
+// Feeds are designated by their configuration name or their title
+func (configured *hook) matches(change *dbfeed.Change) bool {
+
+	if !configured.events[change.Type] {
+		return false
+	}
+
+	if len(configured.feeds) > 0 && !configured.feeds[change.Feed.ConfigName] && !configured.feeds[change.Feed.Title] {
+		return false
+	}
+
+	if len(configured.categories) > 0 && !configured.matchesCategory(change) {
+		return false
+	}
+
+	if configured.match != nil {
+		item := change.Item
+		return configured.match.MatchString(item.Title) ||
+			configured.match.MatchString(item.Description) ||
+			configured.match.MatchString(item.Content)
+	}
+
+	return true
+}
+
+func (configured *hook) matchesCategory(change *dbfeed.Change) bool {
+
+	categories := append(append([]*dbfeed.FeedCategory{}, change.Feed.Categories...), change.Item.Categories...)
+	for _, category := range categories {
+		if configured.categories[strings.ToLower(category.Category)] {
+			return true
+		}
+	}
+	for _, tag := range change.Item.Tags {
+		if configured.categories[strings.ToLower(tag)] {
+			return true
+		}
+	}
+	return false
+}
+
+func (configured *hook) contentType() string {
+	if configured.config.ContentType != "" {
+		return configured.config.ContentType
+	}
+	return defaultContentType
+}
+
+func (configured *hook) maxAttempts() uint {
+	if configured.config.MaxAttempts > 0 {
+		return configured.config.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+func (configured *hook) timeout() time.Duration {
+	if configured.config.TimeoutSeconds > 0 {
+		return time.Duration(configured.config.TimeoutSeconds) * time.Second
+	}
+	return defaultTimeoutSeconds * time.Second
+}
+
+// Queues a delivery for every matching webhook in the transaction saving the changes, so none is lost.
+// The items of a feed saved for the first time are not reported, its whole history being new.
+func writeDeliveries(ctx context.Context, tx *sql.Tx, changes []*dbfeed.Change) error {
+
+	if !Enabled() {
+		return nil
+	}
+
+	createdFeeds := map[appDatabase.PrimaryKey]bool{}
+	for _, change := range changes {
+		if change.Type == dbfeed.ChangeFeedCreated {
+			createdFeeds[change.Feed.Id] = true
+		}
+	}
+
+	skipped := map[string]int{}
+	for _, change := range changes {
+		if change.Item == nil {
+			continue
+		}
+		if change.Type == dbfeed.ChangeItemInserted && createdFeeds[change.Feed.Id] {
+			skipped[change.Feed.Title]++
+			continue
+		}
+		for _, configured := range hooks {
+			if !configured.matches(change) {
+				continue
+			}
+			delivery, err := newDelivery(configured, change.Type, change.Feed, change.Item, nil, nil)
+			if err != nil {
+				// A broken template must not prevent the feed from being saved
+				appLog.DebugError(err, fmt.Sprintf("Unable to render a delivery for webhook [%s]", configured.config.Name))
+				continue
+			}
+			err = hookStore.CreateDeliveryTx(ctx, tx, delivery)
+			if err != nil {
+				appLog.DebugError(err, fmt.Sprintf("Unable to queue a delivery for webhook [%s]", configured.config.Name))
+				return err
+			}
+		}
+	}
+
+	for title, count := range skipped {
+		log.Info(fmt.Sprintf("Feed [%s] is saved for the first time, its %d items are not sent to webhooks", title, count))
+	}
+	return nil
+}
+
+// Queues the event for the named webhook whatever its filters, data being added to the payload
+func Notify(ctx context.Context, name string, event string, feed *dbfeed.Feed, item *dbfeed.FeedItem, data interface{}) error {
+
+	configured := hookByName(name)
+	if configured == nil || hookStore == nil {
+		return ErrUnknownWebhook
+	}
+
+	_, err := queue(ctx, configured, event, feed, item, data, nil)
+	return err
+}
+
+// Attempted as soon as possible unless nextAttempt is given
+func queue(ctx context.Context, configured *hook, event string, feed *dbfeed.Feed, item *dbfeed.FeedItem, data interface{}, nextAttempt *time.Time) (*dbwebhook.Delivery, error) {
+
+	delivery, err := newDelivery(configured, event, feed, item, data, nextAttempt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hookStore.CreateDelivery(ctx, delivery)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func newDelivery(configured *hook, event string, feed *dbfeed.Feed, item *dbfeed.FeedItem, data interface{}, nextAttempt *time.Time) (*dbwebhook.Delivery, error) {
+
+	payload, err := render(configured, event, feed, item, data)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &dbwebhook.Delivery{
+		Webhook:     configured.config.Name,
+		Event:       event,
+		ContentType: configured.contentType(),
+		Payload:     payload,
+		NextAttempt: nextAttempt,
+	}
+	if item != nil && item.Id != 0 {
+		delivery.ItemId = &item.Id
+	}
+	return delivery, nil
+}
@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+	"time"
+
+	appDatabase "github.com/dademo/rssreader/modules/database"
+	"github.com/dademo/rssreader/modules/database/dbfeed"
+)
+
+// Values given to the webhook templates
+type TemplateData struct {
+	Event   string
+	Webhook string
+	Feed    *dbfeed.Feed
+	Item    *dbfeed.FeedItem
+	// Given along with events not coming from feed changes
+	Data interface{}
+}
+
+// Body sent when no template is configured
+type payload struct {
+	Event   string       `json:"event"`
+	Webhook string       `json:"webhook"`
+	Feed    *payloadFeed `json:"feed"`
+	Item    *payloadItem `json:"item"`
+	Data    interface{}  `json:"data,omitempty"`
+}
+
+type payloadFeed struct {
+	Id         appDatabase.PrimaryKey `json:"id"`
+	Title      string                 `json:"title"`
+	Link       string                 `json:"link"`
+	ConfigName string                 `json:"configName"`
+}
+
+type payloadItem struct {
+	Id          appDatabase.PrimaryKey `json:"id"`
+	GUID        string                 `json:"guid"`
+	Title       string                 `json:"title"`
+	Link        string                 `json:"link"`
+	Description string                 `json:"description"`
+	Content     string                 `json:"content"`
+	Author      string                 `json:"author,omitempty"`
+	Published   *time.Time             `json:"published"`
+	Updated     *time.Time             `json:"updated"`
+	Categories  []string               `json:"categories"`
+	Tags        []string               `json:"tags"`
+}
+
+// The json function quotes values, so that templates can build JSON documents
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		marshalled, err := json.Marshal(value)
+		return string(marshalled), err
+	},
+}
+
+func render(configured *hook, event string, feed *dbfeed.Feed, item *dbfeed.FeedItem, data interface{}) (string, error) {
+
+	if configured.template != nil {
+
+		var buffer bytes.Buffer
+		err := configured.template.Execute(&buffer, &TemplateData{
+			Event:   event,
+			Webhook: configured.config.Name,
+			Feed:    feed,
+			Item:    item,
+			Data:    data,
+		})
+		if err != nil {
+			return "", err
+		}
+		return buffer.String(), nil
+	}
+
+	body := &payload{
+		Event:   event,
+		Webhook: configured.config.Name,
+		Data:    data,
+		Feed: &payloadFeed{
+			Id:         feed.Id,
+			Title:      feed.Title,
+			Link:       feed.Link,
+			ConfigName: feed.ConfigName,
+		},
+		Item: &payloadItem{
+			Id:          item.Id,
+			GUID:        item.GUID,
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Content:     item.Content,
+			Published:   item.Published,
+			Updated:     item.Updated,
+			Categories:  make([]string, 0, len(item.Categories)),
+			Tags:        item.Tags,
+		},
+	}
+	if item.Author != nil {
+		body.Item.Author = item.Author.Name
+	}
+	for _, category := range item.Categories {
+		body.Item.Categories = append(body.Item.Categories, category.Category)
+	}
+	if body.Item.Tags == nil {
+		body.Item.Tags = []string{}
+	}
+
+	marshalled, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	return string(marshalled), nil
+}